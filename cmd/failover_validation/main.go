@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
 )
 
 func main() {
@@ -11,8 +14,31 @@ func main() {
 	totalNodes := flag.Int("total-nodes", 0, "The total number of nodes registered")
 	flag.Parse()
 
-	if *visibleNodes == 0 || *visibleNodes < (*totalNodes/2+1) {
-		fmt.Printf("Unable to perform failover as quorum can not be met. Total nodes: %d, Visible nodes: %d\n", *totalNodes, *visibleNodes)
+	node, err := flypg.NewNode()
+	if err != nil {
+		// Fall back to the unweighted vote reported by repmgr.
+		fmt.Printf("Unable to resolve node, falling back to visible node count: %s\n", err)
+
+		if *visibleNodes == 0 || *visibleNodes < (*totalNodes/2+1) {
+			fmt.Printf("Unable to perform failover as quorum can not be met. Total nodes: %d, Visible nodes: %d\n", *totalNodes, *visibleNodes)
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
+	validation := flypg.ValidateFailover(context.Background(), node, *visibleNodes, *totalNodes)
+
+	for _, check := range validation.Checks {
+		status := "passed"
+		if !check.Passed {
+			status = "failed"
+		}
+		fmt.Printf("[%s] %s: %s\n", status, check.Name, check.Reason)
+	}
+
+	if !validation.Approved {
+		fmt.Printf("Unable to perform failover, %s has been rejected as a promotion candidate\n", validation.Candidate)
 		os.Exit(1)
 	}
 
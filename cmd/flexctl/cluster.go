@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/spf13/cobra"
+)
+
+func newClusterCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Inspect the cluster",
+	}
+
+	cmd.AddCommand(newClusterStatus())
+
+	return cmd
+}
+
+type clusterStatusResult struct {
+	Result flypg.ClusterStatus `json:"result"`
+	Error  string              `json:"error,omitempty"`
+}
+
+func newClusterStatus() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Shows the status of every cluster member",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		url, err := getAPIURL()
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.Get(fmt.Sprintf("%s/commands/admin/cluster/status", url))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // nolint:errcheck
+
+		var rv clusterStatusResult
+		if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error fetching cluster status: %s", rv.Error)
+		}
+
+		isJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("failed to get json flag: %v", err)
+		}
+
+		if isJSON {
+			e := json.NewEncoder(os.Stdout)
+			e.SetIndent("", "  ")
+			return e.Encode(rv.Result)
+		}
+
+		table := tablewriter.NewTable(os.Stdout,
+			tablewriter.WithRowAlignment(tw.AlignLeft),
+			tablewriter.WithHeaderAlignment(tw.AlignLeft),
+			tablewriter.WithRowAutoWrap(tw.WrapNone),
+		)
+		table.Header("Member", "Role", "Region", "Lag (bytes)", "Lag (s)", "Timeline", "Slot", "Locks", "Repmgrd", "Version")
+
+		for _, m := range rv.Result.Members {
+			name := m.Name
+			if name == "" {
+				name = m.Hostname
+			}
+
+			role := m.Role
+			if !m.Active {
+				role += " (inactive)"
+			}
+
+			if err := table.Append([]string{
+				name,
+				role,
+				m.Region,
+				optionalInt(m.LagBytes),
+				optionalFloat(m.LagSeconds),
+				valueOrDash(m.Timeline != 0, strconv.Itoa(m.Timeline)),
+				slotSummary(m.Slot),
+				locksSummary(m.State),
+				repmgrdSummary(m.State),
+				valueOrDash(m.PGVersion != "", m.PGVersion),
+			}); err != nil {
+				return fmt.Errorf("failed to append row: %v", err)
+			}
+		}
+
+		if err := table.Render(); err != nil {
+			return fmt.Errorf("failed to render table: %v", err)
+		}
+
+		for _, e := range rv.Result.Errors {
+			fmt.Printf("Warning: %s\n", e)
+		}
+
+		for _, m := range rv.Result.Members {
+			for _, e := range m.Errors {
+				fmt.Printf("Warning (%s): %s\n", m.Hostname, e)
+			}
+		}
+
+		return nil
+	}
+
+	cmd.Flags().BoolP("json", "", false, "Output in JSON format")
+
+	return cmd
+}
+
+func valueOrDash(ok bool, v string) string {
+	if !ok {
+		return "-"
+	}
+
+	return v
+}
+
+func optionalInt(v *int64) string {
+	if v == nil {
+		return "-"
+	}
+
+	return strconv.FormatInt(*v, 10)
+}
+
+func optionalFloat(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+
+	return strconv.FormatFloat(*v, 'f', 1, 64)
+}
+
+func slotSummary(slot *flypg.SlotStatus) string {
+	if slot == nil {
+		return "-"
+	}
+
+	state := "inactive"
+	if slot.Active {
+		state = "active"
+	}
+
+	return fmt.Sprintf("%s (%s)", state, slot.WalStatus)
+}
+
+func locksSummary(state *flypg.MemberState) string {
+	if state == nil {
+		return "unknown"
+	}
+
+	var locks []string
+	if state.ZombieLock {
+		locks = append(locks, "zombie")
+	}
+
+	if state.ReadonlyLock {
+		locks = append(locks, "readonly")
+	}
+
+	if len(locks) == 0 {
+		return "none"
+	}
+
+	return strings.Join(locks, ", ")
+}
+
+func repmgrdSummary(state *flypg.MemberState) string {
+	switch {
+	case state == nil:
+		return "unknown"
+	case state.RepmgrdRunning:
+		return "running"
+	default:
+		return "stopped"
+	}
+}
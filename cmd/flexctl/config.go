@@ -0,0 +1,376 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/api"
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/spf13/cobra"
+)
+
+var configComponents = []string{"postgres", "barman", "flypg"}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage cluster configuration",
+	}
+
+	cmd.AddCommand(newConfigHistory(), newConfigRollback(), newConfigApply(), newConfigApplyStatus())
+
+	return cmd
+}
+
+type configHistoryResult struct {
+	Result []flypg.ConfigRevision `json:"result"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+func newConfigHistory() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:       "history <postgres|barman|flypg>",
+		Short:     "Lists the revision history of a configuration",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: configComponents,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		url, err := getAPIURL()
+		if err != nil {
+			return err
+		}
+
+		url = fmt.Sprintf("%s/commands/admin/settings/history/%s", url, args[0])
+		resp, err := http.Get(url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // nolint:errcheck
+
+		var rv configHistoryResult
+		if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error listing configuration history: %s", rv.Error)
+		}
+
+		isJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("failed to get json flag: %v", err)
+		}
+
+		if isJSON {
+			e := json.NewEncoder(os.Stdout)
+			e.SetIndent("", "  ")
+			return e.Encode(rv.Result)
+		}
+
+		if len(rv.Result) == 0 {
+			fmt.Println("No revisions found")
+			return nil
+		}
+
+		table := tablewriter.NewTable(os.Stdout,
+			tablewriter.WithRowAlignment(tw.AlignLeft),
+			tablewriter.WithHeaderAlignment(tw.AlignLeft),
+			tablewriter.WithRowAutoWrap(tw.WrapNone),
+		)
+		table.Header("Revision", "Author", "Created at", "Changes")
+
+		for _, rev := range rv.Result {
+			var changes []string
+			for _, change := range rev.Changes {
+				changes = append(changes, fmt.Sprintf("%s: %v -> %v", change.Key, valueOrNone(change.Previous), valueOrNone(change.Current)))
+			}
+
+			if rev.RollbackOf != 0 {
+				changes = append([]string{fmt.Sprintf("rollback of revision %d", rev.RollbackOf)}, changes...)
+			}
+
+			if err := table.Append([]string{
+				strconv.Itoa(rev.Revision),
+				rev.Author,
+				rev.CreatedAt.Format(time.RFC3339),
+				strings.Join(changes, ", "),
+			}); err != nil {
+				return fmt.Errorf("failed to append row: %v", err)
+			}
+		}
+
+		if err := table.Render(); err != nil {
+			return fmt.Errorf("failed to render table: %v", err)
+		}
+
+		return nil
+	}
+
+	cmd.Flags().BoolP("json", "", false, "Output in JSON format")
+
+	return cmd
+}
+
+func newConfigRollback() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollback <postgres|barman|flypg> <revision>",
+		Short: "Rolls a configuration back to a previous revision",
+		Args:  cobra.ExactArgs(2),
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if _, err := strconv.Atoi(args[1]); err != nil {
+			return fmt.Errorf("invalid revision %q", args[1])
+		}
+
+		url, err := getAPIURL()
+		if err != nil {
+			return err
+		}
+
+		url = fmt.Sprintf("%s/commands/admin/settings/rollback/%s/%s", url, args[0], args[1])
+		req, err := http.NewRequestWithContext(cmd.Context(), http.MethodPost, url, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(api.AuthorHeader, cliAuthor())
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // nolint:errcheck
+
+		var rv configUpdateResult
+		if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error rolling back configuration: %s", rv.Error)
+		}
+
+		if rv.Result.Message != "" {
+			fmt.Println(rv.Result.Message)
+		}
+
+		if rv.Result.RestartRequired {
+			appName, err := getAppName()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("A restart is required for these changes to take effect. Run `fly pg restart -a %s` to restart.\n", appName)
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+type configApplyResult struct {
+	Result flypg.ConfigApplyOperation `json:"result"`
+	Error  string                     `json:"error,omitempty"`
+}
+
+func newConfigApply() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Applies the stored configuration across the cluster, performing rolling restarts as needed",
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		url, err := getAPIURL()
+		if err != nil {
+			return err
+		}
+
+		url = fmt.Sprintf("%s/commands/admin/settings/apply/cluster", url)
+		req, err := http.NewRequestWithContext(cmd.Context(), http.MethodPost, url, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(api.AuthorHeader, cliAuthor())
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // nolint:errcheck
+
+		var rv configApplyResult
+		if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error applying configuration: %s", rv.Error)
+		}
+
+		fmt.Printf("Started config apply %s\n", rv.Result.ID)
+
+		wait, err := cmd.Flags().GetBool("wait")
+		if err != nil {
+			return fmt.Errorf("failed to get wait flag: %v", err)
+		}
+
+		if !wait {
+			fmt.Println("Run `flexctl config apply-status` to follow its progress.")
+			return nil
+		}
+
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+
+		reported := 0
+		for range ticker.C {
+			op, err := fetchConfigApplyStatus()
+			if err != nil {
+				return err
+			}
+
+			for _, step := range op.Steps[reported:] {
+				if step.Status == flypg.ConfigApplyRunning {
+					break
+				}
+				fmt.Printf("  %s %s: %s\n", step.Action, step.Member, step.Status)
+				reported++
+			}
+
+			switch op.Status {
+			case flypg.ConfigApplyCompleted:
+				fmt.Println("Config apply completed")
+				return nil
+			case flypg.ConfigApplyFailed:
+				return fmt.Errorf("config apply failed: %s", op.Error)
+			}
+		}
+
+		return nil
+	}
+
+	cmd.Flags().BoolP("wait", "w", false, "Wait for the config apply to complete")
+
+	return cmd
+}
+
+func newConfigApplyStatus() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply-status",
+		Short: "Shows the progress of the most recent cluster-wide config apply",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		op, err := fetchConfigApplyStatus()
+		if err != nil {
+			return err
+		}
+
+		isJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("failed to get json flag: %v", err)
+		}
+
+		if isJSON {
+			e := json.NewEncoder(os.Stdout)
+			e.SetIndent("", "  ")
+			return e.Encode(op)
+		}
+
+		fmt.Printf("ID: %s\n", op.ID)
+		fmt.Printf("Author: %s\n", op.Author)
+		fmt.Printf("Status: %s\n", op.Status)
+		fmt.Printf("Started at: %s\n", op.StartedAt.Format(time.RFC3339))
+		if len(op.PendingRestart) > 0 {
+			fmt.Printf("Pending restart: %s\n", strings.Join(op.PendingRestart, ", "))
+		}
+		if op.Error != "" {
+			fmt.Printf("Error: %s\n", op.Error)
+		}
+		fmt.Println()
+
+		table := tablewriter.NewTable(os.Stdout,
+			tablewriter.WithRowAlignment(tw.AlignLeft),
+			tablewriter.WithHeaderAlignment(tw.AlignLeft),
+		)
+		table.Header("Member", "Action", "Status", "Started at", "Error")
+
+		for _, step := range op.Steps {
+			if err := table.Append([]string{
+				step.Member,
+				step.Action,
+				step.Status,
+				step.StartedAt.Format(time.RFC3339),
+				step.Error,
+			}); err != nil {
+				return fmt.Errorf("failed to append row: %v", err)
+			}
+		}
+
+		if err := table.Render(); err != nil {
+			return fmt.Errorf("failed to render table: %v", err)
+		}
+
+		return nil
+	}
+
+	cmd.Flags().BoolP("json", "", false, "Output in JSON format")
+
+	return cmd
+}
+
+func fetchConfigApplyStatus() (*flypg.ConfigApplyOperation, error) {
+	url, err := getAPIURL()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/commands/admin/settings/apply/status", url))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+
+	var rv configApplyResult
+	if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+		return nil, err
+	}
+
+	if rv.Error != "" {
+		return nil, fmt.Errorf("error fetching config apply status: %s", rv.Error)
+	}
+
+	return &rv.Result, nil
+}
+
+// cliAuthor identifies the operator running flexctl.
+func cliAuthor() string {
+	user := os.Getenv("USER")
+	if user == "" {
+		user = "flexctl"
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		return user
+	}
+
+	return fmt.Sprintf("%s@%s", user, host)
+}
+
+func valueOrNone(v any) any {
+	if v == nil {
+		return "(none)"
+	}
+
+	return v
+}
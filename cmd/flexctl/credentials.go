@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/fly-apps/postgres-flex/internal/api"
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/spf13/cobra"
+)
+
+const generatedPasswordLength = 32
+
+type credentialRotationResult struct {
+	Result flypg.CredentialRotationResult `json:"result"`
+	Error  string                         `json:"error,omitempty"`
+}
+
+func newCredentialsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "credentials",
+		Short: "Manage the credentials of internal users",
+	}
+
+	cmd.AddCommand(newCredentialsRotate())
+
+	return cmd
+}
+
+func newCredentialsRotate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rotate <user>",
+		Short: "Rotates the password of an internal user without downtime",
+		Long: "Rotates the password of an internal user (flypgadmin, postgres or repmgr) without downtime.\n\n" +
+			"The role is updated on the primary and every member picks up the new password. " +
+			"Update the user's secret with the new password afterwards to complete the rotation.",
+		Args: cobra.ExactArgs(1),
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			return fmt.Errorf("failed to get password flag: %v", err)
+		}
+
+		yes, err := cmd.Flags().GetBool("yes")
+		if err != nil {
+			return fmt.Errorf("failed to get yes flag: %v", err)
+		}
+
+		if !yes && !confirm(fmt.Sprintf("Rotate the password of %s across the cluster?", args[0])) {
+			fmt.Println("Aborted")
+			return nil
+		}
+
+		generated := password == ""
+		if generated {
+			if password, err = generatePassword(); err != nil {
+				return err
+			}
+		}
+
+		url, err := getAPIURL()
+		if err != nil {
+			return err
+		}
+
+		body, err := json.Marshal(map[string]string{
+			"username": args[0],
+			"password": password,
+		})
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(cmd.Context(), http.MethodPost,
+			fmt.Sprintf("%s/commands/admin/credentials/rotate", url), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(api.AuthorHeader, cliAuthor())
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // nolint:errcheck
+
+		var rv credentialRotationResult
+		if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error rotating credentials: %s", rv.Error)
+		}
+
+		fmt.Printf("Password for %s rotated on the primary\n", rv.Result.Username)
+
+		failed := 0
+		for _, m := range rv.Result.Members {
+			if m.Error != "" {
+				failed++
+				fmt.Printf("  %s: %s\n", m.Member, m.Error)
+				continue
+			}
+			fmt.Printf("  %s: synced\n", m.Member)
+		}
+
+		if failed > 0 {
+			fmt.Printf("%d member(s) failed to sync. They pick up the new password once they are restarted\n", failed)
+		}
+
+		secret := fmt.Sprintf("%s=%s", rv.Result.Secret, password)
+		if !generated {
+			secret = fmt.Sprintf("%s=<password>", rv.Result.Secret)
+		}
+
+		app, err := getAppName()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Complete the rotation by updating the secret:")
+		fmt.Printf("  fly secrets set %s --app %s\n", secret, app)
+		fmt.Println("Until then, the new password is kept in the state store, encrypted with the current secret")
+
+		return nil
+	}
+
+	cmd.Flags().StringP("password", "p", "", "New password. A random password is generated when omitted")
+	cmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+
+	return cmd
+}
+
+func generatePassword() (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	password := make([]byte, generatedPasswordLength)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %s", err)
+		}
+		password[i] = alphabet[n.Int64()]
+	}
+
+	return string(password), nil
+}
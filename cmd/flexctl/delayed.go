@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/api"
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/spf13/cobra"
+)
+
+type delayedStatusResult struct {
+	Result flypg.DelayedStatus `json:"result"`
+	Error  string              `json:"error,omitempty"`
+}
+
+type detachedCopyResult struct {
+	Result flypg.DetachedCopy `json:"result"`
+	Error  string             `json:"error,omitempty"`
+}
+
+func newNodeDelayedCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delayed",
+		Short: "Manage a delayed replica",
+		Long: "Manage a delayed replica.\n\n" +
+			"Delayed replicas replay WAL behind the primary, so data dropped by mistake can be recovered from a detached copy.",
+	}
+
+	cmd.AddCommand(
+		newDelayedStatus(),
+		newDelayedReplayToggle("pause", "Pauses WAL replay", true),
+		newDelayedReplayToggle("resume", "Resumes WAL replay", false),
+		newDelayedDetach(),
+		newDelayedDiscard(),
+	)
+
+	return cmd
+}
+
+func newDelayedStatus() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Shows how far replay has progressed",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		url, err := memberAPIURL(cmd)
+		if err != nil {
+			return err
+		}
+
+		var rv delayedStatusResult
+		if err := delayedRequest(cmd, http.MethodGet, fmt.Sprintf("%s/commands/admin/delayed/status", url), &rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error fetching delayed replica status: %s", rv.Error)
+		}
+
+		printDelayedStatus(&rv.Result)
+
+		return nil
+	}
+
+	return cmd
+}
+
+func newDelayedReplayToggle(use, short string, paused bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		url, err := memberAPIURL(cmd)
+		if err != nil {
+			return err
+		}
+
+		var rv delayedStatusResult
+		if err := delayedRequest(cmd, http.MethodPost, fmt.Sprintf("%s/commands/admin/delayed/%s", url, use), &rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error changing replay state: %s", rv.Error)
+		}
+
+		if paused {
+			fmt.Println("Replay has been paused. WAL continues to be received from the upstream")
+		} else {
+			fmt.Println("Replay has been resumed")
+		}
+
+		printDelayedStatus(&rv.Result)
+
+		return nil
+	}
+
+	return cmd
+}
+
+func newDelayedDetach() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "detach",
+		Short: "Pauses replay and promotes a detached copy of the replica",
+		Long: "Pauses replay and promotes a detached copy of the replica.\n\n" +
+			"The copy runs alongside the replica on a separate port and is consistent as of the paused replay position. " +
+			"It is not part of the cluster and doesn't archive WAL. Replay remains paused until it is resumed.",
+		Args: cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		yes, err := cmd.Flags().GetBool("yes")
+		if err != nil {
+			return fmt.Errorf("failed to get yes flag: %v", err)
+		}
+
+		if !yes && !confirm("The data directory will be copied, which requires as much free disk space again. Proceed?") {
+			fmt.Println("Aborted")
+			return nil
+		}
+
+		url, err := memberAPIURL(cmd)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Copying delayed replica. This may take a while...")
+
+		var rv detachedCopyResult
+		if err := delayedRequest(cmd, http.MethodPost, fmt.Sprintf("%s/commands/admin/delayed/detach", url), &rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error promoting detached copy: %s", rv.Error)
+		}
+
+		fmt.Printf("Detached copy promoted from %s as of %s\n", rv.Result.ReplayLSN, replayTime(rv.Result.ReplayTimestamp))
+		fmt.Printf("Connect on port %d of this member to recover data, then run `flexctl node delayed discard` when done\n", rv.Result.Port)
+
+		return nil
+	}
+
+	cmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+
+	return cmd
+}
+
+func newDelayedDiscard() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "discard",
+		Short: "Stops and removes the detached copy",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		url, err := memberAPIURL(cmd)
+		if err != nil {
+			return err
+		}
+
+		var rv struct {
+			Error string `json:"error,omitempty"`
+		}
+		if err := delayedRequest(cmd, http.MethodDelete, fmt.Sprintf("%s/commands/admin/delayed/detach", url), &rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error removing detached copy: %s", rv.Error)
+		}
+
+		fmt.Println("Detached copy removed")
+
+		return nil
+	}
+
+	return cmd
+}
+
+func delayedRequest(cmd *cobra.Command, method, url string, rv any) error {
+	req, err := http.NewRequestWithContext(cmd.Context(), method, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(api.AuthorHeader, cliAuthor())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+
+	return json.NewDecoder(resp.Body).Decode(rv)
+}
+
+func printDelayedStatus(s *flypg.DelayedStatus) {
+	fmt.Printf("Apply delay: %s\n", s.ApplyDelay)
+	fmt.Printf("Replay paused: %t\n", s.Paused)
+	fmt.Printf("Replayed up to: %s as of %s\n", valueOrDash(s.ReplayLSN != "", s.ReplayLSN), replayTime(s.ReplayTimestamp))
+
+	if s.DetachedCopy != nil {
+		fmt.Printf("Detached copy: %s on port %d, created %s\n",
+			s.DetachedCopy.DataDir, s.DetachedCopy.Port, s.DetachedCopy.CreatedAt.Format(time.RFC3339))
+	}
+}
+
+func replayTime(ts *time.Time) string {
+	if ts == nil {
+		return "-"
+	}
+
+	return ts.Format(time.RFC3339)
+}
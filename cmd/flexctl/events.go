@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/spf13/cobra"
+)
+
+func newEventsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Inspect repmgr events handled by a member",
+	}
+
+	cmd.PersistentFlags().StringP("host", "", "localhost", "Member to target. Events are recorded by the member they occurred on")
+
+	cmd.AddCommand(newEventsHistory())
+
+	return cmd
+}
+
+type eventHistoryResult struct {
+	Result []flypg.RepmgrEvent `json:"result"`
+	Error  string              `json:"error,omitempty"`
+}
+
+func newEventsHistory() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Lists the repmgr events handled by the member",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		limit, err := cmd.Flags().GetInt("limit")
+		if err != nil {
+			return fmt.Errorf("failed to get limit flag: %v", err)
+		}
+
+		name, err := cmd.Flags().GetString("name")
+		if err != nil {
+			return fmt.Errorf("failed to get name flag: %v", err)
+		}
+
+		apiURL, err := memberAPIURL(cmd)
+		if err != nil {
+			return err
+		}
+
+		query := url.Values{}
+		query.Set("limit", strconv.Itoa(limit))
+		if name != "" {
+			query.Set("name", name)
+		}
+
+		resp, err := http.Get(fmt.Sprintf("%s/commands/events/history?%s", apiURL, query.Encode()))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // nolint:errcheck
+
+		var rv eventHistoryResult
+		if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error fetching event history: %s", rv.Error)
+		}
+
+		isJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("failed to get json flag: %v", err)
+		}
+
+		if isJSON {
+			e := json.NewEncoder(os.Stdout)
+			e.SetIndent("", "  ")
+			return e.Encode(rv.Result)
+		}
+
+		if len(rv.Result) == 0 {
+			fmt.Println("No events have been recorded")
+			return nil
+		}
+
+		table := tablewriter.NewTable(os.Stdout,
+			tablewriter.WithRowAlignment(tw.AlignLeft),
+			tablewriter.WithHeaderAlignment(tw.AlignLeft),
+			tablewriter.WithRowAutoWrap(tw.WrapNone),
+		)
+		table.Header("Time", "Event", "Node ID", "Success", "Actions", "Error", "Details")
+
+		for _, e := range rv.Result {
+			if err := table.Append([]string{
+				e.Timestamp.Format(time.RFC3339),
+				e.Name,
+				strconv.Itoa(e.NodeID),
+				strconv.FormatBool(e.Success),
+				valueOrDash(len(e.Actions) > 0, strings.Join(e.Actions, ", ")),
+				valueOrDash(e.Error != "", e.Error),
+				valueOrDash(e.Details != "", e.Details),
+			}); err != nil {
+				return fmt.Errorf("failed to append row: %v", err)
+			}
+		}
+
+		if err := table.Render(); err != nil {
+			return fmt.Errorf("failed to render table: %v", err)
+		}
+
+		return nil
+	}
+
+	cmd.Flags().IntP("limit", "n", 20, "Maximum number of events to show. 0 shows everything")
+	cmd.Flags().StringP("name", "", "", "Only show events with the specified name, e.g. standby_promote")
+	cmd.Flags().BoolP("json", "", false, "Output in JSON format")
+
+	return cmd
+}
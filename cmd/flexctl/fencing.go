@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/api"
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/spf13/cobra"
+)
+
+func newFencingCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fencing",
+		Short: "Inspect and resolve split-brain protection decisions",
+	}
+
+	cmd.PersistentFlags().StringP("host", "", "localhost", "Member to target. Fencing state is local to each member")
+
+	cmd.AddCommand(newFencingHistory(), newFencingResolve(), newFailoverValidations())
+
+	return cmd
+}
+
+// memberAPIURL resolves the admin api of the member specified by the --host flag.
+func memberAPIURL(cmd *cobra.Command) (string, error) {
+	host, err := cmd.Flags().GetString("host")
+	if err != nil {
+		return "", fmt.Errorf("failed to get host flag: %v", err)
+	}
+
+	return fmt.Sprintf("http://%s:%d", host, api.Port), nil
+}
+
+type fencingHistoryResult struct {
+	Result []flypg.FencingEvent `json:"result"`
+	Error  string               `json:"error,omitempty"`
+}
+
+func newFencingHistory() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Lists the fencing decisions recorded by the member",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		limit, err := cmd.Flags().GetInt("limit")
+		if err != nil {
+			return fmt.Errorf("failed to get limit flag: %v", err)
+		}
+
+		url, err := memberAPIURL(cmd)
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.Get(fmt.Sprintf("%s/commands/admin/fencing/history?limit=%d", url, limit))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // nolint:errcheck
+
+		var rv fencingHistoryResult
+		if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error fetching fencing history: %s", rv.Error)
+		}
+
+		isJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("failed to get json flag: %v", err)
+		}
+
+		if isJSON {
+			e := json.NewEncoder(os.Stdout)
+			e.SetIndent("", "  ")
+			return e.Encode(rv.Result)
+		}
+
+		if len(rv.Result) == 0 {
+			fmt.Println("No fencing decisions have been recorded")
+			return nil
+		}
+
+		table := tablewriter.NewTable(os.Stdout,
+			tablewriter.WithRowAlignment(tw.AlignLeft),
+			tablewriter.WithHeaderAlignment(tw.AlignLeft),
+			tablewriter.WithRowAutoWrap(tw.WrapNone),
+		)
+		table.Header("Time", "Event", "Decision", "Members", "Active", "Inactive", "Conflicts", "Quorum", "Primary", "Reported Primaries")
+
+		for _, e := range rv.Result {
+			if err := table.Append([]string{
+				e.Timestamp.Format(time.RFC3339),
+				e.Event,
+				valueOrDash(e.Decision != "", e.Decision),
+				strconv.Itoa(e.TotalMembers),
+				strconv.Itoa(e.TotalActive),
+				strconv.Itoa(e.TotalInactive),
+				strconv.Itoa(e.TotalConflicts),
+				strconv.Itoa(e.Quorum),
+				valueOrDash(e.ResolvedPrimary != "", e.ResolvedPrimary),
+				conflictSummary(e.ConflictMap),
+			}); err != nil {
+				return fmt.Errorf("failed to append row: %v", err)
+			}
+		}
+
+		if err := table.Render(); err != nil {
+			return fmt.Errorf("failed to render table: %v", err)
+		}
+
+		return nil
+	}
+
+	cmd.Flags().IntP("limit", "n", 20, "Maximum number of decisions to show. 0 shows everything")
+	cmd.Flags().BoolP("json", "", false, "Output in JSON format")
+
+	return cmd
+}
+
+func conflictSummary(conflicts map[string]int) string {
+	if len(conflicts) == 0 {
+		return "-"
+	}
+
+	var entries []string
+	for hostname, total := range conflicts {
+		entries = append(entries, fmt.Sprintf("%s (%d)", hostname, total))
+	}
+	sort.Strings(entries)
+
+	return strings.Join(entries, ", ")
+}
+
+type failoverValidationsResult struct {
+	Result []flypg.FailoverValidation `json:"result"`
+	Error  string                     `json:"error,omitempty"`
+}
+
+func newFailoverValidations() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validations",
+		Short: "Lists the promotion candidacies validated on the member and the reasoning behind each decision",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		limit, err := cmd.Flags().GetInt("limit")
+		if err != nil {
+			return fmt.Errorf("failed to get limit flag: %v", err)
+		}
+
+		url, err := memberAPIURL(cmd)
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.Get(fmt.Sprintf("%s/commands/admin/failover/validations?limit=%d", url, limit))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // nolint:errcheck
+
+		var rv failoverValidationsResult
+		if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error fetching failover validations: %s", rv.Error)
+		}
+
+		isJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("failed to get json flag: %v", err)
+		}
+
+		if isJSON {
+			e := json.NewEncoder(os.Stdout)
+			e.SetIndent("", "  ")
+			return e.Encode(rv.Result)
+		}
+
+		if len(rv.Result) == 0 {
+			fmt.Println("No failover validations have been recorded")
+			return nil
+		}
+
+		table := tablewriter.NewTable(os.Stdout,
+			tablewriter.WithRowAlignment(tw.AlignLeft),
+			tablewriter.WithHeaderAlignment(tw.AlignLeft),
+			tablewriter.WithRowAutoWrap(tw.WrapNone),
+		)
+		table.Header("Time", "Candidate", "Approved", "Reason")
+
+		for _, v := range rv.Result {
+			reason := "all checks passed"
+			if rejections := v.Rejections(); len(rejections) > 0 {
+				var reasons []string
+				for _, c := range rejections {
+					reasons = append(reasons, fmt.Sprintf("%s: %s", c.Name, c.Reason))
+				}
+				reason = strings.Join(reasons, "; ")
+			}
+
+			if err := table.Append([]string{
+				v.Timestamp.Format(time.RFC3339),
+				v.Candidate,
+				strconv.FormatBool(v.Approved),
+				reason,
+			}); err != nil {
+				return fmt.Errorf("failed to append row: %v", err)
+			}
+		}
+
+		if err := table.Render(); err != nil {
+			return fmt.Errorf("failed to render table: %v", err)
+		}
+
+		return nil
+	}
+
+	cmd.Flags().IntP("limit", "n", 20, "Maximum number of validations to show. 0 shows everything")
+	cmd.Flags().BoolP("json", "", false, "Output in JSON format")
+
+	return cmd
+}
+
+type fencingResolveResult struct {
+	Result flypg.FencingResolution `json:"result"`
+	Error  string                  `json:"error,omitempty"`
+}
+
+func newFencingResolve() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "resolve",
+		Short: "Recovers a fenced member by rejoining a primary or declaring itself the primary",
+		Long: "Recovers a fenced member.\n\n" +
+			"--rejoin <primary> rewinds this member and follows the specified primary. Any changes that diverged from it are discarded.\n" +
+			"--promote declares this member as the true primary, unregisters every other member and lifts the fence.",
+		Args: cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		primary, err := cmd.Flags().GetString("rejoin")
+		if err != nil {
+			return fmt.Errorf("failed to get rejoin flag: %v", err)
+		}
+
+		promote, err := cmd.Flags().GetBool("promote")
+		if err != nil {
+			return fmt.Errorf("failed to get promote flag: %v", err)
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return fmt.Errorf("failed to get dry-run flag: %v", err)
+		}
+
+		yes, err := cmd.Flags().GetBool("yes")
+		if err != nil {
+			return fmt.Errorf("failed to get yes flag: %v", err)
+		}
+
+		action := flypg.FencingResolveRejoin
+		if promote {
+			action = flypg.FencingResolvePromote
+		}
+
+		url, err := memberAPIURL(cmd)
+		if err != nil {
+			return err
+		}
+
+		plan, err := resolveFencing(url, action, primary, true)
+		if err != nil {
+			return err
+		}
+
+		printFencingResolution(plan)
+
+		if dryRun {
+			return nil
+		}
+
+		if !yes && !confirm("Proceed?") {
+			fmt.Println("Aborted")
+			return nil
+		}
+
+		fmt.Println("Resolving fencing. This may take a few minutes...")
+
+		res, err := resolveFencing(url, action, primary, false)
+		if err != nil {
+			return err
+		}
+
+		switch res.Action {
+		case flypg.FencingResolveRejoin:
+			fmt.Printf("Rejoined %s as a standby\n", res.Primary)
+		case flypg.FencingResolvePromote:
+			fmt.Printf("%s is now the primary\n", res.Primary)
+		}
+
+		return nil
+	}
+
+	cmd.Flags().StringP("rejoin", "", "", "Hostname or machine ID of the primary to rejoin")
+	cmd.Flags().BoolP("promote", "", false, "Declare this member as the true primary")
+	cmd.Flags().BoolP("dry-run", "", false, "Show what the member currently sees and the planned changes without applying them")
+	cmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+	cmd.MarkFlagsMutuallyExclusive("rejoin", "promote")
+	cmd.MarkFlagsOneRequired("rejoin", "promote")
+
+	return cmd
+}
+
+func resolveFencing(url, action, primary string, dryRun bool) (*flypg.FencingResolution, error) {
+	body, err := json.Marshal(map[string]any{
+		"action":  action,
+		"primary": primary,
+		"dry_run": dryRun,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%s/commands/admin/fencing/resolve", url), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+
+	var rv fencingResolveResult
+	if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+		return nil, err
+	}
+
+	if rv.Error != "" {
+		return nil, fmt.Errorf("error resolving fencing: %s", rv.Error)
+	}
+
+	return &rv.Result, nil
+}
+
+func printFencingResolution(res *flypg.FencingResolution) {
+	s := res.Sample
+
+	fmt.Println("Current cluster state:")
+	fmt.Printf("  Members: %d, Active: %d, Inactive: %d, Conflicts: %d, Quorum: %d\n",
+		s.TotalMembers, s.TotalActive, s.TotalInactive, s.TotalConflicts, s.Quorum)
+	fmt.Printf("  Diagnosis: %s\n", valueOrDash(s.Decision != "", s.Decision))
+	if s.Error != "" {
+		fmt.Printf("  Error: %s\n", s.Error)
+	}
+
+	for _, m := range s.Members {
+		switch {
+		case !m.Reachable:
+			fmt.Printf("  %s: unreachable (%s)\n", m.Hostname, m.Error)
+		case m.Conflict:
+			fmt.Printf("  %s: reports %s as primary (conflict)\n", m.Hostname, m.ReportedPrimary)
+		default:
+			fmt.Printf("  %s: reports %s as primary\n", m.Hostname, m.ReportedPrimary)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Planned changes:")
+
+	switch res.Action {
+	case flypg.FencingResolveRejoin:
+		fmt.Printf("  Rewind this member and rejoin %s as a standby\n", res.Primary)
+	case flypg.FencingResolvePromote:
+		fmt.Printf("  Declare %s as the primary\n", res.Primary)
+		for _, hostname := range res.Unregistered {
+			fmt.Printf("  Unregister %s\n", hostname)
+		}
+		fmt.Println("  Clear the zombie lock and re-enable writes")
+	}
+}
+
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N]: ", prompt)
+
+	var answer string
+	if _, err := fmt.Scanln(&answer); err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
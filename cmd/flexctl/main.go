@@ -24,6 +24,30 @@ func main() {
 	backupCmd.AddCommand(backupCreateCmd)
 	backupCmd.AddCommand(newBackupConfig())
 
+	// Config commands
+	rootCmd.AddCommand(newConfigCmd())
+
+	// Switchover
+	rootCmd.AddCommand(newSwitchover())
+
+	// Cluster commands
+	rootCmd.AddCommand(newClusterCmd())
+
+	// Fencing commands
+	rootCmd.AddCommand(newFencingCmd())
+
+	// Event commands
+	rootCmd.AddCommand(newEventsCmd())
+
+	// Maintenance commands
+	rootCmd.AddCommand(newMaintenanceCmd())
+
+	// Node commands
+	rootCmd.AddCommand(newNodeCmd())
+
+	// Credential commands
+	rootCmd.AddCommand(newCredentialsCmd())
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
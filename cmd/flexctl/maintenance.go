@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/api"
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/spf13/cobra"
+)
+
+type maintenanceResult struct {
+	Result flypg.MaintenanceStatus `json:"result"`
+	Error  string                  `json:"error,omitempty"`
+}
+
+func newMaintenanceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "maintenance",
+		Short: "Pauses automatic failover across the cluster while disruptive work is performed",
+	}
+
+	cmd.AddCommand(
+		newMaintenanceToggle("enable", "Pauses automatic failover and destructive background monitors", true),
+		newMaintenanceToggle("disable", "Resumes automatic failover and background monitors", false),
+		newMaintenanceStatus(),
+	)
+
+	return cmd
+}
+
+func newMaintenanceToggle(use, short string, enabled bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		url, err := getAPIURL()
+		if err != nil {
+			return err
+		}
+
+		url = fmt.Sprintf("%s/commands/admin/maintenance/%s", url, use)
+		req, err := http.NewRequestWithContext(cmd.Context(), http.MethodPost, url, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(api.AuthorHeader, cliAuthor())
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // nolint:errcheck
+
+		var rv maintenanceResult
+		if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error changing maintenance mode: %s", rv.Error)
+		}
+
+		if enabled {
+			fmt.Println("Maintenance mode enabled. Automatic failover has been paused")
+		} else {
+			fmt.Println("Maintenance mode disabled. Automatic failover has been resumed")
+		}
+
+		return printMaintenanceStatus(&rv.Result)
+	}
+
+	return cmd
+}
+
+func newMaintenanceStatus() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Shows whether the cluster is in maintenance mode and the pause state of each member",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		url, err := getAPIURL()
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.Get(fmt.Sprintf("%s/commands/admin/maintenance/status", url))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // nolint:errcheck
+
+		var rv maintenanceResult
+		if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error fetching maintenance status: %s", rv.Error)
+		}
+
+		isJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("failed to get json flag: %v", err)
+		}
+
+		if isJSON {
+			e := json.NewEncoder(os.Stdout)
+			e.SetIndent("", "  ")
+			return e.Encode(rv.Result)
+		}
+
+		return printMaintenanceStatus(&rv.Result)
+	}
+
+	cmd.Flags().BoolP("json", "", false, "Output in JSON format")
+
+	return cmd
+}
+
+func printMaintenanceStatus(status *flypg.MaintenanceStatus) error {
+	state := "disabled"
+	if status.Enabled {
+		state = "enabled"
+	}
+
+	if status.Since.IsZero() {
+		fmt.Printf("Maintenance mode: %s\n", state)
+	} else {
+		fmt.Printf("Maintenance mode: %s (since %s by %s)\n", state, status.Since.Format(time.RFC3339), valueOrDash(status.Author != "", status.Author))
+	}
+
+	if status.Error != "" {
+		fmt.Printf("Warning: %s. Unreachable members will be paused by their monitor once they recover\n", status.Error)
+	}
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithRowAlignment(tw.AlignLeft),
+		tablewriter.WithHeaderAlignment(tw.AlignLeft),
+		tablewriter.WithRowAutoWrap(tw.WrapNone),
+	)
+	table.Header("Member", "Role", "Failover Paused", "Error")
+
+	for _, m := range status.Members {
+		if err := table.Append([]string{
+			m.Hostname,
+			m.Role,
+			valueOrDash(m.Error == "", fmt.Sprint(m.Paused)),
+			valueOrDash(m.Error != "", m.Error),
+		}); err != nil {
+			return fmt.Errorf("failed to append row: %v", err)
+		}
+	}
+
+	if err := table.Render(); err != nil {
+		return fmt.Errorf("failed to render table: %v", err)
+	}
+
+	return nil
+}
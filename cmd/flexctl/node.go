@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/api"
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/spf13/cobra"
+)
+
+func newNodeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "node",
+		Short: "Manage an individual member",
+	}
+
+	cmd.PersistentFlags().StringP("host", "", "localhost", "Member to target")
+
+	cmd.AddCommand(newNodeReseed(), newNodeReseedHistory(), newNodeDelayedCmd())
+
+	return cmd
+}
+
+type reseedResult struct {
+	Result flypg.Reseed `json:"result"`
+	Error  string       `json:"error,omitempty"`
+}
+
+func newNodeReseed() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reseed",
+		Short: "Rebuilds a standby that is unable to follow the primary",
+		Long: "Rebuilds a standby that is unable to follow the primary.\n\n" +
+			"The standby is rewound and rejoined to the primary first. If that fails, the data directory is wiped and the primary is cloned from scratch.",
+		Args: cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		forceClone, err := cmd.Flags().GetBool("force-clone")
+		if err != nil {
+			return fmt.Errorf("failed to get force-clone flag: %v", err)
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return fmt.Errorf("failed to get dry-run flag: %v", err)
+		}
+
+		yes, err := cmd.Flags().GetBool("yes")
+		if err != nil {
+			return fmt.Errorf("failed to get yes flag: %v", err)
+		}
+
+		url, err := memberAPIURL(cmd)
+		if err != nil {
+			return err
+		}
+
+		plan, err := reseedNode(url, forceClone, true)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Reseeding %s from %s\n", plan.Hostname, plan.Primary)
+		fmt.Printf("  Reason: %s\n", plan.Reason)
+		fmt.Printf("  Methods: %s\n", strings.Join(plan.Methods, ", then "))
+
+		if dryRun {
+			return nil
+		}
+
+		if !yes && !confirm("Local data on this member will be discarded. Proceed?") {
+			fmt.Println("Aborted")
+			return nil
+		}
+
+		fmt.Println("Reseeding standby. This may take a while...")
+
+		res, err := reseedNode(url, forceClone, false)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s has been reseeded from %s using %s\n", res.Hostname, res.Primary, res.Method)
+
+		return nil
+	}
+
+	cmd.Flags().BoolP("force-clone", "", false, "Skip the rewind and clone the primary straight away")
+	cmd.Flags().BoolP("dry-run", "", false, "Show the planned reseed without applying it")
+	cmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+
+	return cmd
+}
+
+func reseedNode(url string, forceClone, dryRun bool) (*flypg.Reseed, error) {
+	body, err := json.Marshal(map[string]any{
+		"force_clone": forceClone,
+		"dry_run":     dryRun,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/commands/admin/reseed", url), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(api.AuthorHeader, cliAuthor())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+
+	var rv reseedResult
+	if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+		return nil, err
+	}
+
+	if rv.Error != "" {
+		return nil, fmt.Errorf("error reseeding standby: %s", rv.Error)
+	}
+
+	return &rv.Result, nil
+}
+
+type reseedHistoryResult struct {
+	Result []flypg.Reseed `json:"result"`
+	Error  string         `json:"error,omitempty"`
+}
+
+func newNodeReseedHistory() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reseeds",
+		Short: "Lists the reseeds performed on the member",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		limit, err := cmd.Flags().GetInt("limit")
+		if err != nil {
+			return fmt.Errorf("failed to get limit flag: %v", err)
+		}
+
+		url, err := memberAPIURL(cmd)
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.Get(fmt.Sprintf("%s/commands/admin/reseed/history?limit=%d", url, limit))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // nolint:errcheck
+
+		var rv reseedHistoryResult
+		if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error fetching reseed history: %s", rv.Error)
+		}
+
+		isJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return fmt.Errorf("failed to get json flag: %v", err)
+		}
+
+		if isJSON {
+			e := json.NewEncoder(os.Stdout)
+			e.SetIndent("", "  ")
+			return e.Encode(rv.Result)
+		}
+
+		if len(rv.Result) == 0 {
+			fmt.Println("No reseeds have been recorded")
+			return nil
+		}
+
+		table := tablewriter.NewTable(os.Stdout,
+			tablewriter.WithRowAlignment(tw.AlignLeft),
+			tablewriter.WithHeaderAlignment(tw.AlignLeft),
+			tablewriter.WithRowAutoWrap(tw.WrapNone),
+		)
+		table.Header("Time", "Trigger", "Primary", "Method", "Reason", "Error")
+
+		for _, r := range rv.Result {
+			if err := table.Append([]string{
+				r.Timestamp.Format(time.RFC3339),
+				r.Trigger,
+				r.Primary,
+				valueOrDash(r.Method != "", r.Method),
+				valueOrDash(r.Reason != "", r.Reason),
+				valueOrDash(r.Error != "", r.Error),
+			}); err != nil {
+				return fmt.Errorf("failed to append row: %v", err)
+			}
+		}
+
+		if err := table.Render(); err != nil {
+			return fmt.Errorf("failed to render table: %v", err)
+		}
+
+		return nil
+	}
+
+	cmd.Flags().IntP("limit", "n", 20, "Maximum number of reseeds to show. 0 shows everything")
+	cmd.Flags().BoolP("json", "", false, "Output in JSON format")
+
+	return cmd
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/spf13/cobra"
+)
+
+type switchoverResult struct {
+	Result flypg.SwitchoverResult `json:"result"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+func newSwitchover() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "switchover",
+		Short: "Promotes the specified standby and demotes the current primary",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		target, err := cmd.Flags().GetString("to")
+		if err != nil {
+			return fmt.Errorf("failed to get to flag: %v", err)
+		}
+
+		maxLag, err := cmd.Flags().GetInt64("max-lag-bytes")
+		if err != nil {
+			return fmt.Errorf("failed to get max-lag-bytes flag: %v", err)
+		}
+
+		url, err := getAPIURL()
+		if err != nil {
+			return err
+		}
+
+		body, err := json.Marshal(map[string]any{
+			"to":            target,
+			"max_lag_bytes": maxLag,
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Performing switchover to %s. This may take a few minutes...\n", target)
+
+		url = fmt.Sprintf("%s/commands/admin/switchover", url)
+		resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // nolint:errcheck
+
+		var rv switchoverResult
+		if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+			return err
+		}
+
+		if rv.Error != "" {
+			return fmt.Errorf("error performing switchover: %s", rv.Error)
+		}
+
+		fmt.Println("Switchover completed")
+		fmt.Printf("  Old primary: %s\n", rv.Result.OldPrimary)
+		fmt.Printf("  New primary: %s\n", rv.Result.NewPrimary)
+		fmt.Printf("  Lag at switchover: %d bytes\n", rv.Result.LagBytes)
+		fmt.Printf("  Duration: %s\n", rv.Result.Duration)
+
+		return nil
+	}
+
+	cmd.Flags().StringP("to", "", "", "Machine ID of the standby to promote")
+	cmd.Flags().Int64P("max-lag-bytes", "", flypg.DefaultSwitchoverMaxLag, "Maximum replication lag the standby may have")
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
@@ -15,10 +15,19 @@ var (
 	deadMemberMonitorFrequency       = time.Hour * 1
 	replicationStateMonitorFrequency = time.Hour * 1
 	clusterStateMonitorFrequency     = time.Minute * 5
+	synchronousReplicationFrequency  = time.Second * 15
+	maintenanceMonitorFrequency      = time.Second * 30
+	notificationFlushFrequency       = time.Second * 15
+	reseedMonitorFrequency           = time.Minute * 1
+	upstreamMonitorFrequency         = time.Minute * 1
+	logicalSlotSyncFrequency         = time.Minute * 1
+	certificateMonitorFrequency      = time.Hour * 12
 
 	defaultDeadMemberRemovalThreshold   = time.Hour * 12
 	defaultInactiveSlotRemovalThreshold = time.Hour * 12
 
+	defaultReseedDetectionThreshold = time.Minute * 5
+
 	defaultBackupRetentionEvalFrequency = time.Hour * 12
 	defaultFullBackupSchedule           = time.Hour * 24
 )
@@ -73,6 +82,27 @@ func main() {
 	// Readonly monitor
 	go monitorClusterState(ctx, node)
 
+	// Synchronous replication monitor
+	go monitorSynchronousReplication(ctx, node)
+
+	// Maintenance monitor
+	go monitorMaintenance(ctx, node)
+
+	// Webhook notification retries
+	go monitorNotifications(ctx, node)
+
+	// Standby reseed monitor
+	go monitorReseed(ctx, node)
+
+	// Cascading replication upstream monitor
+	go monitorUpstream(ctx, node)
+
+	// Logical slot synchronization monitor
+	go monitorLogicalSlotSync(ctx, node)
+
+	// Certificate rotation monitor
+	go monitorCertificates(ctx, node)
+
 	// Replication slot monitor
 	monitorReplicationSlots(ctx, node)
 }
@@ -0,0 +1,20 @@
+package main
+
+import "github.com/fly-apps/postgres-flex/internal/metrics"
+
+var (
+	deadMemberRemovals = metrics.NewCounter("flypg_dead_member_removals_total",
+		"Number of dead members unregistered from the cluster, by result.", "result")
+	replicationSlotDrops = metrics.NewCounter("flypg_replication_slot_drops_total",
+		"Number of inactive replication slots dropped, by result.", "result")
+	inactiveReplicationSlots = metrics.NewGauge("flypg_inactive_replication_slots",
+		"Number of inactive replication slots observed on the primary.")
+	logicalReplicationSlots = metrics.NewGauge("flypg_logical_replication_slots",
+		"Number of logical replication slots observed on the primary.")
+	inactiveLogicalReplicationSlots = metrics.NewGauge("flypg_inactive_logical_replication_slots",
+		"Number of inactive logical replication slots observed on the primary.")
+	logicalSlotRetainedWal = metrics.NewGauge("flypg_logical_replication_slot_retained_wal_bytes",
+		"Bytes of WAL retained by logical replication slots on the primary.")
+	monitorTickFailures = metrics.NewCounter("flypg_monitor_tick_failures_total",
+		"Number of monitor ticks that failed, by monitor.", "monitor")
+)
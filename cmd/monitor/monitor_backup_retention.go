@@ -30,8 +30,9 @@ func monitorBackupRetention(ctx context.Context, node *flypg.Node, barman *flypg
 
 			if _, err := barman.WALArchiveDelete(ctx); err != nil {
 				log.Printf("[WARN] Failed to prune WAL Archive: %s", err)
+				node.Notify(ctx, flypg.NotificationBackupRetentionFailed, "failed to prune the WAL archive",
+					map[string]any{"error": err.Error()})
 			}
-
 		}
 	}
 }
@@ -10,7 +10,8 @@ import (
 )
 
 const (
-	backupRetryInterval = time.Second * 30
+	backupRetryInterval            = time.Second * 30
+	backupRescheduleCheckFrequency = time.Second * 30
 )
 
 func monitorBackupSchedule(ctx context.Context, node *flypg.Node, barman *flypg.Barman) {
@@ -37,6 +38,8 @@ func monitorBackupSchedule(ctx context.Context, node *flypg.Node, barman *flypg.
 			switch {
 			case err != nil:
 				log.Printf("[WARN] Failed to perform initial base backup: %s", err)
+				node.Notify(ctx, flypg.NotificationBackupFailed, "initial base backup failed",
+					map[string]any{"error": err.Error()})
 				log.Printf("[INFO] Retrying in 10 minutes...")
 				lastBackupTime = time.Now().Add(-backupFrequency(barman) + 10*time.Minute)
 			default:
@@ -61,49 +64,73 @@ func monitorBackupSchedule(ctx context.Context, node *flypg.Node, barman *flypg.
 	ticker := time.NewTicker(nextScheduledBackup)
 	defer ticker.Stop()
 
+	// Promotions request the schedule to be re-evaluated, so a newly promoted primary doesn't
+	// have to wait on its own ticker if a backup is overdue.
+	rescheduleTicker := time.NewTicker(backupRescheduleCheckFrequency)
+	defer rescheduleTicker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("[WARN] Shutting down backup schedule monitor...")
 			return
-		case <-ticker.C:
-			// Check to see if we are the Primary. This is necessary given failovers can occur at runtime.
-			primary, err := isPrimary(ctx, node)
-			if err != nil {
-				log.Printf("[WARN] Failed to resolve primary status: %s", err)
+		case <-rescheduleTicker.C:
+			if !flypg.BackupRescheduleRequested() {
 				continue
 			}
 
-			if !primary {
-				continue
+			log.Println("[INFO] Re-evaluating backup schedule following a promotion")
+			if next, ok := evaluateBackupSchedule(ctx, node, barman); ok {
+				ticker.Reset(next)
 			}
-
-			lastBackupTime, err := barman.LastCompletedBackup(ctx)
-			if err != nil {
-				log.Printf("[WARN] Failed to determine when the last backup was taken: %s", err)
-				continue
+		case <-ticker.C:
+			if next, ok := evaluateBackupSchedule(ctx, node, barman); ok {
+				// Reset the ticker frequency in case the backup frequency has changed.
+				ticker.Reset(next)
 			}
+		}
+	}
+}
 
-			// Recalculate the next scheduled backup time.
-			nextScheduledBackup = calculateNextBackupTime(barman, lastBackupTime)
+// evaluateBackupSchedule performs a full backup when one is due and returns when the next
+// backup is due. False is returned when we are not the primary or the schedule can't be resolved.
+func evaluateBackupSchedule(ctx context.Context, node *flypg.Node, barman *flypg.Barman) (time.Duration, bool) {
+	// Check to see if we are the Primary. This is necessary given failovers can occur at runtime.
+	primary, err := isPrimary(ctx, node)
+	if err != nil {
+		log.Printf("[WARN] Failed to resolve primary status: %s", err)
+		return 0, false
+	}
 
-			// Perform a full backup if the next scheduled backup time is less than 0.
-			if nextScheduledBackup < 0 {
-				log.Println("[INFO] Performing full backup...")
-				if err := performBaseBackup(ctx, barman, false); err != nil {
-					log.Printf("[WARN] Failed to perform full backup: %v", err)
-				}
+	if !primary {
+		return 0, false
+	}
 
-				// TODO - We should consider retrying at a shorter interval in the event of a failure.
-				nextScheduledBackup = backupFrequency(barman)
-			}
+	lastBackupTime, err := barman.LastCompletedBackup(ctx)
+	if err != nil {
+		log.Printf("[WARN] Failed to determine when the last backup was taken: %s", err)
+		return 0, false
+	}
 
-			log.Printf("[INFO] Next full backup due in: %s", nextScheduledBackup)
+	// Recalculate the next scheduled backup time.
+	nextScheduledBackup := calculateNextBackupTime(barman, lastBackupTime)
 
-			// Reset the ticker frequency in case the backup frequency has changed.
-			ticker.Reset(nextScheduledBackup)
+	// Perform a full backup if the next scheduled backup time is less than 0.
+	if nextScheduledBackup < 0 {
+		log.Println("[INFO] Performing full backup...")
+		if err := performBaseBackup(ctx, barman, false); err != nil {
+			log.Printf("[WARN] Failed to perform full backup: %v", err)
+			node.Notify(ctx, flypg.NotificationBackupFailed, "scheduled full backup failed",
+				map[string]any{"error": err.Error()})
 		}
+
+		// TODO - We should consider retrying at a shorter interval in the event of a failure.
+		nextScheduledBackup = backupFrequency(barman)
 	}
+
+	log.Printf("[INFO] Next full backup due in: %s", nextScheduledBackup)
+
+	return nextScheduledBackup, true
 }
 
 func calculateNextBackupTime(barman *flypg.Barman, lastBackupTime time.Time) time.Duration {
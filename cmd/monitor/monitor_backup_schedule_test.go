@@ -9,6 +9,7 @@ import (
 
 	"github.com/fly-apps/postgres-flex/internal/flypg"
 	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+	"github.com/fly-apps/postgres-flex/internal/utils"
 )
 
 const (
@@ -24,7 +25,7 @@ func TestBackupFrequency(t *testing.T) {
 
 	setDefaultEnv(t)
 
-	store, _ := state.NewStore()
+	store := state.NewMemoryStore()
 
 	barman, err := flypg.NewBarman(store, os.Getenv("S3_ARCHIVE_CONFIG"), flypg.DefaultAuthProfile)
 	if err != nil {
@@ -50,7 +51,7 @@ func TestCalculateNextBackupTime(t *testing.T) {
 
 	setDefaultEnv(t)
 
-	store, _ := state.NewStore()
+	store := state.NewMemoryStore()
 	barman, err := flypg.NewBarman(store, os.Getenv("S3_ARCHIVE_CONFIG"), flypg.DefaultAuthProfile)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -124,7 +125,11 @@ func setDefaultEnv(t *testing.T) {
 
 func setup(t *testing.T) error {
 	t.Setenv("FLY_VM_MEMORY_MB", fmt.Sprint(256*(1024*1024)))
-	t.Setenv("UNIT_TESTING", "true")
+
+	// The postgres user only exists within the image.
+	setFileOwnership := utils.SetFileOwnership
+	t.Cleanup(func() { utils.SetFileOwnership = setFileOwnership })
+	utils.SetFileOwnership = func(string, string) error { return nil }
 
 	if _, err := os.Stat(pgTestDirectory); err != nil {
 		if os.IsNotExist(err) {
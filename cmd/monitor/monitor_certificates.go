@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+func monitorCertificates(ctx context.Context, node *flypg.Node) {
+	ticker := time.NewTicker(certificateMonitorFrequency)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := flypg.RotateCertificates(ctx, node); err != nil {
+			log.Printf("certificateTick failed with: %s", err)
+			monitorTickFailures.Inc("certificates")
+		}
+	}
+}
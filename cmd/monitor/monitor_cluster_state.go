@@ -16,6 +16,7 @@ func monitorClusterState(ctx context.Context, node *flypg.Node) {
 	for range ticker.C {
 		if err := clusterStateMonitorTick(ctx, node); err != nil {
 			log.Printf("clusterStateMonitorTick failed with: %s", err)
+			monitorTickFailures.Inc("cluster_state")
 		}
 	}
 }
@@ -38,9 +38,15 @@ func monitorDeadMembers(ctx context.Context, node *flypg.Node) error {
 	defer ticker.Stop()
 
 	for range ticker.C {
+		if suspendedForMaintenance(node, "dead member monitor") {
+			pauseClock(seenAt)
+			continue
+		}
+
 		err := deadMemberMonitorTick(ctx, node, seenAt, removalThreshold)
 		if err != nil {
 			log.Printf("deadMemberMonitorTick failed with: %s", err)
+			monitorTickFailures.Inc("dead_members")
 		}
 	}
 
@@ -78,8 +84,10 @@ func deadMemberMonitorTick(ctx context.Context, node *flypg.Node, seenAt map[int
 				log.Printf("Removing dead member: %s\n", voter.Hostname)
 				if err := node.RepMgr.UnregisterMember(voter); err != nil {
 					log.Printf("failed to unregister member %s: %v", voter.Hostname, err)
+					deadMemberRemovals.Inc("failure")
 					continue
 				}
+				deadMemberRemovals.Inc("success")
 				delete(seenAt, voter.ID)
 			}
 
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+func monitorLogicalSlotSync(ctx context.Context, node *flypg.Node) {
+	ticker := time.NewTicker(logicalSlotSyncFrequency)
+	defer ticker.Stop()
+	for range ticker.C {
+		if suspendedForMaintenance(node, "logical slot sync monitor") {
+			continue
+		}
+
+		if err := flypg.ManageLogicalSlotSync(ctx, node); err != nil {
+			log.Printf("logicalSlotSyncTick failed with: %s", err)
+			monitorTickFailures.Inc("logical_slot_sync")
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+func monitorMaintenance(ctx context.Context, node *flypg.Node) {
+	ticker := time.NewTicker(maintenanceMonitorFrequency)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := flypg.EnforceMaintenance(ctx, node); err != nil {
+			log.Printf("maintenanceMonitorTick failed with: %s", err)
+			monitorTickFailures.Inc("maintenance")
+		}
+	}
+}
+
+// suspendedForMaintenance reports whether destructive monitors should skip the current tick.
+// Ticks are also skipped when the maintenance state can't be resolved.
+func suspendedForMaintenance(node *flypg.Node, monitor string) bool {
+	store, err := node.StateStore()
+	if err != nil {
+		log.Printf("Skipping %s: failed to initialize state store: %s", monitor, err)
+		return true
+	}
+
+	enabled, err := flypg.MaintenanceEnabled(store)
+	if err != nil {
+		log.Printf("Skipping %s: %s", monitor, err)
+		return true
+	}
+
+	if enabled {
+		log.Printf("Skipping %s: cluster is in maintenance mode", monitor)
+	}
+
+	return enabled
+}
+
+// pauseClock shifts the tracked timestamps forward so time spent in maintenance doesn't count
+// towards removal thresholds.
+func pauseClock[K comparable](seenAt map[K]time.Time) {
+	for id := range seenAt {
+		seenAt[id] = time.Now()
+	}
+}
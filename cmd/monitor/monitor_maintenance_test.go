@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+func TestSuspendedForMaintenance(t *testing.T) {
+	store := state.NewMemoryStore()
+
+	node := &flypg.Node{}
+	node.SetStateStore(store)
+
+	t.Run("Disabled", func(t *testing.T) {
+		if suspendedForMaintenance(node, "test monitor") {
+			t.Fatal("expected monitor to run")
+		}
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		if err := store.PushUserConfig("maintenance", []byte(`{"enabled": true}`)); err != nil {
+			t.Fatal(err)
+		}
+
+		if !suspendedForMaintenance(node, "test monitor") {
+			t.Fatal("expected monitor to be suspended")
+		}
+	})
+
+	t.Run("Unreadable", func(t *testing.T) {
+		if err := store.PushUserConfig("maintenance", []byte(`{`)); err != nil {
+			t.Fatal(err)
+		}
+
+		if !suspendedForMaintenance(node, "test monitor") {
+			t.Fatal("expected monitor to be suspended")
+		}
+	})
+}
+
+func TestPauseClock(t *testing.T) {
+	seenAt := map[int]time.Time{1: time.Now().Add(-48 * time.Hour)}
+
+	pauseClock(seenAt)
+
+	if time.Since(seenAt[1]) > time.Minute {
+		t.Fatalf("expected timestamp to be reset, got %s", seenAt[1])
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+// monitorNotifications retries webhook deliveries that have been left within the spool.
+func monitorNotifications(ctx context.Context, node *flypg.Node) {
+	ticker := time.NewTicker(notificationFlushFrequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("[WARN] Shutting down notification monitor...")
+			return
+		case <-ticker.C:
+			if err := node.FlyConfig.Notifier().Flush(ctx); err != nil {
+				log.Printf("[WARN] Failed to flush notifications: %s", err)
+			}
+		}
+	}
+}
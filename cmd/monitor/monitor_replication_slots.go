@@ -11,18 +11,24 @@ import (
 )
 
 func monitorReplicationSlots(ctx context.Context, node *flypg.Node) {
-	inactiveSlotStatus := map[int]time.Time{}
+	inactiveSlotStatus := map[string]time.Time{}
 
 	ticker := time.NewTicker(replicationStateMonitorFrequency)
 	defer ticker.Stop()
 	for range ticker.C {
+		if suspendedForMaintenance(node, "replication slot monitor") {
+			pauseClock(inactiveSlotStatus)
+			continue
+		}
+
 		if err := replicationSlotMonitorTick(ctx, node, inactiveSlotStatus); err != nil {
 			log.Printf("replicationSlotMonitorTick failed with: %s", err)
+			monitorTickFailures.Inc("replication_slots")
 		}
 	}
 }
 
-func replicationSlotMonitorTick(ctx context.Context, node *flypg.Node, inactiveSlotStatus map[int]time.Time) error {
+func replicationSlotMonitorTick(ctx context.Context, node *flypg.Node, inactiveSlotStatus map[string]time.Time) error {
 	conn, err := node.RepMgr.NewLocalConnection(ctx)
 	if err != nil {
 		log.Printf("failed to open local connection: %s\n", err)
@@ -45,9 +51,18 @@ func replicationSlotMonitorTick(ctx context.Context, node *flypg.Node, inactiveS
 		log.Printf("failed to list replication slots: %s\n", err)
 	}
 
+	inactive := 0
+	for _, slot := range slots {
+		if !slot.Active {
+			inactive++
+		}
+	}
+	inactiveReplicationSlots.Set(float64(inactive))
+	recordLogicalSlotMetrics(slots)
+
 	for _, slot := range slots {
 		if slot.Active {
-			delete(inactiveSlotStatus, int(slot.MemberID))
+			delete(inactiveSlotStatus, slot.Name)
 			continue
 		}
 
@@ -60,27 +75,44 @@ func replicationSlotMonitorTick(ctx context.Context, node *flypg.Node, inactiveS
 		}
 
 		// Check to see if slot has already been registered as inactive.
-		if lastSeen, ok := inactiveSlotStatus[int(slot.MemberID)]; ok {
+		if lastSeen, ok := inactiveSlotStatus[slot.Name]; ok {
 			// TODO - Consider creating a separate threshold for when the member exists.
 			// TODO - Consider being more aggressive with removing replication slots if disk capacity is at dangerous levels.
 			// TODO - Make inactiveSlotRemovalThreshold configurable.
 
+			// Logical slots belong to subscribers outside of the cluster, so dropping them would
+			// silently break their replication. They are reported rather than removed.
+			if !autoDroppable(slot) {
+				log.Printf("[WARN] Logical replication slot %s has been inactive for %v and will not be removed automatically\n",
+					slot.Name, time.Since(lastSeen).Round(time.Second))
+				continue
+			}
+
 			// Remove the replication slot if it has been inactive for longer than the defined threshold
 			if time.Since(lastSeen) > defaultInactiveSlotRemovalThreshold {
 				log.Printf("Dropping replication slot: %s\n", slot.Name)
 				if err := admin.DropReplicationSlot(ctx, conn, slot.Name); err != nil {
 					log.Printf("failed to drop replication slot %s: %v\n", slot.Name, err)
+					replicationSlotDrops.Inc("failure")
 					continue
 				}
+				replicationSlotDrops.Inc("success")
 
-				delete(inactiveSlotStatus, int(slot.MemberID))
+				delete(inactiveSlotStatus, slot.Name)
 
 				continue
 			}
 
 			log.Printf("Replication slot %s has been inactive for %v\n", slot.Name, time.Since(lastSeen).Round(time.Second))
 		} else {
-			inactiveSlotStatus[int(slot.MemberID)] = time.Now()
+			inactiveSlotStatus[slot.Name] = time.Now()
+		}
+	}
+
+	// Forget slots that no longer exist.
+	for name := range inactiveSlotStatus {
+		if !slotExists(slots, name) {
+			delete(inactiveSlotStatus, name)
 		}
 	}
 
@@ -90,3 +122,38 @@ func replicationSlotMonitorTick(ctx context.Context, node *flypg.Node, inactiveS
 
 	return nil
 }
+
+// autoDroppable reports whether the slot may be removed once it has been inactive for longer than
+// the removal threshold. Only physical slots managed by repmgr qualify.
+func autoDroppable(slot admin.ReplicationSlot) bool {
+	return !slot.Logical() && slot.MemberID > 0
+}
+
+func slotExists(slots []admin.ReplicationSlot, name string) bool {
+	for _, slot := range slots {
+		if slot.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func recordLogicalSlotMetrics(slots []admin.ReplicationSlot) {
+	var total, inactive, retained int
+	for _, slot := range slots {
+		if !slot.Logical() {
+			continue
+		}
+
+		total++
+		if !slot.Active {
+			inactive++
+		}
+		retained += slot.RetainedWalInBytes
+	}
+
+	logicalReplicationSlots.Set(float64(total))
+	inactiveLogicalReplicationSlots.Set(float64(inactive))
+	logicalSlotRetainedWal.Set(float64(retained))
+}
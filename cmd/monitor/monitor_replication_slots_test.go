@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+)
+
+func TestAutoDroppable(t *testing.T) {
+	t.Run("RepmgrSlot", func(t *testing.T) {
+		slot := admin.ReplicationSlot{Name: "repmgr_slot_2", Type: "physical", MemberID: 2}
+
+		if !autoDroppable(slot) {
+			t.Fatal("expected repmgr slots to be droppable")
+		}
+	})
+
+	t.Run("LogicalSlot", func(t *testing.T) {
+		slot := admin.ReplicationSlot{Name: "orders_sub", Type: "logical", Plugin: "pgoutput", Database: "app"}
+
+		if autoDroppable(slot) {
+			t.Fatal("expected logical slots to be left alone")
+		}
+	})
+
+	t.Run("UnmanagedPhysicalSlot", func(t *testing.T) {
+		slot := admin.ReplicationSlot{Name: "pg_receivewal", Type: "physical"}
+
+		if autoDroppable(slot) {
+			t.Fatal("expected physical slots not managed by repmgr to be left alone")
+		}
+	})
+}
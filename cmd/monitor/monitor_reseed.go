@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+// reseedDetection tracks how long the standby has been unable to follow the primary.
+type reseedDetection struct {
+	reason     string
+	detectedAt time.Time
+}
+
+// observe records the latest diagnosis. An empty reason indicates the standby is healthy.
+func (d *reseedDetection) observe(reason string, now time.Time) {
+	if reason == "" {
+		d.reset()
+		return
+	}
+
+	if d.detectedAt.IsZero() {
+		d.detectedAt = now
+	}
+	d.reason = reason
+}
+
+// due reports whether the standby has been unable to follow for longer than the threshold.
+func (d *reseedDetection) due(now time.Time, threshold time.Duration) bool {
+	return !d.detectedAt.IsZero() && now.Sub(d.detectedAt) >= threshold
+}
+
+func (d *reseedDetection) reset() {
+	d.reason = ""
+	d.detectedAt = time.Time{}
+}
+
+func monitorReseed(ctx context.Context, node *flypg.Node) {
+	ticker := time.NewTicker(reseedMonitorFrequency)
+	defer ticker.Stop()
+
+	var detection reseedDetection
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("[WARN] Shutting down reseed monitor...")
+			return
+		case <-ticker.C:
+			if suspendedForMaintenance(node, "reseed monitor") {
+				detection.reset()
+				continue
+			}
+
+			reason, err := flypg.DiagnoseReseed(ctx, node)
+			if err != nil {
+				log.Printf("[WARN] Failed to diagnose standby: %s", err)
+				monitorTickFailures.Inc("reseed")
+				continue
+			}
+
+			firstSeen := reason != "" && detection.detectedAt.IsZero()
+			detection.observe(reason, time.Now())
+			if reason == "" {
+				continue
+			}
+
+			autoReseed := node.FlyConfig.BoolSetting("autoReseed", false)
+
+			if firstSeen {
+				log.Printf("[WARN] Standby is unable to follow the primary: %s", reason)
+				if !autoReseed {
+					log.Println("[WARN] Run `flexctl node reseed` to rebuild this standby or set `autoReseed` to do so automatically")
+				}
+			}
+
+			threshold := node.FlyConfig.DurationSetting("reseedDetectionThreshold", defaultReseedDetectionThreshold)
+			if !autoReseed || !detection.due(time.Now(), threshold) {
+				continue
+			}
+
+			log.Printf("[WARN] Standby has been unable to follow the primary for %s, reseeding", threshold)
+			if _, err := flypg.ReseedStandby(ctx, node, flypg.ReseedTriggerAutomatic, detection.reason, false, false); err != nil {
+				log.Printf("[ERROR] Failed to reseed standby: %s", err)
+			}
+
+			detection.reset()
+		}
+	}
+}
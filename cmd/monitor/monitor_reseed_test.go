@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReseedDetection(t *testing.T) {
+	now := time.Now()
+	threshold := 5 * time.Minute
+
+	var detection reseedDetection
+
+	t.Run("Healthy", func(t *testing.T) {
+		detection.observe("", now)
+
+		if detection.due(now.Add(time.Hour), threshold) {
+			t.Fatal("expected a healthy standby not to be due for reseed")
+		}
+	})
+
+	t.Run("BelowThreshold", func(t *testing.T) {
+		detection.observe("wal removed", now)
+		detection.observe("wal removed", now.Add(time.Minute))
+
+		if detection.due(now.Add(time.Minute), threshold) {
+			t.Fatal("expected reseed not to be due before the threshold")
+		}
+	})
+
+	t.Run("AboveThreshold", func(t *testing.T) {
+		detection.observe("timeline diverged", now.Add(6*time.Minute))
+
+		if !detection.due(now.Add(6*time.Minute), threshold) {
+			t.Fatal("expected reseed to be due once the threshold has passed")
+		}
+
+		if detection.reason != "timeline diverged" {
+			t.Fatalf("expected the latest reason to be retained, got %q", detection.reason)
+		}
+	})
+
+	t.Run("Recovered", func(t *testing.T) {
+		detection.observe("", now.Add(7*time.Minute))
+
+		if detection.due(now.Add(7*time.Minute), threshold) {
+			t.Fatal("expected a recovered standby not to be due for reseed")
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+func monitorSynchronousReplication(ctx context.Context, node *flypg.Node) {
+	ticker := time.NewTicker(synchronousReplicationFrequency)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := synchronousReplicationTick(ctx, node); err != nil {
+			log.Printf("synchronousReplicationTick failed with: %s", err)
+			monitorTickFailures.Inc("synchronous_replication")
+		}
+	}
+}
+
+func synchronousReplicationTick(ctx context.Context, node *flypg.Node) error {
+	conn, err := node.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open local connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	member, err := node.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to query local member: %s", err)
+	}
+
+	// Only the primary manages the synchronous standbys.
+	// We need to check this per-tick as the role can change at runtime.
+	if member.Role != flypg.PrimaryRoleName {
+		return nil
+	}
+
+	if err := conn.Close(ctx); err != nil {
+		return fmt.Errorf("failed to close connection: %s", err)
+	}
+
+	return flypg.ManageSynchronousReplication(ctx, node)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+// monitorUpstream keeps standbys outside of the primary region replicating from their region's
+// relay, re-pointing them when the relay dies or a new one becomes available.
+func monitorUpstream(ctx context.Context, node *flypg.Node) {
+	// Standbys within the primary region always replicate from the primary.
+	if node.PrimaryRegion == node.RepMgr.Region {
+		return
+	}
+
+	ticker := time.NewTicker(upstreamMonitorFrequency)
+	defer ticker.Stop()
+
+	// Assume cascading was enabled prior to boot, so a standby left behind on a relay is
+	// re-pointed at the primary once the setting is turned off.
+	wasEnabled := true
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("[WARN] Shutting down upstream monitor...")
+			return
+		case <-ticker.C:
+			enabled := node.FlyConfig.BoolSetting("cascadingReplication", false)
+			if !enabled && !wasEnabled {
+				continue
+			}
+
+			if suspendedForMaintenance(node, "upstream monitor") {
+				continue
+			}
+
+			if err := node.FollowRegionalUpstream(ctx); err != nil {
+				log.Printf("[WARN] Failed to follow regional upstream: %s", err)
+				monitorTickFailures.Inc("upstream")
+				continue
+			}
+
+			wasEnabled = enabled
+		}
+	}
+}
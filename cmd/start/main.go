@@ -109,10 +109,16 @@ func main() {
 		supervisor.WithRestart(0, 5*time.Second),
 	)
 
+	// The exporter verifies the member's certificate once one has been issued.
+	sslParams := "sslmode=disable"
+	if flypg.TLSEnabled() {
+		sslParams = fmt.Sprintf("sslmode=verify-full&sslrootcert=%s", flypg.CACertPath())
+	}
+
 	exporterEnv := map[string]string{
-		"DATA_SOURCE_URI":                     fmt.Sprintf("[%s]:%d/postgres?sslmode=disable", node.PrivateIP, node.Port),
+		"DATA_SOURCE_URI":                     fmt.Sprintf("[%s]:%d/postgres?%s", node.PrivateIP, node.Port, sslParams),
 		"DATA_SOURCE_USER":                    node.SUCredentials.Username,
-		"DATA_SOURCE_PASS":                    node.SUCredentials.Password,
+		"DATA_SOURCE_PASS_FILE":               flypg.ExporterPasswordPath(),
 		"PG_EXPORTER_EXCLUDE_DATABASE":        "template0,template1",
 		"PG_EXPORTER_AUTO_DISCOVER_DATABASES": "true",
 		"PG_EXPORTER_EXTEND_QUERY_PATH":       "/fly/queries.yaml",
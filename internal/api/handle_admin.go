@@ -1,8 +1,10 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"maps"
 	"net/http"
 	"os"
@@ -11,6 +13,7 @@ import (
 	"github.com/fly-apps/postgres-flex/internal/flypg"
 	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
 	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+	"github.com/jackc/pgx/v5"
 	"golang.org/x/exp/slices"
 )
 
@@ -106,6 +109,9 @@ func handleRole(w http.ResponseWriter, r *http.Request) {
 		alteredRole = "primary"
 	case flypg.StandbyRoleName:
 		alteredRole = "replica"
+		if node.RepMgr.Delayed {
+			alteredRole = flypg.DelayedRoleName
+		}
 	default:
 		alteredRole = "unknown"
 	}
@@ -118,6 +124,8 @@ func handleRole(w http.ResponseWriter, r *http.Request) {
 type SettingsUpdate struct {
 	Message         string `json:"message"`
 	RestartRequired bool   `json:"restart_required"`
+	// Settings holds the resulting user settings, with secrets redacted.
+	Settings flypg.ConfigMap `json:"settings,omitempty"`
 }
 
 func handleUpdatePostgresSettings(w http.ResponseWriter, r *http.Request) {
@@ -134,7 +142,7 @@ func handleUpdatePostgresSettings(w http.ResponseWriter, r *http.Request) {
 	}
 	defer func() { _ = conn.Close(r.Context()) }()
 
-	consul, err := state.NewStore()
+	store, err := state.NewStore()
 	if err != nil {
 		renderErr(w, err)
 		return
@@ -163,17 +171,10 @@ func handleUpdatePostgresSettings(w http.ResponseWriter, r *http.Request) {
 
 	node.PGConfig.SetUserConfig(cfg)
 
-	var requiresRestart []string
-
-	for k := range cfg {
-		restart, err := admin.SettingRequiresRestart(r.Context(), conn, k)
-		if err != nil {
-			renderErr(w, err)
-			return
-		}
-		if restart {
-			requiresRestart = append(requiresRestart, k)
-		}
+	requiresRestart, err := settingsRequiringRestart(r.Context(), conn, cfg)
+	if err != nil {
+		renderErr(w, err)
+		return
 	}
 
 	res := &Response{Result: SettingsUpdate{
@@ -188,7 +189,7 @@ func handleUpdatePostgresSettings(w http.ResponseWriter, r *http.Request) {
 		}}
 	}
 
-	err = flypg.PushUserConfig(&node.PGConfig, consul)
+	err = flypg.PushUserConfig(&node.PGConfig, store, requestAuthor(r))
 	if err != nil {
 		renderErr(w, err)
 		return
@@ -197,6 +198,22 @@ func handleUpdatePostgresSettings(w http.ResponseWriter, r *http.Request) {
 	renderJSON(w, res, http.StatusOK)
 }
 
+func settingsRequiringRestart(ctx context.Context, conn *pgx.Conn, cfg flypg.ConfigMap) ([]string, error) {
+	var requiresRestart []string
+
+	for k := range cfg {
+		restart, err := admin.SettingRequiresRestart(ctx, conn, k)
+		if err != nil {
+			return nil, err
+		}
+		if restart {
+			requiresRestart = append(requiresRestart, k)
+		}
+	}
+
+	return requiresRestart, nil
+}
+
 func handleApplyConfig(w http.ResponseWriter, r *http.Request) {
 	node, err := flypg.NewNode()
 	if err != nil {
@@ -211,18 +228,23 @@ func handleApplyConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	defer func() { _ = conn.Close(r.Context()) }()
 
-	consul, err := state.NewStore()
+	store, err := state.NewStore()
 	if err != nil {
 		renderErr(w, err)
 		return
 	}
 
-	err = flypg.SyncUserConfig(&node.PGConfig, consul)
+	err = flypg.SyncUserConfig(&node.PGConfig, store)
 	if err != nil {
 		renderErr(w, err)
 		return
 	}
 
+	if err := flypg.SyncUserConfig(&node.FlyConfig, store); err != nil {
+		renderErr(w, err)
+		return
+	}
+
 	err = admin.ReloadPostgresConfig(r.Context(), conn)
 	if err != nil {
 		renderErr(w, err)
@@ -376,7 +398,7 @@ func handleUpdateBarmanSettings(w http.ResponseWriter, r *http.Request) {
 
 	barman.SetUserConfig(cfg)
 
-	if err := flypg.PushUserConfig(barman, store); err != nil {
+	if err := flypg.PushUserConfig(barman, store, requestAuthor(r)); err != nil {
 		renderErr(w, err)
 		return
 	}
@@ -393,3 +415,82 @@ func handleUpdateBarmanSettings(w http.ResponseWriter, r *http.Request) {
 
 	renderJSON(w, res, http.StatusOK)
 }
+
+// mergeFlyPGSettings validates the requested changes and merges them into the current settings.
+func mergeFlyPGSettings(fc *flypg.FlyPGConfig, current flypg.ConfigMap, body io.Reader) (flypg.ConfigMap, error) {
+	var requestedChanges map[string]any
+	if err := json.NewDecoder(body).Decode(&requestedChanges); err != nil {
+		return nil, fmt.Errorf("invalid request: %s", err)
+	}
+
+	if err := fc.Validate(requestedChanges); err != nil {
+		return nil, err
+	}
+
+	maps.Copy(current, requestedChanges)
+
+	return current, nil
+}
+
+func handleViewFlyPGSettings(w http.ResponseWriter, _ *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	all, err := node.FlyConfig.CurrentConfig()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	resp := &Response{Result: flypg.RedactConfig(all)}
+	renderJSON(w, resp, http.StatusOK)
+}
+
+func handleUpdateFlyPGSettings(w http.ResponseWriter, r *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	store, err := state.NewStore()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	cfg, err := flypg.ReadFromFile(node.FlyConfig.UserConfigFile())
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	cfg, err = mergeFlyPGSettings(&node.FlyConfig, cfg, r.Body)
+	if err != nil {
+		renderJSON(w, errRes{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	node.FlyConfig.SetUserConfig(cfg)
+
+	if err := flypg.PushUserConfig(&node.FlyConfig, store, requestAuthor(r)); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	if err := flypg.SyncUserConfig(&node.FlyConfig, store); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	res := &Response{Result: SettingsUpdate{
+		Message:         "Updated. Run a cluster-wide apply to propagate the change to other members",
+		RestartRequired: false,
+		Settings:        flypg.RedactConfig(cfg),
+	}}
+
+	renderJSON(w, res, http.StatusOK)
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+func TestMergeFlyPGSettings(t *testing.T) {
+	fc := flypg.FlyPGConfig{}
+
+	t.Run("json numbers", func(t *testing.T) {
+		current := flypg.ConfigMap{"replicationLagTimeThreshold": "10m"}
+
+		body := `{"replicationLagBytesThreshold": 104857600, "failoverLagBytesThreshold": 16777216}`
+		cfg, err := mergeFlyPGSettings(&fc, current, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg["replicationLagBytesThreshold"] != float64(104857600) {
+			t.Fatalf("expected replicationLagBytesThreshold to be merged, got %v", cfg["replicationLagBytesThreshold"])
+		}
+
+		if cfg["failoverLagBytesThreshold"] != float64(16777216) {
+			t.Fatalf("expected failoverLagBytesThreshold to be merged, got %v", cfg["failoverLagBytesThreshold"])
+		}
+
+		if cfg["replicationLagTimeThreshold"] != "10m" {
+			t.Fatalf("expected existing settings to be kept, got %v", cfg["replicationLagTimeThreshold"])
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, body := range []string{
+			`{"replicationLagBytesThreshold": 1.5}`,
+			`{"failoverLagBytesThreshold": -1}`,
+			`{"synchronousReplicas": -2}`,
+			`{"unknownSetting": 1}`,
+			`{"replicationLagBytesThreshold":`,
+		} {
+			current := flypg.ConfigMap{"replicationLagBytesThreshold": float64(1024)}
+
+			if _, err := mergeFlyPGSettings(&fc, current, strings.NewReader(body)); err == nil {
+				t.Fatalf("expected %s to be rejected", body)
+			}
+
+			if current["replicationLagBytesThreshold"] != float64(1024) {
+				t.Fatalf("expected %s to leave the settings untouched", body)
+			}
+		}
+	})
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+func handleClusterStatus(w http.ResponseWriter, r *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	status, err := flypg.CollectClusterStatus(r.Context(), node)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	res := &Response{Result: status}
+	renderJSON(w, res, http.StatusOK)
+}
+
+func handleMemberState(w http.ResponseWriter, _ *http.Request) {
+	res := &Response{Result: flypg.CurrentMemberState()}
+	renderJSON(w, res, http.StatusOK)
+}
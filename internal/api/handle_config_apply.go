@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+// handleClusterApplyConfig kicks off a cluster-wide config apply. The operation is coordinated
+// by the primary, so requests received by other members are forwarded.
+func handleClusterApplyConfig(w http.ResponseWriter, r *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	if forwardToPrimary(w, r, node, flypg.ClusterApplyConfigEndpoint) {
+		return
+	}
+
+	store, err := state.NewStore()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	op, err := flypg.StartClusterConfigApply(store, requestAuthor(r))
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	go func() {
+		if err := flypg.RunClusterConfigApply(context.Background(), node, store, op); err != nil {
+			log.Printf("[ERROR] Cluster config apply %s failed: %s", op.ID, err)
+			return
+		}
+
+		log.Printf("Cluster config apply %s completed", op.ID)
+	}()
+
+	res := &Response{Result: op}
+	renderJSON(w, res, http.StatusAccepted)
+}
+
+func handleClusterApplyStatus(w http.ResponseWriter, _ *http.Request) {
+	store, err := state.NewStore()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	op, err := flypg.ClusterConfigApplyStatus(store)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	res := &Response{Result: op}
+	renderJSON(w, res, http.StatusOK)
+}
+
+func handleRestartPostgres(w http.ResponseWriter, r *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	if err := flypg.RestartPostgres(r.Context(), node.DataDir); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	res := &Response{Result: true}
+	renderJSON(w, res, http.StatusOK)
+}
+
+// forwardToPrimary relays the request to the primary when the local member is not the primary.
+// Returns true when the request has been handled.
+func forwardToPrimary(w http.ResponseWriter, r *http.Request, node *flypg.Node, target string) bool {
+	conn, err := node.RepMgr.NewLocalConnection(r.Context())
+	if err != nil {
+		renderErr(w, err)
+		return true
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	member, err := node.RepMgr.Member(r.Context(), conn)
+	if err != nil {
+		renderErr(w, err)
+		return true
+	}
+
+	if member.Role == flypg.PrimaryRoleName {
+		return false
+	}
+
+	primary, err := node.RepMgr.PrimaryMember(r.Context(), conn)
+	if err != nil {
+		renderErr(w, fmt.Errorf("failed to resolve primary: %s", err))
+		return true
+	}
+
+	forwardRequest(w, r, primary.Hostname, target)
+	return true
+}
+
+// forwardRequest relays the request to the specified member and writes back its response.
+func forwardRequest(w http.ResponseWriter, r *http.Request, hostname, target string) {
+	endpoint := fmt.Sprintf("http://%s:%d/%s", hostname, Port, target)
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, endpoint, r.Body)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+	req.Header = r.Header.Clone()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		renderErr(w, fmt.Errorf("failed to forward request to %s: %s", hostname, err))
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.Printf("failed to write forwarded response: %s", err)
+	}
+}
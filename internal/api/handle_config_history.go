@@ -0,0 +1,198 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	postgresComponent = "postgres"
+	barmanComponent   = "barman"
+	flypgComponent    = "flypg"
+
+	// AuthorHeader identifies who is responsible for a settings change.
+	AuthorHeader = "X-Flypg-Author"
+)
+
+func handleConfigHistory(w http.ResponseWriter, r *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	store, err := state.NewStore()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	cfg, err := resolveComponentConfig(node, store, chi.URLParam(r, "component"))
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	history, err := flypg.ConfigHistory(cfg, store)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	res := &Response{Result: history}
+	renderJSON(w, res, http.StatusOK)
+}
+
+func handleConfigRollback(w http.ResponseWriter, r *http.Request) {
+	component := chi.URLParam(r, "component")
+
+	rev, err := strconv.Atoi(chi.URLParam(r, "rev"))
+	if err != nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid revision: %s", err)}, http.StatusBadRequest)
+		return
+	}
+
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	store, err := state.NewStore()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	cfg, err := resolveComponentConfig(node, store, component)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	revision, err := flypg.FindConfigRevision(cfg, store, rev)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	var res *Response
+
+	// Rollbacks go through the same validations as a regular settings update.
+	switch component {
+	case postgresComponent:
+		conn, err := localConnection(r.Context(), "postgres")
+		if err != nil {
+			renderErr(w, err)
+			return
+		}
+		defer func() { _ = conn.Close(r.Context()) }()
+
+		validated, err := node.PGConfig.Validate(r.Context(), conn, revision.Config)
+		if err != nil {
+			renderErr(w, fmt.Errorf("revision %d failed validation: %s", rev, err))
+			return
+		}
+		revision.Config = validated
+
+		requiresRestart, err := settingsRequiringRestart(r.Context(), conn, validated)
+		if err != nil {
+			renderErr(w, err)
+			return
+		}
+
+		res = &Response{Result: SettingsUpdate{
+			Message:         fmt.Sprintf("Rolled back to revision %d", rev),
+			RestartRequired: false,
+		}}
+
+		if len(requiresRestart) > 0 {
+			res = &Response{Result: SettingsUpdate{
+				Message:         fmt.Sprintf("Rolled back to revision %d, but settings %s need a restart to apply", rev, strings.Join(requiresRestart, ", ")),
+				RestartRequired: true,
+			}}
+		}
+	case flypgComponent:
+		if err := node.FlyConfig.Validate(revision.Config); err != nil {
+			renderErr(w, fmt.Errorf("revision %d failed validation: %s", rev, err))
+			return
+		}
+
+		res = &Response{Result: SettingsUpdate{
+			Message:         fmt.Sprintf("Rolled back to revision %d", rev),
+			RestartRequired: false,
+		}}
+	case barmanComponent:
+		barman := cfg.(*flypg.BarmanConfig)
+		if err := barman.Validate(revision.Config); err != nil {
+			renderErr(w, fmt.Errorf("revision %d failed validation: %s", rev, err))
+			return
+		}
+
+		res = &Response{Result: SettingsUpdate{
+			Message:         fmt.Sprintf("Rolled back to revision %d", rev),
+			RestartRequired: true,
+		}}
+	default:
+		renderJSON(w, errRes{Error: fmt.Sprintf("%s settings do not support rollbacks", component)}, http.StatusBadRequest)
+		return
+	}
+
+	if err := flypg.RollbackUserConfig(cfg, store, revision, requestAuthor(r)); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	if component == barmanComponent || component == flypgComponent {
+		if err := flypg.SyncUserConfig(cfg, store); err != nil {
+			renderErr(w, err)
+			return
+		}
+	}
+
+	renderJSON(w, res, http.StatusOK)
+}
+
+// resolveComponentConfig resolves the components whose settings are versioned. Repmgr settings
+// can't be changed through the API, so they have no history to restore.
+func resolveComponentConfig(node *flypg.Node, store state.StateStore, component string) (flypg.Config, error) {
+	switch component {
+	case postgresComponent:
+		return &node.PGConfig, nil
+	case flypgComponent:
+		return &node.FlyConfig, nil
+	case barmanComponent:
+		if os.Getenv("S3_ARCHIVE_CONFIG") == "" {
+			return nil, fmt.Errorf("barman is not enabled")
+		}
+
+		barman, err := flypg.NewBarman(store, os.Getenv("S3_ARCHIVE_CONFIG"), flypg.DefaultAuthProfile)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := barman.LoadConfig(flypg.DefaultBarmanConfigDir); err != nil {
+			return nil, err
+		}
+
+		return barman.BarmanConfig, nil
+	default:
+		return nil, fmt.Errorf("unknown settings component %q", component)
+	}
+}
+
+// requestAuthor identifies who issued the request, falling back to the remote address.
+func requestAuthor(r *http.Request) string {
+	if author := r.Header.Get(AuthorHeader); author != "" {
+		return author
+	}
+
+	return r.RemoteAddr
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+type credentialRotationRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func handleRotateCredential(w http.ResponseWriter, r *http.Request) {
+	var input credentialRotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	result, err := flypg.RotateCredential(r.Context(), node, input.Username, input.Password, requestAuthor(r))
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: result}, http.StatusOK)
+}
+
+func handleSyncCredentials(w http.ResponseWriter, r *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	if err := flypg.SyncCredentials(r.Context(), node); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: true}, http.StatusOK)
+}
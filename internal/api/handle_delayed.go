@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+func handleDelayedStatus(w http.ResponseWriter, r *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	status, err := flypg.GetDelayedStatus(r.Context(), node)
+	if err != nil {
+		renderDelayedErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: status}, http.StatusOK)
+}
+
+func handlePauseDelayedReplay(w http.ResponseWriter, r *http.Request) {
+	setDelayedReplayPaused(w, r, true)
+}
+
+func handleResumeDelayedReplay(w http.ResponseWriter, r *http.Request) {
+	setDelayedReplayPaused(w, r, false)
+}
+
+func setDelayedReplayPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	status, err := flypg.SetDelayedReplayPaused(r.Context(), node, paused)
+	if err != nil {
+		renderDelayedErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: status}, http.StatusOK)
+}
+
+func handlePromoteDetachedCopy(w http.ResponseWriter, r *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	// Copying the data directory can take a while and shouldn't be abandoned half way through.
+	dc, err := flypg.PromoteDetachedCopy(context.WithoutCancel(r.Context()), node)
+	if err != nil {
+		renderDelayedErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: dc}, http.StatusOK)
+}
+
+func handleRemoveDetachedCopy(w http.ResponseWriter, r *http.Request) {
+	if err := flypg.RemoveDetachedCopy(r.Context()); err != nil {
+		renderDelayedErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: true}, http.StatusOK)
+}
+
+func renderDelayedErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, flypg.ErrNotDelayed), errors.Is(err, flypg.ErrDetachedCopyExists), errors.Is(err, flypg.ErrInsufficientSpace):
+		renderJSON(w, errRes{Error: err.Error()}, http.StatusConflict)
+	case errors.Is(err, flypg.ErrDetachedCopyMissing):
+		renderJSON(w, errRes{Error: err.Error()}, http.StatusNotFound)
+	default:
+		renderErr(w, err)
+	}
+}
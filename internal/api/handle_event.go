@@ -1,10 +1,7 @@
 package api
 
 import (
-	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
 	"log"
 	"net/http"
 
@@ -18,12 +15,6 @@ type EventRequest struct {
 	Details string `json:"details"`
 }
 
-const (
-	childNodeDisconnect = "child_node_disconnect"
-	childNodeReconnect  = "child_node_reconnect"
-	childNodeNewConnect = "child_node_new_connect"
-)
-
 func handleEvent(w http.ResponseWriter, r *http.Request) {
 	var event EventRequest
 	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
@@ -33,49 +24,42 @@ func handleEvent(w http.ResponseWriter, r *http.Request) {
 	}
 	defer func() { _ = r.Body.Close() }()
 
-	if !event.Success {
-		errMsg := fmt.Sprintf("[ERROR] Event %q failed: %s", event.Name, event.Details)
-		log.Println(errMsg)
-		renderErr(w, errors.New(errMsg))
+	log.Printf("Processing event: %q \n", event.Name)
 
+	node, err := flypg.NewNode()
+	if err != nil {
+		log.Printf("[ERROR] Failed to initialize node: %s\n", err)
+		renderErr(w, err)
 		return
 	}
 
-	if err := processEvent(r.Context(), event); err != nil {
+	e := flypg.RepmgrEvent{
+		Name:    event.Name,
+		NodeID:  event.NodeID,
+		Success: event.Success,
+		Details: event.Details,
+	}
+
+	if err := flypg.ProcessRepmgrEvent(r.Context(), node, e); err != nil {
 		log.Printf("[ERROR] Failed to process event: %s\n", err)
 		renderErr(w, err)
 		return
 	}
 }
 
-func processEvent(ctx context.Context, event EventRequest) error {
-	log.Printf("Processing event: %q \n", event.Name)
-	node, err := flypg.NewNode()
+func handleEventHistory(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryLimit(r)
 	if err != nil {
-		return fmt.Errorf("failed to initialize node: %s", err)
+		renderJSON(w, errRes{Error: err.Error()}, http.StatusBadRequest)
+		return
 	}
 
-	switch event.Name {
-	case childNodeDisconnect, childNodeReconnect, childNodeNewConnect:
-		conn, err := node.RepMgr.NewLocalConnection(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to open local connection: %s", err)
-		}
-		defer func() { _ = conn.Close(ctx) }()
-
-		member, err := node.RepMgr.Member(ctx, conn)
-		if err != nil {
-			return fmt.Errorf("failed to resolve member: %s", err)
-		}
-
-		if member.Role != flypg.PrimaryRoleName {
-			return nil
-		}
-
-		if err := flypg.EvaluateClusterState(ctx, conn, node); err != nil {
-			return fmt.Errorf("failed to evaluate cluster state: %s", err)
-		}
+	events, err := flypg.RepmgrEventHistory(limit, r.URL.Query().Get("name"))
+	if err != nil {
+		renderErr(w, err)
+		return
 	}
 
-	return nil
+	res := &Response{Result: events}
+	renderJSON(w, res, http.StatusOK)
 }
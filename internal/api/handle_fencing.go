@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+// queryLimit parses the optional `limit` query parameter. A limit of 0 means no limit.
+func queryLimit(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("invalid limit: %s", v)
+	}
+
+	return limit, nil
+}
+
+func handleFencingHistory(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryLimit(r)
+	if err != nil {
+		renderJSON(w, errRes{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	events, err := flypg.FencingHistory(limit)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	res := &Response{Result: events}
+	renderJSON(w, res, http.StatusOK)
+}
+
+func handleFailoverValidations(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryLimit(r)
+	if err != nil {
+		renderJSON(w, errRes{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	validations, err := flypg.FailoverValidationHistory(limit)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	res := &Response{Result: validations}
+	renderJSON(w, res, http.StatusOK)
+}
+
+type fencingResolveRequest struct {
+	Action  string `json:"action"`
+	Primary string `json:"primary"`
+	DryRun  bool   `json:"dry_run"`
+}
+
+func handleFencingResolve(w http.ResponseWriter, r *http.Request) {
+	var req fencingResolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	// The recovery shouldn't be interrupted half way through if the client goes away.
+	ctx := context.WithoutCancel(r.Context())
+
+	res, err := flypg.ResolveFencing(ctx, node, req.Action, req.Primary, req.DryRun)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: res}, http.StatusOK)
+}
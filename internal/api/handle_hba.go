@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+	"github.com/go-chi/chi/v5"
+)
+
+type hbaRuleRequest struct {
+	flypg.HBAEntry
+	Comment string `json:"comment"`
+}
+
+// hbaChangeResponse reports the outcome of a rule change, along with the reload result of
+// every member.
+type hbaChangeResponse struct {
+	Rule    *flypg.HBARule          `json:"rule,omitempty"`
+	Members []flypg.HBAReloadResult `json:"members"`
+}
+
+func handleListHBARules(w http.ResponseWriter, r *http.Request) {
+	store, err := state.NewStore()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	rules, err := flypg.HBARules(store)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: rules}, http.StatusOK)
+}
+
+func handleAddHBARule(w http.ResponseWriter, r *http.Request) {
+	var input hbaRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	store, err := state.NewStore()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	rule, err := node.AddHBARule(store, flypg.HBARule{
+		HBAEntry: input.HBAEntry,
+		Comment:  input.Comment,
+		Author:   requestAuthor(r),
+	})
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	members, err := flypg.BroadcastHBAReload(r.Context(), node)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: hbaChangeResponse{Rule: rule, Members: members}}, http.StatusOK)
+}
+
+func handleRemoveHBARule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	store, err := state.NewStore()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	if err := flypg.RemoveHBARule(store, id); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	members, err := flypg.BroadcastHBAReload(r.Context(), node)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: hbaChangeResponse{Members: members}}, http.StatusOK)
+}
+
+func handleReloadHBA(w http.ResponseWriter, r *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	if err := flypg.ReloadHBA(r.Context(), node); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: true}, http.StatusOK)
+}
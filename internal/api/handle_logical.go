@@ -0,0 +1,391 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Logical slots are synchronized to standbys starting with PG17, which allows subscriptions to
+// carry on following a failover of the publisher.
+const failoverSlotMinVersion = 170000
+
+type publicationRequest struct {
+	Name       string   `json:"name"`
+	Database   string   `json:"database"`
+	Tables     []string `json:"tables"`
+	Operations []string `json:"operations"`
+}
+
+type createSubscriptionRequest struct {
+	Name         string   `json:"name"`
+	Database     string   `json:"database"`
+	Connection   string   `json:"connection"`
+	Publications []string `json:"publications"`
+	CopyData     *bool    `json:"copy_data"`
+	Enabled      *bool    `json:"enabled"`
+	Failover     *bool    `json:"failover"`
+}
+
+type updateSubscriptionRequest struct {
+	Name         string   `json:"name"`
+	Database     string   `json:"database"`
+	Enabled      *bool    `json:"enabled"`
+	Publications []string `json:"publications"`
+}
+
+// publicationDatabase resolves the database a publication request targets, as publications are
+// scoped to a single database.
+func publicationDatabase(r *http.Request) string {
+	if db := r.URL.Query().Get("database"); db != "" {
+		return db
+	}
+
+	return "postgres"
+}
+
+func handleListPublications(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	conn, err := localConnection(ctx, publicationDatabase(r))
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	pubs, err := admin.ListPublications(ctx, conn)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: pubs}, http.StatusOK)
+}
+
+func handleGetPublication(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx  = r.Context()
+		name = chi.URLParam(r, "name")
+	)
+
+	conn, err := localConnection(ctx, publicationDatabase(r))
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	pub, err := admin.FindPublication(ctx, conn, name)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	if pub == nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("publication %q not found", name)}, http.StatusNotFound)
+		return
+	}
+
+	renderJSON(w, &Response{Result: pub}, http.StatusOK)
+}
+
+func handleCreatePublication(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var input publicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if input.Name == "" || input.Database == "" {
+		renderJSON(w, errRes{Error: "name and database are required"}, http.StatusBadRequest)
+		return
+	}
+
+	conn, err := localConnection(ctx, input.Database)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	if err := admin.CreatePublication(ctx, conn, input.Name, input.Tables, input.Operations); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: true}, http.StatusOK)
+}
+
+func handleUpdatePublication(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var input publicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if input.Name == "" || input.Database == "" || len(input.Tables) == 0 {
+		renderJSON(w, errRes{Error: "name, database and tables are required"}, http.StatusBadRequest)
+		return
+	}
+
+	conn, err := localConnection(ctx, input.Database)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	if err := admin.SetPublicationTables(ctx, conn, input.Name, input.Tables); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: true}, http.StatusOK)
+}
+
+func handleDeletePublication(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx  = r.Context()
+		name = chi.URLParam(r, "name")
+	)
+
+	conn, err := localConnection(ctx, publicationDatabase(r))
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	if err := admin.DropPublication(ctx, conn, name); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: true}, http.StatusOK)
+}
+
+func handleListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx      = r.Context()
+		database = r.URL.Query().Get("database")
+	)
+
+	conn, err := localConnection(ctx, publicationDatabase(r))
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	subs, err := admin.ListSubscriptions(ctx, conn)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: subscriptionsWithin(subs, database)}, http.StatusOK)
+}
+
+// subscriptionsWithin filters the subscriptions down to the specified database. Every
+// subscription is returned when no database is specified.
+func subscriptionsWithin(subs []admin.Subscription, database string) []admin.Subscription {
+	if database == "" {
+		return subs
+	}
+
+	filtered := []admin.Subscription{}
+	for _, sub := range subs {
+		if sub.Database == database {
+			filtered = append(filtered, sub)
+		}
+	}
+
+	return filtered
+}
+
+func handleGetSubscription(w http.ResponseWriter, r *http.Request) {
+	var (
+		name     = chi.URLParam(r, "name")
+		database = r.URL.Query().Get("database")
+	)
+
+	conn, sub, ok := subscriptionConnection(w, r, database, name)
+	if !ok {
+		return
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	renderJSON(w, &Response{Result: sub}, http.StatusOK)
+}
+
+func handleCreateSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var input createSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if input.Name == "" || input.Database == "" || input.Connection == "" || len(input.Publications) == 0 {
+		renderJSON(w, errRes{Error: "name, database, connection and publications are required"}, http.StatusBadRequest)
+		return
+	}
+
+	conn, err := localConnection(ctx, input.Database)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	opts := admin.SubscriptionOptions{
+		CopyData: input.CopyData == nil || *input.CopyData,
+		Enabled:  input.Enabled == nil || *input.Enabled,
+	}
+
+	// Default to failover-enabled slots wherever they are supported, so the subscription survives
+	// a failover of the publisher.
+	if input.Failover != nil {
+		opts.Failover = *input.Failover
+	} else {
+		version, err := admin.ServerVersionNum(ctx, conn)
+		if err != nil {
+			renderErr(w, err)
+			return
+		}
+		opts.Failover = version >= failoverSlotMinVersion
+	}
+
+	if err := admin.CreateSubscription(ctx, conn, input.Name, input.Connection, input.Publications, opts); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: true}, http.StatusOK)
+}
+
+func handleUpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var input updateSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if input.Name == "" || (input.Enabled == nil && len(input.Publications) == 0) {
+		renderJSON(w, errRes{Error: "name and either enabled or publications are required"}, http.StatusBadRequest)
+		return
+	}
+
+	conn, _, ok := subscriptionConnection(w, r, input.Database, input.Name)
+	if !ok {
+		return
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	if len(input.Publications) > 0 {
+		if err := admin.SetSubscriptionPublications(ctx, conn, input.Name, input.Publications); err != nil {
+			renderErr(w, err)
+			return
+		}
+	}
+
+	if input.Enabled != nil {
+		if err := admin.SetSubscriptionEnabled(ctx, conn, input.Name, *input.Enabled); err != nil {
+			renderErr(w, err)
+			return
+		}
+	}
+
+	renderJSON(w, &Response{Result: true}, http.StatusOK)
+}
+
+func handleDeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx      = r.Context()
+		name     = chi.URLParam(r, "name")
+		database = r.URL.Query().Get("database")
+		detach   = r.URL.Query().Get("detach") == "true"
+	)
+
+	conn, _, ok := subscriptionConnection(w, r, database, name)
+	if !ok {
+		return
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	if err := admin.DropSubscription(ctx, conn, name, detach); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: true}, http.StatusOK)
+}
+
+// subscriptionConnection opens a connection to the database the subscription lives in, as
+// subscriptions can only be altered from within their own database. Subscription names are only
+// unique per database, so the database is required. A response is rendered and false is
+// returned on failure.
+func subscriptionConnection(w http.ResponseWriter, r *http.Request, database, name string) (*pgx.Conn, *admin.Subscription, bool) {
+	ctx := r.Context()
+
+	if name == "" || database == "" {
+		renderJSON(w, errRes{Error: "name and database are required"}, http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	conn, err := localConnection(ctx, database)
+	if err != nil {
+		renderErr(w, err)
+		return nil, nil, false
+	}
+
+	sub, err := admin.FindSubscription(ctx, conn, database, name)
+	if err != nil {
+		_ = conn.Close(ctx)
+		renderErr(w, err)
+		return nil, nil, false
+	}
+
+	if sub == nil {
+		_ = conn.Close(ctx)
+		renderJSON(w, errRes{Error: fmt.Sprintf("subscription %q not found in database %q", name, database)}, http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	return conn, sub, true
+}
+
+func handleListReplicationSlots(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	conn, err := localConnection(ctx, "postgres")
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	slots, err := admin.ListReplicationSlots(ctx, conn)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: slots}, http.StatusOK)
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+)
+
+func TestSubscriptionsWithin(t *testing.T) {
+	subs := []admin.Subscription{
+		{Name: "orders", Database: "app"},
+		{Name: "events", Database: "analytics"},
+	}
+
+	if all := subscriptionsWithin(subs, ""); len(all) != 2 {
+		t.Fatalf("expected every subscription without a database, got %+v", all)
+	}
+
+	filtered := subscriptionsWithin(subs, "analytics")
+	if len(filtered) != 1 || filtered[0].Name != "events" {
+		t.Fatalf("expected only the analytics subscription, got %+v", filtered)
+	}
+
+	if none := subscriptionsWithin(subs, "missing"); none == nil || len(none) != 0 {
+		t.Fatalf("expected an empty list, got %+v", none)
+	}
+}
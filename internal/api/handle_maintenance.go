@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+func handleEnableMaintenance(w http.ResponseWriter, r *http.Request) {
+	setMaintenance(w, r, true)
+}
+
+func handleDisableMaintenance(w http.ResponseWriter, r *http.Request) {
+	setMaintenance(w, r, false)
+}
+
+func setMaintenance(w http.ResponseWriter, r *http.Request, enabled bool) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	// Don't leave the cluster half paused if the client goes away.
+	ctx := context.WithoutCancel(r.Context())
+
+	status, err := flypg.SetMaintenance(ctx, node, enabled, requestAuthor(r))
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	res := &Response{Result: status}
+	renderJSON(w, res, http.StatusOK)
+}
+
+func handleMaintenanceStatus(w http.ResponseWriter, r *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	status, err := flypg.GetMaintenanceStatus(r.Context(), node)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	res := &Response{Result: status}
+	renderJSON(w, res, http.StatusOK)
+}
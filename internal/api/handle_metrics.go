@@ -0,0 +1,20 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/fly-apps/postgres-flex/internal/metrics"
+)
+
+func handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	var buf bytes.Buffer
+	if err := metrics.WriteText(&buf); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
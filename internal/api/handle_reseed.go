@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+type reseedRequest struct {
+	ForceClone bool `json:"force_clone"`
+	DryRun     bool `json:"dry_run"`
+}
+
+func handleReseed(w http.ResponseWriter, r *http.Request) {
+	var req reseedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	// The reseed shouldn't be interrupted half way through if the client goes away.
+	ctx := context.WithoutCancel(r.Context())
+
+	reason := fmt.Sprintf("requested by %s", requestAuthor(r))
+	if diagnosis, err := flypg.DiagnoseReseed(ctx, node); err == nil && diagnosis != "" {
+		reason = fmt.Sprintf("%s: %s", reason, diagnosis)
+	}
+
+	res, err := flypg.ReseedStandby(ctx, node, flypg.ReseedTriggerManual, reason, req.ForceClone, req.DryRun)
+	if err != nil {
+		if errors.Is(err, flypg.ErrReseedNotStandby) || errors.Is(err, flypg.ErrReseedInProgress) {
+			renderJSON(w, errRes{Error: err.Error()}, http.StatusConflict)
+			return
+		}
+
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: res}, http.StatusOK)
+}
+
+func handleReseedHistory(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryLimit(r)
+	if err != nil {
+		renderJSON(w, errRes{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	reseeds, err := flypg.ReseedHistory(limit)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: reseeds}, http.StatusOK)
+}
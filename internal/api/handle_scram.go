@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+type scramMigrationRequest struct {
+	Enforce bool `json:"enforce"`
+}
+
+func handleScramStatus(w http.ResponseWriter, r *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	status, err := flypg.GetScramStatus(r.Context(), node)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: status}, http.StatusOK)
+}
+
+func handleScramMigrate(w http.ResponseWriter, r *http.Request) {
+	var req scramMigrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	migration, err := flypg.MigrateToScram(r.Context(), node, req.Enforce)
+	if err != nil {
+		if errors.Is(err, flypg.ErrScramMigrationNotPrimary) || errors.Is(err, flypg.ErrMD5RolesRemaining) {
+			renderJSON(w, errRes{Error: err.Error()}, http.StatusConflict)
+			return
+		}
+
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: migration}, http.StatusOK)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+)
+
+type switchoverRequest struct {
+	To          string `json:"to"`
+	MaxLagBytes int64  `json:"max_lag_bytes,omitempty"`
+}
+
+// handleSwitchover performs a planned switchover to the requested standby. The switchover is
+// coordinated by the primary, so requests received by other members are forwarded.
+func handleSwitchover(w http.ResponseWriter, r *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	if forwardToPrimary(w, r, node, flypg.SwitchoverEndpoint) {
+		return
+	}
+
+	var input switchoverRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+
+	if input.To == "" {
+		renderJSON(w, errRes{Error: "a switchover target is required"}, http.StatusBadRequest)
+		return
+	}
+
+	maxLag := flypg.DefaultSwitchoverMaxLag
+	if input.MaxLagBytes > 0 {
+		maxLag = input.MaxLagBytes
+	}
+
+	result, err := flypg.PlannedSwitchover(r.Context(), node, input.To, maxLag)
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	res := &Response{Result: result}
+	renderJSON(w, res, http.StatusOK)
+}
+
+// handleStandbySwitchover promotes the local standby on behalf of the primary coordinating a
+// planned switchover. Operators should go through handleSwitchover instead.
+func handleStandbySwitchover(w http.ResponseWriter, r *http.Request) {
+	node, err := flypg.NewNode()
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	if err := flypg.PromoteSwitchoverCandidate(r.Context(), node); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	res := &Response{Result: true}
+	renderJSON(w, res, http.StatusOK)
+}
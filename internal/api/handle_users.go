@@ -1,12 +1,17 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 )
 
 func handleListUsers(w http.ResponseWriter, r *http.Request) {
@@ -58,6 +63,48 @@ func handleGetUser(w http.ResponseWriter, r *http.Request) {
 func handleCreateUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	var input createUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if input.Username == "" {
+		renderJSON(w, errRes{Error: "username is required"}, http.StatusBadRequest)
+		return
+	}
+
+	// A single database without an access level comes from a client that predates scoped users.
+	// The field was ignored back then, so it still is.
+	if input.Databases.single && input.Access == "" {
+		input.Databases.Names = nil
+	}
+
+	scoped := input.Access != "" || len(input.Databases.Names) > 0
+	if scoped {
+		if input.Superuser {
+			renderJSON(w, errRes{Error: "superusers can't be scoped to databases"}, http.StatusBadRequest)
+			return
+		}
+
+		if !input.Access.Valid() || len(input.Databases.Names) == 0 {
+			renderJSON(w, errRes{Error: "databases and an access of read-only or read-write are required for scoped users"}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if input.RestrictPublicConnect && !scoped {
+		renderJSON(w, errRes{Error: "restrict_public_connect is only supported for scoped users"}, http.StatusBadRequest)
+		return
+	}
+
+	validUntil, err := parseValidUntil(input.ValidUntil)
+	if err != nil {
+		renderJSON(w, errRes{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
 	conn, err := localConnection(ctx, "postgres")
 	if err != nil {
 		renderErr(w, err)
@@ -65,85 +112,365 @@ func handleCreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 	defer func() { _ = conn.Close(r.Context()) }()
 
-	var input createUserRequest
-	err = json.NewDecoder(r.Body).Decode(&input)
-	if err != nil {
+	opts := admin.RoleOptions{ConnectionLimit: input.ConnectionLimit, ValidUntil: validUntil}
+	if err := admin.CreateRole(ctx, conn, input.Username, input.Password, opts); err != nil {
 		renderErr(w, err)
 		return
 	}
-	defer func() { _ = r.Body.Close() }()
 
-	err = admin.CreateUser(ctx, conn, input.Username, input.Password)
+	// Grants span several databases, so they can't share a transaction. Undo any connect
+	// restrictions and drop the role instead of leaving a half-created user behind.
+	restrictions, err := setupUser(r, conn, input, scoped)
 	if err != nil {
+		for _, restriction := range restrictions {
+			if uErr := admin.UndoDatabaseConnectRestriction(ctx, conn, restriction); uErr != nil {
+				log.Printf("[WARN] Failed to restore PUBLIC connect on %s after a failed create: %s", restriction.Database, uErr)
+			}
+		}
+
+		if dErr := dropUser(ctx, conn, input.Username); dErr != nil {
+			log.Printf("[WARN] Failed to drop %s after a failed create: %s", input.Username, dErr)
+		}
+
 		renderErr(w, err)
 		return
 	}
 
+	res := &Response{
+		Result: true,
+	}
+
+	renderJSON(w, res, http.StatusOK)
+}
+
+// setupUser grants a newly created role its memberships and access. The connect restrictions
+// that have been applied are returned, even when an error occurs, so they can be undone.
+func setupUser(r *http.Request, conn *pgx.Conn, input createUserRequest, scoped bool) ([]admin.ConnectRestriction, error) {
+	ctx := r.Context()
+
+	for _, role := range input.MemberOf {
+		if err := admin.GrantRoleMembership(ctx, conn, role, input.Username); err != nil {
+			return nil, fmt.Errorf("failed to grant membership of %s: %s", role, err)
+		}
+	}
+
 	if input.Superuser {
-		err = admin.GrantSuperuser(ctx, conn, input.Username)
+		return nil, admin.GrantSuperuser(ctx, conn, input.Username)
+	}
+
+	databases, err := admin.ListDatabases(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %s", err)
+	}
+
+	if !scoped {
+		if err := admin.GrantAccess(ctx, conn, input.Username); err != nil {
+			return nil, err
+		}
+
+		// Databases restricted through restrict_public_connect no longer grant CONNECT through PUBLIC.
+		names := make([]string, 0, len(databases))
+		for _, database := range databases {
+			names = append(names, database.Name)
+		}
+
+		return nil, admin.GrantDatabaseConnect(ctx, conn, input.Username, names)
+	}
+
+	if err := grantUserAccess(r, input.Username, input.Access, input.Databases.Names, input.Schemas); err != nil {
+		return nil, err
+	}
+
+	if !input.RestrictPublicConnect {
+		return nil, nil
+	}
+
+	// PUBLIC is able to connect to every database by default, which would let the user into
+	// databases it hasn't been scoped to.
+	var restrictions []admin.ConnectRestriction
+	for _, database := range databases {
+		if slices.Contains(input.Databases.Names, database.Name) {
+			continue
+		}
+
+		restriction, err := admin.RestrictDatabaseConnect(ctx, conn, database.Name, input.Username)
 		if err != nil {
-			renderErr(w, err)
+			return restrictions, fmt.Errorf("failed to restrict connections to %s: %s", database.Name, err)
+		}
+
+		if restriction != nil {
+			restrictions = append(restrictions, *restriction)
+		}
+	}
+
+	return restrictions, nil
+}
+
+func handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var input updateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if input.Username == "" {
+		renderJSON(w, errRes{Error: "username is required"}, http.StatusBadRequest)
+		return
+	}
+
+	validUntil, err := parseValidUntil(input.ValidUntil)
+	if err != nil {
+		renderJSON(w, errRes{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	conn, err := localConnection(ctx, "postgres")
+	if err != nil {
+		renderErr(w, err)
+		return
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	opts := admin.RoleOptions{ConnectionLimit: input.ConnectionLimit, ValidUntil: validUntil}
+	if err := admin.AlterRole(ctx, conn, input.Username, opts); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	for _, role := range input.GrantRoles {
+		if err := admin.GrantRoleMembership(ctx, conn, role, input.Username); err != nil {
+			renderErr(w, fmt.Errorf("failed to grant membership of %s: %s", role, err))
 			return
 		}
-	} else {
-		err = admin.GrantAccess(ctx, conn, input.Username)
+	}
+
+	for _, role := range input.RevokeRoles {
+		if err := admin.RevokeRoleMembership(ctx, conn, role, input.Username); err != nil {
+			renderErr(w, fmt.Errorf("failed to revoke membership of %s: %s", role, err))
+			return
+		}
+	}
+
+	renderJSON(w, &Response{Result: true}, http.StatusOK)
+}
+
+func handleGrantUserAccess(w http.ResponseWriter, r *http.Request) {
+	var input userAccessRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if input.Username == "" || len(input.Databases) == 0 || !input.Access.Valid() {
+		renderJSON(w, errRes{Error: "username, databases and an access of read-only or read-write are required"}, http.StatusBadRequest)
+		return
+	}
+
+	if err := grantUserAccess(r, input.Username, input.Access, input.Databases, input.Schemas); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	renderJSON(w, &Response{Result: true}, http.StatusOK)
+}
+
+func handleRevokeUserAccess(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var input userAccessRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("invalid request: %s", err)}, http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if input.Username == "" || len(input.Databases) == 0 {
+		renderJSON(w, errRes{Error: "username and databases are required"}, http.StatusBadRequest)
+		return
+	}
+
+	for _, database := range input.Databases {
+		dbConn, err := localConnection(ctx, database)
 		if err != nil {
 			renderErr(w, err)
 			return
 		}
-	}
 
-	res := &Response{
-		Result: true,
+		err = admin.RevokeDatabaseAccess(ctx, dbConn, input.Username, database, schemasOrDefault(input.Schemas))
+		_ = dbConn.Close(ctx)
+		if err != nil {
+			renderErr(w, fmt.Errorf("failed to revoke access to %s: %s", database, err))
+			return
+		}
 	}
 
-	renderJSON(w, res, http.StatusOK)
+	renderJSON(w, &Response{Result: true}, http.StatusOK)
 }
 
-func handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+func handleUserGrants(w http.ResponseWriter, r *http.Request) {
 	var (
-		ctx  = r.Context()
-		name = chi.URLParam(r, "name")
+		ctx      = r.Context()
+		name     = chi.URLParam(r, "name")
+		database = r.URL.Query().Get("database")
 	)
 
-	conn, err := localConnection(r.Context(), "postgres")
+	conn, err := localConnection(ctx, "postgres")
 	if err != nil {
 		renderErr(w, err)
 		return
 	}
 	defer func() { _ = conn.Close(r.Context()) }()
 
-	databases, err := admin.ListDatabases(ctx, conn)
+	grants, err := admin.FindRoleGrants(ctx, conn, name)
 	if err != nil {
-		renderErr(w, fmt.Errorf("failed to list databases: %s", err))
+		renderErr(w, err)
 		return
 	}
 
-	for _, database := range databases {
-		dbConn, err := localConnection(r.Context(), database.Name)
+	if grants == nil {
+		renderJSON(w, errRes{Error: fmt.Sprintf("user %q not found", name)}, http.StatusNotFound)
+		return
+	}
+
+	// Schema privileges are local to each database, so they are resolved for every database the
+	// user is able to connect to, or only the one requested.
+	for i, db := range grants.Databases {
+		if database != "" && db.Database != database {
+			continue
+		}
+
+		if !slices.Contains(db.Privileges, "CONNECT") && database == "" {
+			continue
+		}
+
+		dbConn, err := localConnection(ctx, db.Database)
 		if err != nil {
 			renderErr(w, err)
 			return
 		}
-		defer func() { _ = dbConn.Close(r.Context()) }()
 
-		if err := admin.ReassignOwnership(ctx, dbConn, name, "postgres"); err != nil {
-			renderErr(w, fmt.Errorf("failed to reassign ownership: %s", err))
+		schemas, err := admin.ListSchemaGrants(ctx, dbConn, name)
+		_ = dbConn.Close(ctx)
+		if err != nil {
+			renderErr(w, fmt.Errorf("failed to resolve privileges within %s: %s", db.Database, err))
 			return
 		}
 
-		if err := admin.DropOwned(ctx, dbConn, name); err != nil {
-			renderErr(w, fmt.Errorf("failed to drop remaining objects: %s", err))
-			return
+		grants.Databases[i].Schemas = schemas
+	}
+
+	renderJSON(w, &Response{Result: grants}, http.StatusOK)
+}
+
+// grantUserAccess grants access to the specified schemas of every database, which defaults to
+// the public schema.
+func grantUserAccess(r *http.Request, username string, access admin.AccessLevel, databases, schemas []string) error {
+	ctx := r.Context()
+
+	for _, database := range databases {
+		dbConn, err := localConnection(ctx, database)
+		if err != nil {
+			return err
+		}
+
+		err = admin.GrantDatabaseAccess(ctx, dbConn, username, database, schemasOrDefault(schemas), access)
+		_ = dbConn.Close(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to grant access to %s: %s", database, err)
 		}
 	}
 
-	err = admin.DropRole(ctx, conn, name)
+	return nil
+}
+
+func schemasOrDefault(schemas []string) []string {
+	if len(schemas) == 0 {
+		return []string{"public"}
+	}
+
+	return schemas
+}
+
+// parseValidUntil parses an RFC 3339 timestamp. "infinity" removes the expiry.
+func parseValidUntil(value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	if *value == "infinity" {
+		return &time.Time{}, nil
+	}
+
+	validUntil, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, fmt.Errorf("valid_until must be an RFC 3339 timestamp or infinity: %s", err)
+	}
+
+	return &validUntil, nil
+}
+
+func handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx  = r.Context()
+		name = chi.URLParam(r, "name")
+	)
+
+	conn, err := localConnection(r.Context(), "postgres")
 	if err != nil {
-		renderErr(w, fmt.Errorf("failed to drop role: %s", err))
+		renderErr(w, err)
+		return
+	}
+	defer func() { _ = conn.Close(r.Context()) }()
+
+	if err := dropUser(ctx, conn, name); err != nil {
+		renderErr(w, err)
 		return
 	}
 
 	res := &Response{Result: true}
 	renderJSON(w, res, http.StatusOK)
 }
+
+// dropUser reassigns the objects the role owns to postgres and drops its privileges within every
+// database, after which the role itself is dropped.
+func dropUser(ctx context.Context, conn *pgx.Conn, name string) error {
+	databases, err := admin.ListDatabases(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to list databases: %s", err)
+	}
+
+	for _, database := range databases {
+		dbConn, err := localConnection(ctx, database.Name)
+		if err != nil {
+			return err
+		}
+
+		err = dropOwnedObjects(ctx, dbConn, name)
+		_ = dbConn.Close(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := admin.DropRole(ctx, conn, name); err != nil {
+		return fmt.Errorf("failed to drop role: %s", err)
+	}
+
+	return nil
+}
+
+func dropOwnedObjects(ctx context.Context, conn *pgx.Conn, name string) error {
+	if err := admin.ReassignOwnership(ctx, conn, name, "postgres"); err != nil {
+		return fmt.Errorf("failed to reassign ownership: %s", err)
+	}
+
+	if err := admin.DropOwned(ctx, conn, name); err != nil {
+		return fmt.Errorf("failed to drop remaining objects: %s", err)
+	}
+
+	return nil
+}
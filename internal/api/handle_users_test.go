@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseValidUntil(t *testing.T) {
+	t.Run("unset", func(t *testing.T) {
+		validUntil, err := parseValidUntil(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if validUntil != nil {
+			t.Fatalf("expected no expiry change, got %s", validUntil)
+		}
+	})
+
+	t.Run("infinity", func(t *testing.T) {
+		value := "infinity"
+		validUntil, err := parseValidUntil(&value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if validUntil == nil || !validUntil.IsZero() {
+			t.Fatalf("expected a zero time to remove the expiry, got %v", validUntil)
+		}
+	})
+
+	t.Run("timestamp", func(t *testing.T) {
+		value := "2027-01-01T00:00:00+02:00"
+		validUntil, err := parseValidUntil(&value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := time.Date(2026, 12, 31, 22, 0, 0, 0, time.UTC)
+		if validUntil == nil || !validUntil.Equal(expected) {
+			t.Fatalf("expected %s, got %v", expected, validUntil)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, value := range []string{"2027-01-01", "tomorrow", ""} {
+			if _, err := parseValidUntil(&value); err == nil {
+				t.Fatalf("expected %q to be rejected", value)
+			}
+		}
+	})
+}
+
+func TestCreateUserRequestDatabases(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected []string
+		single   bool
+	}{
+		{name: "list", body: `{"databases": ["app", "reporting"]}`, expected: []string{"app", "reporting"}},
+		{name: "single", body: `{"databases": "app"}`, expected: []string{"app"}, single: true},
+		{name: "empty", body: `{"databases": ""}`, single: true},
+		{name: "unset", body: `{}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var input createUserRequest
+			if err := json.Unmarshal([]byte(tc.body), &input); err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(input.Databases.Names, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, input.Databases.Names)
+			}
+
+			if input.Databases.single != tc.single {
+				t.Fatalf("expected single to be %t", tc.single)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		var input createUserRequest
+		if err := json.Unmarshal([]byte(`{"databases": 1}`), &input); err == nil {
+			t.Fatal("expected a number to be rejected")
+		}
+	})
+}
+
+func TestCreateUserRestrictPublicConnect(t *testing.T) {
+	body := `{"username": "reporting", "password": "secret", "restrict_public_connect": true}`
+
+	w := httptest.NewRecorder()
+	handleCreateUser(w, httptest.NewRequest(http.MethodPost, "/commands/users/create", strings.NewReader(body)))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unscoped user, got %d: %s", w.Code, w.Body.String())
+	}
+}
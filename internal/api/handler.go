@@ -22,6 +22,7 @@ func StartHttpServer() error {
 	r := chi.NewMux()
 	r.Mount("/flycheck", flycheck.Handler())
 	r.Mount("/commands", Handler())
+	r.Get("/metrics", handleMetrics)
 
 	server := &http.Server{
 		Handler:           r,
@@ -36,12 +37,17 @@ func Handler() http.Handler {
 	r := chi.NewRouter()
 	r.Route("/events", func(r chi.Router) {
 		r.Post("/process", handleEvent)
+		r.Get("/history", handleEventHistory)
 	})
 
 	r.Route("/users", func(r chi.Router) {
 		r.Get("/{name}", handleGetUser)
+		r.Get("/{name}/grants", handleUserGrants)
 		r.Get("/list", handleListUsers)
 		r.Post("/create", handleCreateUser)
+		r.Post("/update", handleUpdateUser)
+		r.Post("/grant", handleGrantUserAccess)
+		r.Post("/revoke", handleRevokeUserAccess)
 		r.Delete("/delete/{name}", handleDeleteUser)
 	})
 
@@ -52,6 +58,22 @@ func Handler() http.Handler {
 		r.Delete("/delete/{name}", handleDeleteDatabase)
 	})
 
+	r.Route("/publications", func(r chi.Router) {
+		r.Get("/list", handleListPublications)
+		r.Get("/{name}", handleGetPublication)
+		r.Post("/create", handleCreatePublication)
+		r.Post("/update", handleUpdatePublication)
+		r.Delete("/delete/{name}", handleDeletePublication)
+	})
+
+	r.Route("/subscriptions", func(r chi.Router) {
+		r.Get("/list", handleListSubscriptions)
+		r.Get("/{name}", handleGetSubscription)
+		r.Post("/create", handleCreateSubscription)
+		r.Post("/update", handleUpdateSubscription)
+		r.Delete("/delete/{name}", handleDeleteSubscription)
+	})
+
 	r.Route("/admin", func(r chi.Router) {
 		r.Get("/readonly/enable", handleEnableReadonly)
 		r.Get("/readonly/disable", handleDisableReadonly)
@@ -59,14 +81,58 @@ func Handler() http.Handler {
 		r.Get("/haproxy/restart", handleHaproxyRestart)
 
 		r.Get("/role", handleRole)
+		r.Get("/member/state", handleMemberState)
+		r.Get("/cluster/status", handleClusterStatus)
+		r.Get("/replication/slots", handleListReplicationSlots)
+
+		r.Get("/fencing/history", handleFencingHistory)
+		r.Post("/fencing/resolve", handleFencingResolve)
+		r.Get("/failover/validations", handleFailoverValidations)
+
+		r.Post("/maintenance/enable", handleEnableMaintenance)
+		r.Post("/maintenance/disable", handleDisableMaintenance)
+		r.Get("/maintenance/status", handleMaintenanceStatus)
+
+		r.Post("/reseed", handleReseed)
+		r.Get("/reseed/history", handleReseedHistory)
+
+		r.Get("/delayed/status", handleDelayedStatus)
+		r.Post("/delayed/pause", handlePauseDelayedReplay)
+		r.Post("/delayed/resume", handleResumeDelayedReplay)
+		r.Post("/delayed/detach", handlePromoteDetachedCopy)
+		r.Delete("/delayed/detach", handleRemoveDetachedCopy)
+
+		r.Get("/hba", handleListHBARules)
+		r.Post("/hba", handleAddHBARule)
+		r.Delete("/hba/{id}", handleRemoveHBARule)
+		r.Post("/hba/reload", handleReloadHBA)
+
+		r.Get("/auth/scram", handleScramStatus)
+		r.Post("/auth/scram/migrate", handleScramMigrate)
+
+		r.Post("/credentials/rotate", handleRotateCredential)
+		r.Post("/credentials/sync", handleSyncCredentials)
+
 		r.Get("/settings/view/postgres", handleViewPostgresSettings)
 		r.Get("/settings/view/repmgr", handleViewRepmgrSettings)
 		r.Get("/settings/view/barman", handleViewBarmanSettings)
+		r.Get("/settings/view/flypg", handleViewFlyPGSettings)
 
 		r.Post("/settings/update/postgres", handleUpdatePostgresSettings)
 		r.Post("/settings/update/barman", handleUpdateBarmanSettings)
+		r.Post("/settings/update/flypg", handleUpdateFlyPGSettings)
 
 		r.Post("/settings/apply", handleApplyConfig)
+		r.Post("/settings/apply/cluster", handleClusterApplyConfig)
+		r.Get("/settings/apply/status", handleClusterApplyStatus)
+
+		r.Get("/settings/history/{component}", handleConfigHistory)
+		r.Post("/settings/rollback/{component}/{rev}", handleConfigRollback)
+
+		r.Post("/postgres/restart", handleRestartPostgres)
+		r.Post("/switchover", handleSwitchover)
+		// Issued by the primary to the candidate while coordinating a switchover.
+		r.Post("/switchover/standby", handleStandbySwitchover)
 	})
 
 	return r
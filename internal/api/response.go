@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/fly-apps/postgres-flex/internal/flypg"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v5"
 )
@@ -27,14 +28,32 @@ func status(err error) int {
 		return http.StatusOK
 	}
 
-	if errors.Is(err, pgx.ErrNoRows) {
+	if errors.Is(err, pgx.ErrNoRows) ||
+		errors.Is(err, flypg.ErrConfigRevisionNotFound) ||
+		errors.Is(err, flypg.ErrConfigApplyNotFound) ||
+		errors.Is(err, flypg.ErrHBARuleNotFound) {
 		return http.StatusNotFound
 	}
 
+	if errors.Is(err, flypg.ErrInvalidSwitchoverCandidate) ||
+		errors.Is(err, flypg.ErrInvalidFencingResolution) ||
+		errors.Is(err, flypg.ErrInvalidHBARule) ||
+		errors.Is(err, flypg.ErrUnknownInternalUser) ||
+		errors.Is(err, flypg.ErrInvalidPassword) {
+		return http.StatusBadRequest
+	}
+
+	if errors.Is(err, flypg.ErrConfigApplyInProgress) ||
+		errors.Is(err, flypg.ErrNotFenced) {
+		return http.StatusConflict
+	}
+
 	var pgErr *pgconn.PgError
 
 	if errors.As(err, &pgErr) {
 		switch pgErr.Code {
+		case "42704": // undefined object
+			return http.StatusNotFound
 		case "42710": // unique violation
 			return http.StatusConflict
 		case "23505": // unique violation
@@ -1,10 +1,69 @@
 package api
 
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+)
+
 type createUserRequest struct {
 	Username  string `json:"username"`
 	Password  string `json:"password"`
 	Superuser bool   `json:"superuser"`
-	Database  string `json:"databases"`
+	// Access scopes the user to the specified databases and schemas. Users created without it
+	// are granted read and write access to every database.
+	Access          admin.AccessLevel `json:"access"`
+	Databases       databaseNames     `json:"databases"`
+	Schemas         []string          `json:"schemas"`
+	ConnectionLimit *int              `json:"connection_limit"`
+	ValidUntil      *string           `json:"valid_until"`
+	MemberOf        []string          `json:"member_of"`
+	// RestrictPublicConnect revokes CONNECT from PUBLIC on every database a scoped user hasn't
+	// been granted, which affects every role within the cluster.
+	RestrictPublicConnect bool `json:"restrict_public_connect"`
+}
+
+// databaseNames accepts either a list of databases or a single database name. Clients that
+// predate scoped users send a single name, which used to be ignored.
+type databaseNames struct {
+	Names  []string
+	single bool
+}
+
+func (d *databaseNames) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*d = databaseNames{single: true}
+		if name != "" {
+			d.Names = []string{name}
+		}
+		return nil
+	}
+
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("databases must be a database name or a list of database names")
+	}
+
+	*d = databaseNames{Names: names}
+
+	return nil
+}
+
+type updateUserRequest struct {
+	Username        string   `json:"username"`
+	ConnectionLimit *int     `json:"connection_limit"`
+	ValidUntil      *string  `json:"valid_until"`
+	GrantRoles      []string `json:"grant_roles"`
+	RevokeRoles     []string `json:"revoke_roles"`
+}
+
+type userAccessRequest struct {
+	Username  string            `json:"username"`
+	Access    admin.AccessLevel `json:"access"`
+	Databases []string          `json:"databases"`
+	Schemas   []string          `json:"schemas"`
 }
 
 type createDatabaseRequest struct {
@@ -65,8 +65,31 @@ func CheckPostgreSQL(ctx context.Context, checks *check.CheckSuite) (*check.Chec
 			_ = checks.AddCheck("disk-capacity", func() (string, error) {
 				return diskCapacityCheck(ctx, node)
 			})
+
+			// Compare the streaming replicas against the registered standbys.
+			_ = checks.AddCheck("replication", func() (string, error) {
+				return attachedReplicasCheck(ctx, node, member, localConn, repConn)
+			})
 		}
+	}
+
+	if member.Role == flypg.StandbyRoleName {
+		primary := resolvePrimaryReference(ctx, node, repConn)
+
+		_ = checks.AddCheck("wal-receiver", func() (string, error) {
+			return walReceiverCheck(ctx, localConn)
+		})
 
+		// Delayed replicas intentionally trail the primary by their apply delay.
+		if !node.RepMgr.Delayed {
+			_ = checks.AddCheck("replication-lag", func() (string, error) {
+				return replicationLagCheck(ctx, node, localConn, primary)
+			})
+		}
+
+		_ = checks.AddCheck("timeline", func() (string, error) {
+			return timelineCheck(ctx, localConn, primary)
+		})
 	}
 
 	return checks, nil
@@ -86,6 +109,10 @@ func diskCapacityCheck(ctx context.Context, node *flypg.Node) (string, error) {
 		// If the read-only lock has already been set, we can assume that we've already
 		// broadcasted.
 		if !flypg.ReadOnlyLockExists() {
+			node.Notify(ctx, flypg.NotificationDiskCapacityExceeded,
+				fmt.Sprintf("disk usage is at %0.1f%%, exceeding the %0.1f%% threshold", usedPercentage, diskCapacityPercentageThreshold),
+				map[string]any{"used_percentage": usedPercentage, "threshold_percentage": diskCapacityPercentageThreshold})
+
 			log.Println("[WARN] Broadcasting readonly change to registered standbys")
 			if err := flypg.BroadcastReadonlyChange(ctx, node, true); err != nil {
 				log.Printf("[ERROR] Failed to enable readonly: %s\n", err)
@@ -0,0 +1,149 @@
+package flycheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg"
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultReplicationLagBytesThreshold = 100 * 1024 * 1024
+	defaultReplicationLagTimeThreshold  = time.Minute * 5
+)
+
+// primaryReference holds the primary's position, resolved once per check run.
+type primaryReference struct {
+	lsn      string
+	timeline int
+	err      error
+}
+
+func resolvePrimaryReference(ctx context.Context, node *flypg.Node, repConn *pgx.Conn) primaryReference {
+	primary, err := node.RepMgr.PrimaryMember(ctx, repConn)
+	if err != nil {
+		return primaryReference{err: fmt.Errorf("failed to resolve primary: %s", err)}
+	}
+
+	conn, err := node.RepMgr.NewRemoteConnection(ctx, primary.Hostname)
+	if err != nil {
+		return primaryReference{err: fmt.Errorf("failed to connect to primary: %s", err)}
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	lsn, err := admin.CurrentWALLSN(ctx, conn)
+	if err != nil {
+		return primaryReference{err: fmt.Errorf("failed to resolve primary wal position: %s", err)}
+	}
+
+	timeline, err := admin.CurrentTimeline(ctx, conn)
+	if err != nil {
+		return primaryReference{err: fmt.Errorf("failed to resolve primary timeline: %s", err)}
+	}
+
+	return primaryReference{lsn: lsn, timeline: timeline}
+}
+
+func replicationLagCheck(ctx context.Context, node *flypg.Node, local *pgx.Conn, primary primaryReference) (string, error) {
+	bytesThreshold := node.FlyConfig.IntSetting("replicationLagBytesThreshold", defaultReplicationLagBytesThreshold)
+	timeThreshold := node.FlyConfig.DurationSetting("replicationLagTimeThreshold", defaultReplicationLagTimeThreshold)
+
+	lagSeconds, err := admin.ReplayLagSeconds(ctx, local)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve replay lag: %s", err)
+	}
+	lagTime := time.Duration(lagSeconds * float64(time.Second)).Round(time.Second)
+
+	if lagTime > timeThreshold {
+		return "", fmt.Errorf("replay is %s behind, exceeds threshold of %s", lagTime, timeThreshold)
+	}
+
+	// Byte lag can only be measured against the primary's current position.
+	if primary.err != nil {
+		return "", primary.err
+	}
+
+	lagBytes, err := admin.ReplayLagBytes(ctx, local, primary.lsn)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve replay lag: %s", err)
+	}
+
+	if lagBytes > bytesThreshold {
+		return "", fmt.Errorf("replay is %d bytes behind, exceeds threshold of %d bytes", lagBytes, bytesThreshold)
+	}
+
+	return fmt.Sprintf("%d bytes, %s behind", lagBytes, lagTime), nil
+}
+
+func walReceiverCheck(ctx context.Context, local *pgx.Conn) (string, error) {
+	status, err := admin.GetWalReceiverStatus(ctx, local)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("wal receiver is not running")
+		}
+		return "", fmt.Errorf("failed to query wal receiver: %s", err)
+	}
+
+	if status.Status != "streaming" {
+		return "", fmt.Errorf("wal receiver is %s", status.Status)
+	}
+
+	return fmt.Sprintf("streaming from %s", status.SenderHost), nil
+}
+
+func timelineCheck(ctx context.Context, local *pgx.Conn, primary primaryReference) (string, error) {
+	if primary.err != nil {
+		return "", primary.err
+	}
+
+	status, err := admin.GetWalReceiverStatus(ctx, local)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("wal receiver is not running")
+		}
+		return "", fmt.Errorf("failed to query wal receiver: %s", err)
+	}
+
+	if status.Timeline != primary.timeline {
+		return "", fmt.Errorf("standby is on timeline %d, primary is on timeline %d", status.Timeline, primary.timeline)
+	}
+
+	return fmt.Sprintf("timeline %d", status.Timeline), nil
+}
+
+func attachedReplicasCheck(ctx context.Context, node *flypg.Node, primary *flypg.Member, local *pgx.Conn, repConn *pgx.Conn) (string, error) {
+	members, err := node.RepMgr.Members(ctx, repConn)
+	if err != nil {
+		return "", fmt.Errorf("failed to query members: %s", err)
+	}
+
+	// Standbys cascading from a regional relay stream from the relay rather than the primary.
+	registered := 0
+	for _, member := range members {
+		if member.Role == flypg.StandbyRoleName && member.Active && member.UpstreamID == primary.ID {
+			registered++
+		}
+	}
+
+	stats, err := admin.ListReplicationStats(ctx, local)
+	if err != nil {
+		return "", fmt.Errorf("failed to query replication stats: %s", err)
+	}
+
+	streaming := 0
+	for _, stat := range stats {
+		if stat.State == "streaming" {
+			streaming++
+		}
+	}
+
+	if streaming < registered {
+		return "", fmt.Errorf("%d of %d registered standbys are streaming", streaming, registered)
+	}
+
+	return fmt.Sprintf("%d of %d registered standbys are streaming", streaming, registered), nil
+}
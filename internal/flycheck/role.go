@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/fly-apps/postgres-flex/internal/flypg"
 	"github.com/pkg/errors"
@@ -35,6 +36,16 @@ func PostgreSQLRole(ctx context.Context, checks *check.CheckSuite) (*check.Check
 		_ = conn.Close(ctx)
 	}
 
+	// Maintenance mode is reported alongside the role so operators can see why automatic
+	// failover isn't taking place.
+	if store, err := node.StateStore(); err == nil {
+		if ms, err := flypg.GetMaintenanceState(store); err == nil && ms.Enabled {
+			_ = checks.AddCheck("maintenance", func() (string, error) {
+				return fmt.Sprintf("enabled since %s by %s", ms.Since.Format(time.RFC3339), ms.Author), nil
+			})
+		}
+	}
+
 	_ = checks.AddCheck("role", func() (string, error) {
 		if flypg.ZombieLockExists() {
 			return "zombie", nil
@@ -54,6 +65,11 @@ func PostgreSQLRole(ctx context.Context, checks *check.CheckSuite) (*check.Check
 			}
 
 		case flypg.StandbyRoleName:
+			// Delayed replicas are reported separately so they aren't routed reads.
+			if node.RepMgr.Delayed {
+				return flypg.DelayedRoleName, nil
+			}
+
 			return "replica", nil
 		case flypg.WitnessRoleName:
 			return "witness", nil
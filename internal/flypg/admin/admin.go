@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -30,11 +31,47 @@ func GrantSuperuser(ctx context.Context, pg *pgx.Conn, username string) error {
 }
 
 func CreateUser(ctx context.Context, pg *pgx.Conn, username string, password string) error {
+	if err := setScramPasswordEncryption(ctx, pg); err != nil {
+		return err
+	}
+
 	sql := fmt.Sprintf(`CREATE USER %s WITH LOGIN PASSWORD '%s'`, username, password)
 	_, err := pg.Exec(ctx, sql)
 	return err
 }
 
+// setScramPasswordEncryption ensures passwords set within the session are hashed with
+// scram-sha-256, regardless of the server's password_encryption setting.
+func setScramPasswordEncryption(ctx context.Context, pg *pgx.Conn) error {
+	if _, err := pg.Exec(ctx, "SET password_encryption = 'scram-sha-256';"); err != nil {
+		return fmt.Errorf("failed to set password_encryption: %s", err)
+	}
+
+	return nil
+}
+
+// MD5PasswordRoles returns the roles whose passwords are still stored as md5 hashes. These roles
+// are unable to authenticate against scram-sha-256 pg_hba.conf entries.
+func MD5PasswordRoles(ctx context.Context, pg *pgx.Conn) ([]string, error) {
+	sql := "SELECT rolname FROM pg_authid WHERE rolpassword LIKE 'md5%' ORDER BY rolname;"
+	rows, err := pg.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
 func ManageDefaultUsers(ctx context.Context, conn *pgx.Conn, creds []Credential) error {
 	curUsers, err := ListUsers(ctx, conn)
 	if err != nil {
@@ -68,6 +105,10 @@ func ManageDefaultUsers(ctx context.Context, conn *pgx.Conn, creds []Credential)
 }
 
 func ChangePassword(ctx context.Context, pg *pgx.Conn, username, password string) error {
+	if err := setScramPasswordEncryption(ctx, pg); err != nil {
+		return err
+	}
+
 	sql := fmt.Sprintf("ALTER USER %s WITH LOGIN PASSWORD '%s';", username, password)
 
 	_, err := pg.Exec(ctx, sql)
@@ -127,27 +168,51 @@ func DeleteDatabase(ctx context.Context, pg *pgx.Conn, name string) error {
 }
 
 type ReplicationSlot struct {
-	MemberID           int32
-	Name               string
-	Active             bool
-	WalStatus          string
-	RetainedWalInBytes int
+	// MemberID is only resolved for slots managed by repmgr.
+	MemberID           int32  `json:"member_id,omitempty"`
+	Name               string `json:"name"`
+	Type               string `json:"type"`
+	Plugin             string `json:"plugin,omitempty"`
+	Database           string `json:"database,omitempty"`
+	Active             bool   `json:"active"`
+	WalStatus          string `json:"wal_status"`
+	RetainedWalInBytes int    `json:"retained_wal_bytes"`
+	// Failover reports whether a logical slot is synchronized to standbys. PG17+ only.
+	Failover bool `json:"failover"`
+}
+
+// Logical reports whether the slot is used for logical decoding.
+func (s ReplicationSlot) Logical() bool {
+	return s.Type == "logical"
+}
+
+// replicationSlotColumns is shared across slot queries. The failover column only exists on PG17+,
+// so it's resolved through the row's json representation to remain compatible with older versions.
+const replicationSlotColumns = `slot_name, slot_type, COALESCE(plugin::text, ''), COALESCE(database::text, ''),
+	active, COALESCE(wal_status, ''), COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), restart_lsn), 0) AS retained_wal,
+	COALESCE((to_jsonb(s) ->> 'failover')::bool, false) AS failover`
+
+func scanReplicationSlot(row pgx.Row, slot *ReplicationSlot) error {
+	return row.Scan(&slot.Name, &slot.Type, &slot.Plugin, &slot.Database,
+		&slot.Active, &slot.WalStatus, &slot.RetainedWalInBytes, &slot.Failover)
 }
 
 func GetReplicationSlot(ctx context.Context, pg *pgx.Conn, slotName string) (*ReplicationSlot, error) {
-	sql := fmt.Sprintf("SELECT slot_name, active, wal_status, pg_wal_lsn_diff(pg_current_wal_lsn(), restart_lsn) AS retained_wal FROM pg_replication_slots where slot_name = '%s';", slotName)
+	sql := fmt.Sprintf("SELECT %s FROM pg_replication_slots s where slot_name = '%s';", replicationSlotColumns, slotName)
 	row := pg.QueryRow(ctx, sql)
 
 	var slot ReplicationSlot
-	if err := row.Scan(&slot.Name, &slot.Active, &slot.WalStatus, &slot.RetainedWalInBytes); err != nil {
+	if err := scanReplicationSlot(row, &slot); err != nil {
 		return nil, err
 	}
 
 	return &slot, nil
 }
 
+// ListReplicationSlots returns both physical and logical replication slots. The MemberID is
+// resolved for slots managed by repmgr.
 func ListReplicationSlots(ctx context.Context, pg *pgx.Conn) ([]ReplicationSlot, error) {
-	sql := "SELECT slot_name, active, wal_status, pg_wal_lsn_diff(pg_current_wal_lsn(), restart_lsn) AS retained_wal FROM pg_replication_slots;"
+	sql := fmt.Sprintf("SELECT %s FROM pg_replication_slots s ORDER BY slot_name;", replicationSlotColumns)
 	rows, err := pg.Query(ctx, sql)
 	if err != nil {
 		return nil, err
@@ -158,24 +223,22 @@ func ListReplicationSlots(ctx context.Context, pg *pgx.Conn) ([]ReplicationSlot,
 
 	for rows.Next() {
 		var slot ReplicationSlot
-		if err := rows.Scan(&slot.Name, &slot.Active, &slot.WalStatus, &slot.RetainedWalInBytes); err != nil {
+		if err := scanReplicationSlot(rows, &slot); err != nil {
 			return nil, err
 		}
 
 		// Extract the repmgr member id from the slot name.
 		// Slot name has the following format: repmgr_slot_<member-id>
-		slotArr := strings.Split(slot.Name, "_")
-		if slotArr[0] == "repmgr" {
-			idStr := slotArr[2]
-
+		if idStr, ok := strings.CutPrefix(slot.Name, "repmgr_slot_"); ok && slot.Type == "physical" {
 			num, err := strconv.ParseInt(idStr, 10, 32)
 			if err != nil {
 				return nil, err
 			}
 
 			slot.MemberID = int32(num)
-			slots = append(slots, slot)
 		}
+
+		slots = append(slots, slot)
 	}
 
 	return slots, nil
@@ -344,6 +407,31 @@ func ReloadPostgresConfig(ctx context.Context, pg *pgx.Conn) error {
 	return err
 }
 
+// HBAFileErrors returns the errors postgres reports when parsing pg_hba.conf as it currently
+// exists on disk.
+func HBAFileErrors(ctx context.Context, pg *pgx.Conn) ([]string, error) {
+	sql := "SELECT line_number, error FROM pg_hba_file_rules WHERE error IS NOT NULL ORDER BY line_number;"
+	rows, err := pg.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var errs []string
+	for rows.Next() {
+		var (
+			line int
+			msg  string
+		)
+		if err := rows.Scan(&line, &msg); err != nil {
+			return nil, err
+		}
+		errs = append(errs, fmt.Sprintf("line %d: %s", line, msg))
+	}
+
+	return errs, rows.Err()
+}
+
 func SettingExists(ctx context.Context, pg *pgx.Conn, setting string) (bool, error) {
 	sql := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM pg_settings WHERE name='%s')", setting)
 	var out bool
@@ -375,6 +463,200 @@ func SettingRequiresRestart(ctx context.Context, pg *pgx.Conn, setting string) (
 	return out, nil
 }
 
+// PendingRestartSettings returns the settings that have been changed within the configuration
+// files but require a restart before they take effect.
+func PendingRestartSettings(ctx context.Context, pg *pgx.Conn) ([]string, error) {
+	sql := "SELECT name FROM pg_settings WHERE pending_restart ORDER BY name;"
+	rows, err := pg.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var settings []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		settings = append(settings, name)
+	}
+
+	return settings, rows.Err()
+}
+
+func CurrentWALLSN(ctx context.Context, pg *pgx.Conn) (string, error) {
+	var lsn string
+	if err := pg.QueryRow(ctx, "SELECT pg_current_wal_lsn()::text;").Scan(&lsn); err != nil {
+		return "", err
+	}
+
+	return lsn, nil
+}
+
+// StandbyReplayedTo returns true when the specified standby is streaming from the primary and has
+// replayed up to the specified LSN.
+func StandbyReplayedTo(ctx context.Context, pg *pgx.Conn, applicationName, lsn string) (bool, error) {
+	sql := "SELECT EXISTS(SELECT 1 FROM pg_stat_replication WHERE application_name = $1 AND state = 'streaming' AND replay_lsn >= $2::pg_lsn);"
+	var out bool
+	if err := pg.QueryRow(ctx, sql, applicationName, lsn).Scan(&out); err != nil {
+		return false, err
+	}
+
+	return out, nil
+}
+
+// StandbyReplayLag returns the number of bytes the specified streaming standby has yet to replay.
+func StandbyReplayLag(ctx context.Context, pg *pgx.Conn, applicationName string) (int64, error) {
+	sql := "SELECT pg_wal_lsn_diff(pg_current_wal_lsn(), replay_lsn)::bigint FROM pg_stat_replication WHERE application_name = $1 AND state = 'streaming';"
+	var lag int64
+	if err := pg.QueryRow(ctx, sql, applicationName).Scan(&lag); err != nil {
+		return 0, err
+	}
+
+	return lag, nil
+}
+
+type ReplicationStat struct {
+	ApplicationName  string
+	State            string
+	LagBytes         int64
+	ReplayLagSeconds float64
+}
+
+// ListReplicationStats returns the replication state of every standby streaming from the primary.
+func ListReplicationStats(ctx context.Context, pg *pgx.Conn) ([]ReplicationStat, error) {
+	sql := "SELECT application_name, state, COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), replay_lsn), 0)::bigint, COALESCE(EXTRACT(EPOCH FROM replay_lag), 0)::float8 FROM pg_stat_replication;"
+	rows, err := pg.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []ReplicationStat
+	for rows.Next() {
+		var stat ReplicationStat
+		if err := rows.Scan(&stat.ApplicationName, &stat.State, &stat.LagBytes, &stat.ReplayLagSeconds); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+func ServerVersion(ctx context.Context, pg *pgx.Conn) (string, error) {
+	var version string
+	if err := pg.QueryRow(ctx, "SHOW server_version;").Scan(&version); err != nil {
+		return "", err
+	}
+
+	return version, nil
+}
+
+func CurrentTimeline(ctx context.Context, pg *pgx.Conn) (int, error) {
+	var timeline int
+	if err := pg.QueryRow(ctx, "SELECT timeline_id FROM pg_control_checkpoint();").Scan(&timeline); err != nil {
+		return 0, err
+	}
+
+	return timeline, nil
+}
+
+func InRecovery(ctx context.Context, pg *pgx.Conn) (bool, error) {
+	var inRecovery bool
+	if err := pg.QueryRow(ctx, "SELECT pg_is_in_recovery();").Scan(&inRecovery); err != nil {
+		return false, err
+	}
+
+	return inRecovery, nil
+}
+
+// LastReplayLSN returns the last LSN replayed during recovery. An empty string is returned
+// when the server is not in recovery.
+func LastReplayLSN(ctx context.Context, pg *pgx.Conn) (string, error) {
+	var lsn string
+	if err := pg.QueryRow(ctx, "SELECT COALESCE(pg_last_wal_replay_lsn()::text, '');").Scan(&lsn); err != nil {
+		return "", err
+	}
+
+	return lsn, nil
+}
+
+// LastReplayTimestamp returns the commit time of the last transaction replayed during recovery.
+// A nil time is returned when no transactions have been replayed.
+func LastReplayTimestamp(ctx context.Context, pg *pgx.Conn) (*time.Time, error) {
+	var ts *time.Time
+	if err := pg.QueryRow(ctx, "SELECT pg_last_xact_replay_timestamp();").Scan(&ts); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+// WalReplayPaused reports whether WAL replay has been paused, or a pause has been requested.
+func WalReplayPaused(ctx context.Context, pg *pgx.Conn) (bool, error) {
+	var state string
+	if err := pg.QueryRow(ctx, "SELECT pg_get_wal_replay_pause_state();").Scan(&state); err != nil {
+		return false, err
+	}
+
+	return state != "not paused", nil
+}
+
+func PauseWalReplay(ctx context.Context, pg *pgx.Conn) error {
+	_, err := pg.Exec(ctx, "SELECT pg_wal_replay_pause();")
+	return err
+}
+
+func ResumeWalReplay(ctx context.Context, pg *pgx.Conn) error {
+	_, err := pg.Exec(ctx, "SELECT pg_wal_replay_resume();")
+	return err
+}
+
+type WalReceiverStatus struct {
+	Status     string
+	Timeline   int
+	SenderHost string
+}
+
+// GetWalReceiverStatus returns the status of the standby's WAL receiver. pgx.ErrNoRows is
+// returned when no WAL receiver is running.
+func GetWalReceiverStatus(ctx context.Context, pg *pgx.Conn) (*WalReceiverStatus, error) {
+	sql := "SELECT status, received_tli, COALESCE(sender_host, '') FROM pg_stat_wal_receiver;"
+	var status WalReceiverStatus
+	if err := pg.QueryRow(ctx, sql).Scan(&status.Status, &status.Timeline, &status.SenderHost); err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// ReplayLagBytes returns the number of bytes between the specified LSN and the last LSN
+// replayed by the standby.
+func ReplayLagBytes(ctx context.Context, pg *pgx.Conn, lsn string) (int64, error) {
+	sql := "SELECT COALESCE(pg_wal_lsn_diff($1::pg_lsn, pg_last_wal_replay_lsn()), 0)::bigint;"
+	var lag int64
+	if err := pg.QueryRow(ctx, sql, lsn).Scan(&lag); err != nil {
+		return 0, err
+	}
+
+	return lag, nil
+}
+
+// ReplayLagSeconds returns how far behind the standby's replay is. Zero is returned when
+// everything that has been received has been replayed.
+func ReplayLagSeconds(ctx context.Context, pg *pgx.Conn) (float64, error) {
+	sql := `SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END::float8;`
+	var lag float64
+	if err := pg.QueryRow(ctx, sql).Scan(&lag); err != nil {
+		return 0, err
+	}
+
+	return lag, nil
+}
+
 type PGSetting struct {
 	Name           string    `json:"name,omitempty"`
 	Setting        string    `json:"setting,omitempty"`
@@ -448,3 +730,29 @@ func ValidatePGSettings(ctx context.Context, conn *pgx.Conn, requested map[strin
 
 	return nil
 }
+
+// AlterSystemSetting persists the setting within postgresql.auto.conf, which takes precedence
+// over the managed config files.
+func AlterSystemSetting(ctx context.Context, pg *pgx.Conn, key, value string) error {
+	sql := fmt.Sprintf("ALTER SYSTEM SET %s = '%s'", key, strings.ReplaceAll(value, "'", "''"))
+	_, err := pg.Exec(ctx, sql)
+	return err
+}
+
+// AlterSystemReset removes the setting from postgresql.auto.conf.
+func AlterSystemReset(ctx context.Context, pg *pgx.Conn, key string) error {
+	sql := fmt.Sprintf("ALTER SYSTEM RESET %s", key)
+	_, err := pg.Exec(ctx, sql)
+	return err
+}
+
+// AlterSystemSettingExists returns true when the setting has been persisted within postgresql.auto.conf.
+func AlterSystemSettingExists(ctx context.Context, pg *pgx.Conn, key string) (bool, error) {
+	sql := "SELECT EXISTS(SELECT 1 FROM pg_file_settings WHERE name = $1 AND sourcefile LIKE '%postgresql.auto.conf');"
+	var out bool
+	if err := pg.QueryRow(ctx, sql, key).Scan(&out); err != nil {
+		return false, err
+	}
+
+	return out, nil
+}
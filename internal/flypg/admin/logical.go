@@ -0,0 +1,337 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PublicationOperations lists the DML operations a publication is able to publish.
+var PublicationOperations = []string{"insert", "update", "delete", "truncate"}
+
+type Publication struct {
+	Name       string   `json:"name"`
+	Owner      string   `json:"owner"`
+	AllTables  bool     `json:"all_tables"`
+	Operations []string `json:"operations"`
+	Tables     []string `json:"tables"`
+}
+
+type Subscription struct {
+	Name         string   `json:"name"`
+	Database     string   `json:"database"`
+	Enabled      bool     `json:"enabled"`
+	Failover     bool     `json:"failover"`
+	Publications []string `json:"publications"`
+	SlotName     string   `json:"slot_name"`
+	ReceivedLSN  string   `json:"received_lsn"`
+	LastMessage  string   `json:"last_message_at"`
+}
+
+// SubscriptionOptions are applied through the WITH clause of CREATE SUBSCRIPTION.
+type SubscriptionOptions struct {
+	CopyData bool
+	Enabled  bool
+	// Failover requests the subscription's slot on the publisher to be synchronized to its
+	// standbys. This is only supported by PG17+.
+	Failover bool
+}
+
+// ServerVersionNum returns the server version in its numeric form, e.g. 170002.
+func ServerVersionNum(ctx context.Context, pg *pgx.Conn) (int, error) {
+	var version int
+	if err := pg.QueryRow(ctx, "SELECT current_setting('server_version_num')::int;").Scan(&version); err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// ListPublications returns the publications defined within the database we are connected to.
+func ListPublications(ctx context.Context, pg *pgx.Conn) ([]Publication, error) {
+	sql := `
+		SELECT p.pubname, r.rolname, p.puballtables,
+			p.pubinsert, p.pubupdate, p.pubdelete, p.pubtruncate,
+			COALESCE((SELECT array_agg(format('%I.%I', t.schemaname, t.tablename) ORDER BY t.schemaname, t.tablename)
+				FROM pg_publication_tables t WHERE t.pubname = p.pubname), '{}') AS tables
+		FROM pg_publication p
+		JOIN pg_roles r ON r.oid = p.pubowner
+		ORDER BY p.pubname;
+		`
+
+	rows, err := pg.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []Publication
+
+	for rows.Next() {
+		var (
+			pub                              Publication
+			insert, update, remove, truncate bool
+		)
+
+		if err := rows.Scan(&pub.Name, &pub.Owner, &pub.AllTables, &insert, &update, &remove, &truncate, &pub.Tables); err != nil {
+			return nil, err
+		}
+
+		for i, enabled := range []bool{insert, update, remove, truncate} {
+			if enabled {
+				pub.Operations = append(pub.Operations, PublicationOperations[i])
+			}
+		}
+
+		values = append(values, pub)
+	}
+
+	return values, rows.Err()
+}
+
+func FindPublication(ctx context.Context, pg *pgx.Conn, name string) (*Publication, error) {
+	pubs, err := ListPublications(ctx, pg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pub := range pubs {
+		if pub.Name == name {
+			return &pub, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CreatePublication creates a publication for the specified tables, which are expected in the
+// form of `schema.table` or `table`. All tables are published when none are specified. All
+// operations are published when none are specified.
+func CreatePublication(ctx context.Context, pg *pgx.Conn, name string, tables []string, operations []string) error {
+	sql, err := createPublicationSQL(name, tables, operations)
+	if err != nil {
+		return err
+	}
+
+	_, err = pg.Exec(ctx, sql)
+	return err
+}
+
+func createPublicationSQL(name string, tables []string, operations []string) (string, error) {
+	target := "FOR ALL TABLES"
+	if len(tables) > 0 {
+		list, err := quoteTables(tables)
+		if err != nil {
+			return "", err
+		}
+		target = "FOR TABLE " + list
+	}
+
+	sql := fmt.Sprintf("CREATE PUBLICATION %s %s", pgx.Identifier{name}.Sanitize(), target)
+
+	if len(operations) > 0 {
+		for _, op := range operations {
+			if !validPublicationOperation(op) {
+				return "", fmt.Errorf("invalid publication operation %q", op)
+			}
+		}
+		sql += fmt.Sprintf(" WITH (publish = '%s')", strings.Join(operations, ", "))
+	}
+
+	return sql + ";", nil
+}
+
+// SetPublicationTables replaces the set of tables published by the publication.
+func SetPublicationTables(ctx context.Context, pg *pgx.Conn, name string, tables []string) error {
+	if len(tables) == 0 {
+		return fmt.Errorf("at least one table is required")
+	}
+
+	list, err := quoteTables(tables)
+	if err != nil {
+		return err
+	}
+
+	sql := fmt.Sprintf("ALTER PUBLICATION %s SET TABLE %s;", pgx.Identifier{name}.Sanitize(), list)
+	_, err = pg.Exec(ctx, sql)
+	return err
+}
+
+func DropPublication(ctx context.Context, pg *pgx.Conn, name string) error {
+	sql := fmt.Sprintf("DROP PUBLICATION %s;", pgx.Identifier{name}.Sanitize())
+	_, err := pg.Exec(ctx, sql)
+	return err
+}
+
+// ListSubscriptions returns the subscriptions across all databases, along with the state of
+// their apply workers.
+func ListSubscriptions(ctx context.Context, pg *pgx.Conn) ([]Subscription, error) {
+	// subfailover only exists on PG17+, so it's resolved through the row's json representation
+	// to keep the query compatible with older versions.
+	sql := `
+		SELECT s.subname, d.datname, s.subenabled,
+			COALESCE((to_jsonb(s) ->> 'subfailover')::bool, false) AS failover,
+			s.subpublications, COALESCE(s.subslotname::text, ''),
+			COALESCE(st.received_lsn::text, ''),
+			COALESCE(to_char(st.last_msg_receipt_time AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"'), '')
+		FROM pg_subscription s
+		JOIN pg_database d ON d.oid = s.subdbid
+		LEFT JOIN pg_stat_subscription st ON st.subid = s.oid AND st.relid IS NULL
+		ORDER BY d.datname, s.subname;
+		`
+
+	rows, err := pg.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []Subscription
+
+	for rows.Next() {
+		var sub Subscription
+		if err := rows.Scan(&sub.Name, &sub.Database, &sub.Enabled, &sub.Failover,
+			&sub.Publications, &sub.SlotName, &sub.ReceivedLSN, &sub.LastMessage); err != nil {
+			return nil, err
+		}
+
+		values = append(values, sub)
+	}
+
+	return values, rows.Err()
+}
+
+// FindSubscription looks up a subscription within the specified database. Subscription names are
+// only unique per database, so the database is required.
+func FindSubscription(ctx context.Context, pg *pgx.Conn, database, name string) (*Subscription, error) {
+	if database == "" {
+		return nil, fmt.Errorf("a database is required to look up subscription %q", name)
+	}
+
+	subs, err := ListSubscriptions(ctx, pg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sub := range subs {
+		if sub.Database == database && sub.Name == name {
+			return &sub, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CreateSubscription creates a subscription within the database we are connected to.
+func CreateSubscription(ctx context.Context, pg *pgx.Conn, name, connInfo string, publications []string, opts SubscriptionOptions) error {
+	sql, err := createSubscriptionSQL(name, connInfo, publications, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = pg.Exec(ctx, sql)
+	return err
+}
+
+func createSubscriptionSQL(name, connInfo string, publications []string, opts SubscriptionOptions) (string, error) {
+	if len(publications) == 0 {
+		return "", fmt.Errorf("at least one publication is required")
+	}
+
+	with := []string{
+		fmt.Sprintf("copy_data = %t", opts.CopyData),
+		fmt.Sprintf("enabled = %t", opts.Enabled),
+	}
+	if opts.Failover {
+		with = append(with, "failover = true")
+	}
+
+	sql := fmt.Sprintf("CREATE SUBSCRIPTION %s CONNECTION %s PUBLICATION %s WITH (%s);",
+		pgx.Identifier{name}.Sanitize(),
+		quoteLiteral(connInfo),
+		quoteIdentifiers(publications),
+		strings.Join(with, ", "),
+	)
+
+	return sql, nil
+}
+
+func SetSubscriptionEnabled(ctx context.Context, pg *pgx.Conn, name string, enabled bool) error {
+	action := "DISABLE"
+	if enabled {
+		action = "ENABLE"
+	}
+
+	sql := fmt.Sprintf("ALTER SUBSCRIPTION %s %s;", pgx.Identifier{name}.Sanitize(), action)
+	_, err := pg.Exec(ctx, sql)
+	return err
+}
+
+func SetSubscriptionPublications(ctx context.Context, pg *pgx.Conn, name string, publications []string) error {
+	if len(publications) == 0 {
+		return fmt.Errorf("at least one publication is required")
+	}
+
+	sql := fmt.Sprintf("ALTER SUBSCRIPTION %s SET PUBLICATION %s;",
+		pgx.Identifier{name}.Sanitize(), quoteIdentifiers(publications))
+	_, err := pg.Exec(ctx, sql)
+	return err
+}
+
+// DropSubscription drops the subscription along with its slot on the publisher. When detach is
+// set the subscription is disassociated from its slot first, which allows the subscription to be
+// dropped while the publisher is unreachable. The slot must then be dropped on the publisher manually.
+func DropSubscription(ctx context.Context, pg *pgx.Conn, name string, detach bool) error {
+	ident := pgx.Identifier{name}.Sanitize()
+
+	if detach {
+		if _, err := pg.Exec(ctx, fmt.Sprintf("ALTER SUBSCRIPTION %s DISABLE;", ident)); err != nil {
+			return err
+		}
+
+		if _, err := pg.Exec(ctx, fmt.Sprintf("ALTER SUBSCRIPTION %s SET (slot_name = NONE);", ident)); err != nil {
+			return err
+		}
+	}
+
+	_, err := pg.Exec(ctx, fmt.Sprintf("DROP SUBSCRIPTION %s;", ident))
+	return err
+}
+
+func validPublicationOperation(op string) bool {
+	for _, valid := range PublicationOperations {
+		if op == valid {
+			return true
+		}
+	}
+
+	return false
+}
+
+func quoteTables(tables []string) (string, error) {
+	quoted := make([]string, 0, len(tables))
+	for _, table := range tables {
+		parts := strings.Split(table, ".")
+		if len(parts) > 2 {
+			return "", fmt.Errorf("invalid table name %q", table)
+		}
+		quoted = append(quoted, pgx.Identifier(parts).Sanitize())
+	}
+
+	return strings.Join(quoted, ", "), nil
+}
+
+func quoteIdentifiers(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, pgx.Identifier{name}.Sanitize())
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
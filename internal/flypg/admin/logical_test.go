@@ -0,0 +1,112 @@
+package admin
+
+import (
+	"context"
+	"testing"
+)
+
+func TestQuoteTables(t *testing.T) {
+	tests := []struct {
+		tables   []string
+		expected string
+	}{
+		{[]string{"orders"}, `"orders"`},
+		{[]string{"public.orders", "sales.Line Items"}, `"public"."orders", "sales"."Line Items"`},
+		{[]string{`my"table`}, `"my""table"`},
+		{[]string{"orders; DROP TABLE users"}, `"orders; DROP TABLE users"`},
+	}
+
+	for _, tc := range tests {
+		quoted, err := quoteTables(tc.tables)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if quoted != tc.expected {
+			t.Fatalf("expected %s, got %s", tc.expected, quoted)
+		}
+	}
+
+	if _, err := quoteTables([]string{"db.public.orders"}); err == nil {
+		t.Fatal("expected a table name with more than two parts to be rejected")
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	expected := `'host=source.internal password=it''s'`
+	if quoted := quoteLiteral("host=source.internal password=it's"); quoted != expected {
+		t.Fatalf("expected %s, got %s", expected, quoted)
+	}
+}
+
+func TestCreatePublicationSQL(t *testing.T) {
+	t.Run("all tables", func(t *testing.T) {
+		sql, err := createPublicationSQL("orders", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `CREATE PUBLICATION "orders" FOR ALL TABLES;`
+		if sql != expected {
+			t.Fatalf("expected %s, got %s", expected, sql)
+		}
+	})
+
+	t.Run("tables and operations", func(t *testing.T) {
+		sql, err := createPublicationSQL(`my"pub`, []string{"public.orders", "items"}, []string{"insert", "update"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `CREATE PUBLICATION "my""pub" FOR TABLE "public"."orders", "items" WITH (publish = 'insert, update');`
+		if sql != expected {
+			t.Fatalf("expected %s, got %s", expected, sql)
+		}
+	})
+
+	t.Run("invalid operation", func(t *testing.T) {
+		if _, err := createPublicationSQL("orders", nil, []string{"insert', 'delete"}); err == nil {
+			t.Fatal("expected an unknown operation to be rejected")
+		}
+	})
+}
+
+func TestCreateSubscriptionSQL(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		sql, err := createSubscriptionSQL("orders", "host=source.internal dbname=app", []string{"orders", "Items"},
+			SubscriptionOptions{CopyData: true, Enabled: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `CREATE SUBSCRIPTION "orders" CONNECTION 'host=source.internal dbname=app' PUBLICATION "orders", "Items" WITH (copy_data = true, enabled = true);`
+		if sql != expected {
+			t.Fatalf("expected %s, got %s", expected, sql)
+		}
+	})
+
+	t.Run("quoting", func(t *testing.T) {
+		sql, err := createSubscriptionSQL(`my"sub`, "password=it's", []string{`my"pub`},
+			SubscriptionOptions{Failover: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `CREATE SUBSCRIPTION "my""sub" CONNECTION 'password=it''s' PUBLICATION "my""pub" WITH (copy_data = false, enabled = false, failover = true);`
+		if sql != expected {
+			t.Fatalf("expected %s, got %s", expected, sql)
+		}
+	})
+
+	t.Run("no publications", func(t *testing.T) {
+		if _, err := createSubscriptionSQL("orders", "host=source.internal", nil, SubscriptionOptions{}); err == nil {
+			t.Fatal("expected an error without publications")
+		}
+	})
+}
+
+func TestFindSubscriptionRequiresDatabase(t *testing.T) {
+	if _, err := FindSubscription(context.Background(), nil, "", "orders"); err == nil {
+		t.Fatal("expected an error without a database")
+	}
+}
@@ -0,0 +1,487 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AccessLevel is the level of access a role is granted within a schema.
+type AccessLevel string
+
+const (
+	AccessReadOnly  AccessLevel = "read-only"
+	AccessReadWrite AccessLevel = "read-write"
+)
+
+// Valid reports whether the access level is known.
+func (a AccessLevel) Valid() bool {
+	return a == AccessReadOnly || a == AccessReadWrite
+}
+
+func (a AccessLevel) tablePrivileges() string {
+	if a == AccessReadWrite {
+		return "SELECT, INSERT, UPDATE, DELETE"
+	}
+
+	return "SELECT"
+}
+
+func (a AccessLevel) sequencePrivileges() string {
+	if a == AccessReadWrite {
+		return "USAGE, SELECT, UPDATE"
+	}
+
+	return "SELECT"
+}
+
+// RoleOptions are applied when creating or altering a role. Nil fields are left unchanged.
+type RoleOptions struct {
+	// ConnectionLimit caps the number of concurrent connections. -1 removes the limit.
+	ConnectionLimit *int
+	// ValidUntil expires the role's password. A zero time removes the expiry.
+	ValidUntil *time.Time
+}
+
+func (o RoleOptions) clause() string {
+	var parts []string
+	if o.ConnectionLimit != nil {
+		parts = append(parts, fmt.Sprintf("CONNECTION LIMIT %d", *o.ConnectionLimit))
+	}
+
+	if o.ValidUntil != nil {
+		validUntil := "infinity"
+		if !o.ValidUntil.IsZero() {
+			validUntil = o.ValidUntil.UTC().Format(time.RFC3339)
+		}
+		parts = append(parts, fmt.Sprintf("VALID UNTIL %s", quoteLiteral(validUntil)))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// RoleGrants describes the effective privileges of a role.
+type RoleGrants struct {
+	Role            string           `json:"role"`
+	Superuser       bool             `json:"superuser"`
+	Login           bool             `json:"login"`
+	ConnectionLimit int              `json:"connection_limit"`
+	ValidUntil      *time.Time       `json:"valid_until,omitempty"`
+	MemberOf        []string         `json:"member_of"`
+	Databases       []DatabaseGrants `json:"databases"`
+}
+
+// DatabaseGrants lists the privileges a role holds on a database and the schemas within it.
+type DatabaseGrants struct {
+	Database   string         `json:"database"`
+	Privileges []string       `json:"privileges"`
+	Schemas    []SchemaGrants `json:"schemas,omitempty"`
+}
+
+// SchemaGrants lists the privileges a role holds on a schema and the relations within it.
+type SchemaGrants struct {
+	Schema     string        `json:"schema"`
+	Privileges []string      `json:"privileges"`
+	Tables     []TableGrants `json:"tables,omitempty"`
+}
+
+// TableGrants lists the privileges a role holds on a table, view or sequence.
+type TableGrants struct {
+	Table      string   `json:"table"`
+	Privileges []string `json:"privileges"`
+}
+
+// CreateRole creates a role with the specified options. Roles without a password can't log in,
+// which is useful for roles that only serve to group privileges.
+func CreateRole(ctx context.Context, pg *pgx.Conn, name, password string, opts RoleOptions) error {
+	if err := setScramPasswordEncryption(ctx, pg); err != nil {
+		return err
+	}
+
+	login := "NOLOGIN"
+	if password != "" {
+		login = fmt.Sprintf("LOGIN PASSWORD %s", quoteLiteral(password))
+	}
+
+	sql := fmt.Sprintf("CREATE ROLE %s WITH %s %s", pgx.Identifier{name}.Sanitize(), login, opts.clause())
+	_, err := pg.Exec(ctx, sql)
+
+	return err
+}
+
+// AlterRole applies the specified options to an existing role.
+func AlterRole(ctx context.Context, pg *pgx.Conn, name string, opts RoleOptions) error {
+	clause := opts.clause()
+	if clause == "" {
+		return nil
+	}
+
+	sql := fmt.Sprintf("ALTER ROLE %s WITH %s", pgx.Identifier{name}.Sanitize(), clause)
+	_, err := pg.Exec(ctx, sql)
+
+	return err
+}
+
+// GrantRoleMembership makes the member inherit the privileges of the specified role.
+func GrantRoleMembership(ctx context.Context, pg *pgx.Conn, role, member string) error {
+	sql := fmt.Sprintf("GRANT %s TO %s", pgx.Identifier{role}.Sanitize(), pgx.Identifier{member}.Sanitize())
+	_, err := pg.Exec(ctx, sql)
+
+	return err
+}
+
+// RevokeRoleMembership removes the member from the specified role.
+func RevokeRoleMembership(ctx context.Context, pg *pgx.Conn, role, member string) error {
+	sql := fmt.Sprintf("REVOKE %s FROM %s", pgx.Identifier{role}.Sanitize(), pgx.Identifier{member}.Sanitize())
+	_, err := pg.Exec(ctx, sql)
+
+	return err
+}
+
+// GrantDatabaseAccess grants the role access to the specified schemas of the database we are
+// connected to. Privileges previously granted within those schemas are replaced, so access can be
+// downgraded from read-write to read-only. Default privileges cover tables and sequences the
+// schema owner creates later on.
+func GrantDatabaseAccess(ctx context.Context, pg *pgx.Conn, role, database string, schemas []string, access AccessLevel) error {
+	if !access.Valid() {
+		return fmt.Errorf("invalid access level %q", access)
+	}
+
+	return pgx.BeginFunc(ctx, pg, func(tx pgx.Tx) error {
+		r := pgx.Identifier{role}.Sanitize()
+
+		sql := fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO %s", pgx.Identifier{database}.Sanitize(), r)
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+
+		for _, schema := range schemas {
+			owner, err := schemaOwner(ctx, tx, schema)
+			if err != nil {
+				return err
+			}
+
+			statements := grantSchemaStatements(r, pgx.Identifier{schema}.Sanitize(), pgx.Identifier{owner}.Sanitize(), access)
+			for _, sql := range statements {
+				if _, err := tx.Exec(ctx, sql); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// RevokeDatabaseAccess revokes the role's privileges within the specified schemas of the database
+// we are connected to, along with its explicit CONNECT privilege. Note that PUBLIC is granted
+// CONNECT on new databases by default.
+func RevokeDatabaseAccess(ctx context.Context, pg *pgx.Conn, role, database string, schemas []string) error {
+	return pgx.BeginFunc(ctx, pg, func(tx pgx.Tx) error {
+		r := pgx.Identifier{role}.Sanitize()
+
+		for _, schema := range schemas {
+			owner, err := schemaOwner(ctx, tx, schema)
+			if err != nil {
+				return err
+			}
+
+			for _, sql := range revokeSchemaStatements(r, pgx.Identifier{schema}.Sanitize(), pgx.Identifier{owner}.Sanitize()) {
+				if _, err := tx.Exec(ctx, sql); err != nil {
+					return err
+				}
+			}
+		}
+
+		sql := fmt.Sprintf("REVOKE CONNECT ON DATABASE %s FROM %s", pgx.Identifier{database}.Sanitize(), r)
+		_, err := tx.Exec(ctx, sql)
+
+		return err
+	})
+}
+
+// ConnectRestriction records the changes made by RestrictDatabaseConnect, so they can be undone.
+type ConnectRestriction struct {
+	Database string
+	// Roles were granted CONNECT explicitly to keep the access they had through PUBLIC.
+	Roles []string
+}
+
+// RestrictDatabaseConnect revokes CONNECT on the database from PUBLIC, so only roles that have been
+// granted CONNECT explicitly are able to connect. Login roles without an explicit grant, other than
+// the excluded role, are granted CONNECT first so they keep their access. Nil is returned if PUBLIC
+// wasn't able to connect to begin with.
+func RestrictDatabaseConnect(ctx context.Context, pg *pgx.Conn, database, exclude string) (*ConnectRestriction, error) {
+	var restriction *ConnectRestriction
+
+	err := pgx.BeginFunc(ctx, pg, func(tx pgx.Tx) error {
+		const acl = `
+			SELECT a.grantee FROM pg_database d, aclexplode(COALESCE(d.datacl, acldefault('d', d.datdba))) a
+			WHERE d.datname = $1 AND a.privilege_type = 'CONNECT'`
+
+		var public bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS ("+acl+" AND a.grantee = 0)", database).Scan(&public); err != nil {
+			return err
+		}
+
+		if !public {
+			return nil
+		}
+
+		rows, err := tx.Query(ctx, `
+			SELECT rolname FROM pg_roles
+			WHERE rolcanlogin AND NOT rolsuper AND rolname <> $2
+				AND oid NOT IN (`+acl+`)
+			ORDER BY rolname`, database, exclude)
+		if err != nil {
+			return err
+		}
+
+		roles, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return err
+		}
+
+		for _, sql := range restrictConnectStatements(database, roles) {
+			if _, err := tx.Exec(ctx, sql); err != nil {
+				return err
+			}
+		}
+
+		restriction = &ConnectRestriction{Database: database, Roles: roles}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return restriction, nil
+}
+
+// UndoDatabaseConnectRestriction grants CONNECT back to PUBLIC and revokes the grants made by
+// RestrictDatabaseConnect.
+func UndoDatabaseConnectRestriction(ctx context.Context, pg *pgx.Conn, restriction ConnectRestriction) error {
+	return pgx.BeginFunc(ctx, pg, func(tx pgx.Tx) error {
+		for _, sql := range undoRestrictConnectStatements(restriction) {
+			if _, err := tx.Exec(ctx, sql); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// GrantDatabaseConnect grants the role CONNECT on each of the specified databases.
+func GrantDatabaseConnect(ctx context.Context, pg *pgx.Conn, role string, databases []string) error {
+	return pgx.BeginFunc(ctx, pg, func(tx pgx.Tx) error {
+		for _, database := range databases {
+			sql := fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO %s", pgx.Identifier{database}.Sanitize(), pgx.Identifier{role}.Sanitize())
+			if _, err := tx.Exec(ctx, sql); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func restrictConnectStatements(database string, roles []string) []string {
+	d := pgx.Identifier{database}.Sanitize()
+
+	statements := make([]string, 0, len(roles)+1)
+	for _, role := range roles {
+		statements = append(statements, fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO %s", d, pgx.Identifier{role}.Sanitize()))
+	}
+
+	return append(statements, fmt.Sprintf("REVOKE CONNECT ON DATABASE %s FROM PUBLIC", d))
+}
+
+func undoRestrictConnectStatements(restriction ConnectRestriction) []string {
+	d := pgx.Identifier{restriction.Database}.Sanitize()
+
+	statements := []string{fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO PUBLIC", d)}
+	for _, role := range restriction.Roles {
+		statements = append(statements, fmt.Sprintf("REVOKE CONNECT ON DATABASE %s FROM %s", d, pgx.Identifier{role}.Sanitize()))
+	}
+
+	return statements
+}
+
+// grantSchemaStatements replaces the role's privileges within the schema with the specified
+// access level. The arguments are expected to be quoted identifiers.
+func grantSchemaStatements(role, schema, owner string, access AccessLevel) []string {
+	return append(revokeSchemaStatements(role, schema, owner),
+		fmt.Sprintf("GRANT USAGE ON SCHEMA %s TO %s", schema, role),
+		fmt.Sprintf("GRANT %s ON ALL TABLES IN SCHEMA %s TO %s", access.tablePrivileges(), schema, role),
+		fmt.Sprintf("GRANT %s ON ALL SEQUENCES IN SCHEMA %s TO %s", access.sequencePrivileges(), schema, role),
+		fmt.Sprintf("ALTER DEFAULT PRIVILEGES FOR ROLE %s IN SCHEMA %s GRANT %s ON TABLES TO %s", owner, schema, access.tablePrivileges(), role),
+		fmt.Sprintf("ALTER DEFAULT PRIVILEGES FOR ROLE %s IN SCHEMA %s GRANT %s ON SEQUENCES TO %s", owner, schema, access.sequencePrivileges(), role),
+	)
+}
+
+func revokeSchemaStatements(role, schema, owner string) []string {
+	return []string{
+		fmt.Sprintf("ALTER DEFAULT PRIVILEGES FOR ROLE %s IN SCHEMA %s REVOKE ALL ON TABLES FROM %s", owner, schema, role),
+		fmt.Sprintf("ALTER DEFAULT PRIVILEGES FOR ROLE %s IN SCHEMA %s REVOKE ALL ON SEQUENCES FROM %s", owner, schema, role),
+		fmt.Sprintf("REVOKE ALL ON ALL TABLES IN SCHEMA %s FROM %s", schema, role),
+		fmt.Sprintf("REVOKE ALL ON ALL SEQUENCES IN SCHEMA %s FROM %s", schema, role),
+		fmt.Sprintf("REVOKE ALL ON SCHEMA %s FROM %s", schema, role),
+	}
+}
+
+func schemaOwner(ctx context.Context, tx pgx.Tx, schema string) (string, error) {
+	var owner string
+	err := tx.QueryRow(ctx,
+		"SELECT pg_get_userbyid(nspowner) FROM pg_namespace WHERE nspname = $1", schema).Scan(&owner)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("schema %q does not exist", schema)
+	}
+
+	return owner, err
+}
+
+// FindRoleGrants returns the role's attributes, the roles it is a member of, directly or
+// indirectly, and its privileges on every database. Nil is returned if the role doesn't exist.
+func FindRoleGrants(ctx context.Context, pg *pgx.Conn, role string) (*RoleGrants, error) {
+	sql := `
+		SELECT r.rolname, r.rolsuper, r.rolcanlogin, r.rolconnlimit,
+			CASE WHEN r.rolvaliduntil = 'infinity' THEN NULL ELSE r.rolvaliduntil END AS valid_until,
+			COALESCE((SELECT array_agg(m.rolname::text ORDER BY m.rolname)
+				FROM pg_roles m
+				WHERE m.oid <> r.oid AND pg_has_role(r.oid, m.oid, 'MEMBER')), '{}') AS member_of
+		FROM pg_roles r
+		WHERE r.rolname = $1`
+
+	grants := RoleGrants{}
+	err := pg.QueryRow(ctx, sql, role).Scan(
+		&grants.Role, &grants.Superuser, &grants.Login, &grants.ConnectionLimit, &grants.ValidUntil, &grants.MemberOf)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sql = `
+		SELECT d.datname,
+			array_remove(ARRAY[
+				CASE WHEN has_database_privilege($1::name, d.datname, 'CONNECT') THEN 'CONNECT' END,
+				CASE WHEN has_database_privilege($1::name, d.datname, 'CREATE') THEN 'CREATE' END,
+				CASE WHEN has_database_privilege($1::name, d.datname, 'TEMPORARY') THEN 'TEMPORARY' END
+			], NULL) AS privileges
+		FROM pg_database d
+		WHERE NOT d.datistemplate
+		ORDER BY d.datname`
+
+	rows, err := pg.Query(ctx, sql, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var db DatabaseGrants
+		if err := rows.Scan(&db.Database, &db.Privileges); err != nil {
+			return nil, err
+		}
+		grants.Databases = append(grants.Databases, db)
+	}
+
+	return &grants, rows.Err()
+}
+
+// ListSchemaGrants returns the role's effective privileges on the schemas, tables, views and
+// sequences of the database we are connected to. Schemas the role holds no privileges within are
+// omitted.
+func ListSchemaGrants(ctx context.Context, pg *pgx.Conn, role string) ([]SchemaGrants, error) {
+	sql := `
+		SELECT n.nspname,
+			array_remove(ARRAY[
+				CASE WHEN has_schema_privilege($1::name, n.oid, 'USAGE') THEN 'USAGE' END,
+				CASE WHEN has_schema_privilege($1::name, n.oid, 'CREATE') THEN 'CREATE' END
+			], NULL) AS privileges
+		FROM pg_namespace n
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+			AND n.nspname NOT LIKE 'pg_toast%'
+			AND n.nspname NOT LIKE 'pg_temp%'
+		ORDER BY n.nspname`
+
+	rows, err := pg.Query(ctx, sql, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []SchemaGrants
+	for rows.Next() {
+		var s SchemaGrants
+		if err := rows.Scan(&s.Schema, &s.Privileges); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sql = `
+		SELECT n.nspname, c.relname, p.privileges
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		CROSS JOIN LATERAL (
+			SELECT array_agg(priv ORDER BY priv) AS privileges
+			FROM unnest(CASE WHEN c.relkind = 'S'
+				THEN ARRAY['USAGE', 'SELECT', 'UPDATE']
+				ELSE ARRAY['SELECT', 'INSERT', 'UPDATE', 'DELETE', 'TRUNCATE', 'REFERENCES', 'TRIGGER']
+			END) AS priv
+			WHERE CASE WHEN c.relkind = 'S'
+				THEN has_sequence_privilege($1::name, c.oid, priv)
+				ELSE has_table_privilege($1::name, c.oid, priv)
+			END
+		) p
+		WHERE c.relkind IN ('r', 'p', 'v', 'm', 'f', 'S')
+			AND n.nspname = ANY($2)
+			AND p.privileges IS NOT NULL
+		ORDER BY n.nspname, c.relname`
+
+	names := make([]string, 0, len(schemas))
+	for _, s := range schemas {
+		names = append(names, s.Schema)
+	}
+
+	rows, err = pg.Query(ctx, sql, role, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := map[string][]TableGrants{}
+	for rows.Next() {
+		var schema string
+		var t TableGrants
+		if err := rows.Scan(&schema, &t.Table, &t.Privileges); err != nil {
+			return nil, err
+		}
+		tables[schema] = append(tables[schema], t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	granted := []SchemaGrants{}
+	for _, s := range schemas {
+		s.Tables = tables[s.Schema]
+		if len(s.Privileges) == 0 && len(s.Tables) == 0 {
+			continue
+		}
+		granted = append(granted, s)
+	}
+
+	return granted, nil
+}
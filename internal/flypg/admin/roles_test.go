@@ -0,0 +1,118 @@
+package admin
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRoleOptionsClause(t *testing.T) {
+	limit := 5
+	unlimited := -1
+	validUntil := time.Date(2027, 1, 1, 2, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	tests := []struct {
+		name     string
+		opts     RoleOptions
+		expected string
+	}{
+		{name: "empty", opts: RoleOptions{}, expected: ""},
+		{name: "connection limit", opts: RoleOptions{ConnectionLimit: &limit}, expected: "CONNECTION LIMIT 5"},
+		{name: "unlimited", opts: RoleOptions{ConnectionLimit: &unlimited}, expected: "CONNECTION LIMIT -1"},
+		{name: "valid until", opts: RoleOptions{ValidUntil: &validUntil}, expected: "VALID UNTIL '2027-01-01T00:00:00Z'"},
+		{name: "infinity", opts: RoleOptions{ValidUntil: &time.Time{}}, expected: "VALID UNTIL 'infinity'"},
+		{
+			name:     "both",
+			opts:     RoleOptions{ConnectionLimit: &limit, ValidUntil: &time.Time{}},
+			expected: "CONNECTION LIMIT 5 VALID UNTIL 'infinity'",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if clause := tc.opts.clause(); clause != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, clause)
+			}
+		})
+	}
+}
+
+func TestGrantSchemaStatements(t *testing.T) {
+	t.Run("read-only", func(t *testing.T) {
+		statements := grantSchemaStatements(`"reporting"`, `"public"`, `"postgres"`, AccessReadOnly)
+
+		// Previous privileges are revoked first, so access can be downgraded.
+		if !slices.Equal(statements[:5], revokeSchemaStatements(`"reporting"`, `"public"`, `"postgres"`)) {
+			t.Fatalf("expected the existing privileges to be revoked first, got %v", statements)
+		}
+
+		expected := []string{
+			`GRANT USAGE ON SCHEMA "public" TO "reporting"`,
+			`GRANT SELECT ON ALL TABLES IN SCHEMA "public" TO "reporting"`,
+			`GRANT SELECT ON ALL SEQUENCES IN SCHEMA "public" TO "reporting"`,
+			`ALTER DEFAULT PRIVILEGES FOR ROLE "postgres" IN SCHEMA "public" GRANT SELECT ON TABLES TO "reporting"`,
+			`ALTER DEFAULT PRIVILEGES FOR ROLE "postgres" IN SCHEMA "public" GRANT SELECT ON SEQUENCES TO "reporting"`,
+		}
+
+		if !slices.Equal(statements[5:], expected) {
+			t.Fatalf("expected %v, got %v", expected, statements[5:])
+		}
+	})
+
+	t.Run("read-write", func(t *testing.T) {
+		statements := grantSchemaStatements(`"app"`, `"sales"`, `"owner"`, AccessReadWrite)
+
+		expected := []string{
+			`GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA "sales" TO "app"`,
+			`GRANT USAGE, SELECT, UPDATE ON ALL SEQUENCES IN SCHEMA "sales" TO "app"`,
+		}
+
+		if !slices.Equal(statements[6:8], expected) {
+			t.Fatalf("expected %v, got %v", expected, statements[6:8])
+		}
+	})
+}
+
+func TestRevokeSchemaStatements(t *testing.T) {
+	expected := []string{
+		`ALTER DEFAULT PRIVILEGES FOR ROLE "postgres" IN SCHEMA "public" REVOKE ALL ON TABLES FROM "reporting"`,
+		`ALTER DEFAULT PRIVILEGES FOR ROLE "postgres" IN SCHEMA "public" REVOKE ALL ON SEQUENCES FROM "reporting"`,
+		`REVOKE ALL ON ALL TABLES IN SCHEMA "public" FROM "reporting"`,
+		`REVOKE ALL ON ALL SEQUENCES IN SCHEMA "public" FROM "reporting"`,
+		`REVOKE ALL ON SCHEMA "public" FROM "reporting"`,
+	}
+
+	if statements := revokeSchemaStatements(`"reporting"`, `"public"`, `"postgres"`); !slices.Equal(statements, expected) {
+		t.Fatalf("expected %v, got %v", expected, statements)
+	}
+}
+
+func TestRestrictConnectStatements(t *testing.T) {
+	expected := []string{
+		`GRANT CONNECT ON DATABASE "my""db" TO "app"`,
+		`GRANT CONNECT ON DATABASE "my""db" TO "Reporting"`,
+		`REVOKE CONNECT ON DATABASE "my""db" FROM PUBLIC`,
+	}
+
+	if statements := restrictConnectStatements(`my"db`, []string{"app", "Reporting"}); !slices.Equal(statements, expected) {
+		t.Fatalf("expected %v, got %v", expected, statements)
+	}
+
+	// PUBLIC loses CONNECT even when no other role relies on it.
+	if statements := restrictConnectStatements("app", nil); len(statements) != 1 {
+		t.Fatalf("expected a single revoke, got %v", statements)
+	}
+}
+
+func TestUndoRestrictConnectStatements(t *testing.T) {
+	expected := []string{
+		`GRANT CONNECT ON DATABASE "my""db" TO PUBLIC`,
+		`REVOKE CONNECT ON DATABASE "my""db" FROM "app"`,
+		`REVOKE CONNECT ON DATABASE "my""db" FROM "Reporting"`,
+	}
+
+	restriction := ConnectRestriction{Database: `my"db`, Roles: []string{"app", "Reporting"}}
+	if statements := undoRestrictConnectStatements(restriction); !slices.Equal(statements, expected) {
+		t.Fatalf("expected %v, got %v", expected, statements)
+	}
+}
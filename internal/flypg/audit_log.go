@@ -0,0 +1,92 @@
+package flypg
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Audit logs are rotated once they grow beyond this size, retaining a single previous generation.
+const auditLogMaxSize = 10 * 1024 * 1024
+
+// appendAuditEntry appends the entry to the specified log as a single JSON line.
+func appendAuditEntry(path string, entry any) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Size() > auditLogMaxSize {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate %s: %s", path, err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// auditHistory returns the most recent entries across both log generations, newest first.
+// A limit of 0 returns every retained entry.
+func auditHistory[T any](path string, limit int) ([]T, error) {
+	var entries []T
+
+	for _, p := range []string{path + ".1", path} {
+		e, err := readAuditLog[T](p)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e...)
+	}
+
+	// Reverse so the most recent entries come first.
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+func readAuditLog[T any](path string) ([]T, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open %s: %s", path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var entries []T
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e T
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// Skip entries that were only partially written.
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", path, err)
+	}
+
+	return entries, nil
+}
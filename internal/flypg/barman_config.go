@@ -43,7 +43,7 @@ func (c *BarmanConfig) SetUserConfig(newConfig ConfigMap) { c.userConfig = newCo
 func (c *BarmanConfig) InternalConfigFile() string        { return c.internalConfigFilePath }
 func (c *BarmanConfig) UserConfigFile() string            { return c.userConfigFilePath }
 
-func NewBarmanConfig(store *state.Store, configDir string) (*BarmanConfig, error) {
+func NewBarmanConfig(store state.StateStore, configDir string) (*BarmanConfig, error) {
 	cfg := &BarmanConfig{
 		internalConfigFilePath: configDir + "barman.internal.conf",
 		userConfigFilePath:     configDir + "barman.user.conf",
@@ -168,7 +168,7 @@ func (c *BarmanConfig) Validate(requestedChanges map[string]interface{}) error {
 	return nil
 }
 
-func (c *BarmanConfig) initialize(store *state.Store, configDir string) error {
+func (c *BarmanConfig) initialize(store state.StateStore, configDir string) error {
 	// Ensure directory exists
 	if err := os.MkdirAll(configDir, 0o600); err != nil {
 		return fmt.Errorf("failed to create barman config directory: %s", err)
@@ -176,9 +176,9 @@ func (c *BarmanConfig) initialize(store *state.Store, configDir string) error {
 
 	c.SetDefaults()
 
-	// Sync the user config from consul
+	// Sync the user config from the state store
 	if err := SyncUserConfig(c, store); err != nil {
-		log.Printf("[WARN] Failed to sync user config from consul for barman: %s\n", err.Error())
+		log.Printf("[WARN] Failed to sync user config from state store for barman: %s\n", err.Error())
 	}
 
 	// Write the internal defaults
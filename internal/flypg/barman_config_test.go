@@ -12,7 +12,7 @@ func TestValidateBarmanConfig(t *testing.T) {
 	}
 	defer cleanup()
 
-	store, _ := state.NewStore()
+	store := state.NewMemoryStore()
 
 	b, err := NewBarmanConfig(store, testBarmanConfigDir)
 	if err != nil {
@@ -158,7 +158,7 @@ func TestBarmanConfigSettings(t *testing.T) {
 	}
 	defer cleanup()
 
-	store, _ := state.NewStore()
+	store := state.NewMemoryStore()
 
 	t.Run("defaults", func(t *testing.T) {
 		b, err := NewBarmanConfig(store, testBarmanConfigDir)
@@ -190,7 +190,7 @@ func TestBarmanSettingUpdate(t *testing.T) {
 	}
 	defer cleanup()
 
-	store, _ := state.NewStore()
+	store := state.NewMemoryStore()
 
 	b, err := NewBarmanConfig(store, testBarmanConfigDir)
 	if err != nil {
@@ -222,9 +222,6 @@ func (*BarmanRestore) resolveBackupFromTime(backupList BackupList, restoreStr st
 	var lastBackupID string
 	var lastBackupTime time.Time
 
-	// This is the layout presented by barman
-	layout := "Mon Jan 2 15:04:05 2006"
-
 	for _, backup := range backupList.Backups {
 		// TODO - This shouldn't be needed, but will keep it around until we can improve tests.
 		if backup.Status != "DONE" {
@@ -232,7 +229,7 @@ func (*BarmanRestore) resolveBackupFromTime(backupList BackupList, restoreStr st
 		}
 
 		// Parse the backup end time
-		endTime, err := time.Parse(layout, backup.EndTime)
+		endTime, err := time.Parse(barmanTimeLayout, backup.EndTime)
 		if err != nil {
 			return "", fmt.Errorf("failed to parse backup end time: %s", err)
 		}
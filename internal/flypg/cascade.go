@@ -0,0 +1,156 @@
+package flypg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/fly-apps/postgres-flex/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// regionalRelay returns the standby that members within the specified member's region should
+// replicate from. The relay is the active standby with the lowest node id, which keeps every
+// member of the region in agreement without coordination and rules out replication cycles.
+// Nil is returned when the member itself is the relay.
+func regionalRelay(self Member, candidates []Member) *Member {
+	var eligible []Member
+	for _, candidate := range candidates {
+		if candidate.ID == self.ID || candidate.Role != StandbyRoleName || !candidate.Active {
+			continue
+		}
+
+		if candidate.Region != self.Region {
+			continue
+		}
+
+		eligible = append(eligible, candidate)
+	}
+
+	sort.Slice(eligible, func(i, j int) bool { return eligible[i].ID < eligible[j].ID })
+
+	if len(eligible) == 0 || eligible[0].ID > self.ID {
+		return nil
+	}
+
+	return &eligible[0]
+}
+
+// cascadingReplication reports whether standbys outside of the primary region should replicate
+// from an in-region relay rather than from the primary.
+func (n *Node) cascadingReplication() bool {
+	return n.FlyConfig.BoolSetting("cascadingReplication", false)
+}
+
+// ResolveUpstream returns the member the local standby should replicate from. Standbys outside
+// of the primary region follow their region's relay when cascading replication is enabled,
+// otherwise the primary is returned.
+func (n *Node) ResolveUpstream(ctx context.Context, conn *pgx.Conn) (*Member, error) {
+	primary, err := n.RepMgr.PrimaryMember(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve primary: %s", err)
+	}
+
+	if !n.cascadingReplication() || n.RepMgr.eligiblePrimary() {
+		return primary, nil
+	}
+
+	self, err := n.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve member: %s", err)
+	}
+
+	members, err := n.RepMgr.Members(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members: %s", err)
+	}
+
+	delayed := n.delayedMemberNames()
+
+	// Only consider relays we are able to reach, so a dead relay is passed over. Delayed replicas
+	// are passed over as well.
+	var reachable []Member
+	for _, member := range members {
+		if member.ID == self.ID || member.Role != StandbyRoleName || !member.Active || member.Region != self.Region {
+			continue
+		}
+
+		if delayed[member.Name] {
+			continue
+		}
+
+		mConn, err := n.RepMgr.NewRemoteConnection(ctx, member.Hostname)
+		if err != nil {
+			log.Printf("[WARN] Relay candidate %s is unreachable: %s", member.Hostname, err)
+			continue
+		}
+		_ = mConn.Close(ctx)
+
+		reachable = append(reachable, member)
+	}
+
+	if relay := regionalRelay(*self, reachable); relay != nil {
+		return relay, nil
+	}
+
+	return primary, nil
+}
+
+// FollowRegionalUpstream re-points the local standby at the upstream resolved by ResolveUpstream
+// when it is replicating from a different member. This is a no-op for primaries and witnesses.
+func (n *Node) FollowRegionalUpstream(ctx context.Context) error {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open local connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	member, err := n.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to resolve member: %s", err)
+	}
+
+	if member.Role != StandbyRoleName {
+		return nil
+	}
+
+	desired, err := n.ResolveUpstream(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	current, err := n.RepMgr.UpstreamMember(ctx, conn, member.ID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to resolve current upstream: %s", err)
+	}
+
+	if current != nil && current.ID == desired.ID {
+		return nil
+	}
+
+	// Following restarts postgres, so the connection needs to be released first.
+	if err := conn.Close(ctx); err != nil {
+		return fmt.Errorf("failed to close connection: %s", err)
+	}
+
+	log.Printf("[INFO] Re-pointing standby at upstream %s (node %d)", desired.Hostname, desired.ID)
+
+	if err := n.RepMgr.followUpstream(ctx, desired.ID); err != nil {
+		return fmt.Errorf("failed to follow %s: %s", desired.Hostname, err)
+	}
+
+	return nil
+}
+
+// followUpstream instructs the local standby to replicate from the specified member, which may
+// be the primary or another standby. The new upstream is recorded as the node's upstream_node_id.
+func (r *RepMgr) followUpstream(ctx context.Context, upstreamID int) error {
+	_, err := utils.RunCmd(ctx, "postgres",
+		"repmgr", "standby", "follow",
+		"-f", r.ConfigPath,
+		fmt.Sprintf("--upstream-node-id=%d", upstreamID))
+
+	return err
+}
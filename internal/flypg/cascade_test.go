@@ -0,0 +1,50 @@
+package flypg
+
+import "testing"
+
+func TestRegionalRelay(t *testing.T) {
+	members := []Member{
+		{ID: 1, Region: "ord", Role: PrimaryRoleName, Active: true},
+		{ID: 2, Region: "ord", Role: StandbyRoleName, Active: true},
+		{ID: 30, Region: "syd", Role: StandbyRoleName, Active: true},
+		{ID: 40, Region: "syd", Role: StandbyRoleName, Active: true},
+		{ID: 50, Region: "syd", Role: WitnessRoleName, Active: true},
+		{ID: 60, Region: "syd", Role: StandbyRoleName, Active: true},
+	}
+
+	t.Run("relay", func(t *testing.T) {
+		relay := regionalRelay(members[3], members)
+		if relay == nil || relay.ID != 30 {
+			t.Fatalf("expected node 40 to follow node 30, got %+v", relay)
+		}
+
+		relay = regionalRelay(members[5], members)
+		if relay == nil || relay.ID != 30 {
+			t.Fatalf("expected node 60 to follow node 30, got %+v", relay)
+		}
+	})
+
+	t.Run("self", func(t *testing.T) {
+		if relay := regionalRelay(members[2], members); relay != nil {
+			t.Fatalf("expected the lowest standby to be the relay, got %d", relay.ID)
+		}
+	})
+
+	t.Run("inactive", func(t *testing.T) {
+		candidates := []Member{
+			{ID: 30, Region: "syd", Role: StandbyRoleName, Active: false},
+			{ID: 40, Region: "syd", Role: StandbyRoleName, Active: true},
+		}
+
+		relay := regionalRelay(Member{ID: 60, Region: "syd"}, candidates)
+		if relay == nil || relay.ID != 40 {
+			t.Fatalf("expected inactive relay to be passed over, got %+v", relay)
+		}
+	})
+
+	t.Run("otherRegion", func(t *testing.T) {
+		if relay := regionalRelay(Member{ID: 70, Region: "ams"}, members); relay != nil {
+			t.Fatalf("expected no relay outside of the region, got %d", relay.ID)
+		}
+	})
+}
@@ -0,0 +1,245 @@
+package flypg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+)
+
+const (
+	MemberStateEndpoint = "commands/admin/member/state"
+
+	repmgrdPIDFile = "/tmp/repmgrd.pid"
+)
+
+var memberStatusTimeout = time.Second * 10
+
+// MemberState describes the state that can only be observed from the member itself.
+type MemberState struct {
+	ZombieLock     bool `json:"zombie_lock"`
+	ReadonlyLock   bool `json:"readonly_lock"`
+	RepmgrdRunning bool `json:"repmgrd_running"`
+}
+
+// SlotStatus describes the replication slot held on the primary on behalf of a member.
+type SlotStatus struct {
+	Name               string `json:"name"`
+	Active             bool   `json:"active"`
+	WalStatus          string `json:"wal_status"`
+	RetainedWalInBytes int    `json:"retained_wal_in_bytes"`
+}
+
+// MemberStatus is the aggregated view of a single member.
+type MemberStatus struct {
+	ID         int          `json:"id"`
+	Name       string       `json:"name"`
+	Hostname   string       `json:"hostname"`
+	Region     string       `json:"region"`
+	Role       string       `json:"role"`
+	Active     bool         `json:"active"`
+	Reachable  bool         `json:"reachable"`
+	PGVersion  string       `json:"pg_version,omitempty"`
+	Timeline   int          `json:"timeline,omitempty"`
+	InRecovery bool         `json:"in_recovery"`
+	Streaming  bool         `json:"streaming"`
+	LagBytes   *int64       `json:"lag_bytes,omitempty"`
+	LagSeconds *float64     `json:"lag_seconds,omitempty"`
+	Slot       *SlotStatus  `json:"slot,omitempty"`
+	State      *MemberState `json:"state,omitempty"`
+	Errors     []string     `json:"errors,omitempty"`
+}
+
+// ClusterStatus is the aggregated view of every registered member.
+type ClusterStatus struct {
+	Primary     string         `json:"primary,omitempty"`
+	Members     []MemberStatus `json:"members"`
+	Errors      []string       `json:"errors,omitempty"`
+	CollectedAt time.Time      `json:"collected_at"`
+}
+
+// CurrentMemberState inspects the local lock files and repmgrd process.
+func CurrentMemberState() MemberState {
+	return MemberState{
+		ZombieLock:     ZombieLockExists(),
+		ReadonlyLock:   ReadOnlyLockExists(),
+		RepmgrdRunning: repmgrdRunning(),
+	}
+}
+
+// CollectClusterStatus queries every registered member for its status. Members that
+// can't be reached are still reported, along with the errors encountered.
+func CollectClusterStatus(ctx context.Context, n *Node) (*ClusterStatus, error) {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	members, err := n.RepMgr.Members(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members: %s", err)
+	}
+
+	status := &ClusterStatus{
+		Members:     make([]MemberStatus, len(members)),
+		CollectedAt: time.Now().UTC(),
+	}
+
+	var (
+		stats []admin.ReplicationStat
+		slots []admin.ReplicationSlot
+	)
+
+	// Lag and slot details are omitted when the primary can't be resolved.
+	primary, err := n.RepMgr.PrimaryMember(ctx, conn)
+	if err != nil {
+		status.Errors = append(status.Errors, fmt.Sprintf("failed to resolve primary: %s", err))
+	} else {
+		status.Primary = primary.Hostname
+
+		stats, slots, err = primaryReplicationState(ctx, n, primary.Hostname)
+		if err != nil {
+			status.Errors = append(status.Errors, err.Error())
+		}
+	}
+
+	if err := conn.Close(ctx); err != nil {
+		return nil, fmt.Errorf("failed to close connection: %s", err)
+	}
+
+	delayed := n.delayedMemberNames()
+
+	var wg sync.WaitGroup
+	for i, member := range members {
+		wg.Add(1)
+		go func(i int, member Member) {
+			defer wg.Done()
+			status.Members[i] = collectMemberStatus(ctx, n, member, delayed, stats, slots)
+		}(i, member)
+	}
+	wg.Wait()
+
+	return status, nil
+}
+
+func primaryReplicationState(ctx context.Context, n *Node, hostname string) ([]admin.ReplicationStat, []admin.ReplicationSlot, error) {
+	conn, err := n.RepMgr.NewRemoteConnection(ctx, hostname)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to primary: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	stats, err := admin.ListReplicationStats(ctx, conn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query replication stats: %s", err)
+	}
+
+	slots, err := admin.ListReplicationSlots(ctx, conn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query replication slots: %s", err)
+	}
+
+	return stats, slots, nil
+}
+
+func collectMemberStatus(ctx context.Context, n *Node, member Member, delayed map[string]bool, stats []admin.ReplicationStat, slots []admin.ReplicationSlot) MemberStatus {
+	ms := replicationStatus(member, delayed, stats, slots)
+
+	if err := collectPostgresStatus(ctx, n, member.Hostname, &ms); err != nil {
+		ms.Errors = append(ms.Errors, err.Error())
+	}
+
+	var state MemberState
+	if err := fetchMemberResult(ctx, member.Hostname, MemberStateEndpoint, &state); err != nil {
+		ms.Errors = append(ms.Errors, fmt.Sprintf("failed to query admin api: %s", err))
+	} else {
+		ms.State = &state
+	}
+
+	return ms
+}
+
+// replicationStatus reports the member's replication state, as observed from the primary.
+func replicationStatus(member Member, delayed map[string]bool, stats []admin.ReplicationStat, slots []admin.ReplicationSlot) MemberStatus {
+	ms := MemberStatus{
+		ID:       member.ID,
+		Name:     member.Name,
+		Hostname: member.Hostname,
+		Region:   member.Region,
+		Role:     member.Role,
+		Active:   member.Active,
+	}
+
+	// Delayed replicas are registered with repmgr as standbys.
+	if member.Role == StandbyRoleName && delayed[member.Name] {
+		ms.Role = DelayedRoleName
+	}
+
+	for _, stat := range stats {
+		if stat.ApplicationName == member.ApplicationName() {
+			lagBytes, lagSeconds := stat.LagBytes, stat.ReplayLagSeconds
+			ms.Streaming = stat.State == "streaming"
+			ms.LagBytes = &lagBytes
+			ms.LagSeconds = &lagSeconds
+		}
+	}
+
+	for _, slot := range slots {
+		if int(slot.MemberID) == member.ID {
+			ms.Slot = &SlotStatus{
+				Name:               slot.Name,
+				Active:             slot.Active,
+				WalStatus:          slot.WalStatus,
+				RetainedWalInBytes: slot.RetainedWalInBytes,
+			}
+		}
+	}
+
+	return ms
+}
+
+func collectPostgresStatus(ctx context.Context, n *Node, hostname string, ms *MemberStatus) error {
+	conn, err := n.RepMgr.NewRemoteConnection(ctx, hostname)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	ms.Reachable = true
+
+	if ms.PGVersion, err = admin.ServerVersion(ctx, conn); err != nil {
+		return fmt.Errorf("failed to resolve server version: %s", err)
+	}
+
+	if ms.Timeline, err = admin.CurrentTimeline(ctx, conn); err != nil {
+		return fmt.Errorf("failed to resolve timeline: %s", err)
+	}
+
+	if ms.InRecovery, err = admin.InRecovery(ctx, conn); err != nil {
+		return fmt.Errorf("failed to resolve recovery state: %s", err)
+	}
+
+	return nil
+}
+
+func repmgrdRunning() bool {
+	pidBytes, err := os.ReadFile(repmgrdPIDFile)
+	if err != nil {
+		return false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidBytes)))
+	if err != nil {
+		return false
+	}
+
+	// Signal 0 performs error checking only, which tells us whether the process exists.
+	return syscall.Kill(pid, 0) == nil
+}
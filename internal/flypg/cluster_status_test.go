@@ -0,0 +1,115 @@
+package flypg
+
+import (
+	"testing"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+)
+
+func TestReplicationStatus(t *testing.T) {
+	stats := []admin.ReplicationStat{
+		{ApplicationName: "6e8226ec711087", State: "streaming", LagBytes: 1024, ReplayLagSeconds: 0.5},
+		{ApplicationName: "3d8d9925f15e89", State: "catchup", LagBytes: 4096, ReplayLagSeconds: 12},
+		{ApplicationName: "fdaa:0:2e26:a7b:8c31:bf37:488c:2", State: "streaming"},
+	}
+
+	slots := []admin.ReplicationSlot{
+		{MemberID: 2, Name: "repmgr_slot_2", Active: true, WalStatus: "reserved", RetainedWalInBytes: 2048},
+	}
+
+	delayed := map[string]bool{"148ed193b95d89": true}
+
+	tests := []struct {
+		name      string
+		member    Member
+		role      string
+		streaming bool
+		lagBytes  *int64
+		slot      string
+	}{
+		{
+			name:      "streaming standby",
+			member:    Member{ID: 2, Name: "6e8226ec711087", Role: StandbyRoleName, Active: true},
+			role:      StandbyRoleName,
+			streaming: true,
+			lagBytes:  ptr(int64(1024)),
+			slot:      "repmgr_slot_2",
+		},
+		{
+			name:     "catching up standby",
+			member:   Member{ID: 3, Name: "3d8d9925f15e89", Role: StandbyRoleName, Active: true},
+			role:     StandbyRoleName,
+			lagBytes: ptr(int64(4096)),
+		},
+		{
+			name:      "hostname fallback",
+			member:    Member{ID: 4, Hostname: "fdaa:0:2e26:a7b:8c31:bf37:488c:2", Role: StandbyRoleName, Active: true},
+			role:      StandbyRoleName,
+			streaming: true,
+			lagBytes:  ptr(int64(0)),
+		},
+		{
+			name:   "delayed replica",
+			member: Member{ID: 5, Name: "148ed193b95d89", Role: StandbyRoleName, Active: true},
+			role:   DelayedRoleName,
+		},
+		{
+			name:   "primary",
+			member: Member{ID: 1, Name: "148ed193b95d80", Role: PrimaryRoleName, Active: true},
+			role:   PrimaryRoleName,
+		},
+		{
+			name:   "witness",
+			member: Member{ID: 6, Name: "148ed193b95d89", Role: WitnessRoleName, Active: true},
+			role:   WitnessRoleName,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ms := replicationStatus(tc.member, delayed, stats, slots)
+
+			if ms.Role != tc.role {
+				t.Fatalf("expected role %s, got %s", tc.role, ms.Role)
+			}
+
+			if ms.Streaming != tc.streaming {
+				t.Fatalf("expected streaming to be %t, got %t", tc.streaming, ms.Streaming)
+			}
+
+			switch {
+			case tc.lagBytes == nil && ms.LagBytes != nil:
+				t.Fatalf("expected no lag to be reported, got %d", *ms.LagBytes)
+			case tc.lagBytes != nil && (ms.LagBytes == nil || *ms.LagBytes != *tc.lagBytes):
+				t.Fatalf("expected lag of %d bytes, got %v", *tc.lagBytes, ms.LagBytes)
+			}
+
+			switch {
+			case tc.slot == "" && ms.Slot != nil:
+				t.Fatalf("expected no slot, got %+v", ms.Slot)
+			case tc.slot != "" && (ms.Slot == nil || ms.Slot.Name != tc.slot):
+				t.Fatalf("expected slot %s, got %+v", tc.slot, ms.Slot)
+			}
+
+			if ms.ID != tc.member.ID || ms.Name != tc.member.Name || ms.Active != tc.member.Active {
+				t.Fatalf("expected member details to be carried over, got %+v", ms)
+			}
+		})
+	}
+}
+
+func TestMemberApplicationName(t *testing.T) {
+	member := Member{Name: "6e8226ec711087", Hostname: "6e8226ec711087.vm.my-app.internal"}
+	if member.ApplicationName() != "6e8226ec711087" {
+		t.Fatalf("expected the node name, got %s", member.ApplicationName())
+	}
+
+	member.Name = ""
+	if member.ApplicationName() != "6e8226ec711087.vm.my-app.internal" {
+		t.Fatalf("expected the hostname, got %s", member.ApplicationName())
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/fly-apps/postgres-flex/internal/flypg/state"
@@ -13,6 +14,40 @@ import (
 
 type ConfigMap map[string]interface{}
 
+// redactedSetting replaces the value of secret settings wherever settings are returned, recorded
+// within the config history or logged.
+const redactedSetting = "********"
+
+// secretSettings are the settings whose values must never leave the member. New secret settings
+// need to be registered here.
+var secretSettings = map[string]bool{
+	"webhookSecret": true,
+}
+
+// RedactConfig returns a copy of the config with the values of secret settings masked.
+func RedactConfig(cfg ConfigMap) ConfigMap {
+	if cfg == nil {
+		return nil
+	}
+
+	redacted := make(ConfigMap, len(cfg))
+	for k, v := range cfg {
+		redacted[k] = RedactSetting(k, v)
+	}
+
+	return redacted
+}
+
+// RedactSetting masks the value of a secret setting. Empty values are left as is, so it remains
+// visible whether the secret has been set.
+func RedactSetting(key string, value any) any {
+	if !secretSettings[key] || value == nil || fmt.Sprint(value) == "" {
+		return value
+	}
+
+	return redactedSetting
+}
+
 type Config interface {
 	InternalConfigFile() string
 	UserConfigFile() string
@@ -23,13 +58,13 @@ type Config interface {
 	CurrentConfig() (ConfigMap, error)
 }
 
-func WriteUserConfig(c Config, consul *state.Store) error {
+func WriteUserConfig(c Config, store state.StateStore, author string) error {
 	if c.UserConfig() == nil {
 		return nil
 	}
 
-	if err := pushToConsul(c, consul); err != nil {
-		return fmt.Errorf("failed to write to consul: %s", err)
+	if err := pushRevision(c, store, author, 0); err != nil {
+		return err
 	}
 
 	if err := WriteConfigFiles(c); err != nil {
@@ -39,26 +74,24 @@ func WriteUserConfig(c Config, consul *state.Store) error {
 	return nil
 }
 
-func PushUserConfig(c Config, consul *state.Store) error {
+// PushUserConfig writes the user config to the state store and records it within the
+// config history.
+func PushUserConfig(c Config, store state.StateStore, author string) error {
 	if c.UserConfig() == nil {
 		return nil
 	}
 
-	if err := pushToConsul(c, consul); err != nil {
-		return fmt.Errorf("failed to write to consul: %s", err)
+	if err := pushRevision(c, store, author, 0); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func SyncUserConfig(c Config, consul *state.Store) error {
-	if os.Getenv("UNIT_TESTING") != "" {
-		return nil
-	}
-
-	cfg, err := pullFromConsul(c, consul)
+func SyncUserConfig(c Config, store state.StateStore) error {
+	cfg, err := pullFromStore(c, store)
 	if err != nil {
-		return fmt.Errorf("failed to pull config from consul: %s", err)
+		return fmt.Errorf("failed to pull config from state store: %s", err)
 	}
 	if cfg == nil {
 		return nil
@@ -73,25 +106,8 @@ func SyncUserConfig(c Config, consul *state.Store) error {
 	return nil
 }
 
-func pushToConsul(c Config, consul *state.Store) error {
-	if c.UserConfig() == nil {
-		return nil
-	}
-
-	configBytes, err := json.Marshal(c.UserConfig())
-	if err != nil {
-		return fmt.Errorf("failed to marshal user config: %s", err)
-	}
-
-	if err := consul.PushUserConfig(c.ConsulKey(), configBytes); err != nil {
-		return fmt.Errorf("failed to push user config to consul: %s", err)
-	}
-
-	return nil
-}
-
-func pullFromConsul(c Config, consul *state.Store) (ConfigMap, error) {
-	configBytes, err := consul.PullUserConfig(c.ConsulKey())
+func pullFromStore(c Config, store state.StateStore) (ConfigMap, error) {
+	configBytes, err := store.PullUserConfig(c.ConsulKey())
 	if err != nil {
 		return nil, err
 	}
@@ -107,6 +123,16 @@ func pullFromConsul(c Config, consul *state.Store) (ConfigMap, error) {
 	return storeCfg, nil
 }
 
+// formatConfigValue formats a setting for the config files. Numbers pulled from the state store
+// are float64s, which would otherwise be written with an exponent once they grow large.
+func formatConfigValue(value any) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	return fmt.Sprint(value)
+}
+
 func WriteConfigFiles(c Config) error {
 	if err := writeUserConfigFile(c); err != nil {
 		return fmt.Errorf("failed to write user config: %s", err)
@@ -129,10 +155,12 @@ func ReadFromFile(path string) (ConfigMap, error) {
 	conf := ConfigMap{}
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		lineArr := strings.Split(scanner.Text(), "=")
-		key := strings.TrimSpace(lineArr[0])
-		value := strings.TrimSpace(lineArr[1])
-		conf[key] = value
+		// Values may contain `=`, e.g. conninfo strings, URLs or base64 encoded secrets.
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		conf[strings.TrimSpace(key)] = strings.TrimSpace(value)
 	}
 
 	return conf, nil
@@ -148,7 +176,7 @@ func writeInternalConfigFile(c Config) error {
 	internal := c.InternalConfig()
 
 	for key, value := range internal {
-		entry := fmt.Sprintf("%s = %v\n", key, value)
+		entry := fmt.Sprintf("%s = %s\n", key, formatConfigValue(value))
 		if _, err := file.Write([]byte(entry)); err != nil {
 			return fmt.Errorf("failed to write to file: %s", err)
 		}
@@ -160,10 +188,6 @@ func writeInternalConfigFile(c Config) error {
 		return fmt.Errorf("failed to close file: %s", err)
 	}
 
-	if os.Getenv("UNIT_TESTING") != "" {
-		return nil
-	}
-
 	if err := utils.SetFileOwnership(c.InternalConfigFile(), "postgres"); err != nil {
 		return fmt.Errorf("failed to set file ownership on %s: %s", c.InternalConfigFile(), err)
 	}
@@ -179,7 +203,7 @@ func writeUserConfigFile(c Config) error {
 	defer func() { _ = file.Close() }()
 
 	for key, value := range c.UserConfig() {
-		entry := fmt.Sprintf("%s = %v\n", key, value)
+		entry := fmt.Sprintf("%s = %s\n", key, formatConfigValue(value))
 		if _, err := file.Write([]byte(entry)); err != nil {
 			return fmt.Errorf("failed to write to file: %s", err)
 		}
@@ -191,10 +215,6 @@ func writeUserConfigFile(c Config) error {
 		return fmt.Errorf("failed to close file: %s", err)
 	}
 
-	if os.Getenv("UNIT_TESTING") != "" {
-		return nil
-	}
-
 	if err := utils.SetFileOwnership(c.UserConfigFile(), "postgres"); err != nil {
 		return fmt.Errorf("failed to set file ownership on %s: %s", c.UserConfigFile(), err)
 	}
@@ -0,0 +1,435 @@
+package flypg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+const (
+	ApplyConfigEndpoint        = "commands/admin/settings/apply"
+	ClusterApplyConfigEndpoint = "commands/admin/settings/apply/cluster"
+	RestartPostgresEndpoint    = "commands/admin/postgres/restart"
+	StandbySwitchoverEndpoint  = "commands/admin/switchover/standby"
+
+	ConfigApplyRunning   = "running"
+	ConfigApplyCompleted = "completed"
+	ConfigApplyFailed    = "failed"
+
+	ConfigApplyActionApply      = "apply"
+	ConfigApplyActionRestart    = "restart"
+	ConfigApplyActionSwitchover = "switchover"
+
+	configApplyKey = "operations/config_apply"
+)
+
+var (
+	// ErrConfigApplyInProgress - Another cluster-wide config apply is currently running.
+	ErrConfigApplyInProgress = errors.New("a cluster-wide config apply is already in progress")
+	// ErrConfigApplyNotFound - No cluster-wide config apply has been recorded.
+	ErrConfigApplyNotFound = errors.New("no cluster-wide config apply has been recorded")
+
+	// configApplyStaleThreshold is the duration after which a running operation is considered
+	// abandoned, e.g. because the coordinator was restarted mid-flight.
+	configApplyStaleThreshold = time.Hour * 2
+
+	memberRequestTimeout  = time.Minute * 10
+	memberRecoveryTimeout = time.Minute * 5
+	memberPollFrequency   = time.Second * 2
+)
+
+// ConfigApplyOperation tracks the progress of a cluster-wide config apply.
+type ConfigApplyOperation struct {
+	ID             string            `json:"id"`
+	Author         string            `json:"author"`
+	Status         string            `json:"status"`
+	PendingRestart []string          `json:"pending_restart,omitempty"`
+	Steps          []ConfigApplyStep `json:"steps"`
+	Error          string            `json:"error,omitempty"`
+	StartedAt      time.Time         `json:"started_at"`
+	FinishedAt     time.Time         `json:"finished_at,omitempty"`
+}
+
+// ConfigApplyStep represents a single action taken against a member.
+type ConfigApplyStep struct {
+	Member     string    `json:"member"`
+	Action     string    `json:"action"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// StartClusterConfigApply registers a new cluster-wide config apply. Only one operation
+// may be running at any given time.
+func StartClusterConfigApply(store state.StateStore, author string) (*ConfigApplyOperation, error) {
+	current, err := store.PullUserConfig(configApplyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve current config apply: %s", err)
+	}
+
+	if current != nil {
+		var existing ConfigApplyOperation
+		if err := json.Unmarshal(current, &existing); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal current config apply: %s", err)
+		}
+
+		if existing.Status == ConfigApplyRunning && time.Since(existing.StartedAt) < configApplyStaleThreshold {
+			return nil, ErrConfigApplyInProgress
+		}
+	}
+
+	now := time.Now().UTC()
+	op := &ConfigApplyOperation{
+		ID:        fmt.Sprint(now.UnixNano()),
+		Author:    author,
+		Status:    ConfigApplyRunning,
+		Steps:     []ConfigApplyStep{},
+		StartedAt: now,
+	}
+
+	opBytes, err := json.Marshal(op)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config apply: %s", err)
+	}
+
+	swapped, err := store.CompareAndSwap(configApplyKey, current, opBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register config apply: %s", err)
+	}
+
+	if !swapped {
+		return nil, ErrConfigApplyInProgress
+	}
+
+	return op, nil
+}
+
+// ClusterConfigApplyStatus returns the most recent cluster-wide config apply.
+func ClusterConfigApplyStatus(store state.StateStore) (*ConfigApplyOperation, error) {
+	opBytes, err := store.PullUserConfig(configApplyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull config apply: %s", err)
+	}
+
+	if opBytes == nil {
+		return nil, ErrConfigApplyNotFound
+	}
+
+	var op ConfigApplyOperation
+	if err := json.Unmarshal(opBytes, &op); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config apply: %s", err)
+	}
+
+	return &op, nil
+}
+
+// RunClusterConfigApply must be run on the primary. It will:
+// * Instruct every member to sync and reload its config.
+// * Restart standbys one at a time when settings are pending a restart, waiting for each to catch up.
+// * Switch over to an in-region standby and restart the old primary last.
+func RunClusterConfigApply(ctx context.Context, n *Node, store state.StateStore, op *ConfigApplyOperation) error {
+	err := op.run(ctx, n, store)
+
+	op.Status = ConfigApplyCompleted
+	if err != nil {
+		op.Status = ConfigApplyFailed
+		op.Error = err.Error()
+	}
+	op.FinishedAt = time.Now().UTC()
+	op.persist(store)
+
+	return err
+}
+
+func (op *ConfigApplyOperation) run(ctx context.Context, n *Node, store state.StateStore) error {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	primary, err := n.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to resolve member: %s", err)
+	}
+
+	if primary.Role != PrimaryRoleName {
+		return fmt.Errorf("config apply must be coordinated by the primary")
+	}
+
+	members, err := n.RepMgr.Members(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to query members: %s", err)
+	}
+
+	var peers []Member
+	for _, member := range members {
+		if member.ID == primary.ID {
+			continue
+		}
+
+		if !member.Active {
+			log.Printf("[WARN] Skipping inactive member %s", member.Hostname)
+			continue
+		}
+
+		peers = append(peers, member)
+	}
+
+	// Sync and reload the config across every member, starting with the primary.
+	for _, member := range append([]Member{*primary}, peers...) {
+		if err := op.runStep(store, member.Hostname, ConfigApplyActionApply, func() error {
+			return requestMember(ctx, http.MethodPost, member.Hostname, ApplyConfigEndpoint)
+		}); err != nil {
+			return err
+		}
+	}
+
+	pending, err := admin.PendingRestartSettings(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to resolve settings pending restart: %s", err)
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	op.PendingRestart = pending
+	op.persist(store)
+
+	if err := conn.Close(ctx); err != nil {
+		return fmt.Errorf("failed to close connection: %s", err)
+	}
+
+	delayed, err := DelayedMembers(store)
+	if err != nil {
+		return err
+	}
+
+	// Restart standbys one at a time, waiting on each to catch up before moving on. Delayed
+	// replicas won't catch up by design, so only wait for them to come back up.
+	for _, member := range peers {
+		_, isDelayed := delayed[member.Name]
+
+		if err := op.runStep(store, member.Hostname, ConfigApplyActionRestart, func() error {
+			if err := requestMember(ctx, http.MethodPost, member.Hostname, RestartPostgresEndpoint); err != nil {
+				return err
+			}
+
+			if member.Role == StandbyRoleName && !isDelayed {
+				return waitForStandbyCatchUp(ctx, n, n.RepMgr.PrivateIP, member)
+			}
+
+			return waitForMember(ctx, n, member.Hostname)
+		}); err != nil {
+			return err
+		}
+	}
+
+	candidate := switchoverCandidate(n, peers, delayed)
+	if candidate == nil {
+		log.Println("[WARN] No eligible standby available for switchover. Restarting primary in place.")
+
+		return op.runStep(store, primary.Hostname, ConfigApplyActionRestart, func() error {
+			if err := RestartPostgres(ctx, n.DataDir); err != nil {
+				return err
+			}
+
+			return waitForMember(ctx, n, n.RepMgr.PrivateIP)
+		})
+	}
+
+	if err := op.runStep(store, candidate.Hostname, ConfigApplyActionSwitchover, func() error {
+		return switchover(ctx, n, *candidate, members)
+	}); err != nil {
+		return err
+	}
+
+	// The switchover will typically restart the old primary, so verify whether a restart is
+	// still necessary.
+	localConn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = localConn.Close(ctx) }()
+
+	pending, err = admin.PendingRestartSettings(ctx, localConn)
+	if err != nil {
+		return fmt.Errorf("failed to resolve settings pending restart: %s", err)
+	}
+
+	if err := localConn.Close(ctx); err != nil {
+		return fmt.Errorf("failed to close connection: %s", err)
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	return op.runStep(store, primary.Hostname, ConfigApplyActionRestart, func() error {
+		if err := RestartPostgres(ctx, n.DataDir); err != nil {
+			return err
+		}
+
+		return waitForStandbyCatchUp(ctx, n, candidate.Hostname, *primary)
+	})
+}
+
+func (op *ConfigApplyOperation) runStep(store state.StateStore, member, action string, fn func() error) error {
+	op.Steps = append(op.Steps, ConfigApplyStep{
+		Member:    member,
+		Action:    action,
+		Status:    ConfigApplyRunning,
+		StartedAt: time.Now().UTC(),
+	})
+	op.persist(store)
+
+	step := &op.Steps[len(op.Steps)-1]
+
+	err := fn()
+
+	step.Status = ConfigApplyCompleted
+	if err != nil {
+		step.Status = ConfigApplyFailed
+		step.Error = err.Error()
+	}
+	step.FinishedAt = time.Now().UTC()
+	op.persist(store)
+
+	if err != nil {
+		return fmt.Errorf("failed to %s member %s: %s", action, member, err)
+	}
+
+	return nil
+}
+
+func (op *ConfigApplyOperation) persist(store state.StateStore) {
+	opBytes, err := json.Marshal(op)
+	if err != nil {
+		log.Printf("[WARN] Failed to marshal config apply: %s", err)
+		return
+	}
+
+	if err := store.PushUserConfig(configApplyKey, opBytes); err != nil {
+		log.Printf("[WARN] Failed to record config apply progress: %s", err)
+	}
+}
+
+// switchoverCandidate returns the first standby that is eligible for promotion. Delayed
+// replicas are never considered.
+func switchoverCandidate(n *Node, peers []Member, delayed map[string]string) *Member {
+	for _, member := range peers {
+		if _, ok := delayed[member.Name]; ok {
+			continue
+		}
+
+		if member.Role == StandbyRoleName && member.Region == n.PrimaryRegion {
+			return &member
+		}
+	}
+
+	return nil
+}
+
+// waitForStandbyCatchUp waits for the standby to stream from the primary and replay
+// everything the primary had written at the time of the call.
+func waitForStandbyCatchUp(ctx context.Context, n *Node, primaryHost string, standby Member) error {
+	conn, err := n.RepMgr.NewRemoteConnection(ctx, primaryHost)
+	if err != nil {
+		return fmt.Errorf("failed to connect to primary: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	lsn, err := admin.CurrentWALLSN(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to resolve current wal lsn: %s", err)
+	}
+
+	ticker := time.NewTicker(memberPollFrequency)
+	defer ticker.Stop()
+	timeout := time.After(memberRecoveryTimeout)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for %s to catch up to %s", standby.Hostname, lsn)
+		case <-ticker.C:
+			caughtUp, err := admin.StandbyReplayedTo(ctx, conn, standby.ApplicationName(), lsn)
+			if err != nil {
+				return fmt.Errorf("failed to verify replication state: %s", err)
+			}
+
+			if caughtUp {
+				return nil
+			}
+		}
+	}
+}
+
+// waitForMember waits for Postgres on the specified host to accept connections.
+func waitForMember(ctx context.Context, n *Node, hostname string) error {
+	ticker := time.NewTicker(memberPollFrequency)
+	defer ticker.Stop()
+	timeout := time.After(memberRecoveryTimeout)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for %s to accept connections", hostname)
+		case <-ticker.C:
+			conn, err := n.RepMgr.NewRemoteConnection(ctx, hostname)
+			if err != nil {
+				continue
+			}
+
+			err = conn.Ping(ctx)
+			_ = conn.Close(ctx)
+			if err == nil {
+				return nil
+			}
+		}
+	}
+}
+
+// waitForLocalRole waits for the local member to be registered with the specified role.
+func waitForLocalRole(ctx context.Context, n *Node, role string) error {
+	ticker := time.NewTicker(memberPollFrequency)
+	defer ticker.Stop()
+	timeout := time.After(memberRecoveryTimeout)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for local member to become %s", role)
+		case <-ticker.C:
+			conn, err := n.RepMgr.NewLocalConnection(ctx)
+			if err != nil {
+				continue
+			}
+
+			member, err := n.RepMgr.Member(ctx, conn)
+			_ = conn.Close(ctx)
+			if err != nil {
+				continue
+			}
+
+			if member.Role == role {
+				return nil
+			}
+		}
+	}
+}
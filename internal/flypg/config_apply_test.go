@@ -0,0 +1,153 @@
+package flypg
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+func TestStartClusterConfigApply(t *testing.T) {
+	t.Run("no previous operation", func(t *testing.T) {
+		store := state.NewMemoryStore()
+
+		if _, err := ClusterConfigApplyStatus(store); !errors.Is(err, ErrConfigApplyNotFound) {
+			t.Fatalf("expected ErrConfigApplyNotFound, got %v", err)
+		}
+
+		op, err := StartClusterConfigApply(store, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if op.Status != ConfigApplyRunning {
+			t.Fatalf("expected status to be %s, got %s", ConfigApplyRunning, op.Status)
+		}
+
+		current, err := ClusterConfigApplyStatus(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if current.ID != op.ID || current.Author != "alice" {
+			t.Fatalf("unexpected operation: %+v", current)
+		}
+	})
+
+	t.Run("operation in progress", func(t *testing.T) {
+		store := state.NewMemoryStore()
+
+		if _, err := StartClusterConfigApply(store, "alice"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := StartClusterConfigApply(store, "bob"); !errors.Is(err, ErrConfigApplyInProgress) {
+			t.Fatalf("expected ErrConfigApplyInProgress, got %v", err)
+		}
+	})
+
+	t.Run("previous operation finished", func(t *testing.T) {
+		store := state.NewMemoryStore()
+
+		op, err := StartClusterConfigApply(store, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		op.Status = ConfigApplyFailed
+		op.persist(store)
+
+		if _, err := StartClusterConfigApply(store, "bob"); err != nil {
+			t.Fatalf("expected new operation to start, got %v", err)
+		}
+	})
+
+	t.Run("stale operation", func(t *testing.T) {
+		store := state.NewMemoryStore()
+
+		stale := &ConfigApplyOperation{
+			ID:        "stale",
+			Status:    ConfigApplyRunning,
+			StartedAt: time.Now().Add(-configApplyStaleThreshold - time.Minute),
+		}
+
+		staleBytes, err := json.Marshal(stale)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.PushUserConfig(configApplyKey, staleBytes); err != nil {
+			t.Fatal(err)
+		}
+
+		op, err := StartClusterConfigApply(store, "bob")
+		if err != nil {
+			t.Fatalf("expected stale operation to be replaced, got %v", err)
+		}
+
+		if op.ID == "stale" {
+			t.Fatal("expected a new operation")
+		}
+	})
+}
+
+func TestConfigApplyRunStep(t *testing.T) {
+	store := state.NewMemoryStore()
+
+	op, err := StartClusterConfigApply(store, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := op.runStep(store, "member-1", ConfigApplyActionApply, func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := op.runStep(store, "member-2", ConfigApplyActionRestart, func() error { return errors.New("boom") }); err == nil {
+		t.Fatal("expected step to fail")
+	}
+
+	current, err := ClusterConfigApplyStatus(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(current.Steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(current.Steps))
+	}
+
+	if current.Steps[0].Status != ConfigApplyCompleted {
+		t.Fatalf("expected first step to be completed, got %s", current.Steps[0].Status)
+	}
+
+	if current.Steps[1].Status != ConfigApplyFailed || current.Steps[1].Error != "boom" {
+		t.Fatalf("expected second step to have failed, got %+v", current.Steps[1])
+	}
+}
+
+func TestSwitchoverCandidate(t *testing.T) {
+	node := &Node{PrimaryRegion: "ord"}
+
+	peers := []Member{
+		{Name: "3d8d9925f15e89", Role: StandbyRoleName, Region: "lax"},
+		{Name: "148ed193b95d89", Role: StandbyRoleName, Region: "ord"},
+		{Name: "6e8226ec711087", Role: StandbyRoleName, Region: "ord"},
+	}
+
+	t.Run("SkipsDelayedReplicas", func(t *testing.T) {
+		candidate := switchoverCandidate(node, peers, map[string]string{"148ed193b95d89": "1h0m0s"})
+		if candidate == nil || candidate.Name != "6e8226ec711087" {
+			t.Fatalf("expected 6e8226ec711087 to be selected, got %+v", candidate)
+		}
+	})
+
+	t.Run("OnlyDelayedReplicas", func(t *testing.T) {
+		delayed := map[string]string{"148ed193b95d89": "1h0m0s", "6e8226ec711087": "1h0m0s"}
+
+		if candidate := switchoverCandidate(node, peers, delayed); candidate != nil {
+			t.Fatalf("expected no candidate, got %+v", candidate)
+		}
+	})
+}
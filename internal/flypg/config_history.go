@@ -0,0 +1,281 @@
+package flypg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+// ErrConfigRevisionNotFound - The requested config revision does not exist.
+var ErrConfigRevisionNotFound = errors.New("config revision not found")
+
+// maxRevisionClaimAttempts bounds the number of times we will retry claiming a revision
+// number, or swapping in the config, when racing with another writer.
+const maxRevisionClaimAttempts = 10
+
+// ConfigRevision is an immutable snapshot of a pushed user config.
+type ConfigRevision struct {
+	Revision   int            `json:"revision"`
+	Author     string         `json:"author"`
+	CreatedAt  time.Time      `json:"created_at"`
+	Config     ConfigMap      `json:"config"`
+	Changes    []ConfigChange `json:"changes"`
+	RollbackOf int            `json:"rollback_of,omitempty"`
+}
+
+// ConfigChange describes a single setting that differs from the previous revision.
+type ConfigChange struct {
+	Key      string `json:"key"`
+	Previous any    `json:"previous,omitempty"`
+	Current  any    `json:"current,omitempty"`
+}
+
+// ConfigHistory returns every recorded revision for the specified config, oldest first.
+func ConfigHistory(c Config, store state.StateStore) ([]ConfigRevision, error) {
+	head, err := revisionHead(c, store)
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []ConfigRevision
+	for rev := 1; rev <= head; rev++ {
+		revision, err := FindConfigRevision(c, store, rev)
+		if err != nil {
+			// A revision number may have been claimed by a writer that failed
+			// before it could be recorded.
+			if errors.Is(err, ErrConfigRevisionNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		revisions = append(revisions, *revision)
+	}
+
+	return revisions, nil
+}
+
+// FindConfigRevision returns the specified revision of a config.
+func FindConfigRevision(c Config, store state.StateStore, rev int) (*ConfigRevision, error) {
+	revBytes, err := store.PullUserConfig(revisionKey(c, rev))
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull revision %d: %s", rev, err)
+	}
+
+	if revBytes == nil {
+		return nil, ErrConfigRevisionNotFound
+	}
+
+	var revision ConfigRevision
+	if err := json.Unmarshal(revBytes, &revision); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal revision %d: %s", rev, err)
+	}
+
+	// Revisions recorded before secrets were redacted may still hold them.
+	revision.redact()
+
+	return &revision, nil
+}
+
+func (r *ConfigRevision) redact() {
+	r.Config = RedactConfig(r.Config)
+	for i, change := range r.Changes {
+		r.Changes[i].Previous = RedactSetting(change.Key, change.Previous)
+		r.Changes[i].Current = RedactSetting(change.Key, change.Current)
+	}
+}
+
+// RollbackUserConfig re-applies the user config captured by the specified revision.
+// The rollback itself is recorded as a new revision. Secrets aren't recorded within
+// the history, so their current values are left in place.
+func RollbackUserConfig(c Config, store state.StateStore, revision *ConfigRevision, author string) error {
+	current, err := pullFromStore(c, store)
+	if err != nil {
+		return fmt.Errorf("failed to pull current config: %s", err)
+	}
+
+	cfg := ConfigMap{}
+	for k, v := range revision.Config {
+		if !secretSettings[k] {
+			cfg[k] = v
+		}
+	}
+
+	for k := range secretSettings {
+		if v, ok := current[k]; ok {
+			cfg[k] = v
+		}
+	}
+
+	c.SetUserConfig(cfg)
+
+	return pushRevision(c, store, author, revision.Revision)
+}
+
+// pushRevision writes the user config to the state store and records it as a new revision.
+// The revision number is claimed up front, so concurrent writers never share a revision.
+func pushRevision(c Config, store state.StateStore, author string, rollbackOf int) error {
+	rev, err := claimRevision(c, store)
+	if err != nil {
+		return fmt.Errorf("failed to claim config revision: %s", err)
+	}
+
+	previous, err := swapUserConfig(c, store)
+	if err != nil {
+		return fmt.Errorf("failed to write to state store: %s", err)
+	}
+
+	configPushes.Inc(c.ConsulKey())
+
+	if _, err := recordConfigRevision(c, store, rev, previous, author, rollbackOf); err != nil {
+		return fmt.Errorf("failed to record config revision: %s", err)
+	}
+
+	return nil
+}
+
+// swapUserConfig replaces the user config within the state store and returns the config it
+// replaced, so the revision's changes are diffed against what was actually overwritten.
+func swapUserConfig(c Config, store state.StateStore) (ConfigMap, error) {
+	configBytes, err := json.Marshal(c.UserConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal user config: %s", err)
+	}
+
+	for range maxRevisionClaimAttempts {
+		current, err := store.PullUserConfig(c.ConsulKey())
+		if err != nil {
+			return nil, fmt.Errorf("failed to pull current config: %s", err)
+		}
+
+		swapped, err := store.CompareAndSwap(c.ConsulKey(), current, configBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to push user config to state store: %s", err)
+		}
+
+		if !swapped {
+			continue
+		}
+
+		var previous ConfigMap
+		if current != nil {
+			if err := json.Unmarshal(current, &previous); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal previous config: %s", err)
+			}
+		}
+
+		return previous, nil
+	}
+
+	return nil, fmt.Errorf("failed to push user config after %d attempts", maxRevisionClaimAttempts)
+}
+
+// recordConfigRevision stores the current user config as the specified revision. Secrets
+// are redacted ahead of time, as revisions are kept indefinitely.
+func recordConfigRevision(c Config, store state.StateStore, rev int, previous ConfigMap, author string, rollbackOf int) (*ConfigRevision, error) {
+	revision := &ConfigRevision{
+		Revision:   rev,
+		Author:     author,
+		CreatedAt:  time.Now().UTC(),
+		Config:     c.UserConfig(),
+		Changes:    diffConfig(previous, c.UserConfig()),
+		RollbackOf: rollbackOf,
+	}
+	revision.redact()
+
+	revBytes, err := json.Marshal(revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal revision: %s", err)
+	}
+
+	// Revisions are immutable, so refuse to overwrite an existing entry.
+	recorded, err := store.CompareAndSwap(revisionKey(c, rev), nil, revBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write revision %d: %s", rev, err)
+	}
+
+	if !recorded {
+		return nil, fmt.Errorf("revision %d already exists", rev)
+	}
+
+	return revision, nil
+}
+
+// claimRevision atomically increments the revision head and returns the claimed number.
+func claimRevision(c Config, store state.StateStore) (int, error) {
+	for range maxRevisionClaimAttempts {
+		current, err := store.PullUserConfig(revisionHeadKey(c))
+		if err != nil {
+			return 0, fmt.Errorf("failed to resolve revision head: %s", err)
+		}
+
+		head := 0
+		if current != nil {
+			head, err = strconv.Atoi(string(current))
+			if err != nil {
+				return 0, fmt.Errorf("failed to parse revision head: %s", err)
+			}
+		}
+
+		next := head + 1
+
+		swapped, err := store.CompareAndSwap(revisionHeadKey(c), current, []byte(strconv.Itoa(next)))
+		if err != nil {
+			return 0, fmt.Errorf("failed to claim revision: %s", err)
+		}
+
+		if swapped {
+			return next, nil
+		}
+	}
+
+	return 0, fmt.Errorf("failed to claim revision after %d attempts", maxRevisionClaimAttempts)
+}
+
+func revisionHead(c Config, store state.StateStore) (int, error) {
+	current, err := store.PullUserConfig(revisionHeadKey(c))
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve revision head: %s", err)
+	}
+
+	if current == nil {
+		return 0, nil
+	}
+
+	return strconv.Atoi(string(current))
+}
+
+func diffConfig(previous, current ConfigMap) []ConfigChange {
+	changes := []ConfigChange{}
+
+	for k, v := range current {
+		prev, ok := previous[k]
+		if !ok || fmt.Sprint(prev) != fmt.Sprint(v) {
+			changes = append(changes, ConfigChange{Key: k, Previous: prev, Current: v})
+		}
+	}
+
+	for k, v := range previous {
+		if _, ok := current[k]; !ok {
+			changes = append(changes, ConfigChange{Key: k, Previous: v})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes
+}
+
+func revisionHeadKey(c Config) string {
+	return fmt.Sprintf("history/%s/head", c.ConsulKey())
+}
+
+func revisionKey(c Config, rev int) string {
+	return fmt.Sprintf("history/%s/%d", c.ConsulKey(), rev)
+}
@@ -0,0 +1,246 @@
+package flypg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+func TestConfigHistory(t *testing.T) {
+	if err := setup(t); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	store := state.NewMemoryStore()
+
+	pgConf := &PGConfig{
+		DataDir:                pgTestDirectory,
+		Port:                   5433,
+		ConfigFilePath:         pgConfigFilePath,
+		InternalConfigFilePath: pgInternalConfigFilePath,
+		UserConfigFilePath:     pgUserConfigFilePath,
+		passwordFilePath:       pgPasswordFilePath,
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		history, err := ConfigHistory(pgConf, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(history) != 0 {
+			t.Fatalf("expected no revisions, got %d", len(history))
+		}
+	})
+
+	pgConf.SetUserConfig(ConfigMap{"log_statement": "ddl", "max_connections": "100"})
+	if err := PushUserConfig(pgConf, store, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	pgConf.SetUserConfig(ConfigMap{"log_statement": "all", "work_mem": "8MB"})
+	if err := PushUserConfig(pgConf, store, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("revisions", func(t *testing.T) {
+		history, err := ConfigHistory(pgConf, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(history) != 2 {
+			t.Fatalf("expected 2 revisions, got %d", len(history))
+		}
+
+		first := history[0]
+		if first.Revision != 1 || first.Author != "alice" {
+			t.Fatalf("unexpected first revision: %+v", first)
+		}
+
+		if len(first.Changes) != 2 {
+			t.Fatalf("expected 2 changes in first revision, got %+v", first.Changes)
+		}
+
+		second := history[1]
+		if second.Revision != 2 || second.Author != "bob" {
+			t.Fatalf("unexpected second revision: %+v", second)
+		}
+
+		expected := []ConfigChange{
+			{Key: "log_statement", Previous: "ddl", Current: "all"},
+			{Key: "max_connections", Previous: "100"},
+			{Key: "work_mem", Current: "8MB"},
+		}
+
+		if len(second.Changes) != len(expected) {
+			t.Fatalf("expected %d changes, got %+v", len(expected), second.Changes)
+		}
+
+		for i, change := range second.Changes {
+			if change.Key != expected[i].Key || change.Previous != expected[i].Previous || change.Current != expected[i].Current {
+				t.Fatalf("expected change %+v, got %+v", expected[i], change)
+			}
+		}
+	})
+
+	t.Run("missing revision", func(t *testing.T) {
+		_, err := FindConfigRevision(pgConf, store, 10)
+		if !errors.Is(err, ErrConfigRevisionNotFound) {
+			t.Fatalf("expected ErrConfigRevisionNotFound, got %v", err)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		revision, err := FindConfigRevision(pgConf, store, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := RollbackUserConfig(pgConf, store, revision, "carol"); err != nil {
+			t.Fatal(err)
+		}
+
+		latest, err := FindConfigRevision(pgConf, store, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if latest.RollbackOf != 1 {
+			t.Fatalf("expected rollback of revision 1, got %d", latest.RollbackOf)
+		}
+
+		if latest.Config["log_statement"] != "ddl" {
+			t.Fatalf("expected log_statement to be ddl, got %v", latest.Config["log_statement"])
+		}
+
+		cfg, err := pullFromStore(pgConf, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg["max_connections"] != "100" {
+			t.Fatalf("expected state store to contain rolled back config, got %v", cfg)
+		}
+	})
+}
+
+func TestConfigHistorySecrets(t *testing.T) {
+	store := state.NewMemoryStore()
+	flyConf := &FlyPGConfig{}
+
+	flyConf.SetUserConfig(ConfigMap{"webhookSecret": "first-secret", "webhookURLs": "https://example.com/hook"})
+	if err := PushUserConfig(flyConf, store, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	flyConf.SetUserConfig(ConfigMap{"webhookSecret": "second-secret", "webhookURLs": "https://example.com/other"})
+	if err := PushUserConfig(flyConf, store, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("redacted", func(t *testing.T) {
+		for rev := 1; rev <= 2; rev++ {
+			raw, err := store.PullUserConfig(revisionKey(flyConf, rev))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.Contains(string(raw), "first-secret") || strings.Contains(string(raw), "second-secret") {
+				t.Fatalf("expected revision %d to be stored without secrets, got %s", rev, raw)
+			}
+		}
+
+		history, err := ConfigHistory(flyConf, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if history[1].Config["webhookSecret"] != redactedSetting {
+			t.Fatalf("expected webhookSecret to be redacted, got %v", history[1].Config["webhookSecret"])
+		}
+
+		for _, change := range history[1].Changes {
+			if change.Key == "webhookSecret" && (change.Previous != redactedSetting || change.Current != redactedSetting) {
+				t.Fatalf("expected webhookSecret change to be redacted, got %+v", change)
+			}
+		}
+	})
+
+	t.Run("rollback keeps the current secret", func(t *testing.T) {
+		revision, err := FindConfigRevision(flyConf, store, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := RollbackUserConfig(flyConf, store, revision, "carol"); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := pullFromStore(flyConf, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg["webhookURLs"] != "https://example.com/hook" {
+			t.Fatalf("expected webhookURLs to be rolled back, got %v", cfg["webhookURLs"])
+		}
+
+		if cfg["webhookSecret"] != "second-secret" {
+			t.Fatalf("expected webhookSecret to be left in place, got %v", cfg["webhookSecret"])
+		}
+	})
+}
+
+func TestConfigHistoryConcurrentPushes(t *testing.T) {
+	store := state.NewMemoryStore()
+
+	const writers = 5
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			pgConf := &PGConfig{}
+			pgConf.SetUserConfig(ConfigMap{"work_mem": fmt.Sprintf("%dMB", i+1)})
+			errs <- PushUserConfig(pgConf, store, fmt.Sprintf("writer-%d", i))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := ConfigHistory(&PGConfig{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history) != writers {
+		t.Fatalf("expected %d revisions, got %d", writers, len(history))
+	}
+
+	authors := map[string]bool{}
+	for i, revision := range history {
+		if revision.Revision != i+1 {
+			t.Fatalf("expected revision %d, got %d", i+1, revision.Revision)
+		}
+		authors[revision.Author] = true
+	}
+
+	if len(authors) != writers {
+		t.Fatalf("expected a revision per writer, got %v", authors)
+	}
+}
@@ -0,0 +1,138 @@
+package flypg
+
+import (
+	"os"
+	"testing"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+func TestUserConfigSync(t *testing.T) {
+	if err := setup(t); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	store := state.NewMemoryStore()
+
+	pgConf := &PGConfig{
+		DataDir:                pgTestDirectory,
+		Port:                   5433,
+		ConfigFilePath:         pgConfigFilePath,
+		InternalConfigFilePath: pgInternalConfigFilePath,
+		UserConfigFilePath:     pgUserConfigFilePath,
+		passwordFilePath:       pgPasswordFilePath,
+	}
+
+	if err := stubPGConfigFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pgConf.initialize(store); err != nil {
+		t.Fatal(err)
+	}
+
+	pgConf.SetUserConfig(ConfigMap{"log_statement": "ddl"})
+
+	if err := PushUserConfig(pgConf, store, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A separate member syncing from the same store should pick up the change.
+	member := &PGConfig{
+		DataDir:                pgTestDirectory,
+		Port:                   5433,
+		ConfigFilePath:         pgConfigFilePath,
+		InternalConfigFilePath: pgInternalConfigFilePath,
+		UserConfigFilePath:     pgUserConfigFilePath,
+		passwordFilePath:       pgPasswordFilePath,
+	}
+
+	if err := SyncUserConfig(member, store); err != nil {
+		t.Fatal(err)
+	}
+
+	if member.UserConfig()["log_statement"] != "ddl" {
+		t.Fatalf("expected log_statement to be ddl, got %v", member.UserConfig()["log_statement"])
+	}
+
+	cfg, err := ReadFromFile(member.UserConfigFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg["log_statement"] != "ddl" {
+		t.Fatalf("expected user config file to contain log_statement, got %v", cfg)
+	}
+}
+
+func TestReadFromFile(t *testing.T) {
+	if err := setup(t); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	path := pgTestDirectory + "/read_from_file.conf"
+	contents := "webhookURLs = https://example.com/hook?token=abc==\nport = 5433\n\ninvalid-line\n"
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := ReadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg["webhookURLs"] != "https://example.com/hook?token=abc==" {
+		t.Fatalf("expected value containing '=' to be preserved, got %v", cfg["webhookURLs"])
+	}
+
+	if cfg["port"] != "5433" {
+		t.Fatalf("expected port to be 5433, got %v", cfg["port"])
+	}
+
+	if len(cfg) != 2 {
+		t.Fatalf("expected 2 settings, got %d", len(cfg))
+	}
+}
+
+func TestRedactConfig(t *testing.T) {
+	cfg := ConfigMap{
+		"webhookSecret": "my-secret",
+		"webhookURLs":   "https://example.com/hook",
+	}
+
+	redacted := RedactConfig(cfg)
+
+	if redacted["webhookSecret"] != redactedSetting {
+		t.Fatalf("expected webhookSecret to be redacted, got %v", redacted["webhookSecret"])
+	}
+
+	if redacted["webhookURLs"] != "https://example.com/hook" {
+		t.Fatalf("expected webhookURLs to be left as is, got %v", redacted["webhookURLs"])
+	}
+
+	if cfg["webhookSecret"] != "my-secret" {
+		t.Fatal("expected the original config to be left untouched")
+	}
+
+	if RedactSetting("webhookSecret", "") != "" {
+		t.Fatal("expected an unset secret to remain empty")
+	}
+}
+
+func TestFormatConfigValue(t *testing.T) {
+	tests := map[any]string{
+		float64(104857600): "104857600",
+		0.9:                "0.9",
+		16777216:           "16777216",
+		"'replica'":        "'replica'",
+		true:               "true",
+	}
+
+	for value, expected := range tests {
+		if got := formatConfigValue(value); got != expected {
+			t.Fatalf("expected %v to be formatted as %s, got %s", value, expected, got)
+		}
+	}
+}
@@ -0,0 +1,560 @@
+package flypg
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+	"github.com/fly-apps/postgres-flex/internal/utils"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	SyncCredentialsEndpoint = "commands/admin/credentials/sync"
+
+	credentialRotationsKey           = "credential_rotations"
+	maxCredentialRotationUpdateTries = 5
+
+	minRotatedPasswordLength = 16
+)
+
+var (
+	// ErrUnknownInternalUser - Only the internal users backed by a secret can be rotated.
+	ErrUnknownInternalUser = errors.New("unknown internal user")
+	// ErrInvalidPassword - The password can't be safely written to the role or passfile.
+	ErrInvalidPassword = errors.New("invalid password")
+
+	// credentialsFilePath is the local copy of the cluster's credential rotations. Every process
+	// on the member resolves its credentials through it.
+	credentialsFilePath = "/data/.credentials"
+
+	// exporterPasswordFilePath holds the superuser's password for postgres_exporter, which reads it
+	// through DATA_SOURCE_PASS_FILE whenever it starts.
+	exporterPasswordFilePath = "/data/.exporter_password"
+
+	// internalCredentialSecrets maps each internal user to the secret its password is read from.
+	internalCredentialSecrets = map[string]string{
+		"flypgadmin": "SU_PASSWORD",
+		"postgres":   "OPERATOR_PASSWORD",
+		"repmgr":     "REPL_PASSWORD",
+	}
+)
+
+// CredentialRotation records a password change for an internal user that hasn't made it into its
+// secret yet. The rotated password is used for as long as the secret holds the password it replaced.
+type CredentialRotation struct {
+	Username string `json:"username"`
+	// SealedPassword is the rotated password, encrypted with a key derived from the secret's value
+	// at the time of the rotation. Only members holding that secret are able to read it.
+	SealedPassword string `json:"sealed_password"`
+	// Replaces is the SHA-256 digest of the secret's value at the time of the rotation.
+	Replaces  string    `json:"replaces"`
+	Author    string    `json:"author,omitempty"`
+	RotatedAt time.Time `json:"rotated_at"`
+}
+
+// CredentialRotationResult is the outcome of a credential rotation.
+type CredentialRotationResult struct {
+	Username string `json:"username"`
+	// Secret must be updated with the new password to complete the rotation.
+	Secret  string                 `json:"secret"`
+	Members []CredentialSyncResult `json:"members"`
+}
+
+// CredentialSyncResult reports whether a single member picked up the rotated credentials.
+type CredentialSyncResult struct {
+	Member string `json:"member"`
+	Error  string `json:"error,omitempty"`
+}
+
+func secretDigest(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newCredentialRotation seals the rotated password with the value of the secret it replaces.
+func newCredentialRotation(username, password, secret, author string) (CredentialRotation, error) {
+	sealed, err := sealRotatedPassword(secret, password)
+	if err != nil {
+		return CredentialRotation{}, err
+	}
+
+	return CredentialRotation{
+		Username:       username,
+		SealedPassword: sealed,
+		Replaces:       secretDigest(secret),
+		Author:         author,
+		RotatedAt:      time.Now().UTC(),
+	}, nil
+}
+
+// rotationCipher derives the key from the secret with HMAC, so it can't be recovered from the
+// digest stored alongside the sealed password.
+func rotationCipher(secret string) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("flypg credential rotation"))
+
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func sealRotatedPassword(secret, password string) (string, error) {
+	aead, err := rotationCipher(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize cipher: %s", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %s", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(password), nil)), nil
+}
+
+func openRotatedPassword(secret, sealed string) (string, error) {
+	aead, err := rotationCipher(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize cipher: %s", err)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed sealed password")
+	}
+
+	password, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to open sealed password: %s", err)
+	}
+
+	return string(password), nil
+}
+
+// resolveCredential returns the rotated credential for the user, as long as the password it was
+// resolved from is the one the rotation replaced.
+func resolveCredential(creds admin.Credential, rotations map[string]CredentialRotation) admin.Credential {
+	rotation, ok := rotations[creds.Username]
+	if !ok || rotation.Replaces != secretDigest(creds.Password) {
+		return creds
+	}
+
+	password, err := openRotatedPassword(creds.Password, rotation.SealedPassword)
+	if err != nil {
+		log.Printf("[WARN] Ignoring credential rotation for %s: %s", creds.Username, err)
+		return creds
+	}
+
+	creds.Password = password
+
+	return creds
+}
+
+// rotatedCredential resolves the current password of an internal user from its secret and the
+// specified rotations. Other credentials are returned as is.
+func rotatedCredential(creds admin.Credential, rotations map[string]CredentialRotation) admin.Credential {
+	secret, ok := internalCredentialSecrets[creds.Username]
+	if !ok {
+		return creds
+	}
+
+	password, ok := os.LookupEnv(secret)
+	if !ok {
+		return creds
+	}
+
+	return resolveCredential(admin.Credential{Username: creds.Username, Password: password}, rotations)
+}
+
+// CredentialRotations returns the rotations recorded within the state store, keyed by username.
+func CredentialRotations(store state.StateStore) (map[string]CredentialRotation, error) {
+	data, err := store.PullUserConfig(credentialRotationsKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull credential rotations: %s", err)
+	}
+
+	return parseCredentialRotations(data)
+}
+
+func parseCredentialRotations(data []byte) (map[string]CredentialRotation, error) {
+	rotations := map[string]CredentialRotation{}
+	if len(data) == 0 {
+		return rotations, nil
+	}
+
+	if err := json.Unmarshal(data, &rotations); err != nil {
+		return nil, fmt.Errorf("failed to parse credential rotations: %s", err)
+	}
+
+	return rotations, nil
+}
+
+// localCredentialRotations reads the rotations synced to this member. A missing file means no
+// rotations have taken place.
+func localCredentialRotations() map[string]CredentialRotation {
+	data, err := os.ReadFile(credentialsFilePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[WARN] Failed to read %s: %s", credentialsFilePath, err)
+		}
+		return nil
+	}
+
+	rotations, err := parseCredentialRotations(data)
+	if err != nil {
+		log.Printf("[WARN] %s", err)
+		return nil
+	}
+
+	return rotations
+}
+
+func writeLocalCredentialRotations(rotations map[string]CredentialRotation) error {
+	data, err := json.Marshal(rotations)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(credentialsFilePath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %s", credentialsFilePath, err)
+	}
+
+	return utils.SetFileOwnership(credentialsFilePath, "postgres")
+}
+
+// ExporterPasswordPath returns the file postgres_exporter reads the superuser's password from.
+func ExporterPasswordPath() string {
+	return exporterPasswordFilePath
+}
+
+// writeExporterPassword writes the superuser's resolved password for postgres_exporter. The
+// exporter runs as root, so the file is only readable by root.
+func (n *Node) writeExporterPassword() error {
+	if err := os.WriteFile(exporterPasswordFilePath, []byte(n.SUCredentials.Password), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %s", exporterPasswordFilePath, err)
+	}
+
+	return nil
+}
+
+// restartExporter stops postgres_exporter. The supervisor starts it back up with the password
+// currently held within the exporter's password file.
+var restartExporter = func() error {
+	_, err := utils.RunCommand("restart-exporter", "root")
+	return err
+}
+
+// recordCredentialRotation stores the new password for the user. Rotating again before the secret
+// has been updated keeps pointing at the secret's value, rather than at the previous rotation.
+func recordCredentialRotation(store state.StateStore, rotation CredentialRotation) error {
+	return updateCredentialRotations(store, func(rotations map[string]CredentialRotation) {
+		rotations[rotation.Username] = rotation
+	})
+}
+
+// restoreCredentialRotation reverts the user's rotation to the specified one, or removes it when nil.
+func restoreCredentialRotation(store state.StateStore, username string, prior *CredentialRotation) error {
+	return updateCredentialRotations(store, func(rotations map[string]CredentialRotation) {
+		if prior == nil {
+			delete(rotations, username)
+			return
+		}
+		rotations[username] = *prior
+	})
+}
+
+func updateCredentialRotations(store state.StateStore, update func(map[string]CredentialRotation)) error {
+	for range maxCredentialRotationUpdateTries {
+		current, err := store.PullUserConfig(credentialRotationsKey)
+		if err != nil {
+			return fmt.Errorf("failed to pull credential rotations: %s", err)
+		}
+
+		rotations, err := parseCredentialRotations(current)
+		if err != nil {
+			return err
+		}
+
+		update(rotations)
+
+		data, err := json.Marshal(rotations)
+		if err != nil {
+			return err
+		}
+
+		swapped, err := store.CompareAndSwap(credentialRotationsKey, current, data)
+		if err != nil {
+			return fmt.Errorf("failed to update credential rotations: %s", err)
+		}
+
+		if swapped {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("failed to update credential rotations after %d attempts", maxCredentialRotationUpdateTries)
+}
+
+// validateRotatedPassword ensures the password can be embedded within the ALTER ROLE statement
+// and repmgr's passfile without escaping.
+func validateRotatedPassword(password string) error {
+	if len(password) < minRotatedPasswordLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrInvalidPassword, minRotatedPasswordLength)
+	}
+
+	if strings.ContainsAny(password, "'\\: \t\n") {
+		return fmt.Errorf("%w: must not contain quotes, backslashes, colons or whitespace", ErrInvalidPassword)
+	}
+
+	return nil
+}
+
+// resolveRotatedCredentials applies the specified rotations to the credentials read from the
+// environment.
+func (n *Node) resolveRotatedCredentials(rotations map[string]CredentialRotation) {
+	n.SUCredentials = rotatedCredential(n.SUCredentials, rotations)
+	n.OperatorCredentials = rotatedCredential(n.OperatorCredentials, rotations)
+	n.ReplCredentials = rotatedCredential(n.ReplCredentials, rotations)
+	n.RepMgr.Credentials = rotatedCredential(n.RepMgr.Credentials, rotations)
+}
+
+// supersededRotations returns the users whose secret has been changed since their rotation.
+func supersededRotations(rotations map[string]CredentialRotation) []string {
+	var superseded []string
+	for username, rotation := range rotations {
+		password, ok := os.LookupEnv(internalCredentialSecrets[username])
+		if ok && secretDigest(password) != rotation.Replaces {
+			superseded = append(superseded, username)
+		}
+	}
+
+	return superseded
+}
+
+// syncCredentialRotations copies the rotations recorded within the state store to this member,
+// so members that missed a rotation, or joined after it, pick it up on boot.
+func (n *Node) syncCredentialRotations(store state.StateStore) error {
+	rotations, err := CredentialRotations(store)
+	if err != nil {
+		return err
+	}
+
+	// Rotations are complete once the secret no longer holds the password they replaced.
+	if superseded := supersededRotations(rotations); len(superseded) > 0 {
+		err := updateCredentialRotations(store, func(current map[string]CredentialRotation) {
+			for _, username := range superseded {
+				if current[username] == rotations[username] {
+					delete(current, username)
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, username := range superseded {
+			log.Printf("[INFO] Secret for %s has been updated, completing credential rotation", username)
+			delete(rotations, username)
+		}
+	}
+
+	if len(rotations) > 0 || utils.FileExists(credentialsFilePath) {
+		if err := writeLocalCredentialRotations(rotations); err != nil {
+			return err
+		}
+	}
+
+	n.resolveRotatedCredentials(rotations)
+
+	return nil
+}
+
+// RotateCredential changes the password of an internal user on the primary and has every member
+// pick it up, without restarting Postgres. The rotation remains in effect until the user's secret
+// is updated with the new password.
+func RotateCredential(ctx context.Context, n *Node, username, password, author string) (*CredentialRotationResult, error) {
+	secret, ok := internalCredentialSecrets[username]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownInternalUser, username)
+	}
+
+	if err := validateRotatedPassword(password); err != nil {
+		return nil, err
+	}
+
+	store, err := n.StateStore()
+	if err != nil {
+		return nil, err
+	}
+
+	rotations, err := CredentialRotations(store)
+	if err != nil {
+		return nil, err
+	}
+
+	var prior *CredentialRotation
+	if rotation, ok := rotations[username]; ok {
+		prior = &rotation
+	}
+
+	repConn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = repConn.Close(ctx) }()
+
+	primary, err := n.RepMgr.PrimaryMember(ctx, repConn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve primary member: %s", err)
+	}
+
+	members, err := n.RepMgr.Members(ctx, repConn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members: %s", err)
+	}
+
+	primaryHost := net.JoinHostPort(primary.Hostname, strconv.Itoa(n.Port))
+	primaryConn, err := openConnection(ctx, primaryHost, "postgres", n.SUCredentials, n.RepMgr.VerifyTLS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish connection to primary: %s", err)
+	}
+	defer func() { _ = primaryConn.Close(ctx) }()
+
+	// The rotation is recorded first, so members that boot while it is in progress pick it up.
+	// Their connections fall back to the previous password until the role has been changed.
+	rotation, err := newCredentialRotation(username, password, os.Getenv(secret), author)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordCredentialRotation(store, rotation); err != nil {
+		return nil, err
+	}
+
+	if err := admin.ChangePassword(ctx, primaryConn, username, password); err != nil {
+		if restoreErr := restoreCredentialRotation(store, username, prior); restoreErr != nil {
+			log.Printf("[WARN] Failed to discard credential rotation: %s", restoreErr)
+		}
+		return nil, fmt.Errorf("failed to change password for %s: %s", username, err)
+	}
+
+	log.Printf("[INFO] Rotated password for %s, syncing %d members", username, len(members))
+
+	result := &CredentialRotationResult{Username: username, Secret: secret}
+	for _, member := range members {
+		sync := CredentialSyncResult{Member: member.Hostname}
+		if err := requestMember(ctx, http.MethodPost, member.Hostname, SyncCredentialsEndpoint); err != nil {
+			log.Printf("[WARN] Failed to sync credentials on member %s: %s", member.Hostname, err)
+			sync.Error = err.Error()
+		}
+		result.Members = append(result.Members, sync)
+	}
+
+	return result, nil
+}
+
+// SyncCredentials applies the rotations recorded within the state store to this member. Repmgr's
+// passfile is rewritten and repmgrd restarted when the repmgr password changed, and the exporter
+// is restarted when the flypgadmin password changed. The member must then be able to open a
+// replication connection to the primary.
+func SyncCredentials(ctx context.Context, n *Node) error {
+	store, err := n.StateStore()
+	if err != nil {
+		return err
+	}
+
+	previous := n.RepMgr.Credentials
+	previousSU := n.SUCredentials
+
+	if err := n.syncCredentialRotations(store); err != nil {
+		return err
+	}
+
+	if n.RepMgr.Credentials != previous {
+		if err := n.RepMgr.writePassfile(); err != nil {
+			return err
+		}
+
+		if repmgrdRunning() {
+			if err := n.RepMgr.restartDaemon(); err != nil {
+				return fmt.Errorf("failed to restart repmgrd: %s", err)
+			}
+		}
+	}
+
+	if n.SUCredentials != previousSU {
+		if err := n.writeExporterPassword(); err != nil {
+			return err
+		}
+
+		if err := restartExporter(); err != nil {
+			return fmt.Errorf("failed to restart exporter: %s", err)
+		}
+	}
+
+	repConn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = repConn.Close(ctx) }()
+
+	primary, err := n.RepMgr.PrimaryMember(ctx, repConn)
+	if err != nil {
+		return fmt.Errorf("failed to resolve primary member: %s", err)
+	}
+
+	if err := verifyReplicationAuth(ctx, primary.Hostname, n.RepMgr); err != nil {
+		return fmt.Errorf("replication connection to %s failed: %s", primary.Hostname, err)
+	}
+
+	return nil
+}
+
+// verifyReplicationAuth opens a physical replication connection to the specified host, which is
+// authenticated the same way as a standby's walreceiver.
+func verifyReplicationAuth(ctx context.Context, hostname string, r RepMgr) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	url := fmt.Sprintf("postgres://%s/%s?replication=true", net.JoinHostPort(hostname, strconv.Itoa(r.Port)), r.DatabaseName)
+	if r.VerifyTLS {
+		url += fmt.Sprintf("&sslmode=verify-full&sslrootcert=%s", CACertPath())
+	}
+
+	conf, err := pgconn.ParseConfig(url)
+	if err != nil {
+		return err
+	}
+
+	conf.User = r.Credentials.Username
+	conf.Password = r.Credentials.Password
+	conf.ConnectTimeout = 5 * time.Second
+
+	conn, err := pgconn.ConnectConfig(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close(ctx)
+}
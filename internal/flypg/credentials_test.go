@@ -0,0 +1,291 @@
+package flypg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+func TestRotatedCredential(t *testing.T) {
+	t.Setenv("REPL_PASSWORD", "original-password")
+
+	rotations := map[string]CredentialRotation{
+		"repmgr": sealedRotation(t, "repmgr", "rotated-password", "original-password"),
+	}
+
+	t.Run("rotated", func(t *testing.T) {
+		creds := rotatedCredential(admin.Credential{Username: "repmgr", Password: "original-password"}, rotations)
+		if creds.Password != "rotated-password" {
+			t.Fatalf("expected the rotated password, got %s", creds.Password)
+		}
+	})
+
+	t.Run("previously-rotated", func(t *testing.T) {
+		// Processes started after an earlier rotation still resolve from the secret.
+		creds := rotatedCredential(admin.Credential{Username: "repmgr", Password: "earlier-password"}, rotations)
+		if creds.Password != "rotated-password" {
+			t.Fatalf("expected the rotated password, got %s", creds.Password)
+		}
+	})
+
+	t.Run("secret-updated", func(t *testing.T) {
+		t.Setenv("REPL_PASSWORD", "rotated-password")
+
+		creds := rotatedCredential(admin.Credential{Username: "repmgr", Password: "rotated-password"}, rotations)
+		if creds.Password != "rotated-password" {
+			t.Fatalf("expected the secret's password, got %s", creds.Password)
+		}
+
+		if superseded := supersededRotations(rotations); len(superseded) != 1 || superseded[0] != "repmgr" {
+			t.Fatalf("expected the rotation to be superseded, got %v", superseded)
+		}
+	})
+
+	t.Run("other-user", func(t *testing.T) {
+		creds := rotatedCredential(admin.Credential{Username: "app", Password: "original-password"}, rotations)
+		if creds.Password != "original-password" {
+			t.Fatalf("expected the password to be left alone, got %s", creds.Password)
+		}
+	})
+}
+
+func TestSealRotatedPassword(t *testing.T) {
+	rotation := sealedRotation(t, "repmgr", "rotated-password", "original-password")
+
+	data, err := json.Marshal(rotation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(data), "rotated-password") {
+		t.Fatalf("expected the rotated password to be sealed, got %s", data)
+	}
+
+	if password := openedPassword(t, rotation, "original-password"); password != "rotated-password" {
+		t.Fatalf("expected the rotated password, got %s", password)
+	}
+
+	if _, err := openRotatedPassword("other-password", rotation.SealedPassword); err == nil {
+		t.Fatal("expected the password not to open with a different secret")
+	}
+}
+
+func TestCredentialRotations(t *testing.T) {
+	store := state.NewMemoryStore()
+
+	first := sealedRotation(t, "repmgr", "first-password", "original")
+	if err := recordCredentialRotation(store, first); err != nil {
+		t.Fatal(err)
+	}
+
+	second := sealedRotation(t, "repmgr", "second-password", "original")
+	if err := recordCredentialRotation(store, second); err != nil {
+		t.Fatal(err)
+	}
+
+	rotations, err := CredentialRotations(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if password := openedPassword(t, rotations["repmgr"], "original"); password != "second-password" {
+		t.Fatalf("expected the latest rotation, got %s", password)
+	}
+
+	t.Run("restore", func(t *testing.T) {
+		if err := restoreCredentialRotation(store, "repmgr", &first); err != nil {
+			t.Fatal(err)
+		}
+
+		rotations, err := CredentialRotations(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if password := openedPassword(t, rotations["repmgr"], "original"); password != "first-password" {
+			t.Fatalf("expected the prior rotation to be restored, got %s", password)
+		}
+
+		if err := restoreCredentialRotation(store, "repmgr", nil); err != nil {
+			t.Fatal(err)
+		}
+
+		rotations, err = CredentialRotations(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := rotations["repmgr"]; ok {
+			t.Fatal("expected the rotation to be removed")
+		}
+	})
+}
+
+func TestSyncCredentialRotations(t *testing.T) {
+	stubOwnership(t)
+	t.Setenv("SU_PASSWORD", "su-password")
+	t.Setenv("OPERATOR_PASSWORD", "operator-password")
+	t.Setenv("REPL_PASSWORD", "repl-password")
+
+	previous := credentialsFilePath
+	credentialsFilePath = filepath.Join(t.TempDir(), ".credentials")
+	defer func() { credentialsFilePath = previous }()
+
+	node := &Node{
+		SUCredentials:       admin.Credential{Username: "flypgadmin", Password: "su-password"},
+		OperatorCredentials: admin.Credential{Username: "postgres", Password: "operator-password"},
+		ReplCredentials:     admin.Credential{Username: "repmgr", Password: "repl-password"},
+	}
+	node.RepMgr.Credentials = node.ReplCredentials
+
+	store := state.NewMemoryStore()
+	rotation := sealedRotation(t, "repmgr", "rotated-password", "repl-password")
+	if err := recordCredentialRotation(store, rotation); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := node.syncCredentialRotations(store); err != nil {
+		t.Fatal(err)
+	}
+
+	if node.RepMgr.Credentials.Password != "rotated-password" || node.ReplCredentials.Password != "rotated-password" {
+		t.Fatal("expected the repmgr credentials to be rotated")
+	}
+
+	if node.SUCredentials.Password != "su-password" {
+		t.Fatal("expected the flypgadmin credentials to be left alone")
+	}
+
+	if local := localCredentialRotations(); local["repmgr"] != rotation {
+		t.Fatalf("expected the rotation to be synced locally, got %+v", local)
+	}
+
+	t.Run("secret-updated", func(t *testing.T) {
+		t.Setenv("REPL_PASSWORD", "rotated-password")
+
+		if err := node.syncCredentialRotations(store); err != nil {
+			t.Fatal(err)
+		}
+
+		rotations, err := CredentialRotations(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rotations) != 0 {
+			t.Fatalf("expected the completed rotation to be removed, got %+v", rotations)
+		}
+
+		if node.RepMgr.Credentials.Password != "rotated-password" {
+			t.Fatal("expected the secret's password to be used")
+		}
+	})
+}
+
+func TestSyncCredentialsExporter(t *testing.T) {
+	node := newTestNode(t)
+
+	store := state.NewMemoryStore()
+	node.SetStateStore(store)
+
+	restarts := 0
+	previous := restartExporter
+	t.Cleanup(func() { restartExporter = previous })
+	restartExporter = func() error {
+		restarts++
+		return nil
+	}
+
+	// Grab a port nothing is listening on, so the replication check fails once the exporter is handled.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.RepMgr.Port = listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	rotation := sealedRotation(t, "flypgadmin", "rotated-su-password", "su-password")
+	if err := recordCredentialRotation(store, rotation); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SyncCredentials(context.Background(), node); err == nil {
+		t.Fatal("expected the replication check to fail")
+	}
+
+	if restarts != 1 {
+		t.Fatalf("expected the exporter to be restarted once, got %d", restarts)
+	}
+
+	password, err := os.ReadFile(ExporterPasswordPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(password) != "rotated-su-password" {
+		t.Fatalf("expected the exporter to be handed the rotated password, got %q", password)
+	}
+
+	info, err := os.Stat(ExporterPasswordPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected the exporter's password file to be private, got %s", info.Mode().Perm())
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		restarts = 0
+
+		if err := SyncCredentials(context.Background(), node); err == nil {
+			t.Fatal("expected the replication check to fail")
+		}
+
+		if restarts != 0 {
+			t.Fatalf("expected the exporter to be left running, got %d restarts", restarts)
+		}
+	})
+}
+
+func TestValidateRotatedPassword(t *testing.T) {
+	if err := validateRotatedPassword("Zq8vN2kLp4Rt7Yw1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, password := range []string{"short", "contains'quote1234", "contains:colon1234", "contains space1234"} {
+		if err := validateRotatedPassword(password); !errors.Is(err, ErrInvalidPassword) {
+			t.Fatalf("expected %q to be rejected, got %v", password, err)
+		}
+	}
+}
+
+func sealedRotation(t *testing.T, username, password, secret string) CredentialRotation {
+	t.Helper()
+
+	rotation, err := newCredentialRotation(username, password, secret, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rotation
+}
+
+func openedPassword(t *testing.T, rotation CredentialRotation, secret string) string {
+	t.Helper()
+
+	password, err := openRotatedPassword(secret, rotation.SealedPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return password
+}
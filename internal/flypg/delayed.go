@@ -0,0 +1,429 @@
+package flypg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+	"github.com/fly-apps/postgres-flex/internal/utils"
+)
+
+const (
+	// DelayedRoleName is reported for standbys that deliberately replay WAL behind the primary.
+	// They are registered with repmgr as standbys.
+	DelayedRoleName = "delayed"
+
+	delayedMembersKey           = "delayed_members"
+	maxDelayedMemberUpdateTries = 5
+
+	detachedCopyPort = 5434
+)
+
+var (
+	detachedCopyDir       = "/data/detached"
+	detachedCopyStateFile = "/data/detached_copy.json"
+
+	ErrNotDelayed          = errors.New("member is not a delayed replica")
+	ErrDetachedCopyExists  = errors.New("a detached copy already exists, remove it first")
+	ErrDetachedCopyMissing = errors.New("no detached copy exists")
+	ErrInsufficientSpace   = errors.New("not enough free disk space for a detached copy")
+)
+
+// DelayedStatus reports how far replay on a delayed replica has progressed.
+type DelayedStatus struct {
+	ApplyDelay      string        `json:"apply_delay"`
+	Paused          bool          `json:"paused"`
+	ReplayLSN       string        `json:"replay_lsn"`
+	ReplayTimestamp *time.Time    `json:"replay_timestamp,omitempty"`
+	DetachedCopy    *DetachedCopy `json:"detached_copy,omitempty"`
+}
+
+// DetachedCopy is a promoted copy of a delayed replica, running alongside it on the same member.
+type DetachedCopy struct {
+	DataDir         string     `json:"data_dir"`
+	Port            int        `json:"port"`
+	ReplayLSN       string     `json:"replay_lsn"`
+	ReplayTimestamp *time.Time `json:"replay_timestamp,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// parseApplyDelay parses the apply delay configured through the `DELAYED_REPLICA` environment
+// variable. An empty value disables the delay.
+func parseApplyDelay(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	delay, err := time.ParseDuration(s)
+	if err != nil || delay <= 0 {
+		return 0, fmt.Errorf("invalid DELAYED_REPLICA apply delay %q, expected a positive duration such as 1h", s)
+	}
+
+	return delay, nil
+}
+
+// DelayedMembers returns the apply delay of every delayed replica, keyed by node name.
+func DelayedMembers(store state.StateStore) (map[string]string, error) {
+	data, err := store.PullUserConfig(delayedMembersKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull delayed members: %s", err)
+	}
+
+	members := map[string]string{}
+	if len(data) == 0 {
+		return members, nil
+	}
+
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, fmt.Errorf("failed to parse delayed members: %s", err)
+	}
+
+	return members, nil
+}
+
+// setDelayedMember records the apply delay of the specified member within the state store so
+// the rest of the cluster can tell it apart from regular standbys. A zero delay removes the entry.
+func setDelayedMember(store state.StateStore, name string, delay time.Duration) error {
+	for range maxDelayedMemberUpdateTries {
+		current, err := store.PullUserConfig(delayedMembersKey)
+		if err != nil {
+			return fmt.Errorf("failed to pull delayed members: %s", err)
+		}
+
+		members := map[string]string{}
+		if len(current) > 0 {
+			if err := json.Unmarshal(current, &members); err != nil {
+				return fmt.Errorf("failed to parse delayed members: %s", err)
+			}
+		}
+
+		existing, ok := members[name]
+		switch {
+		case delay > 0 && existing == delay.String():
+			return nil
+		case delay <= 0 && !ok:
+			return nil
+		case delay > 0:
+			members[name] = delay.String()
+		default:
+			delete(members, name)
+		}
+
+		data, err := json.Marshal(members)
+		if err != nil {
+			return err
+		}
+
+		swapped, err := store.CompareAndSwap(delayedMembersKey, current, data)
+		if err != nil {
+			return fmt.Errorf("failed to update delayed members: %s", err)
+		}
+
+		if swapped {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("failed to update delayed members after %d attempts", maxDelayedMemberUpdateTries)
+}
+
+// syncDelayedMember records whether the local member is a delayed replica.
+func (n *Node) syncDelayedMember() error {
+	store, err := n.StateStore()
+	if err != nil {
+		return fmt.Errorf("failed to initialize state store: %s", err)
+	}
+
+	// Members are keyed by their repmgr node name, as that's what the member list reports.
+	return setDelayedMember(store, n.RepMgr.nodeName(), n.PGConfig.applyDelay)
+}
+
+// delayedMemberNames returns the node names of the delayed replicas. Failing to resolve them
+// shouldn't take down the caller, so an empty set is returned instead.
+func (n *Node) delayedMemberNames() map[string]bool {
+	names := map[string]bool{}
+
+	store, err := n.StateStore()
+	if err != nil {
+		log.Printf("[WARN] Failed to resolve delayed members: %s", err)
+		return names
+	}
+
+	members, err := DelayedMembers(store)
+	if err != nil {
+		log.Printf("[WARN] Failed to resolve delayed members: %s", err)
+		return names
+	}
+
+	for name := range members {
+		names[name] = true
+	}
+
+	return names
+}
+
+// GetDelayedStatus returns the replay progress of the local delayed replica.
+func GetDelayedStatus(ctx context.Context, n *Node) (*DelayedStatus, error) {
+	if !n.RepMgr.Delayed {
+		return nil, ErrNotDelayed
+	}
+
+	conn, err := n.NewLocalConnection(ctx, "postgres", n.SUCredentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	status := &DelayedStatus{ApplyDelay: n.PGConfig.applyDelay.String()}
+
+	if status.Paused, err = admin.WalReplayPaused(ctx, conn); err != nil {
+		return nil, fmt.Errorf("failed to resolve replay state: %s", err)
+	}
+
+	if status.ReplayLSN, err = admin.LastReplayLSN(ctx, conn); err != nil {
+		return nil, fmt.Errorf("failed to resolve replay lsn: %s", err)
+	}
+
+	if status.ReplayTimestamp, err = admin.LastReplayTimestamp(ctx, conn); err != nil {
+		return nil, fmt.Errorf("failed to resolve replay timestamp: %s", err)
+	}
+
+	dc, err := readDetachedCopy()
+	if err != nil && !errors.Is(err, ErrDetachedCopyMissing) {
+		return nil, err
+	}
+	status.DetachedCopy = dc
+
+	return status, nil
+}
+
+// SetDelayedReplayPaused pauses or resumes WAL replay on the local delayed replica. WAL continues
+// to be received while replay is paused, so resuming catches back up to the configured delay.
+func SetDelayedReplayPaused(ctx context.Context, n *Node, paused bool) (*DelayedStatus, error) {
+	if !n.RepMgr.Delayed {
+		return nil, ErrNotDelayed
+	}
+
+	conn, err := n.NewLocalConnection(ctx, "postgres", n.SUCredentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	if paused {
+		if err := admin.PauseWalReplay(ctx, conn); err != nil {
+			return nil, fmt.Errorf("failed to pause replay: %s", err)
+		}
+	} else {
+		if err := admin.ResumeWalReplay(ctx, conn); err != nil {
+			return nil, fmt.Errorf("failed to resume replay: %s", err)
+		}
+	}
+
+	if err := conn.Close(ctx); err != nil {
+		return nil, fmt.Errorf("failed to close connection: %s", err)
+	}
+
+	return GetDelayedStatus(ctx, n)
+}
+
+// PromoteDetachedCopy pauses replay on the local delayed replica, copies it and promotes the copy
+// as a standalone instance listening on a separate port. The copy is consistent as of the paused
+// replay position, so data that has since been dropped on the primary can be recovered from it.
+// Replay remains paused until it is explicitly resumed.
+func PromoteDetachedCopy(ctx context.Context, n *Node) (*DetachedCopy, error) {
+	if !n.RepMgr.Delayed {
+		return nil, ErrNotDelayed
+	}
+
+	if utils.FileExists(detachedCopyDir) {
+		return nil, ErrDetachedCopyExists
+	}
+
+	if err := ensureDetachedCopySpace(n.DataDir); err != nil {
+		return nil, err
+	}
+
+	status, err := SetDelayedReplayPaused(ctx, n, true)
+	if err != nil {
+		return nil, err
+	}
+
+	dc := &DetachedCopy{
+		DataDir:         detachedCopyDir,
+		Port:            detachedCopyPort,
+		ReplayLSN:       status.ReplayLSN,
+		ReplayTimestamp: status.ReplayTimestamp,
+		CreatedAt:       time.Now().UTC(),
+	}
+
+	log.Printf("[INFO] Copying delayed replica paused at %s into %s", dc.ReplayLSN, detachedCopyDir)
+
+	if err := n.copyDelayedReplica(ctx); err != nil {
+		if rErr := os.RemoveAll(detachedCopyDir); rErr != nil {
+			log.Printf("[WARN] Failed to cleanup detached copy: %s", rErr)
+		}
+
+		return nil, err
+	}
+
+	if err := StartPostgres(ctx, detachedCopyDir); err != nil {
+		return nil, fmt.Errorf("failed to start detached copy: %s", err)
+	}
+
+	if err := writeDetachedCopy(dc); err != nil {
+		return nil, err
+	}
+
+	return dc, nil
+}
+
+// ensureDetachedCopySpace verifies the volume can hold a full copy of the data directory, as the
+// detached copy is written alongside it.
+func ensureDetachedCopySpace(dataDir string) error {
+	required, err := dirSizeInBytes(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve data directory size: %s", err)
+	}
+
+	available, err := diskAvailableInBytes(filepath.Dir(detachedCopyDir))
+	if err != nil {
+		return fmt.Errorf("failed to resolve free disk space: %s", err)
+	}
+
+	if required >= available {
+		return fmt.Errorf("%w: %d bytes required, %d bytes available", ErrInsufficientSpace, required, available)
+	}
+
+	return nil
+}
+
+// copyDelayedReplica takes a base backup of the local delayed replica and configures it to
+// recover up until the point it is consistent, then promote. Settings tying the copy to the
+// cluster are overridden within postgresql.auto.conf, as it is read last.
+func (n *Node) copyDelayedReplica(ctx context.Context) error {
+	if _, err := utils.RunCmd(ctx, "postgres",
+		"pg_basebackup",
+		"-D", detachedCopyDir,
+		"-X", "stream",
+		"-c", "fast",
+		"-d", fmt.Sprintf("host=%s port=%d user=%s passfile=%s%s",
+			n.PrivateIP, n.Port, n.RepMgr.Credentials.Username, n.RepMgr.PasswordConfigPath, sslConninfo(n.RepMgr.VerifyTLS)),
+	); err != nil {
+		return fmt.Errorf("failed to copy delayed replica: %s", err)
+	}
+
+	overrides := []string{
+		fmt.Sprintf("port = %d", detachedCopyPort),
+		"shared_buffers = '128MB'",
+		"archive_mode = off",
+		"archive_command = ''",
+		"restore_command = ''",
+		"primary_conninfo = ''",
+		"primary_slot_name = ''",
+		"synchronous_standby_names = ''",
+		"recovery_min_apply_delay = 0",
+		"recovery_target_name = ''",
+		"recovery_target_time = ''",
+		"recovery_target_xid = ''",
+		"recovery_target_lsn = ''",
+		"recovery_target = 'immediate'",
+		"recovery_target_action = 'promote'",
+	}
+
+	autoConf := filepath.Join(detachedCopyDir, "postgresql.auto.conf")
+	f, err := os.OpenFile(autoConf, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %s", autoConf, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := f.WriteString("\n# Detached copy of a delayed replica\n" + strings.Join(overrides, "\n") + "\n"); err != nil {
+		return fmt.Errorf("failed to write %s: %s", autoConf, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %s", autoConf, err)
+	}
+
+	// Recover as a standalone instance rather than a standby.
+	if err := os.Remove(filepath.Join(detachedCopyDir, "standby.signal")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove standby.signal: %s", err)
+	}
+
+	signal := filepath.Join(detachedCopyDir, "recovery.signal")
+	if err := os.WriteFile(signal, nil, 0o600); err != nil {
+		return fmt.Errorf("failed to write recovery.signal: %s", err)
+	}
+
+	for _, path := range []string{autoConf, signal} {
+		if err := utils.SetFileOwnership(path, "postgres"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveDetachedCopy stops the detached copy and removes its data.
+func RemoveDetachedCopy(ctx context.Context) error {
+	if !utils.FileExists(detachedCopyDir) {
+		return ErrDetachedCopyMissing
+	}
+
+	// The copy won't be running if the member has restarted since it was created.
+	if utils.FileExists(filepath.Join(detachedCopyDir, "postmaster.pid")) {
+		if err := StopPostgres(ctx, detachedCopyDir); err != nil {
+			return fmt.Errorf("failed to stop detached copy: %s", err)
+		}
+	}
+
+	if err := os.RemoveAll(detachedCopyDir); err != nil {
+		return fmt.Errorf("failed to remove detached copy: %s", err)
+	}
+
+	if err := os.Remove(detachedCopyStateFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %s", detachedCopyStateFile, err)
+	}
+
+	return nil
+}
+
+func readDetachedCopy() (*DetachedCopy, error) {
+	data, err := os.ReadFile(detachedCopyStateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrDetachedCopyMissing
+		}
+
+		return nil, fmt.Errorf("failed to read %s: %s", detachedCopyStateFile, err)
+	}
+
+	var dc DetachedCopy
+	if err := json.Unmarshal(data, &dc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", detachedCopyStateFile, err)
+	}
+
+	return &dc, nil
+}
+
+func writeDetachedCopy(dc *DetachedCopy) error {
+	data, err := json.Marshal(dc)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(detachedCopyStateFile, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %s", detachedCopyStateFile, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package flypg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+func TestParseApplyDelay(t *testing.T) {
+	t.Run("unset", func(t *testing.T) {
+		delay, err := parseApplyDelay("")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if delay != 0 {
+			t.Fatalf("expected no delay, got %s", delay)
+		}
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		delay, err := parseApplyDelay("1h")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if delay != time.Hour {
+			t.Fatalf("expected 1h, got %s", delay)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, val := range []string{"an hour", "0s", "-1h"} {
+			if _, err := parseApplyDelay(val); err == nil {
+				t.Fatalf("expected %q to be rejected", val)
+			}
+		}
+	})
+}
+
+func TestDelayedMembers(t *testing.T) {
+	store := state.NewMemoryStore()
+
+	t.Run("unset", func(t *testing.T) {
+		members, err := DelayedMembers(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(members) != 0 {
+			t.Fatalf("expected no delayed members, got %v", members)
+		}
+	})
+
+	t.Run("register", func(t *testing.T) {
+		if err := setDelayedMember(store, "148ed726c12358", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := setDelayedMember(store, "3287e67bc12d18", 30*time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		members, err := DelayedMembers(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if members["148ed726c12358"] != "1h0m0s" || members["3287e67bc12d18"] != "30m0s" {
+			t.Fatalf("unexpected delayed members: %v", members)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		if err := setDelayedMember(store, "148ed726c12358", 0); err != nil {
+			t.Fatal(err)
+		}
+
+		members, err := DelayedMembers(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := members["148ed726c12358"]; ok || len(members) != 1 {
+			t.Fatalf("expected member to be removed, got %v", members)
+		}
+	})
+
+	t.Run("voteWeights", func(t *testing.T) {
+		node := &Node{PrimaryRegion: "ord"}
+		node.SetStateStore(store)
+
+		weights := node.VoteWeights()
+		if w := weights.Weight(Member{Name: "3287e67bc12d18", Region: "ord"}); w != 0 {
+			t.Fatalf("expected delayed replica to hold no votes, got %d", w)
+		}
+
+		if w := weights.Weight(Member{Name: "148ed726c12358", Region: "ord"}); w != 1 {
+			t.Fatalf("expected regular standby to hold a single vote, got %d", w)
+		}
+	})
+}
+
+func TestSyncDelayedMember(t *testing.T) {
+	store := state.NewMemoryStore()
+
+	n := &Node{
+		MachineID: "148ed193b95d89",
+		RepMgr:    RepMgr{MachineID: "148ed193b95d89"},
+		PGConfig:  PGConfig{applyDelay: time.Hour},
+	}
+	n.SetStateStore(store)
+
+	if err := n.syncDelayedMember(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The set is read against the member list, which reports repmgr node names.
+	member := Member{Name: n.RepMgr.nodeName(), Role: StandbyRoleName}
+	if !n.delayedMemberNames()[member.Name] {
+		t.Fatalf("expected %s to be recorded as delayed", member.Name)
+	}
+}
+
+func TestEnsureDetachedCopySpace(t *testing.T) {
+	dir := t.TempDir()
+
+	previous := detachedCopyDir
+	t.Cleanup(func() { detachedCopyDir = previous })
+	detachedCopyDir = filepath.Join(dir, "detached")
+
+	dataDir := filepath.Join(dir, "postgresql")
+	if err := os.MkdirAll(filepath.Join(dataDir, "base"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dataDir, "base", "1"), make([]byte, 4096), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := dirSizeInBytes(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size != 4096 {
+		t.Fatalf("expected the data directory to hold 4096 bytes, got %d", size)
+	}
+
+	if err := ensureDetachedCopySpace(dataDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ensureDetachedCopySpace(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("expected an error for a missing data directory")
+	}
+}
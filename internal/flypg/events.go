@@ -0,0 +1,373 @@
+package flypg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/fly-apps/postgres-flex/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// Events emitted by repmgr. See https://www.repmgr.org/docs/current/event-notifications.html
+const (
+	EventPrimaryRegister             = "primary_register"
+	EventPrimaryUnregister           = "primary_unregister"
+	EventStandbyClone                = "standby_clone"
+	EventStandbyRegister             = "standby_register"
+	EventStandbyUnregister           = "standby_unregister"
+	EventStandbyPromote              = "standby_promote"
+	EventStandbyFollow               = "standby_follow"
+	EventStandbySwitchover           = "standby_switchover"
+	EventWitnessRegister             = "witness_register"
+	EventWitnessUnregister           = "witness_unregister"
+	EventNodeRejoin                  = "node_rejoin"
+	EventRepmgrdStart                = "repmgrd_start"
+	EventRepmgrdShutdown             = "repmgrd_shutdown"
+	EventRepmgrdReload               = "repmgrd_reload"
+	EventRepmgrdFailoverPromote      = "repmgrd_failover_promote"
+	EventRepmgrdFailoverFollow       = "repmgrd_failover_follow"
+	EventRepmgrdFailoverAborted      = "repmgrd_failover_aborted"
+	EventRepmgrdStandbyReconnect     = "repmgrd_standby_reconnect"
+	EventRepmgrdPromoteError         = "repmgrd_promote_error"
+	EventRepmgrdLocalDisconnect      = "repmgrd_local_disconnect"
+	EventRepmgrdLocalReconnect       = "repmgrd_local_reconnect"
+	EventRepmgrdUpstreamDisconnect   = "repmgrd_upstream_disconnect"
+	EventRepmgrdUpstreamReconnect    = "repmgrd_upstream_reconnect"
+	EventStandbyDisconnectManual     = "standby_disconnect_manual"
+	EventStandbyFailure              = "standby_failure"
+	EventStandbyRecovery             = "standby_recovery"
+	EventChildNodeDisconnect         = "child_node_disconnect"
+	EventChildNodeReconnect          = "child_node_reconnect"
+	EventChildNodeNewConnect         = "child_node_new_connect"
+	EventChildNodesDisconnectCommand = "child_nodes_disconnect_command"
+)
+
+// RepmgrEvents is the catalogue of events the event handler is notified of.
+var RepmgrEvents = []string{
+	EventPrimaryRegister,
+	EventPrimaryUnregister,
+	EventStandbyClone,
+	EventStandbyRegister,
+	EventStandbyUnregister,
+	EventStandbyPromote,
+	EventStandbyFollow,
+	EventStandbySwitchover,
+	EventWitnessRegister,
+	EventWitnessUnregister,
+	EventNodeRejoin,
+	EventRepmgrdStart,
+	EventRepmgrdShutdown,
+	EventRepmgrdReload,
+	EventRepmgrdFailoverPromote,
+	EventRepmgrdFailoverFollow,
+	EventRepmgrdFailoverAborted,
+	EventRepmgrdStandbyReconnect,
+	EventRepmgrdPromoteError,
+	EventRepmgrdLocalDisconnect,
+	EventRepmgrdLocalReconnect,
+	EventRepmgrdUpstreamDisconnect,
+	EventRepmgrdUpstreamReconnect,
+	EventStandbyDisconnectManual,
+	EventStandbyFailure,
+	EventStandbyRecovery,
+	EventChildNodeDisconnect,
+	EventChildNodeReconnect,
+	EventChildNodeNewConnect,
+	EventChildNodesDisconnectCommand,
+}
+
+const (
+	eventHandlerEvaluateClusterState = "evaluate_cluster_state"
+	eventHandlerPromoted             = "promoted"
+	eventHandlerFollowed             = "followed"
+)
+
+var (
+	repmgrEventLogFile   = "/data/repmgr_events.log"
+	backupRescheduleFile = "/data/.backup_reschedule"
+)
+
+// RepmgrEvent is a repmgr event notification along with how it was handled.
+type RepmgrEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Name      string    `json:"name"`
+	NodeID    int       `json:"node_id"`
+	Success   bool      `json:"success"`
+	Details   string    `json:"details,omitempty"`
+	Actions   []string  `json:"actions,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// eventHandler returns the handler responsible for the specified event. Events without a
+// handler are only recorded.
+func eventHandler(name string) string {
+	switch name {
+	case EventChildNodeDisconnect, EventChildNodeReconnect, EventChildNodeNewConnect:
+		return eventHandlerEvaluateClusterState
+	case EventRepmgrdFailoverPromote, EventStandbyPromote:
+		return eventHandlerPromoted
+	case EventStandbyFollow, EventRepmgrdFailoverFollow, EventNodeRejoin:
+		return eventHandlerFollowed
+	default:
+		return ""
+	}
+}
+
+// ProcessRepmgrEvent handles the event and records it within the event history. Promotion and
+// follow events are handled in the background, as repmgr blocks until the notification
+// command returns.
+func ProcessRepmgrEvent(ctx context.Context, n *Node, e RepmgrEvent) error {
+	e.Timestamp = time.Now().UTC()
+
+	if !e.Success {
+		e.Error = fmt.Sprintf("event %q failed: %s", e.Name, e.Details)
+		completeRepmgrEvent(ctx, n, e)
+		return errors.New(e.Error)
+	}
+
+	switch eventHandler(e.Name) {
+	case eventHandlerEvaluateClusterState:
+		err := handleChildNodeEvent(ctx, n, &e)
+		if err != nil {
+			e.Error = err.Error()
+		}
+		completeRepmgrEvent(ctx, n, e)
+		return err
+	case eventHandlerPromoted:
+		go handleInBackground(ctx, n, e, handlePromotedEvent)
+	case eventHandlerFollowed:
+		go handleInBackground(ctx, n, e, handleFollowedEvent)
+	default:
+		completeRepmgrEvent(ctx, n, e)
+	}
+
+	return nil
+}
+
+func handleInBackground(ctx context.Context, n *Node, e RepmgrEvent, fn func(context.Context, *Node, *RepmgrEvent) error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), memberRecoveryTimeout)
+	defer cancel()
+
+	if err := fn(ctx, n, &e); err != nil {
+		log.Printf("[WARN] Failed to handle event %q: %s", e.Name, err)
+		e.Error = err.Error()
+	}
+
+	completeRepmgrEvent(ctx, n, e)
+}
+
+func handleChildNodeEvent(ctx context.Context, n *Node, e *RepmgrEvent) error {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open local connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	member, err := n.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to resolve member: %s", err)
+	}
+
+	if member.Role != PrimaryRoleName {
+		return nil
+	}
+
+	e.Actions = append(e.Actions, eventHandlerEvaluateClusterState)
+	if err := EvaluateClusterState(ctx, conn, n); err != nil {
+		return fmt.Errorf("failed to evaluate cluster state: %s", err)
+	}
+
+	return nil
+}
+
+// handlePromotedEvent routes traffic to the new primary and ensures backups continue to be
+// taken on schedule.
+func handlePromotedEvent(ctx context.Context, n *Node, e *RepmgrEvent) error {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open local connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	members, err := n.RepMgr.Members(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to query members: %s", err)
+	}
+
+	var failed []string
+	for _, member := range members {
+		if err := requestMember(ctx, http.MethodGet, member.Hostname, RestartHaproxyEndpoint); err != nil {
+			log.Printf("[WARN] Failed to restart haproxy on member %s: %s", member.Hostname, err)
+			failed = append(failed, member.Hostname)
+		}
+	}
+	e.Actions = append(e.Actions, "restart_haproxy")
+
+	if os.Getenv("S3_ARCHIVE_CONFIG") != "" {
+		if err := RequestBackupReschedule(); err != nil {
+			return fmt.Errorf("failed to request backup reschedule: %s", err)
+		}
+		e.Actions = append(e.Actions, "reschedule_backups")
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to restart haproxy on %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// handleFollowedEvent verifies the new upstream holds an active replication slot for the
+// local member, re-pointing cascading standbys at their regional relay.
+func handleFollowedEvent(ctx context.Context, n *Node, e *RepmgrEvent) error {
+	e.Actions = append(e.Actions, "verify_replication_slot")
+
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open local connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	member, err := n.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to resolve member: %s", err)
+	}
+
+	upstream, err := n.RepMgr.UpstreamMember(ctx, conn, member.ID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve upstream: %s", err)
+	}
+
+	uConn, err := n.RepMgr.NewRemoteConnection(ctx, upstream.Hostname)
+	if err != nil {
+		return fmt.Errorf("failed to connect to upstream %s: %s", upstream.Hostname, err)
+	}
+	defer func() { _ = uConn.Close(ctx) }()
+
+	if err := waitForActiveSlot(ctx, uConn, replicationSlotName(member.ID)); err != nil {
+		return err
+	}
+
+	// repmgrd follows the primary when a cascading upstream fails, so re-point at the next
+	// relay within the region if there is one.
+	if n.cascadingReplication() && !n.RepMgr.eligiblePrimary() {
+		e.Actions = append(e.Actions, "follow_regional_upstream")
+		if err := n.FollowRegionalUpstream(ctx); err != nil {
+			return fmt.Errorf("failed to follow regional upstream: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// waitForActiveSlot waits for the replication slot to become active, as the WAL receiver may
+// still be reconnecting.
+func waitForActiveSlot(ctx context.Context, conn *pgx.Conn, name string) error {
+	ticker := time.NewTicker(memberPollFrequency)
+	defer ticker.Stop()
+
+	for {
+		slot, err := admin.GetReplicationSlot(ctx, conn, name)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return fmt.Errorf("replication slot %s does not exist on the upstream", name)
+		case err != nil:
+			return fmt.Errorf("failed to query replication slot %s: %s", name, err)
+		case slot.Active:
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("replication slot %s did not become active: %s", name, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func replicationSlotName(memberID int) string {
+	return fmt.Sprintf("repmgr_slot_%d", memberID)
+}
+
+// completeRepmgrEvent records the handled event and notifies the configured webhooks.
+func completeRepmgrEvent(ctx context.Context, n *Node, e RepmgrEvent) {
+	// A standby that fails to follow its upstream may need to be reseeded. The flag is cleared
+	// once the standby is seen streaming again.
+	if e.Error != "" && eventHandler(e.Name) == eventHandlerFollowed {
+		if err := MarkReseedRequired(e.Error); err != nil {
+			log.Printf("[WARN] Failed to flag standby for reseed: %s", err)
+		}
+	}
+
+	recordRepmgrEvent(e)
+
+	details := map[string]any{
+		"node_id": e.NodeID,
+		"success": e.Success,
+		"details": e.Details,
+	}
+	if len(e.Actions) > 0 {
+		details["actions"] = e.Actions
+	}
+	if e.Error != "" {
+		details["error"] = e.Error
+	}
+
+	n.Notify(ctx, e.Name, fmt.Sprintf("repmgr reported %s for node %d", e.Name, e.NodeID), details)
+}
+
+func recordRepmgrEvent(e RepmgrEvent) {
+	if err := appendAuditEntry(repmgrEventLogFile, e); err != nil {
+		log.Printf("[WARN] Failed to record repmgr event: %s", err)
+	}
+}
+
+// RepmgrEventHistory returns the most recent events handled by the member, newest first. Events
+// can optionally be filtered by name. A limit of 0 returns every retained event.
+func RepmgrEventHistory(limit int, name string) ([]RepmgrEvent, error) {
+	events, err := auditHistory[RepmgrEvent](repmgrEventLogFile, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		var filtered []RepmgrEvent
+		for _, e := range events {
+			if e.Name == name {
+				filtered = append(filtered, e)
+			}
+		}
+		events = filtered
+	}
+
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+
+	return events, nil
+}
+
+// RequestBackupReschedule asks the backup scheduler to re-evaluate when the next backup is due.
+func RequestBackupReschedule() error {
+	if err := os.WriteFile(backupRescheduleFile, []byte(time.Now().UTC().Format(time.RFC3339)), 0o600); err != nil {
+		return err
+	}
+
+	return utils.SetFileOwnership(backupRescheduleFile, "postgres")
+}
+
+// BackupRescheduleRequested reports whether a reschedule has been requested, clearing the request.
+func BackupRescheduleRequested() bool {
+	if err := os.Remove(backupRescheduleFile); err != nil {
+		return false
+	}
+
+	return true
+}
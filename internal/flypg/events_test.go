@@ -0,0 +1,143 @@
+package flypg
+
+import (
+	"context"
+	"testing"
+)
+
+const (
+	repmgrEventTestLogFile   = "./test_results/repmgr_events.log"
+	backupRescheduleTestFile = "./test_results/.backup_reschedule"
+)
+
+func TestEventHandler(t *testing.T) {
+	tests := []struct {
+		event    string
+		expected string
+	}{
+		{event: EventChildNodeDisconnect, expected: eventHandlerEvaluateClusterState},
+		{event: EventChildNodeReconnect, expected: eventHandlerEvaluateClusterState},
+		{event: EventChildNodeNewConnect, expected: eventHandlerEvaluateClusterState},
+		{event: EventRepmgrdFailoverPromote, expected: eventHandlerPromoted},
+		{event: EventStandbyPromote, expected: eventHandlerPromoted},
+		{event: EventStandbyFollow, expected: eventHandlerFollowed},
+		{event: EventRepmgrdFailoverFollow, expected: eventHandlerFollowed},
+		{event: EventNodeRejoin, expected: eventHandlerFollowed},
+		{event: EventRepmgrdUpstreamDisconnect, expected: ""},
+		{event: "unknown_event", expected: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.event, func(t *testing.T) {
+			if handler := eventHandler(tc.event); handler != tc.expected {
+				t.Fatalf("expected handler %q, got %q", tc.expected, handler)
+			}
+		})
+	}
+
+	t.Run("catalogue", func(t *testing.T) {
+		seen := map[string]bool{}
+		for _, event := range RepmgrEvents {
+			if seen[event] {
+				t.Fatalf("duplicate event %s", event)
+			}
+			seen[event] = true
+		}
+
+		for _, tc := range tests {
+			if tc.expected != "" && !seen[tc.event] {
+				t.Fatalf("expected %s to be part of the event catalogue", tc.event)
+			}
+		}
+	})
+}
+
+func TestRepmgrEventHistory(t *testing.T) {
+	if err := setup(t); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	repmgrEventLogFile = repmgrEventTestLogFile
+
+	ctx := context.Background()
+	node := &Node{}
+
+	if err := ProcessRepmgrEvent(ctx, node, RepmgrEvent{Name: EventRepmgrdUpstreamDisconnect, NodeID: 1, Success: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ProcessRepmgrEvent(ctx, node, RepmgrEvent{Name: EventRepmgrdUpstreamReconnect, NodeID: 1, Success: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ProcessRepmgrEvent(ctx, node, RepmgrEvent{Name: EventRepmgrdPromoteError, NodeID: 2, Details: "promotion failed"}); err == nil {
+		t.Fatal("expected failed events to return an error")
+	}
+
+	t.Run("order", func(t *testing.T) {
+		events, err := RepmgrEventHistory(0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(events) != 3 {
+			t.Fatalf("expected 3 events, got %d", len(events))
+		}
+
+		if events[0].Name != EventRepmgrdPromoteError {
+			t.Fatalf("expected most recent event to be %s, got %s", EventRepmgrdPromoteError, events[0].Name)
+		}
+
+		if events[0].Error == "" {
+			t.Fatal("expected failed event to record an error")
+		}
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		events, err := RepmgrEventHistory(0, EventRepmgrdUpstreamDisconnect)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(events) != 1 || events[0].Name != EventRepmgrdUpstreamDisconnect {
+			t.Fatalf("expected a single %s event, got %+v", EventRepmgrdUpstreamDisconnect, events)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		events, err := RepmgrEventHistory(2, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(events) != 2 {
+			t.Fatalf("expected 2 events, got %d", len(events))
+		}
+	})
+}
+
+func TestBackupReschedule(t *testing.T) {
+	if err := setup(t); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	backupRescheduleFile = backupRescheduleTestFile
+
+	if BackupRescheduleRequested() {
+		t.Fatal("expected no reschedule to be requested")
+	}
+
+	if err := RequestBackupReschedule(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !BackupRescheduleRequested() {
+		t.Fatal("expected a reschedule to be requested")
+	}
+
+	if BackupRescheduleRequested() {
+		t.Fatal("expected the reschedule request to be cleared")
+	}
+}
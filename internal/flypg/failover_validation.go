@@ -0,0 +1,316 @@
+package flypg
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	FailoverCheckZombieLock     = "zombie_lock"
+	FailoverCheckMaintenance    = "maintenance"
+	FailoverCheckRegion         = "region"
+	FailoverCheckQuorum         = "quorum"
+	FailoverCheckReplicationLag = "replication_lag"
+	FailoverCheckDelayed        = "delayed_replica"
+
+	defaultFailoverLagBytesThreshold = 16 * 1024 * 1024
+	defaultFailoverValidationTimeout = 30 * time.Second
+)
+
+var failoverValidationLogFile = "/data/failover_validation.log"
+
+// FailoverCheck is the outcome of a single promotion requirement.
+type FailoverCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Reason string `json:"reason"`
+}
+
+// FailoverValidation records whether a promotion candidate was approved and why.
+type FailoverValidation struct {
+	Timestamp    time.Time       `json:"timestamp"`
+	Candidate    string          `json:"candidate"`
+	VisibleNodes int             `json:"visible_nodes"`
+	TotalNodes   int             `json:"total_nodes"`
+	Approved     bool            `json:"approved"`
+	Checks       []FailoverCheck `json:"checks"`
+}
+
+func (v *FailoverValidation) add(c FailoverCheck) {
+	v.Checks = append(v.Checks, c)
+	if !c.Passed {
+		v.Approved = false
+	}
+}
+
+// Rejections returns the checks that prevented the promotion.
+func (v *FailoverValidation) Rejections() []FailoverCheck {
+	var out []FailoverCheck
+	for _, c := range v.Checks {
+		if !c.Passed {
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+// ValidateFailover determines whether the local member is fit to be promoted. repmgr reports the
+// number of nodes visible to the candidate, which is used when the weighted vote can't be resolved.
+// Every check is evaluated so the full reasoning is recorded, even once the candidate is rejected.
+func ValidateFailover(ctx context.Context, n *Node, visibleNodes, totalNodes int) *FailoverValidation {
+	ctx, cancel := context.WithTimeout(ctx, n.FlyConfig.DurationSetting("failoverValidationTimeout", defaultFailoverValidationTimeout))
+	defer cancel()
+
+	v := &FailoverValidation{
+		Timestamp:    time.Now().UTC(),
+		Candidate:    n.Hostname(),
+		VisibleNodes: visibleNodes,
+		TotalNodes:   totalNodes,
+		Approved:     true,
+	}
+
+	v.add(zombieLockCheck())
+	v.add(maintenanceCheck(n))
+	v.add(candidateRegionCheck(n.RepMgr.Region, n.PrimaryRegion))
+	v.add(delayedReplicaCheck(n.RepMgr.Delayed))
+	v.add(failoverQuorumCheck(ctx, n, visibleNodes, totalNodes))
+	v.add(failoverLagCheck(ctx, n))
+
+	failoverValidations.Inc(strconv.FormatBool(v.Approved))
+	recordFailoverValidation(v)
+
+	return v
+}
+
+func zombieLockCheck() FailoverCheck {
+	if ZombieLockExists() {
+		return FailoverCheck{Name: FailoverCheckZombieLock, Reason: "zombie.lock is present, this member has been fenced"}
+	}
+
+	return FailoverCheck{Name: FailoverCheckZombieLock, Passed: true, Reason: "no zombie.lock present"}
+}
+
+func maintenanceCheck(n *Node) FailoverCheck {
+	check := FailoverCheck{Name: FailoverCheckMaintenance}
+
+	store, err := n.StateStore()
+	if err != nil {
+		// An unreachable state store shouldn't prevent the cluster from recovering.
+		check.Passed = true
+		check.Reason = fmt.Sprintf("unable to verify maintenance state: %s", err)
+		return check
+	}
+
+	enabled, err := MaintenanceEnabled(store)
+	switch {
+	case err != nil:
+		check.Passed = true
+		check.Reason = fmt.Sprintf("unable to verify maintenance state: %s", err)
+	case enabled:
+		check.Reason = "cluster is in maintenance mode"
+	default:
+		check.Passed = true
+		check.Reason = "cluster is not in maintenance mode"
+	}
+
+	return check
+}
+
+func candidateRegionCheck(region, primaryRegion string) FailoverCheck {
+	if region != primaryRegion {
+		return FailoverCheck{
+			Name:   FailoverCheckRegion,
+			Reason: fmt.Sprintf("candidate resides in %s, outside of the primary region %s", region, primaryRegion),
+		}
+	}
+
+	return FailoverCheck{Name: FailoverCheckRegion, Passed: true, Reason: fmt.Sprintf("candidate resides in the primary region %s", primaryRegion)}
+}
+
+func delayedReplicaCheck(delayed bool) FailoverCheck {
+	if delayed {
+		return FailoverCheck{Name: FailoverCheckDelayed, Reason: "candidate is a delayed replica"}
+	}
+
+	return FailoverCheck{Name: FailoverCheckDelayed, Passed: true, Reason: "candidate is not a delayed replica"}
+}
+
+func failoverQuorumCheck(ctx context.Context, n *Node, visibleNodes, totalNodes int) FailoverCheck {
+	check := FailoverCheck{Name: FailoverCheckQuorum}
+
+	tally, err := FailoverVoteTally(ctx, n)
+	if err != nil {
+		// Fall back to the unweighted vote reported by repmgr.
+		tally = VoteTally{Total: totalNodes, Visible: visibleNodes, Quorum: Quorum(totalNodes)}
+		check.Passed = tally.Met()
+		check.Reason = fmt.Sprintf("unable to resolve weighted vote (%s), visible nodes: %d, total nodes: %d, quorum: %d",
+			err, tally.Visible, tally.Total, tally.Quorum)
+		return check
+	}
+
+	check.Passed = tally.Met()
+	check.Reason = fmt.Sprintf("visible votes: %d, total votes: %d, quorum: %d", tally.Visible, tally.Total, tally.Quorum)
+
+	return check
+}
+
+func failoverLagCheck(ctx context.Context, n *Node) FailoverCheck {
+	check := FailoverCheck{Name: FailoverCheckReplicationLag}
+
+	threshold := n.FlyConfig.IntSetting("failoverLagBytesThreshold", defaultFailoverLagBytesThreshold)
+
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		check.Reason = fmt.Sprintf("failed to establish connection: %s", err)
+		return check
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	lsn, err := admin.LastReplayLSN(ctx, conn)
+	if err != nil {
+		check.Reason = fmt.Sprintf("failed to resolve replay lsn: %s", err)
+		return check
+	}
+
+	local, err := ParseLSN(lsn)
+	if err != nil {
+		check.Reason = fmt.Sprintf("failed to resolve replay lsn: %s", err)
+		return check
+	}
+
+	peers, err := standbyReplayLSNs(ctx, n, conn)
+	if err != nil {
+		check.Reason = fmt.Sprintf("failed to resolve standby replay positions: %s", err)
+		return check
+	}
+
+	return replayLagCheck(local, peers, threshold)
+}
+
+// replayLagCheck compares the candidate's replay position against the most advanced visible standby.
+func replayLagCheck(local uint64, peers map[string]uint64, threshold int64) FailoverCheck {
+	check := FailoverCheck{Name: FailoverCheckReplicationLag}
+
+	var (
+		leader   string
+		advanced = local
+	)
+	for hostname, lsn := range peers {
+		if lsn > advanced || (lsn == advanced && leader != "" && hostname < leader) {
+			leader = hostname
+			advanced = lsn
+		}
+	}
+
+	if leader == "" {
+		check.Passed = true
+		check.Reason = "candidate is the most advanced visible standby"
+		return check
+	}
+
+	lag := advanced - local
+	if lag > uint64(threshold) {
+		check.Reason = fmt.Sprintf("candidate is %d bytes behind %s, exceeding the threshold of %d bytes", lag, leader, threshold)
+		return check
+	}
+
+	check.Passed = true
+	check.Reason = fmt.Sprintf("candidate is %d bytes behind %s, within the threshold of %d bytes", lag, leader, threshold)
+
+	return check
+}
+
+// standbyReplayLSNs returns the replay position of every reachable standby, keyed by hostname.
+func standbyReplayLSNs(ctx context.Context, n *Node, conn *pgx.Conn) (map[string]uint64, error) {
+	self, err := n.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve local member: %s", err)
+	}
+
+	members, err := n.RepMgr.Members(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members: %s", err)
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		lsns = map[string]uint64{}
+	)
+
+	for _, m := range members {
+		if m.ID == self.ID || m.Role != StandbyRoleName {
+			continue
+		}
+
+		wg.Add(1)
+		go func(m Member) {
+			defer wg.Done()
+
+			mConn, err := n.RepMgr.NewRemoteConnection(ctx, m.Hostname)
+			if err != nil {
+				return
+			}
+			defer func() { _ = mConn.Close(ctx) }()
+
+			lsn, err := admin.LastReplayLSN(ctx, mConn)
+			if err != nil || lsn == "" {
+				return
+			}
+
+			pos, err := ParseLSN(lsn)
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			lsns[m.Hostname] = pos
+			mu.Unlock()
+		}(m)
+	}
+	wg.Wait()
+
+	return lsns, nil
+}
+
+// ParseLSN converts the textual representation of a WAL location, e.g. `16/B374D848`, into
+// its byte position.
+func ParseLSN(lsn string) (uint64, error) {
+	hi, lo, ok := strings.Cut(lsn, "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid lsn %q", lsn)
+	}
+
+	h, err := strconv.ParseUint(hi, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lsn %q: %s", lsn, err)
+	}
+
+	l, err := strconv.ParseUint(lo, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lsn %q: %s", lsn, err)
+	}
+
+	return h<<32 | l, nil
+}
+
+func recordFailoverValidation(v *FailoverValidation) {
+	if err := appendAuditEntry(failoverValidationLogFile, v); err != nil {
+		log.Printf("[WARN] Failed to record failover validation: %s", err)
+	}
+}
+
+// FailoverValidationHistory returns the most recent failover validations, newest first. A limit
+// of 0 returns every retained validation.
+func FailoverValidationHistory(limit int) ([]FailoverValidation, error) {
+	return auditHistory[FailoverValidation](failoverValidationLogFile, limit)
+}
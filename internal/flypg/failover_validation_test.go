@@ -0,0 +1,188 @@
+package flypg
+
+import (
+	"testing"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+const failoverValidationTestLogFile = "./test_results/failover_validation.log"
+
+func TestParseLSN(t *testing.T) {
+	tests := []struct {
+		lsn      string
+		expected uint64
+		valid    bool
+	}{
+		{lsn: "0/0", expected: 0, valid: true},
+		{lsn: "0/3000060", expected: 0x3000060, valid: true},
+		{lsn: "16/B374D848", expected: 0x16<<32 | 0xB374D848, valid: true},
+		{lsn: "", valid: false},
+		{lsn: "3000060", valid: false},
+		{lsn: "0/XYZ", valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.lsn, func(t *testing.T) {
+			lsn, err := ParseLSN(tc.lsn)
+			if !tc.valid {
+				if err == nil {
+					t.Fatalf("expected %q to be invalid", tc.lsn)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if lsn != tc.expected {
+				t.Fatalf("expected %d, got %d", tc.expected, lsn)
+			}
+		})
+	}
+}
+
+func TestReplayLagCheck(t *testing.T) {
+	threshold := int64(1024)
+
+	t.Run("MostAdvanced", func(t *testing.T) {
+		check := replayLagCheck(5000, map[string]uint64{"host-2": 4000, "host-3": 5000}, threshold)
+		if !check.Passed {
+			t.Fatalf("expected check to pass: %s", check.Reason)
+		}
+	})
+
+	t.Run("NoVisibleStandbys", func(t *testing.T) {
+		check := replayLagCheck(5000, map[string]uint64{}, threshold)
+		if !check.Passed {
+			t.Fatalf("expected check to pass: %s", check.Reason)
+		}
+	})
+
+	t.Run("WithinThreshold", func(t *testing.T) {
+		check := replayLagCheck(5000, map[string]uint64{"host-2": 6024}, threshold)
+		if !check.Passed {
+			t.Fatalf("expected check to pass: %s", check.Reason)
+		}
+	})
+
+	t.Run("ExceedsThreshold", func(t *testing.T) {
+		check := replayLagCheck(5000, map[string]uint64{"host-2": 5500, "host-3": 7000}, threshold)
+		if check.Passed {
+			t.Fatalf("expected check to fail: %s", check.Reason)
+		}
+
+		expected := "candidate is 2000 bytes behind host-3, exceeding the threshold of 1024 bytes"
+		if check.Reason != expected {
+			t.Fatalf("expected reason %q, got %q", expected, check.Reason)
+		}
+	})
+}
+
+func TestCandidateRegionCheck(t *testing.T) {
+	if check := candidateRegionCheck("iad", "iad"); !check.Passed {
+		t.Fatalf("expected check to pass: %s", check.Reason)
+	}
+
+	if check := candidateRegionCheck("ord", "iad"); check.Passed {
+		t.Fatalf("expected check to fail: %s", check.Reason)
+	}
+}
+
+func TestMaintenanceEnabled(t *testing.T) {
+	store := state.NewMemoryStore()
+
+	t.Run("Unset", func(t *testing.T) {
+		enabled, err := MaintenanceEnabled(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if enabled {
+			t.Fatal("expected maintenance to be disabled")
+		}
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		// State recorded before the author and timestamp were tracked.
+		if err := store.PushUserConfig(maintenanceStateKey, []byte(`{"enabled": true}`)); err != nil {
+			t.Fatal(err)
+		}
+
+		enabled, err := MaintenanceEnabled(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !enabled {
+			t.Fatal("expected maintenance to be enabled")
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		if _, err := setMaintenanceState(store, false, "operator"); err != nil {
+			t.Fatal(err)
+		}
+
+		enabled, err := MaintenanceEnabled(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if enabled {
+			t.Fatal("expected maintenance to be disabled")
+		}
+	})
+}
+
+func TestFailoverValidationHistory(t *testing.T) {
+	if err := setup(t); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	failoverValidationLogFile = failoverValidationTestLogFile
+
+	approved := &FailoverValidation{Candidate: "host-1", Approved: true}
+	approved.add(candidateRegionCheck("iad", "iad"))
+	recordFailoverValidation(approved)
+
+	rejected := &FailoverValidation{Candidate: "host-2", Approved: true}
+	rejected.add(candidateRegionCheck("ord", "iad"))
+	rejected.add(zombieLockCheck())
+	recordFailoverValidation(rejected)
+
+	validations, err := FailoverValidationHistory(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(validations) != 2 {
+		t.Fatalf("expected 2 validations, got %d", len(validations))
+	}
+
+	latest := validations[0]
+	if latest.Candidate != "host-2" || latest.Approved {
+		t.Fatalf("expected host-2 to be rejected, got %+v", latest)
+	}
+
+	rejections := latest.Rejections()
+	if len(rejections) != 1 || rejections[0].Name != FailoverCheckRegion {
+		t.Fatalf("expected a single region rejection, got %+v", rejections)
+	}
+
+	if !validations[1].Approved {
+		t.Fatalf("expected host-1 to be approved, got %+v", validations[1])
+	}
+}
+
+func TestDelayedReplicaCheck(t *testing.T) {
+	if check := delayedReplicaCheck(true); check.Passed {
+		t.Fatal("expected delayed replicas to be rejected")
+	}
+
+	if check := delayedReplicaCheck(false); !check.Passed {
+		t.Fatalf("expected regular standbys to pass, got %q", check.Reason)
+	}
+}
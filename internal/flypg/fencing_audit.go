@@ -0,0 +1,92 @@
+package flypg
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	FencingEventScreening  = "screening"
+	FencingEventQuarantine = "quarantine"
+
+	FencingDecisionPrimary   = "primary"
+	FencingDecisionZombie    = "zombie"
+	FencingDecisionUndecided = "undecided"
+	FencingDecisionError     = "error"
+)
+
+var fencingLogFile = "/data/fencing.log"
+
+// FencingEvent is a single entry within the fencing audit log.
+type FencingEvent struct {
+	Timestamp       time.Time           `json:"timestamp"`
+	Event           string              `json:"event"`
+	Hostname        string              `json:"hostname,omitempty"`
+	TotalMembers    int                 `json:"total_members"`
+	TotalActive     int                 `json:"total_active"`
+	TotalInactive   int                 `json:"total_inactive"`
+	TotalConflicts  int                 `json:"total_conflicts"`
+	Quorum          int                 `json:"quorum"`
+	ConflictMap     map[string]int      `json:"conflict_map,omitempty"`
+	Members         []MemberObservation `json:"members,omitempty"`
+	Decision        string              `json:"decision,omitempty"`
+	ResolvedPrimary string              `json:"resolved_primary,omitempty"`
+	Error           string              `json:"error,omitempty"`
+}
+
+func newFencingEvent(event string, sample *DNASample, primary string, err error) FencingEvent {
+	e := FencingEvent{
+		Timestamp:       time.Now().UTC(),
+		Event:           event,
+		ResolvedPrimary: primary,
+	}
+
+	if sample != nil {
+		e.Hostname = sample.hostname
+		e.TotalMembers = sample.totalMembers
+		e.TotalActive = sample.totalActive
+		e.TotalInactive = sample.totalInactive
+		e.TotalConflicts = sample.totalConflicts
+		e.Quorum = sample.quorum()
+		e.ConflictMap = sample.conflictMap
+		e.Members = sample.observations
+	}
+
+	if event == FencingEventScreening {
+		e.Decision = fencingDecision(err)
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	return e
+}
+
+func fencingDecision(err error) string {
+	switch {
+	case err == nil:
+		return FencingDecisionPrimary
+	case errors.Is(err, ErrZombieDiscovered):
+		return FencingDecisionZombie
+	case errors.Is(err, ErrZombieDiagnosisUndecided):
+		return FencingDecisionUndecided
+	default:
+		return FencingDecisionError
+	}
+}
+
+// recordFencingEvent appends the event to the audit log. Failures are logged, as they
+// should never prevent the fencing decision from being acted upon.
+func recordFencingEvent(e FencingEvent) {
+	if err := appendAuditEntry(fencingLogFile, e); err != nil {
+		log.Printf("[WARN] Failed to record fencing event: %s", err)
+	}
+}
+
+// FencingHistory returns the most recent fencing events, newest first. A limit of 0
+// returns every retained event.
+func FencingHistory(limit int) ([]FencingEvent, error) {
+	return auditHistory[FencingEvent](fencingLogFile, limit)
+}
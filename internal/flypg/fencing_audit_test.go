@@ -0,0 +1,118 @@
+package flypg
+
+import (
+	"os"
+	"testing"
+)
+
+const fencingTestLogFile = "./test_results/fencing.log"
+
+func TestFencingHistory(t *testing.T) {
+	if err := setup(t); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	fencingLogFile = fencingTestLogFile
+
+	sample := &DNASample{
+		hostname:       "host-1",
+		totalMembers:   3,
+		totalActive:    3,
+		totalConflicts: 2,
+		conflictMap:    map[string]int{"host-2": 2},
+		observations: []MemberObservation{
+			{Hostname: "host-2", Reachable: true, ReportedPrimary: "host-2", Conflict: true},
+			{Hostname: "host-3", Reachable: true, ReportedPrimary: "host-2", Conflict: true},
+		},
+	}
+
+	primary, err := ZombieDiagnosis(sample)
+	recordFencingEvent(newFencingEvent(FencingEventScreening, sample, primary, err))
+	recordFencingEvent(newFencingEvent(FencingEventQuarantine, nil, primary, nil))
+
+	t.Run("order", func(t *testing.T) {
+		events, err := FencingHistory(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(events) != 2 {
+			t.Fatalf("expected 2 events, got %d", len(events))
+		}
+
+		if events[0].Event != FencingEventQuarantine {
+			t.Fatalf("expected the most recent event to be a quarantine, got %s", events[0].Event)
+		}
+	})
+
+	t.Run("screening", func(t *testing.T) {
+		events, err := FencingHistory(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		e := events[1]
+		if e.Decision != FencingDecisionZombie {
+			t.Fatalf("expected decision %s, got %s", FencingDecisionZombie, e.Decision)
+		}
+
+		if e.ResolvedPrimary != "host-2" {
+			t.Fatalf("expected resolved primary host-2, got %s", e.ResolvedPrimary)
+		}
+
+		if e.Quorum != 2 {
+			t.Fatalf("expected quorum of 2, got %d", e.Quorum)
+		}
+
+		if len(e.Members) != 2 || e.Members[0].ReportedPrimary != "host-2" {
+			t.Fatalf("expected member observations to be recorded, got %+v", e.Members)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		events, err := FencingHistory(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(events))
+		}
+	})
+
+	t.Run("skipsPartialEntries", func(t *testing.T) {
+		file, err := os.OpenFile(fencingLogFile, os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.WriteString(`{"timestamp":`); err != nil {
+			t.Fatal(err)
+		}
+		_ = file.Close()
+
+		events, err := FencingHistory(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(events) != 2 {
+			t.Fatalf("expected 2 events, got %d", len(events))
+		}
+	})
+}
+
+func TestScreeningOutcome(t *testing.T) {
+	// The metric predates the audit log, so a screening that confirms the primary keeps its original label.
+	if outcome := screeningOutcome(nil); outcome != "healthy" {
+		t.Fatalf("expected outcome healthy, got %s", outcome)
+	}
+
+	if decision := fencingDecision(nil); decision != FencingDecisionPrimary {
+		t.Fatalf("expected decision %s, got %s", FencingDecisionPrimary, decision)
+	}
+
+	if outcome := screeningOutcome(ErrZombieDiscovered); outcome != "zombie" {
+		t.Fatalf("expected outcome zombie, got %s", outcome)
+	}
+}
@@ -0,0 +1,225 @@
+package flypg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	FencingEventResolve = "resolve"
+
+	// FencingResolveRejoin rewinds the local member and rejoins it to the specified primary.
+	FencingResolveRejoin = "rejoin"
+	// FencingResolvePromote declares the local member as the true primary.
+	FencingResolvePromote = "promote"
+)
+
+var (
+	// ErrInvalidFencingResolution - The requested resolution can't be applied to this member.
+	ErrInvalidFencingResolution = errors.New("invalid fencing resolution")
+	// ErrNotFenced - The member has not been fenced.
+	ErrNotFenced = errors.New("member is not fenced, zombie.lock does not exist")
+)
+
+// FencingResolution describes the outcome, or the planned outcome in the case of a dry run,
+// of an operator-driven zombie recovery.
+type FencingResolution struct {
+	Action       string       `json:"action"`
+	DryRun       bool         `json:"dry_run"`
+	Sample       FencingEvent `json:"sample"`
+	Primary      string       `json:"primary,omitempty"`
+	Unregistered []string     `json:"unregistered,omitempty"`
+}
+
+// ResolveFencing recovers a fenced member. The rejoin action rewinds the member and follows the
+// specified primary, while the promote action unregisters every other member and lifts the fence.
+func ResolveFencing(ctx context.Context, n *Node, action, primary string, dryRun bool) (*FencingResolution, error) {
+	res := &FencingResolution{
+		Action: action,
+		DryRun: dryRun,
+		Sample: currentFencingSample(ctx, n),
+	}
+
+	if !dryRun && !ZombieLockExists() {
+		return nil, ErrNotFenced
+	}
+
+	switch action {
+	case FencingResolveRejoin:
+		if err := resolveByRejoin(ctx, n, primary, res); err != nil {
+			return nil, err
+		}
+	case FencingResolvePromote:
+		if err := resolveByPromote(ctx, n, res); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidFencingResolution, action)
+	}
+
+	if !dryRun {
+		recordFencingEvent(FencingEvent{
+			Timestamp:       time.Now().UTC(),
+			Event:           FencingEventResolve,
+			Hostname:        n.Hostname(),
+			Decision:        action,
+			ResolvedPrimary: res.Primary,
+		})
+	}
+
+	return res, nil
+}
+
+// currentFencingSample takes a DNA sample without acting on it.
+func currentFencingSample(ctx context.Context, n *Node) FencingEvent {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return newFencingEvent(FencingEventScreening, nil, "", fmt.Errorf("failed to establish connection: %s", err))
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	members, err := n.RepMgr.QuorumMembers(ctx, conn)
+	if err != nil {
+		return newFencingEvent(FencingEventScreening, nil, "", fmt.Errorf("failed to query standbys: %s", err))
+	}
+
+	sample, err := TakeDNASample(ctx, n, members)
+	if err != nil {
+		return newFencingEvent(FencingEventScreening, nil, "", fmt.Errorf("failed to evaluate cluster data: %s", err))
+	}
+
+	primary, err := ZombieDiagnosis(sample)
+	return newFencingEvent(FencingEventScreening, sample, primary, err)
+}
+
+func resolveByRejoin(ctx context.Context, n *Node, target string, res *FencingResolution) error {
+	if target == "" {
+		return fmt.Errorf("%w: a primary is required to rejoin", ErrInvalidFencingResolution)
+	}
+
+	// Accept machine ids as well as hostnames.
+	if len(target) == 14 {
+		target = n.RepMgr.machineIDToDNS(target)
+	}
+
+	conn, err := n.RepMgr.NewRemoteConnection(ctx, target)
+	if err != nil {
+		return fmt.Errorf("failed to establish a connection to our rejoin target %s: %s", target, err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	primary, err := n.RepMgr.PrimaryMember(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to confirm primary on rejoin target %s: %s", target, err)
+	}
+
+	// Confirm that our rejoin target identifies itself as the primary.
+	if primary.Hostname != target {
+		return fmt.Errorf("%w: %s reports %s as the primary", ErrInvalidFencingResolution, target, primary.Hostname)
+	}
+
+	if primary.Hostname == n.Hostname() {
+		return fmt.Errorf("%w: cannot rejoin ourself, use the promote action instead", ErrInvalidFencingResolution)
+	}
+
+	// If the primary does not reside within our primary region, we cannot rejoin until it is.
+	if primary.Region != n.PrimaryRegion {
+		return fmt.Errorf("%w: %s", ErrInvalidFencingResolution, ErrZombieLockRegionMismatch)
+	}
+
+	res.Primary = primary.Hostname
+
+	if res.DryRun {
+		return nil
+	}
+
+	if err := conn.Close(ctx); err != nil {
+		return fmt.Errorf("failed to close connection: %s", err)
+	}
+
+	log.Printf("[WARN] Rejoining %s as a standby. Diverged changes will be discarded.\n", primary.Hostname)
+
+	// The rejoin requires the local instance to be shut down. Repmgr will start it back up once
+	// the rewind completes, leaving it detached from the supervisor much like a repmgr initiated
+	// restart.
+	if err := StopPostgres(ctx, n.DataDir); err != nil {
+		return err
+	}
+
+	if err := n.RepMgr.rejoinCluster(primary.Hostname); err != nil {
+		return fmt.Errorf("failed to rejoin cluster: %s", err)
+	}
+
+	if err := RemoveZombieLock(); err != nil {
+		return fmt.Errorf("failed to remove zombie lock: %s", err)
+	}
+
+	// The read-only lock was set when we were quarantined and no longer applies.
+	if err := removeReadOnlyLock(); err != nil {
+		return fmt.Errorf("failed to remove read-only lock: %s", err)
+	}
+
+	return nil
+}
+
+func resolveByPromote(ctx context.Context, n *Node, res *FencingResolution) error {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	member, err := n.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to resolve local member: %s", err)
+	}
+
+	if member.Role != PrimaryRoleName {
+		return fmt.Errorf("%w: only a member registered as primary can be declared the primary, we are a %s", ErrInvalidFencingResolution, member.Role)
+	}
+
+	members, err := n.RepMgr.Members(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to query members: %s", err)
+	}
+
+	if err := conn.Close(ctx); err != nil {
+		return fmt.Errorf("failed to close connection: %s", err)
+	}
+
+	res.Primary = member.Hostname
+
+	var others []Member
+	for _, m := range members {
+		if m.ID != member.ID {
+			others = append(others, m)
+			res.Unregistered = append(res.Unregistered, m.Hostname)
+		}
+	}
+
+	if res.DryRun {
+		return nil
+	}
+
+	log.Println("[WARN] Declaring ourself as the primary and unregistering all other members")
+
+	for _, m := range others {
+		log.Printf("Unregistering member %s\n", m.Hostname)
+		if err := n.RepMgr.UnregisterMember(m); err != nil {
+			return fmt.Errorf("failed to unregister member %s: %s", m.Hostname, err)
+		}
+	}
+
+	if err := RemoveZombieLock(); err != nil {
+		return fmt.Errorf("failed to remove zombie lock: %s", err)
+	}
+
+	if err := BroadcastReadonlyChange(ctx, n, false); err != nil {
+		return fmt.Errorf("failed to disable read-only: %s", err)
+	}
+
+	return nil
+}
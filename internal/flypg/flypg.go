@@ -3,7 +3,12 @@ package flypg
 
 import (
 	"fmt"
+	"log"
+	"math"
+	"strconv"
 	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
 )
 
 type FlyPGConfig struct {
@@ -40,8 +45,195 @@ func (c *FlyPGConfig) UserConfigFile() string {
 
 func (c *FlyPGConfig) SetDefaults() {
 	c.internalConfig = ConfigMap{
-		"deadMemberRemovalThreshold": time.Hour * 24,
+		"deadMemberRemovalThreshold":   time.Hour * 24,
+		"replicationLagBytesThreshold": 100 * 1024 * 1024,
+		"replicationLagTimeThreshold":  time.Minute * 5,
+		"synchronousReplicas":          0,
+		"primaryRegionVoteWeight":      defaultPrimaryRegionVoteWeight,
+		"remoteRegionVoteWeight":       defaultRemoteRegionVoteWeight,
+		"memberVoteWeights":            "",
+		"failoverLagBytesThreshold":    defaultFailoverLagBytesThreshold,
+		"failoverValidationTimeout":    defaultFailoverValidationTimeout,
+		"webhookURLs":                  "",
+		"webhookSecret":                "",
+		"webhookEvents":                "",
+		"autoReseed":                   false,
+		"reseedDetectionThreshold":     defaultReseedDetectionThreshold,
+		"standbyCloneSource":           StandbyCloneSourcePrimary,
+		"cascadingReplication":         false,
+		"delayedReplicaVotes":          false,
+		"internalTLS":                  false,
+		"requireClientTLS":             false,
+	}
+}
+
+// Validate ensures the requested settings are known and that their values can be parsed
+// as the same type as their defaults.
+func (c *FlyPGConfig) Validate(requested ConfigMap) error {
+	if c.internalConfig == nil {
+		c.SetDefaults()
+	}
+
+	for k, v := range requested {
+		def, ok := c.internalConfig[k]
+		if !ok {
+			return fmt.Errorf("setting %s is not a valid config option", k)
+		}
+
+		switch def.(type) {
+		case time.Duration:
+			if _, err := time.ParseDuration(fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("setting %s must be a duration: %s", k, err)
+			}
+		case int:
+			i, err := parseIntSetting(v)
+			if err != nil {
+				return fmt.Errorf("setting %s must be an integer: %s", k, err)
+			}
+			if i < 0 {
+				return fmt.Errorf("setting %s must not be negative", k)
+			}
+		case bool:
+			if _, err := strconv.ParseBool(fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("setting %s must be a boolean: %s", k, err)
+			}
+		}
+
+		switch k {
+		case "memberVoteWeights":
+			if _, err := ParseMemberVoteWeights(fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("setting %s is invalid: %s", k, err)
+			}
+		case "webhookURLs":
+			if err := validateWebhookURLs(fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("setting %s is invalid: %s", k, err)
+			}
+		case "webhookEvents":
+			if err := validateWebhookEvents(fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("setting %s is invalid: %s", k, err)
+			}
+		case "standbyCloneSource":
+			if source := fmt.Sprint(v); source != StandbyCloneSourcePrimary && source != StandbyCloneSourceBackup {
+				return fmt.Errorf("setting %s must be either %q or %q", k, StandbyCloneSourcePrimary, StandbyCloneSourceBackup)
+			}
+		}
+	}
+
+	return nil
+}
+
+// DurationSetting resolves the specified setting as a duration, falling back to the
+// default when the setting is missing or can't be parsed.
+func (c *FlyPGConfig) DurationSetting(key string, fallback time.Duration) time.Duration {
+	cfg, err := c.CurrentConfig()
+	if err != nil {
+		log.Printf("[WARN] Failed to read fly config: %s", err)
+		return fallback
+	}
+
+	val, ok := cfg[key]
+	if !ok {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(fmt.Sprint(val))
+	if err != nil {
+		log.Printf("[WARN] Failed to parse %s: %s", key, err)
+		return fallback
+	}
+
+	return d
+}
+
+// IntSetting resolves the specified setting as an integer, falling back to the
+// default when the setting is missing or can't be parsed.
+func (c *FlyPGConfig) IntSetting(key string, fallback int64) int64 {
+	cfg, err := c.CurrentConfig()
+	if err != nil {
+		log.Printf("[WARN] Failed to read fly config: %s", err)
+		return fallback
+	}
+
+	val, ok := cfg[key]
+	if !ok {
+		return fallback
+	}
+
+	i, err := parseIntSetting(val)
+	if err != nil {
+		log.Printf("[WARN] Failed to parse %s: %s", key, err)
+		return fallback
+	}
+
+	return i
+}
+
+// parseIntSetting parses an integer setting. Numbers decoded from JSON arrive as float64s, which
+// are formatted with an exponent once they grow large, e.g. 1.048576e+08.
+func parseIntSetting(v any) (int64, error) {
+	switch val := v.(type) {
+	case int:
+		return int64(val), nil
+	case int64:
+		return val, nil
+	case float64:
+		if val != math.Trunc(val) {
+			return 0, fmt.Errorf("%v is not a whole number", val)
+		}
+		return int64(val), nil
+	}
+
+	str := fmt.Sprint(v)
+	if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+		return i, nil
+	}
+
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil || f != math.Trunc(f) {
+		return 0, fmt.Errorf("%q is not a whole number", str)
+	}
+
+	return int64(f), nil
+}
+
+// BoolSetting resolves the specified setting as a boolean, falling back to the
+// default when the setting is missing or can't be parsed.
+func (c *FlyPGConfig) BoolSetting(key string, fallback bool) bool {
+	cfg, err := c.CurrentConfig()
+	if err != nil {
+		log.Printf("[WARN] Failed to read fly config: %s", err)
+		return fallback
 	}
+
+	val, ok := cfg[key]
+	if !ok {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(fmt.Sprint(val))
+	if err != nil {
+		log.Printf("[WARN] Failed to parse %s: %s", key, err)
+		return fallback
+	}
+
+	return b
+}
+
+// StringSetting resolves the specified setting as a string, falling back to the
+// default when the setting is missing.
+func (c *FlyPGConfig) StringSetting(key string, fallback string) string {
+	cfg, err := c.CurrentConfig()
+	if err != nil {
+		log.Printf("[WARN] Failed to read fly config: %s", err)
+		return fallback
+	}
+
+	val, ok := cfg[key]
+	if !ok {
+		return fallback
+	}
+
+	return fmt.Sprint(val)
 }
 
 func (c *FlyPGConfig) CurrentConfig() (ConfigMap, error) {
@@ -66,11 +258,12 @@ func (c *FlyPGConfig) CurrentConfig() (ConfigMap, error) {
 	return all, nil
 }
 
-func (c *FlyPGConfig) initialize() error {
+func (c *FlyPGConfig) initialize(store state.StateStore) error {
 	c.SetDefaults()
 
-	// Note - Sync from consul has been disabled for this component.
-	// It will be re-enabled once we offer user-defined configuration.
+	if err := SyncUserConfig(c, store); err != nil {
+		log.Printf("[WARN] Failed to sync user config from state store for flypg: %s\n", err.Error())
+	}
 
 	if err := WriteConfigFiles(c); err != nil {
 		return fmt.Errorf("failed to write internal config files: %s", err)
@@ -1,9 +1,11 @@
 package flypg
 
 import (
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
 	"github.com/fly-apps/postgres-flex/internal/utils"
 )
 
@@ -24,7 +26,7 @@ func TestFlyConfigInitialization(t *testing.T) {
 		userConfigFilePath:     flyInternalConfigFilePath,
 	}
 
-	if err := cfg.initialize(); err != nil {
+	if err := cfg.initialize(state.NewMemoryStore()); err != nil {
 		t.Fatal(err)
 	}
 
@@ -49,3 +51,151 @@ func TestFlyConfigInitialization(t *testing.T) {
 		}
 	})
 }
+
+func TestFlyConfigUserOverrides(t *testing.T) {
+	if err := setup(t); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	store := state.NewMemoryStore()
+
+	cfg := FlyPGConfig{
+		internalConfigFilePath: flyInternalConfigFilePath,
+		userConfigFilePath:     flyUserConfigFilePath,
+	}
+
+	cfg.SetUserConfig(ConfigMap{"replicationLagBytesThreshold": 1024})
+	if err := PushUserConfig(&cfg, store, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.initialize(store); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("userValue", func(t *testing.T) {
+		if val := cfg.IntSetting("replicationLagBytesThreshold", 0); val != 1024 {
+			t.Fatalf("expected replicationLagBytesThreshold to be 1024, got %d", val)
+		}
+	})
+
+	t.Run("defaultValue", func(t *testing.T) {
+		if val := cfg.DurationSetting("replicationLagTimeThreshold", 0); val != 5*time.Minute {
+			t.Fatalf("expected replicationLagTimeThreshold to be 5m, got %s", val)
+		}
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		if val := cfg.DurationSetting("unknownSetting", time.Second); val != time.Second {
+			t.Fatalf("expected fallback value, got %s", val)
+		}
+	})
+}
+
+func TestFlyConfigValidate(t *testing.T) {
+	cfg := FlyPGConfig{}
+
+	t.Run("valid", func(t *testing.T) {
+		requested := ConfigMap{
+			"replicationLagBytesThreshold": "2048",
+			"replicationLagTimeThreshold":  "10m",
+		}
+
+		if err := cfg.Validate(requested); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("unknownSetting", func(t *testing.T) {
+		if err := cfg.Validate(ConfigMap{"unknown": "value"}); err == nil {
+			t.Fatal("expected unknown setting to fail validation")
+		}
+	})
+
+	t.Run("invalidDuration", func(t *testing.T) {
+		if err := cfg.Validate(ConfigMap{"replicationLagTimeThreshold": "10"}); err == nil {
+			t.Fatal("expected invalid duration to fail validation")
+		}
+	})
+
+	t.Run("negativeInteger", func(t *testing.T) {
+		if err := cfg.Validate(ConfigMap{"synchronousReplicas": "-1"}); err == nil {
+			t.Fatal("expected negative integer to fail validation")
+		}
+	})
+
+	t.Run("invalidInteger", func(t *testing.T) {
+		if err := cfg.Validate(ConfigMap{"replicationLagBytesThreshold": "10MB"}); err == nil {
+			t.Fatal("expected invalid integer to fail validation")
+		}
+	})
+
+	t.Run("jsonNumbers", func(t *testing.T) {
+		// Request bodies are decoded into a map, so numbers arrive as float64s.
+		if err := cfg.Validate(ConfigMap{"replicationLagBytesThreshold": float64(104857600)}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := cfg.Validate(ConfigMap{"replicationLagBytesThreshold": 1.5}); err == nil {
+			t.Fatal("expected fractional number to fail validation")
+		}
+
+		if err := cfg.Validate(ConfigMap{"failoverLagBytesThreshold": float64(-1)}); err == nil {
+			t.Fatal("expected negative number to fail validation")
+		}
+	})
+
+	t.Run("invalidBoolean", func(t *testing.T) {
+		if err := cfg.Validate(ConfigMap{"autoReseed": "sometimes"}); err == nil {
+			t.Fatal("expected invalid boolean to fail validation")
+		}
+	})
+
+	t.Run("standbyCloneSource", func(t *testing.T) {
+		for _, source := range []string{StandbyCloneSourcePrimary, StandbyCloneSourceBackup} {
+			if err := cfg.Validate(ConfigMap{"standbyCloneSource": source}); err != nil {
+				t.Fatalf("expected %s to be a valid clone source: %s", source, err)
+			}
+		}
+
+		if err := cfg.Validate(ConfigMap{"standbyCloneSource": "snapshot"}); err == nil {
+			t.Fatal("expected unknown clone source to fail validation")
+		}
+	})
+}
+
+func TestFlyConfigIntSetting(t *testing.T) {
+	stubOwnership(t)
+
+	dir := t.TempDir()
+	cfg := FlyPGConfig{
+		internalConfigFilePath: filepath.Join(dir, "flypg.internal.conf"),
+		userConfigFilePath:     filepath.Join(dir, "flypg.user.conf"),
+		internalConfig:         ConfigMap{},
+	}
+
+	// Settings pulled from the state store hold float64s. Files written before they were formatted
+	// may still hold an exponent.
+	cfg.SetUserConfig(ConfigMap{
+		"replicationLagBytesThreshold": float64(104857600),
+		"failoverLagBytesThreshold":    "1.6777216e+07",
+		"synchronousReplicas":          2.5,
+	})
+
+	if err := WriteConfigFiles(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if val := cfg.IntSetting("replicationLagBytesThreshold", 0); val != 104857600 {
+		t.Fatalf("expected replicationLagBytesThreshold to be 104857600, got %d", val)
+	}
+
+	if val := cfg.IntSetting("failoverLagBytesThreshold", 0); val != 16777216 {
+		t.Fatalf("expected failoverLagBytesThreshold to be 16777216, got %d", val)
+	}
+
+	if val := cfg.IntSetting("synchronousReplicas", 1); val != 1 {
+		t.Fatalf("expected fractional value to fall back, got %d", val)
+	}
+}
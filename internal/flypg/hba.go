@@ -0,0 +1,321 @@
+package flypg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+const (
+	ReloadHBAEndpoint = "commands/admin/hba/reload"
+
+	hbaRulesKey           = "hba_rules"
+	maxHBARuleUpdateTries = 5
+)
+
+var (
+	// ErrInvalidHBARule - The rule is malformed or would lock out an internal user.
+	ErrInvalidHBARule = errors.New("invalid hba rule")
+	// ErrHBARuleNotFound - No rule exists with the specified id.
+	ErrHBARuleNotFound = errors.New("hba rule not found")
+
+	hbaRuleTypes   = []string{"host", "hostssl", "hostnossl"}
+	hbaRuleMethods = []string{"md5", "scram-sha-256", "cert", "reject"}
+
+	// privateNetwork is the network internal users connect from.
+	privateNetwork = mustParseCIDR("fdaa::/16")
+)
+
+// HBARule is a user-managed pg_hba.conf entry. Rules are stored within the state store and are
+// evaluated after the internal entries, but before the default access entries.
+type HBARule struct {
+	ID string `json:"id"`
+	HBAEntry
+	Comment   string    `json:"comment,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HBAReloadResult reports the outcome of a pg_hba.conf reload on a single member.
+type HBAReloadResult struct {
+	Member string `json:"member"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HBARules returns the user-managed rules in evaluation order.
+func HBARules(store state.StateStore) ([]HBARule, error) {
+	data, err := store.PullUserConfig(hbaRulesKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull hba rules: %s", err)
+	}
+
+	return parseHBARules(data)
+}
+
+func parseHBARules(data []byte) ([]HBARule, error) {
+	rules := []HBARule{}
+	if len(data) == 0 {
+		return rules, nil
+	}
+
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse hba rules: %s", err)
+	}
+
+	return rules, nil
+}
+
+// addHBARule validates the rule and appends it to the user-managed rules.
+func addHBARule(store state.StateStore, rule HBARule, internalUsers []string) (*HBARule, error) {
+	if err := validateHBARule(rule.HBAEntry, internalUsers); err != nil {
+		return nil, err
+	}
+
+	rule.ID = fmt.Sprint(time.Now().UnixNano())
+	rule.CreatedAt = time.Now().UTC()
+
+	err := updateHBARules(store, func(rules []HBARule) ([]HBARule, error) {
+		for _, existing := range rules {
+			if existing.HBAEntry == rule.HBAEntry {
+				return nil, fmt.Errorf("%w: an identical rule already exists (%s)", ErrInvalidHBARule, existing.ID)
+			}
+		}
+
+		return append(rules, rule), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+// RemoveHBARule removes the rule with the specified id from the user-managed rules.
+func RemoveHBARule(store state.StateStore, id string) error {
+	return updateHBARules(store, func(rules []HBARule) ([]HBARule, error) {
+		for i, rule := range rules {
+			if rule.ID == id {
+				return slices.Delete(rules, i, i+1), nil
+			}
+		}
+
+		return nil, ErrHBARuleNotFound
+	})
+}
+
+func updateHBARules(store state.StateStore, update func([]HBARule) ([]HBARule, error)) error {
+	for range maxHBARuleUpdateTries {
+		current, err := store.PullUserConfig(hbaRulesKey)
+		if err != nil {
+			return fmt.Errorf("failed to pull hba rules: %s", err)
+		}
+
+		rules, err := parseHBARules(current)
+		if err != nil {
+			return err
+		}
+
+		rules, err = update(rules)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(rules)
+		if err != nil {
+			return err
+		}
+
+		swapped, err := store.CompareAndSwap(hbaRulesKey, current, data)
+		if err != nil {
+			return fmt.Errorf("failed to update hba rules: %s", err)
+		}
+
+		if swapped {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("failed to update hba rules after %d attempts", maxHBARuleUpdateTries)
+}
+
+// validateHBARule verifies the entry is well-formed and that it can't lock the internal users out.
+// Internal users connect over the private network using password authentication.
+func validateHBARule(entry HBAEntry, internalUsers []string) error {
+	if !slices.Contains(hbaRuleTypes, entry.Type) {
+		return fmt.Errorf("%w: type must be one of %s", ErrInvalidHBARule, strings.Join(hbaRuleTypes, ", "))
+	}
+
+	if !slices.Contains(hbaRuleMethods, entry.Method) {
+		return fmt.Errorf("%w: method must be one of %s", ErrInvalidHBARule, strings.Join(hbaRuleMethods, ", "))
+	}
+
+	if entry.Method == "cert" && entry.Type != "hostssl" {
+		return fmt.Errorf("%w: cert authentication requires the hostssl type", ErrInvalidHBARule)
+	}
+
+	for field, value := range map[string]string{"database": entry.Database, "user": entry.User} {
+		if value == "" || strings.ContainsAny(value, " \t\n#\"@") {
+			return fmt.Errorf("%w: %s must be a non-empty, comma separated list without whitespace or file inclusions", ErrInvalidHBARule, field)
+		}
+	}
+
+	_, network, err := net.ParseCIDR(entry.Address)
+	if err != nil {
+		return fmt.Errorf("%w: address must be in CIDR notation: %s", ErrInvalidHBARule, err)
+	}
+
+	users := strings.Split(entry.User, ",")
+	for _, user := range users {
+		if slices.Contains(internalUsers, user) {
+			return fmt.Errorf("%w: access for %s is managed internally", ErrInvalidHBARule, user)
+		}
+	}
+
+	// Rules matching every user, or group members we can't resolve ahead of time, must keep
+	// password authentication available to internal users on the private network.
+	matchesInternal := slices.ContainsFunc(users, func(u string) bool {
+		return u == "all" || strings.HasPrefix(u, "+")
+	})
+
+	if matchesInternal && cidrsOverlap(network, privateNetwork) && (entry.Method == "reject" || entry.Method == "cert") {
+		return fmt.Errorf("%w: %s rules for %s on %s would lock out internal users, name the users explicitly",
+			ErrInvalidHBARule, entry.Method, entry.User, entry.Address)
+	}
+
+	return nil
+}
+
+func cidrsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return network
+}
+
+// internalHBAUsers returns the users that pg_hba.conf rules may not target.
+func (n *Node) internalHBAUsers() []string {
+	return []string{n.RepMgr.Credentials.Username, n.OperatorCredentials.Username}
+}
+
+// AddHBARule validates and stores the rule, using the node's internal users for validation.
+func (n *Node) AddHBARule(store state.StateStore, rule HBARule) (*HBARule, error) {
+	return addHBARule(store, rule, n.internalHBAUsers())
+}
+
+// initializeHBA writes pg_hba.conf on boot. When the user-managed rules or the password
+// authentication method can't be resolved, an existing pg_hba.conf is left in place, as dropping
+// rules could widen access.
+func (c *PGConfig) initializeHBA(store state.StateStore) error {
+	rules, err := HBARules(store)
+	if err == nil {
+		c.authMethod, err = resolvePasswordAuthMethod(store)
+	}
+
+	if err != nil {
+		if _, statErr := os.Stat(c.hbaFilePath()); statErr == nil {
+			log.Printf("[WARN] %s, keeping the existing pg_hba.conf", err)
+			return nil
+		}
+
+		log.Printf("[WARN] %s, writing pg_hba.conf without user-managed rules", err)
+	}
+
+	return c.setDefaultHBA(rules)
+}
+
+func (c *PGConfig) hbaFilePath() string {
+	return fmt.Sprintf("%s/pg_hba.conf", c.DataDir)
+}
+
+// ReloadHBA rewrites pg_hba.conf from the state store and reloads postgres. The previous file
+// is restored when postgres is unable to parse the new one.
+func ReloadHBA(ctx context.Context, n *Node) error {
+	store, err := n.StateStore()
+	if err != nil {
+		return err
+	}
+
+	rules, err := HBARules(store)
+	if err != nil {
+		return err
+	}
+
+	n.PGConfig.authMethod, err = resolvePasswordAuthMethod(store)
+	if err != nil {
+		return err
+	}
+	n.PGConfig.requireClientTLS = n.requireClientTLS()
+
+	previous, err := os.ReadFile(n.PGConfig.hbaFilePath())
+	if err != nil {
+		return fmt.Errorf("failed to read pg_hba.conf: %s", err)
+	}
+
+	if err := n.PGConfig.setDefaultHBA(rules); err != nil {
+		return err
+	}
+
+	conn, err := n.NewLocalConnection(ctx, "postgres", n.SUCredentials)
+	if err != nil {
+		return fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	hbaErrors, err := admin.HBAFileErrors(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to verify pg_hba.conf: %s", err)
+	}
+
+	if len(hbaErrors) > 0 {
+		if err := os.WriteFile(n.PGConfig.hbaFilePath(), previous, 0o600); err != nil {
+			return fmt.Errorf("failed to restore pg_hba.conf: %s", err)
+		}
+
+		return fmt.Errorf("pg_hba.conf was rejected, previous version restored: %s", strings.Join(hbaErrors, "; "))
+	}
+
+	return admin.ReloadPostgresConfig(ctx, conn)
+}
+
+// BroadcastHBAReload instructs every registered member to reload pg_hba.conf from the state store.
+func BroadcastHBAReload(ctx context.Context, n *Node) ([]HBAReloadResult, error) {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	members, err := n.RepMgr.Members(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members: %s", err)
+	}
+
+	var results []HBAReloadResult
+	for _, member := range members {
+		result := HBAReloadResult{Member: member.Hostname}
+		if err := requestMember(ctx, http.MethodPost, member.Hostname, ReloadHBAEndpoint); err != nil {
+			log.Printf("[WARN] Failed to reload pg_hba.conf on member %s: %s", member.Hostname, err)
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
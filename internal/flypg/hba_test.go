@@ -0,0 +1,114 @@
+package flypg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+var testInternalUsers = []string{"repmgr", "flypgadmin"}
+
+func TestValidateHBARule(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		entries := []HBAEntry{
+			{Type: "host", Database: "app", User: "app", Address: "10.0.0.0/8", Method: "scram-sha-256"},
+			{Type: "host", Database: "all", User: "app", Address: "0.0.0.0/0", Method: "reject"},
+			{Type: "hostssl", Database: "app", User: "reporting", Address: "::/0", Method: "cert"},
+			{Type: "host", Database: "all", User: "all", Address: "203.0.113.0/24", Method: "reject"},
+		}
+
+		for _, entry := range entries {
+			if err := validateHBARule(entry, testInternalUsers); err != nil {
+				t.Fatalf("expected %q to be valid, got %s", entry, err)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		entries := map[string]HBAEntry{
+			"localType":        {Type: "local", Database: "all", User: "app", Method: "md5"},
+			"trustMethod":      {Type: "host", Database: "all", User: "app", Address: "10.0.0.0/8", Method: "trust"},
+			"certWithoutSSL":   {Type: "host", Database: "all", User: "app", Address: "10.0.0.0/8", Method: "cert"},
+			"missingAddress":   {Type: "host", Database: "all", User: "app", Method: "md5"},
+			"hostname":         {Type: "host", Database: "all", User: "app", Address: "example.com", Method: "md5"},
+			"whitespace":       {Type: "host", Database: "all", User: "app other", Address: "10.0.0.0/8", Method: "md5"},
+			"fileInclusion":    {Type: "host", Database: "all", User: "@users", Address: "10.0.0.0/8", Method: "md5"},
+			"internalUser":     {Type: "host", Database: "all", User: "app,repmgr", Address: "10.0.0.0/8", Method: "md5"},
+			"rejectAllPrivate": {Type: "host", Database: "all", User: "all", Address: "::/0", Method: "reject"},
+			"rejectGroup":      {Type: "host", Database: "all", User: "+admins", Address: "fdaa:0:1::/48", Method: "reject"},
+		}
+
+		for name, entry := range entries {
+			err := validateHBARule(entry, testInternalUsers)
+			if !errors.Is(err, ErrInvalidHBARule) {
+				t.Fatalf("%s: expected ErrInvalidHBARule, got %v", name, err)
+			}
+		}
+	})
+}
+
+func TestHBARules(t *testing.T) {
+	store := state.NewMemoryStore()
+
+	rules, err := HBARules(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rules) != 0 {
+		t.Fatalf("expected no rules, got %d", len(rules))
+	}
+
+	entry := HBAEntry{Type: "host", Database: "app", User: "app", Address: "10.0.0.0/8", Method: "md5"}
+
+	first, err := addHBARule(store, HBARule{HBAEntry: entry, Author: "alice"}, testInternalUsers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("duplicate", func(t *testing.T) {
+		if _, err := addHBARule(store, HBARule{HBAEntry: entry}, testInternalUsers); !errors.Is(err, ErrInvalidHBARule) {
+			t.Fatalf("expected duplicate rule to be rejected, got %v", err)
+		}
+	})
+
+	reject := HBAEntry{Type: "host", Database: "all", User: "app", Address: "0.0.0.0/0", Method: "reject"}
+	if _, err := addHBARule(store, HBARule{HBAEntry: reject}, testInternalUsers); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("order", func(t *testing.T) {
+		rules, err := HBARules(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rules) != 2 {
+			t.Fatalf("expected 2 rules, got %d", len(rules))
+		}
+
+		if rules[0].ID != first.ID || rules[1].Method != "reject" {
+			t.Fatalf("expected rules to be kept in insertion order, got %+v", rules)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		if err := RemoveHBARule(store, first.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		rules, err := HBARules(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rules) != 1 || rules[0].Method != "reject" {
+			t.Fatalf("expected only the reject rule to remain, got %+v", rules)
+		}
+
+		if err := RemoveHBARule(store, first.ID); !errors.Is(err, ErrHBARuleNotFound) {
+			t.Fatalf("expected ErrHBARuleNotFound, got %v", err)
+		}
+	})
+}
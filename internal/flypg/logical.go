@@ -0,0 +1,174 @@
+package flypg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// Logical slot synchronization to standbys was introduced with PG17.
+	logicalSlotSyncMinVersion = 170000
+
+	syncReplicationSlotsSetting = "sync_replication_slots"
+	hotStandbyFeedbackSetting   = "hot_standby_feedback"
+	primaryConninfoSetting      = "primary_conninfo"
+)
+
+// ManageLogicalSlotSync configures the local standby to synchronize failover-enabled logical slots
+// from the primary, so subscribers are able to resume from a promoted standby. This requires PG17+,
+// wal_level=logical and a standby that replicates directly from the primary. The settings are
+// removed again once these conditions no longer hold.
+func ManageLogicalSlotSync(ctx context.Context, n *Node) error {
+	followsPrimary, standby, err := n.followsPrimary(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !standby {
+		return nil
+	}
+
+	conn, err := n.NewLocalConnection(ctx, "postgres", n.SUCredentials)
+	if err != nil {
+		return fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	version, err := admin.ServerVersionNum(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to resolve server version: %s", err)
+	}
+
+	if version < logicalSlotSyncMinVersion {
+		return nil
+	}
+
+	walLevel, err := admin.GetSetting(ctx, conn, "wal_level")
+	if err != nil {
+		return fmt.Errorf("failed to resolve wal_level: %s", err)
+	}
+
+	// Delayed replicas are never promoted, so there's no reason for them to carry slots.
+	wanted := walLevel.Setting == "logical" && followsPrimary && !n.RepMgr.Delayed
+
+	if !wanted {
+		managed, err := admin.AlterSystemSettingExists(ctx, conn, syncReplicationSlotsSetting)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s source: %s", syncReplicationSlotsSetting, err)
+		}
+
+		if !managed {
+			return nil
+		}
+
+		log.Println("[INFO] Logical slot synchronization no longer applicable, resetting")
+
+		for _, setting := range []string{syncReplicationSlotsSetting, hotStandbyFeedbackSetting} {
+			if err := admin.AlterSystemReset(ctx, conn, setting); err != nil {
+				return fmt.Errorf("failed to reset %s: %s", setting, err)
+			}
+		}
+
+		return admin.ReloadPostgresConfig(ctx, conn)
+	}
+
+	changed := false
+
+	// The slot sync worker requires hot_standby_feedback to prevent the primary from removing
+	// catalog rows the synchronized slots still depend on.
+	for _, setting := range []string{syncReplicationSlotsSetting, hotStandbyFeedbackSetting} {
+		current, err := admin.GetSetting(ctx, conn, setting)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %s", setting, err)
+		}
+
+		if current.Setting == "on" {
+			continue
+		}
+
+		if err := admin.AlterSystemSetting(ctx, conn, setting, "on"); err != nil {
+			return fmt.Errorf("failed to set %s: %s", setting, err)
+		}
+		changed = true
+	}
+
+	// The slot sync worker connects to the database named within primary_conninfo. Repmgr omits it
+	// and rewrites primary_conninfo whenever the standby follows a new primary, so this is re-applied
+	// on every pass.
+	conninfo, err := admin.GetSetting(ctx, conn, primaryConninfoSetting)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %s", primaryConninfoSetting, err)
+	}
+
+	if updated, ok := conninfoWithDBName(conninfo.Setting, n.RepMgr.DatabaseName); ok {
+		if err := admin.AlterSystemSetting(ctx, conn, primaryConninfoSetting, updated); err != nil {
+			return fmt.Errorf("failed to set %s: %s", primaryConninfoSetting, err)
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	log.Println("[INFO] Enabling logical slot synchronization from the primary")
+
+	return admin.ReloadPostgresConfig(ctx, conn)
+}
+
+// followsPrimary reports whether the local member is a standby replicating directly from the
+// primary. The second value reports whether the local member is a standby at all.
+func (n *Node) followsPrimary(ctx context.Context) (bool, bool, error) {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to open local connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	member, err := n.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to resolve member: %s", err)
+	}
+
+	if member.Role != StandbyRoleName {
+		return false, false, nil
+	}
+
+	primary, err := n.RepMgr.PrimaryMember(ctx, conn)
+	if err != nil {
+		return false, true, fmt.Errorf("failed to resolve primary: %s", err)
+	}
+
+	upstream, err := n.RepMgr.UpstreamMember(ctx, conn, member.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, true, nil
+		}
+		return false, true, fmt.Errorf("failed to resolve upstream: %s", err)
+	}
+
+	return upstream.ID == primary.ID, true, nil
+}
+
+// conninfoWithDBName returns the connection string with the dbname keyword appended. False is
+// returned when the connection string is empty or already specifies a database.
+func conninfoWithDBName(conninfo, dbname string) (string, bool) {
+	conninfo = strings.TrimSpace(conninfo)
+	if conninfo == "" {
+		return "", false
+	}
+
+	for _, field := range strings.Fields(conninfo) {
+		if strings.HasPrefix(field, "dbname=") {
+			return "", false
+		}
+	}
+
+	return fmt.Sprintf("%s dbname=%s", conninfo, dbname), true
+}
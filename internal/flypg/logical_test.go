@@ -0,0 +1,31 @@
+package flypg
+
+import "testing"
+
+func TestConninfoWithDBName(t *testing.T) {
+	t.Run("missing", func(t *testing.T) {
+		conninfo := "host=abc.vm.app.internal port=5433 user=repmgr application_name='abc'"
+
+		updated, ok := conninfoWithDBName(conninfo, "repmgr")
+		if !ok {
+			t.Fatal("expected dbname to be appended")
+		}
+
+		expected := conninfo + " dbname=repmgr"
+		if updated != expected {
+			t.Fatalf("expected %q, got %q", expected, updated)
+		}
+	})
+
+	t.Run("present", func(t *testing.T) {
+		if _, ok := conninfoWithDBName("host=abc user=repmgr dbname=repmgr", "repmgr"); ok {
+			t.Fatal("expected conninfo with a dbname to be left alone")
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if _, ok := conninfoWithDBName("", "repmgr"); ok {
+			t.Fatal("expected an empty conninfo to be left alone")
+		}
+	})
+}
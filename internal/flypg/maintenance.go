@@ -0,0 +1,220 @@
+package flypg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+const maintenanceStateKey = "maintenance"
+
+// MaintenanceState is the cluster-wide maintenance flag held within the state store.
+type MaintenanceState struct {
+	Enabled bool      `json:"enabled"`
+	Author  string    `json:"author,omitempty"`
+	Since   time.Time `json:"since"`
+}
+
+// MaintenanceMember reports whether automatic failover has been paused on a given member.
+type MaintenanceMember struct {
+	Hostname string `json:"hostname"`
+	Role     string `json:"role"`
+	Paused   bool   `json:"paused"`
+	Error    string `json:"error,omitempty"`
+}
+
+// MaintenanceStatus is the recorded maintenance state along with what each member is enforcing.
+type MaintenanceStatus struct {
+	MaintenanceState
+	Members []MaintenanceMember `json:"members"`
+	Error   string              `json:"error,omitempty"`
+}
+
+// GetMaintenanceState returns the maintenance state recorded within the state store.
+func GetMaintenanceState(store state.StateStore) (*MaintenanceState, error) {
+	data, err := store.PullUserConfig(maintenanceStateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull maintenance state: %s", err)
+	}
+
+	var ms MaintenanceState
+	if len(data) == 0 {
+		return &ms, nil
+	}
+
+	if err := json.Unmarshal(data, &ms); err != nil {
+		return nil, fmt.Errorf("failed to parse maintenance state: %s", err)
+	}
+
+	return &ms, nil
+}
+
+// MaintenanceEnabled reports whether the cluster has been placed into maintenance mode.
+func MaintenanceEnabled(store state.StateStore) (bool, error) {
+	ms, err := GetMaintenanceState(store)
+	if err != nil {
+		return false, err
+	}
+
+	return ms.Enabled, nil
+}
+
+func setMaintenanceState(store state.StateStore, enabled bool, author string) (*MaintenanceState, error) {
+	ms := &MaintenanceState{
+		Enabled: enabled,
+		Author:  author,
+		Since:   time.Now().UTC(),
+	}
+
+	data, err := json.Marshal(ms)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.PushUserConfig(maintenanceStateKey, data); err != nil {
+		return nil, fmt.Errorf("failed to push maintenance state: %s", err)
+	}
+
+	return ms, nil
+}
+
+// SetMaintenance records the maintenance state within the state store and pauses or unpauses
+// automatic failover across the cluster. The state is recorded first so members that can't be
+// reached right now will converge once their monitor catches up.
+func SetMaintenance(ctx context.Context, n *Node, enabled bool, author string) (*MaintenanceStatus, error) {
+	store, err := n.StateStore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize state store: %s", err)
+	}
+
+	ms, err := setMaintenanceState(store, enabled, author)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MaintenanceStatus{MaintenanceState: *ms}
+
+	if err := n.RepMgr.SetDaemonsPaused(ctx, enabled); err != nil {
+		log.Printf("[WARN] %s", err)
+		status.Error = err.Error()
+	}
+
+	members, err := maintenanceMembers(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	status.Members = members
+
+	return status, nil
+}
+
+// GetMaintenanceStatus returns the recorded maintenance state along with the pause state
+// of each member.
+func GetMaintenanceStatus(ctx context.Context, n *Node) (*MaintenanceStatus, error) {
+	store, err := n.StateStore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize state store: %s", err)
+	}
+
+	ms, err := GetMaintenanceState(store)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := maintenanceMembers(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MaintenanceStatus{MaintenanceState: *ms, Members: members}, nil
+}
+
+func maintenanceMembers(ctx context.Context, n *Node) ([]MaintenanceMember, error) {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	members, err := n.RepMgr.Members(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members: %s", err)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		results = make([]MaintenanceMember, len(members))
+	)
+
+	for i, m := range members {
+		wg.Add(1)
+		go func(i int, m Member) {
+			defer wg.Done()
+
+			results[i] = MaintenanceMember{Hostname: m.Hostname, Role: m.Role}
+
+			mConn, err := n.RepMgr.NewRemoteConnection(ctx, m.Hostname)
+			if err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			defer func() { _ = mConn.Close(ctx) }()
+
+			paused, err := n.RepMgr.DaemonPaused(ctx, mConn)
+			if err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			results[i].Paused = paused
+		}(i, m)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Hostname < results[j].Hostname })
+
+	return results, nil
+}
+
+// EnforceMaintenance pauses automatic failover when the cluster is in maintenance mode but
+// the local repmgrd is not paused, e.g. after the member has been restarted. Failover is never
+// unpaused here, so a manual `repmgr service pause` is left untouched.
+func EnforceMaintenance(ctx context.Context, n *Node) error {
+	store, err := n.StateStore()
+	if err != nil {
+		return fmt.Errorf("failed to initialize state store: %s", err)
+	}
+
+	enabled, err := MaintenanceEnabled(store)
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		return nil
+	}
+
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	paused, err := n.RepMgr.DaemonPaused(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to resolve repmgrd pause state: %s", err)
+	}
+
+	if paused {
+		return nil
+	}
+
+	log.Println("Cluster is in maintenance mode, pausing repmgrd")
+
+	return n.RepMgr.SetDaemonsPaused(ctx, true)
+}
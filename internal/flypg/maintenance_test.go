@@ -0,0 +1,44 @@
+package flypg
+
+import (
+	"testing"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+func TestMaintenanceState(t *testing.T) {
+	store := state.NewMemoryStore()
+
+	t.Run("Enabled", func(t *testing.T) {
+		if _, err := setMaintenanceState(store, true, "operator"); err != nil {
+			t.Fatal(err)
+		}
+
+		ms, err := GetMaintenanceState(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !ms.Enabled {
+			t.Fatal("expected maintenance to be enabled")
+		}
+
+		if ms.Author != "operator" {
+			t.Fatalf("expected author to be operator, got %q", ms.Author)
+		}
+
+		if ms.Since.IsZero() {
+			t.Fatal("expected since to be set")
+		}
+	})
+
+	t.Run("Unreadable", func(t *testing.T) {
+		if err := store.PushUserConfig(maintenanceStateKey, []byte("{")); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := MaintenanceEnabled(store); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package flypg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// memberAPIPort is the port every member's admin API listens on.
+var memberAPIPort = 5500
+
+func memberEndpoint(hostname, target string) string {
+	return fmt.Sprintf("http://%s/%s", net.JoinHostPort(hostname, strconv.Itoa(memberAPIPort)), target)
+}
+
+func requestMember(ctx context.Context, method, hostname, target string) error {
+	ctx, cancel := context.WithTimeout(ctx, memberRequestTimeout)
+	defer cancel()
+
+	endpoint := memberEndpoint(hostname, target)
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode > 299 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// fetchMemberResult issues a GET request against the specified member's admin API and decodes
+// the result into out.
+func fetchMemberResult(ctx context.Context, hostname, target string, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, memberStatusTimeout)
+	defer cancel()
+
+	endpoint := memberEndpoint(hostname, target)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var rv struct {
+		Result json.RawMessage `json:"result"`
+		Error  string          `json:"error,omitempty"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+		return fmt.Errorf("failed to decode response: %s", err)
+	}
+
+	if rv.Error != "" {
+		return fmt.Errorf("%s", rv.Error)
+	}
+
+	return json.Unmarshal(rv.Result, out)
+}
@@ -0,0 +1,105 @@
+package flypg
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// serveMemberAPI points member requests at the handler for the duration of the test.
+func serveMemberAPI(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := memberAPIPort
+	t.Cleanup(func() { memberAPIPort = previous })
+
+	if memberAPIPort, err = strconv.Atoi(port); err != nil {
+		t.Fatal(err)
+	}
+
+	return host
+}
+
+func TestRequestMember(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		var method, path string
+		host := serveMemberAPI(t, func(w http.ResponseWriter, r *http.Request) {
+			method, path = r.Method, r.URL.Path
+		})
+
+		if err := requestMember(ctx, http.MethodPost, host, RestartPostgresEndpoint); err != nil {
+			t.Fatal(err)
+		}
+
+		if method != http.MethodPost || path != "/"+RestartPostgresEndpoint {
+			t.Fatalf("unexpected request %s %s", method, path)
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		host := serveMemberAPI(t, func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "not the primary", http.StatusConflict)
+		})
+
+		err := requestMember(ctx, http.MethodPost, host, RestartPostgresEndpoint)
+		if err == nil || err.Error() != "unexpected status 409: not the primary" {
+			t.Fatalf("expected the status and body to be reported, got %v", err)
+		}
+	})
+}
+
+func TestFetchMemberResult(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("result", func(t *testing.T) {
+		host := serveMemberAPI(t, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"result": {"zombie_lock": true, "repmgrd_running": true}}`))
+		})
+
+		var state MemberState
+		if err := fetchMemberResult(ctx, host, MemberStateEndpoint, &state); err != nil {
+			t.Fatal(err)
+		}
+
+		if !state.ZombieLock || state.ReadonlyLock || !state.RepmgrdRunning {
+			t.Fatalf("unexpected member state %+v", state)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		host := serveMemberAPI(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error": "failed to read lock"}`))
+		})
+
+		var state MemberState
+		err := fetchMemberResult(ctx, host, MemberStateEndpoint, &state)
+		if err == nil || err.Error() != "failed to read lock" {
+			t.Fatalf("expected the member's error to be returned, got %v", err)
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		host := serveMemberAPI(t, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`<html>`))
+		})
+
+		var state MemberState
+		if err := fetchMemberResult(ctx, host, MemberStateEndpoint, &state); err == nil {
+			t.Fatal("expected a malformed response to be rejected")
+		}
+	})
+}
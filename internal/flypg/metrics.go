@@ -0,0 +1,61 @@
+package flypg
+
+import (
+	"errors"
+
+	"github.com/fly-apps/postgres-flex/internal/metrics"
+)
+
+var (
+	zombieScreenings = metrics.NewCounter("flypg_zombie_screenings_total",
+		"Number of zombie screenings performed, by outcome.", "outcome")
+	quarantines = metrics.NewCounter("flypg_quarantines_total",
+		"Number of times the primary has been quarantined.")
+	zombieLockGauge = metrics.NewGauge("flypg_zombie_lock",
+		"Whether the zombie lock was present after the last cluster state evaluation.")
+	readonlyBroadcasts = metrics.NewCounter("flypg_readonly_broadcasts_total",
+		"Number of readonly state changes broadcast to the cluster, by requested state.", "state")
+	readonlyBroadcastFailures = metrics.NewCounter("flypg_readonly_broadcast_failures_total",
+		"Number of members that failed to receive a readonly state change.")
+	backupsTotal = metrics.NewCounter("flypg_backups_total",
+		"Number of base backups attempted, by result.", "result")
+	backupDuration = metrics.NewHistogram("flypg_backup_duration_seconds",
+		"Duration of base backups.", []float64{60, 300, 600, 1800, 3600, 7200, 14400, 28800})
+	lastBackupSuccess = metrics.NewGauge("flypg_backup_last_success_timestamp_seconds",
+		"Unix timestamp of the last successful base backup.")
+	failoverValidations = metrics.NewCounter("flypg_failover_validations_total",
+		"Number of promotion candidates validated, by result.", "result")
+	notificationDeliveries = metrics.NewCounter("flypg_notification_deliveries_total",
+		"Number of webhook delivery attempts, by result.", "result")
+	reseeds = metrics.NewCounter("flypg_reseeds_total",
+		"Number of standby reseeds, by the method that succeeded or failed.", "result")
+	standbyClones = metrics.NewCounter("flypg_standby_clones_total",
+		"Number of standbys provisioned, by source.", "source")
+	configPushes = metrics.NewCounter("flypg_config_pushes_total",
+		"Number of config revisions pushed to the state store, by component.", "component")
+	certificateRenewals = metrics.NewCounter("flypg_certificate_renewals_total",
+		"Number of server certificates issued to the member.")
+	certificateExpiry = metrics.NewGauge("flypg_certificate_expiry_timestamp_seconds",
+		"Unix timestamp at which the member's server certificate expires.")
+)
+
+func screeningOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "healthy"
+	case errors.Is(err, ErrZombieDiscovered):
+		return "zombie"
+	case errors.Is(err, ErrZombieDiagnosisUndecided):
+		return "undecided"
+	default:
+		return "error"
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
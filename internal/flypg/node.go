@@ -16,6 +16,7 @@ import (
 	"github.com/fly-apps/postgres-flex/internal/privnet"
 	"github.com/fly-apps/postgres-flex/internal/utils"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"golang.org/x/exp/slices"
 )
 
@@ -34,6 +35,8 @@ type Node struct {
 	PGConfig  PGConfig
 	RepMgr    RepMgr
 	FlyConfig FlyPGConfig
+
+	store state.StateStore
 }
 
 func NewNode() (*Node, error) {
@@ -100,9 +103,18 @@ func NewNode() (*Node, error) {
 		Credentials:        node.ReplCredentials,
 	}
 
+	// Passwords rotated since the secrets were last set take precedence.
+	node.resolveRotatedCredentials(localCredentialRotations())
+
 	_, present := os.LookupEnv("WITNESS")
 	node.RepMgr.Witness = present
 
+	applyDelay, err := parseApplyDelay(os.Getenv("DELAYED_REPLICA"))
+	if err != nil {
+		return nil, err
+	}
+	node.RepMgr.Delayed = applyDelay > 0
+
 	node.PGConfig = PGConfig{
 		DataDir:                node.DataDir,
 		Port:                   node.Port,
@@ -110,6 +122,7 @@ func NewNode() (*Node, error) {
 		InternalConfigFilePath: fmt.Sprintf("%s/postgresql.internal.conf", node.DataDir),
 		UserConfigFilePath:     fmt.Sprintf("%s/postgresql.user.conf", node.DataDir),
 
+		applyDelay:       applyDelay,
 		passwordFilePath: "/data/.default_password",
 		repmgrUsername:   node.RepMgr.Credentials.Username,
 		repmgrDatabase:   node.RepMgr.DatabaseName,
@@ -125,6 +138,8 @@ func NewNode() (*Node, error) {
 		userConfigFilePath:     "/data/flypg.user.conf",
 	}
 
+	node.RepMgr.VerifyTLS = node.internalTLS()
+
 	return node, nil
 }
 
@@ -134,7 +149,7 @@ func (n *Node) Init(ctx context.Context) error {
 		return fmt.Errorf("failed to set directory ownership: %s", err)
 	}
 
-	store, err := state.NewStore()
+	store, err := n.StateStore()
 	if err != nil {
 		return fmt.Errorf("failed initialize cluster state store: %s", err)
 	}
@@ -167,10 +182,32 @@ func (n *Node) Init(ctx context.Context) error {
 		}
 	}
 
-	if err = WriteSSHKey(); err != nil {
+	if err = writeSSHKey(); err != nil {
 		return fmt.Errorf("failed write ssh keys: %s", err)
 	}
 
+	// The fly config is initialized ahead of provisioning, as it determines how standbys are seeded.
+	if err := n.FlyConfig.initialize(store); err != nil {
+		return fmt.Errorf("failed to initialize fly config: %s", err)
+	}
+
+	// Certificates are provisioned ahead of repmgr, as its conninfo depends on them. Existing
+	// certificates remain in use when the state store is unavailable.
+	if _, err := n.EnsureCertificates(store); err != nil {
+		log.Printf("[WARN] Failed to provision certificates: %s", err)
+	}
+	n.RepMgr.VerifyTLS = n.internalTLS()
+	n.PGConfig.requireClientTLS = n.requireClientTLS()
+
+	// Repmgr's passfile is written from the credentials, so rotations are applied ahead of it.
+	if err := n.syncCredentialRotations(store); err != nil {
+		log.Printf("[WARN] Failed to sync credential rotations: %s", err)
+	}
+
+	if err := n.writeExporterPassword(); err != nil {
+		log.Printf("[WARN] %s", err)
+	}
+
 	if err := n.RepMgr.initialize(); err != nil {
 		return fmt.Errorf("failed to initialize repmgr: %s", err)
 	}
@@ -193,13 +230,8 @@ func (n *Node) Init(ctx context.Context) error {
 					return fmt.Errorf("failed to resolve member over dns: %s", err)
 				}
 
-				if err := n.RepMgr.clonePrimary(cloneTarget.Hostname); err != nil {
-					// Clean-up the directory so it can be retried.
-					if rErr := os.Remove(n.DataDir); rErr != nil {
-						log.Printf("[ERROR] failed to cleanup postgresql dir after clone error: %s\n", rErr)
-					}
-
-					return fmt.Errorf("failed to clone primary: %s", err)
+				if err := n.provisionStandby(ctx, store, cloneTarget.Hostname); err != nil {
+					return err
 				}
 			}
 		} else {
@@ -214,10 +246,7 @@ func (n *Node) Init(ctx context.Context) error {
 		}
 	}
 
-	if err := n.FlyConfig.initialize(); err != nil {
-		return fmt.Errorf("failed to initialize fly config: %s", err)
-	}
-
+	n.PGConfig.restoreFromArchive = restoredFromBackup()
 	if err := n.PGConfig.initialize(store); err != nil {
 		return fmt.Errorf("failed to initialize pg config: %s", err)
 	}
@@ -346,8 +375,8 @@ func (n *Node) PostInit(ctx context.Context) error {
 	} else {
 		// New member
 
-		// Check with consul to see if the cluster has already been initialized
-		store, err := state.NewStore()
+		// Check with the state store to see if the cluster has already been initialized
+		store, err := n.StateStore()
 		if err != nil {
 			return fmt.Errorf("failed initialize cluster state store. %v", err)
 		}
@@ -370,6 +399,10 @@ func (n *Node) PostInit(ctx context.Context) error {
 				)
 			}
 
+			if n.RepMgr.Delayed {
+				return fmt.Errorf("unable to configure a delayed replica as the primary")
+			}
+
 			// Create required users
 			if err := n.setupCredentials(ctx, conn); err != nil {
 				return fmt.Errorf("failed to create required users: %s", err)
@@ -385,16 +418,25 @@ func (n *Node) PostInit(ctx context.Context) error {
 				return fmt.Errorf("failed to register repmgr primary: %s", err)
 			}
 
-			// Set initialization flag within consul so future members know they are joining
+			// New clusters start out with scram-sha-256 hashes, so it can be enforced from the start.
+			if err := n.enforceScramOnInit(ctx, conn, store); err != nil {
+				return err
+			}
+
+			// Set initialization flag within the state store so future members know they are joining
 			// an existing cluster.
 			if err := store.SetInitializationFlag(); err != nil {
-				return fmt.Errorf("failed to register cluster with consul")
+				return fmt.Errorf("failed to register cluster with state store: %s", err)
 			}
 
 			// Let the boot process know that we've already been configured.
 			if err := issueRegistrationCert(); err != nil {
 				return fmt.Errorf("failed to issue registration certificate: %s", err)
 			}
+
+			if err := ReloadHBA(ctx, n); err != nil {
+				log.Printf("[WARN] Failed to reload pg_hba.conf: %s", err)
+			}
 		} else {
 			if n.RepMgr.Witness {
 				log.Println("Registering witness")
@@ -436,9 +478,45 @@ func (n *Node) PostInit(ctx context.Context) error {
 		return fmt.Errorf("failed to close connection: %s", err)
 	}
 
+	// Record whether we are a delayed replica, so the rest of the cluster can account for it.
+	if !n.RepMgr.Witness {
+		if err := n.syncDelayedMember(); err != nil {
+			log.Printf("[WARN] Failed to record delayed replica state: %s", err)
+		}
+	}
+
+	// Standbys outside of the primary region replicate from an in-region relay when cascading
+	// replication is enabled. Failing to do so isn't fatal, as the primary remains a valid upstream.
+	if n.cascadingReplication() && !n.RepMgr.Witness && !n.RepMgr.eligiblePrimary() {
+		if err := n.FollowRegionalUpstream(ctx); err != nil {
+			log.Printf("[WARN] Failed to follow regional upstream: %s", err)
+		}
+	}
+
 	return nil
 }
 
+// StateStore returns the cluster state store, initializing the configured backend on first use.
+func (n *Node) StateStore() (state.StateStore, error) {
+	if n.store != nil {
+		return n.store, nil
+	}
+
+	store, err := state.NewStore()
+	if err != nil {
+		return nil, err
+	}
+
+	n.store = store
+
+	return store, nil
+}
+
+// SetStateStore overrides the state store backend used by the node.
+func (n *Node) SetStateStore(store state.StateStore) {
+	n.store = store
+}
+
 func (n *Node) setupCredentials(ctx context.Context, conn *pgx.Conn) error {
 	requiredCredentials := []admin.Credential{
 		n.OperatorCredentials,
@@ -452,31 +530,52 @@ func (n *Node) setupCredentials(ctx context.Context, conn *pgx.Conn) error {
 // NewLocalConnection opens up a new connection using the flypgadmin user.
 func (n *Node) NewLocalConnection(ctx context.Context, database string, creds admin.Credential) (*pgx.Conn, error) {
 	host := net.JoinHostPort(n.PrivateIP, strconv.Itoa(n.Port))
-	return openConnection(ctx, host, database, creds)
+	return openConnection(ctx, host, database, creds, n.RepMgr.VerifyTLS)
 }
 
-func openConnection(parentCtx context.Context, host string, database string, creds admin.Credential) (*pgx.Conn, error) {
+// openConnection connects to the specified host. When verifyTLS is set, the connection requires
+// TLS and the server's certificate must be issued by the cluster CA for the host it's reached by.
+func openConnection(parentCtx context.Context, host string, database string, creds admin.Credential, verifyTLS bool) (*pgx.Conn, error) {
 	ctx, cancel := context.WithTimeout(parentCtx, 10*time.Second)
 	defer cancel()
 
 	url := fmt.Sprintf("postgres://%s/%s", host, database)
+	if verifyTLS {
+		url += fmt.Sprintf("?sslmode=verify-full&sslrootcert=%s", CACertPath())
+	}
+
 	conf, err := pgx.ParseConfig(url)
 	if err != nil {
 		return nil, err
 	}
 
-	conf.User = creds.Username
-	conf.Password = creds.Password
+	// Long-running processes hold on to the credentials they were started with, so rotations
+	// synced to the member since are resolved on every connection.
+	resolved := rotatedCredential(creds, localCredentialRotations())
+
+	conf.User = resolved.Username
+	conf.Password = resolved.Password
 	conf.ConnectTimeout = 5 * time.Second
 
+	conn, err := pgx.ConnectConfig(ctx, conf)
+	if err == nil || resolved == creds || !isAuthError(err) {
+		return conn, err
+	}
+
+	// The role may not have been changed yet while a rotation is in progress.
+	conf.Password = creds.Password
+
 	return pgx.ConnectConfig(ctx, conf)
 }
 
-func setDirOwnership(ctx context.Context, pathToDir string) error {
-	if os.Getenv("UNIT_TESTING") == "true" {
-		return nil
-	}
+func isAuthError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "28P01"
+}
 
+// setDirOwnership hands the directory over to the postgres user. Like utils.SetFileOwnership, it
+// is swapped out within tests.
+var setDirOwnership = func(ctx context.Context, pathToDir string) error {
 	pgUID, pgGID, err := utils.SystemUserIDs("postgres")
 	if err != nil {
 		return fmt.Errorf("failed to find postgres user ids: %s", err)
@@ -491,7 +590,7 @@ func setDirOwnership(ctx context.Context, pathToDir string) error {
 	return nil
 }
 
-func (n *Node) handleRemoteRestore(ctx context.Context, store *state.Store) error {
+func (n *Node) handleRemoteRestore(ctx context.Context, store state.StateStore) error {
 	// Handle snapshot restore
 	if os.Getenv("FLY_RESTORED_FROM") != "" {
 		active, err := isRestoreActive()
@@ -585,6 +684,28 @@ func (n *Node) migrateNodeNameIfNeeded(ctx context.Context, repConn *pgx.Conn) e
 	return nil
 }
 
+// VoteWeights resolves the vote weights used when establishing quorum.
+func (n *Node) VoteWeights() VoteWeights {
+	w := n.FlyConfig.VoteWeights(n.PrimaryRegion)
+
+	// Delayed replicas don't vote unless configured to, or explicitly weighted.
+	if n.FlyConfig.BoolSetting("delayedReplicaVotes", false) {
+		return w
+	}
+
+	for name := range n.delayedMemberNames() {
+		if w.Members == nil {
+			w.Members = map[string]int{}
+		}
+
+		if _, ok := w.Members[name]; !ok {
+			w.Members[name] = 0
+		}
+	}
+
+	return w
+}
+
 // Hostname returns the hostname of the node.
 func (n *Node) Hostname() string {
 	return fmt.Sprintf("%s.vm.%s.internal", n.MachineID, n.AppName)
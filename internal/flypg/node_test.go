@@ -0,0 +1,306 @@
+package flypg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+	"github.com/fly-apps/postgres-flex/internal/utils"
+)
+
+// stubOwnership skips handing files over to the postgres user and writing ssh keys, as tests
+// don't run within the image.
+func stubOwnership(t *testing.T) {
+	t.Helper()
+
+	setFileOwnership, setDir, writeKey := utils.SetFileOwnership, setDirOwnership, writeSSHKey
+	t.Cleanup(func() {
+		utils.SetFileOwnership, setDirOwnership, writeSSHKey = setFileOwnership, setDir, writeKey
+	})
+
+	utils.SetFileOwnership = func(string, string) error { return nil }
+	setDirOwnership = func(context.Context, string) error { return nil }
+	writeSSHKey = func() error { return nil }
+}
+
+// newTestNode returns a node whose files all reside within a temporary directory.
+func newTestNode(t *testing.T) *Node {
+	t.Helper()
+	stubOwnership(t)
+
+	t.Setenv("FLY_VM_MEMORY_MB", fmt.Sprint(256*(1024*1024)))
+	t.Setenv("SU_PASSWORD", "su-password")
+	t.Setenv("OPERATOR_PASSWORD", "operator-password")
+	t.Setenv("REPL_PASSWORD", "repl-password")
+
+	dir := t.TempDir()
+
+	previousTLSDir, previousCredentialsPath, previousExporterPath := tlsDir, credentialsFilePath, exporterPasswordFilePath
+	previousRestoreMarker := restoredFromBackupMarker
+	t.Cleanup(func() {
+		tlsDir, credentialsFilePath, exporterPasswordFilePath = previousTLSDir, previousCredentialsPath, previousExporterPath
+		restoredFromBackupMarker = previousRestoreMarker
+	})
+	tlsDir = filepath.Join(dir, "tls")
+	credentialsFilePath = filepath.Join(dir, ".credentials")
+	exporterPasswordFilePath = filepath.Join(dir, ".exporter_password")
+	restoredFromBackupMarker = filepath.Join(dir, ".restored_from_backup")
+
+	node := &Node{
+		AppName:       "my-app",
+		MachineID:     "abc123",
+		PrivateIP:     "127.0.0.1",
+		PrimaryRegion: "ord",
+		DataDir:       filepath.Join(dir, "postgresql"),
+		Port:          5433,
+
+		SUCredentials:       admin.Credential{Username: "flypgadmin", Password: "su-password"},
+		OperatorCredentials: admin.Credential{Username: "postgres", Password: "operator-password"},
+		ReplCredentials:     admin.Credential{Username: "repmgr", Password: "repl-password"},
+	}
+
+	node.RepMgr = RepMgr{
+		AppName:            node.AppName,
+		PrimaryRegion:      node.PrimaryRegion,
+		Region:             node.PrimaryRegion,
+		ConfigPath:         filepath.Join(dir, "repmgr.conf"),
+		InternalConfigPath: filepath.Join(dir, "repmgr.internal.conf"),
+		UserConfigPath:     filepath.Join(dir, "repmgr.user.conf"),
+		PasswordConfigPath: filepath.Join(dir, ".pgpass"),
+		DataDir:            node.DataDir,
+		HostName:           node.Hostname(),
+		PrivateIP:          node.PrivateIP,
+		MachineID:          node.MachineID,
+		Port:               node.Port,
+		DatabaseName:       "repmgr",
+		Credentials:        node.ReplCredentials,
+	}
+
+	node.PGConfig = PGConfig{
+		DataDir:                node.DataDir,
+		Port:                   node.Port,
+		ConfigFilePath:         filepath.Join(node.DataDir, "postgresql.conf"),
+		InternalConfigFilePath: filepath.Join(node.DataDir, "postgresql.internal.conf"),
+		UserConfigFilePath:     filepath.Join(node.DataDir, "postgresql.user.conf"),
+
+		passwordFilePath: filepath.Join(dir, ".default_password"),
+		repmgrUsername:   node.RepMgr.Credentials.Username,
+		repmgrDatabase:   node.RepMgr.DatabaseName,
+	}
+
+	node.FlyConfig = FlyPGConfig{
+		internalConfigFilePath: filepath.Join(dir, "flypg.internal.conf"),
+		userConfigFilePath:     filepath.Join(dir, "flypg.user.conf"),
+	}
+
+	return node
+}
+
+// stubDataDir stands in for a data directory that has already been initialized by initdb.
+func stubDataDir(t *testing.T, n *Node) {
+	t.Helper()
+
+	if err := os.MkdirAll(n.DataDir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(n.PGConfig.ConfigFilePath, []byte("# postgresql.conf\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type unavailableStore struct {
+	state.StateStore
+}
+
+func (unavailableStore) IsInitializationFlagSet() (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestNodeStateStoreOverride(t *testing.T) {
+	store := state.NewMemoryStore()
+
+	n := &Node{}
+	n.SetStateStore(store)
+
+	resolved, err := n.StateStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resolved != store {
+		t.Fatal("expected node to use the overridden state store")
+	}
+}
+
+func TestNodeInit(t *testing.T) {
+	t.Run("existing member", func(t *testing.T) {
+		node := newTestNode(t)
+		stubDataDir(t, node)
+
+		store := state.NewMemoryStore()
+		if err := store.SetInitializationFlag(); err != nil {
+			t.Fatal(err)
+		}
+		node.SetStateStore(store)
+
+		if err := node.Init(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := os.ReadFile(node.PGConfig.ConfigFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(contents), "include 'postgresql.internal.conf'") {
+			t.Fatalf("expected postgresql.conf to include the internal config, got %s", contents)
+		}
+
+		for _, path := range []string{
+			node.PGConfig.InternalConfigFilePath,
+			node.RepMgr.ConfigPath,
+			node.RepMgr.InternalConfigPath,
+			node.FlyConfig.InternalConfigFile(),
+			ExporterPasswordPath(),
+		} {
+			if !utils.FileExists(path) {
+				t.Fatalf("expected %s to be written", path)
+			}
+		}
+
+		passfile, err := os.ReadFile(node.RepMgr.PasswordConfigPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(passfile) != "*:*:*:repmgr:repl-password" {
+			t.Fatalf("unexpected passfile contents %q", passfile)
+		}
+
+		if !TLSEnabled() {
+			t.Fatal("expected a server certificate to be issued")
+		}
+
+		ca, err := store.PullUserConfig(clusterCAKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(ca) == 0 {
+			t.Fatal("expected the cluster ca to be stored")
+		}
+
+		initialized, err := store.IsInitializationFlagSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !initialized {
+			t.Fatal("expected the initialization flag to remain set")
+		}
+
+		if node.PGConfig.restoreFromArchive {
+			t.Fatal("expected the archive not to be restored from without a backup restore")
+		}
+	})
+
+	t.Run("restored from backup", func(t *testing.T) {
+		node := newTestNode(t)
+		stubDataDir(t, node)
+
+		if err := os.WriteFile(restoredFromBackupMarker, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		store := state.NewMemoryStore()
+		if err := store.SetInitializationFlag(); err != nil {
+			t.Fatal(err)
+		}
+		node.SetStateStore(store)
+
+		if err := node.Init(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if !node.PGConfig.restoreFromArchive {
+			t.Fatal("expected the archive to be restored from on a member seeded from a backup")
+		}
+	})
+
+	t.Run("credential rotations", func(t *testing.T) {
+		node := newTestNode(t)
+		stubDataDir(t, node)
+
+		store := state.NewMemoryStore()
+		node.SetStateStore(store)
+
+		rotation := sealedRotation(t, "repmgr", "rotated-repl-password", "repl-password")
+		if err := recordCredentialRotation(store, rotation); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := node.Init(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		passfile, err := os.ReadFile(node.RepMgr.PasswordConfigPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(passfile) != "*:*:*:repmgr:rotated-repl-password" {
+			t.Fatalf("expected the passfile to hold the rotated password, got %q", passfile)
+		}
+	})
+
+	t.Run("state store unavailable", func(t *testing.T) {
+		node := newTestNode(t)
+		node.SetStateStore(unavailableStore{})
+
+		if err := node.Init(context.Background()); err == nil {
+			t.Fatal("expected an error when the state store is unavailable")
+		}
+
+		if utils.FileExists(node.RepMgr.ConfigPath) {
+			t.Fatal("expected repmgr to be left unconfigured")
+		}
+	})
+}
+
+func TestNodePostInit(t *testing.T) {
+	t.Run("postgres unavailable", func(t *testing.T) {
+		node := newTestNode(t)
+
+		store := state.NewMemoryStore()
+		node.SetStateStore(store)
+
+		// Grab a port nothing is listening on.
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		node.Port = listener.Addr().(*net.TCPAddr).Port
+		_ = listener.Close()
+
+		if err := node.PostInit(context.Background()); err == nil {
+			t.Fatal("expected an error when postgres is unavailable")
+		}
+
+		initialized, err := store.IsInitializationFlagSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if initialized {
+			t.Fatal("expected the cluster to remain uninitialized")
+		}
+	})
+}
@@ -0,0 +1,418 @@
+package flypg
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Notification events emitted outside of the repmgr event catalogue.
+const (
+	NotificationQuarantine            = "quarantine"
+	NotificationReadonlyEnabled       = "readonly_enabled"
+	NotificationReadonlyDisabled      = "readonly_disabled"
+	NotificationDiskCapacityExceeded  = "disk_capacity_exceeded"
+	NotificationBackupFailed          = "backup_failed"
+	NotificationBackupRetentionFailed = "backup_retention_failed"
+	NotificationReseed                = "standby_reseed"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 signature of the payload when a secret is configured.
+	SignatureHeader = "X-Flypg-Signature"
+	// EventHeader carries the notification event.
+	EventHeader = "X-Flypg-Event"
+	// DeliveryHeader carries the notification id, which is stable across retries.
+	DeliveryHeader = "X-Flypg-Delivery"
+
+	defaultNotificationSpoolDir = "/data/notifications"
+
+	notificationTimeout      = 5 * time.Second
+	notificationBaseBackoff  = 30 * time.Second
+	notificationMaxBackoff   = 30 * time.Minute
+	notificationMaxAttempts  = 10
+	notificationInitialDelay = time.Minute
+)
+
+// NotificationEvents returns every event a webhook can subscribe to.
+func NotificationEvents() []string {
+	return append([]string{
+		NotificationQuarantine,
+		NotificationReadonlyEnabled,
+		NotificationReadonlyDisabled,
+		NotificationDiskCapacityExceeded,
+		NotificationBackupFailed,
+		NotificationBackupRetentionFailed,
+		NotificationReseed,
+	}, RepmgrEvents...)
+}
+
+// Notification is the JSON payload delivered to each webhook.
+type Notification struct {
+	ID        string         `json:"id"`
+	Event     string         `json:"event"`
+	Timestamp time.Time      `json:"timestamp"`
+	App       string         `json:"app"`
+	MachineID string         `json:"machine_id"`
+	Region    string         `json:"region"`
+	Message   string         `json:"message"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// spooledNotification is a pending delivery to a single webhook.
+type spooledNotification struct {
+	URL          string       `json:"url"`
+	Notification Notification `json:"notification"`
+	Attempts     int          `json:"attempts"`
+	NextAttempt  time.Time    `json:"next_attempt"`
+	LastError    string       `json:"last_error,omitempty"`
+}
+
+// Notifier delivers notifications to the configured webhooks. Deliveries are spooled to disk
+// before they are attempted, so they survive restarts and can be retried with backoff.
+type Notifier struct {
+	URLs     []string
+	Secret   string
+	Events   []string
+	SpoolDir string
+
+	client *http.Client
+}
+
+// Notifier resolves the webhook notifier from the current settings.
+func (c *FlyPGConfig) Notifier() *Notifier {
+	return &Notifier{
+		URLs:     splitList(c.StringSetting("webhookURLs", "")),
+		Secret:   c.StringSetting("webhookSecret", ""),
+		Events:   splitList(c.StringSetting("webhookEvents", "")),
+		SpoolDir: defaultNotificationSpoolDir,
+	}
+}
+
+// Notify sends a notification to the configured webhooks. Failures are logged, as notifications
+// should never prevent the caller from carrying on.
+func (n *Node) Notify(ctx context.Context, event, message string, details map[string]any) {
+	if err := n.FlyConfig.Notifier().Notify(ctx, n.notification(event, message, details)); err != nil {
+		log.Printf("[WARN] Failed to send %s notification: %s", event, err)
+	}
+}
+
+// SpoolNotification queues a notification without attempting to deliver it, leaving the delivery
+// to the monitor. Time-critical paths use it so they are never held up by a slow webhook.
+func (n *Node) SpoolNotification(event, message string, details map[string]any) {
+	if err := n.FlyConfig.Notifier().Spool(n.notification(event, message, details)); err != nil {
+		log.Printf("[WARN] Failed to spool %s notification: %s", event, err)
+	}
+}
+
+func (n *Node) notification(event, message string, details map[string]any) Notification {
+	return Notification{
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		App:       n.AppName,
+		MachineID: n.MachineID,
+		Region:    n.RepMgr.Region,
+		Message:   message,
+		Details:   details,
+	}
+}
+
+func (nt *Notifier) subscribed(event string) bool {
+	return len(nt.Events) == 0 || slices.Contains(nt.Events, event)
+}
+
+// Notify spools the notification for every configured webhook and attempts an immediate delivery.
+// Deliveries that fail are left within the spool to be retried by Flush.
+func (nt *Notifier) Notify(ctx context.Context, notification Notification) error {
+	return nt.spool(ctx, notification, true)
+}
+
+// Spool spools the notification for every configured webhook, to be delivered by the next Flush.
+func (nt *Notifier) Spool(notification Notification) error {
+	return nt.spool(context.Background(), notification, false)
+}
+
+func (nt *Notifier) spool(ctx context.Context, notification Notification, immediate bool) error {
+	if len(nt.URLs) == 0 || !nt.subscribed(notification.Event) {
+		return nil
+	}
+
+	if notification.ID == "" {
+		id, err := newNotificationID()
+		if err != nil {
+			return err
+		}
+		notification.ID = id
+	}
+
+	nextAttempt := time.Now()
+	if immediate {
+		// Hold off the spool flusher while the immediate delivery is in flight.
+		nextAttempt = nextAttempt.Add(notificationInitialDelay)
+	}
+
+	var errs []string
+	for i, u := range nt.URLs {
+		s := &spooledNotification{
+			URL:          u,
+			Notification: notification,
+			NextAttempt:  nextAttempt,
+		}
+
+		path := filepath.Join(nt.SpoolDir, fmt.Sprintf("%s-%d.json", notification.ID, i))
+		if err := writeSpooledNotification(path, s); err != nil {
+			errs = append(errs, fmt.Sprintf("failed to spool notification: %s", err))
+			continue
+		}
+
+		if !immediate {
+			continue
+		}
+
+		if err := nt.attempt(ctx, path, s); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Flush retries every spooled notification that is due.
+func (nt *Notifier) Flush(ctx context.Context) error {
+	paths, err := filepath.Glob(filepath.Join(nt.SpoolDir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		s, err := readSpooledNotification(path)
+		if err != nil {
+			log.Printf("[WARN] Discarding unreadable notification %s: %s", path, err)
+			_ = os.Remove(path)
+			continue
+		}
+
+		// The webhook has since been removed from the configuration.
+		if !slices.Contains(nt.URLs, s.URL) {
+			_ = os.Remove(path)
+			continue
+		}
+
+		if time.Now().Before(s.NextAttempt) {
+			continue
+		}
+
+		if err := nt.attempt(ctx, path, s); err != nil {
+			log.Printf("[WARN] %s", err)
+		}
+	}
+
+	return nil
+}
+
+// attempt delivers the spooled notification, removing it from the spool once delivered or once
+// it has run out of attempts.
+func (nt *Notifier) attempt(ctx context.Context, path string, s *spooledNotification) error {
+	err := nt.deliver(ctx, s)
+	if err == nil {
+		notificationDeliveries.Inc("delivered")
+		return os.Remove(path)
+	}
+
+	s.Attempts++
+	s.LastError = err.Error()
+
+	if s.Attempts >= notificationMaxAttempts {
+		notificationDeliveries.Inc("dropped")
+		_ = os.Remove(path)
+		return fmt.Errorf("dropping %s notification %s after %d attempts: %s", s.Notification.Event, s.Notification.ID, s.Attempts, err)
+	}
+
+	notificationDeliveries.Inc("failed")
+	s.NextAttempt = time.Now().Add(notificationBackoff(s.Attempts))
+
+	if err := writeSpooledNotification(path, s); err != nil {
+		return fmt.Errorf("failed to update spooled notification: %s", err)
+	}
+
+	return fmt.Errorf("failed to deliver %s notification to %s, retrying at %s: %s",
+		s.Notification.Event, redactURL(s.URL), s.NextAttempt.Format(time.RFC3339), err)
+}
+
+func (nt *Notifier) deliver(ctx context.Context, s *spooledNotification) error {
+	body, err := json.Marshal(s.Notification)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notificationTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, s.Notification.Event)
+	req.Header.Set(DeliveryHeader, s.Notification.ID)
+	if nt.Secret != "" {
+		req.Header.Set(SignatureHeader, SignPayload(nt.Secret, body))
+	}
+
+	client := nt.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return redactURLError(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// SignPayload returns the signature of the payload in the form of `sha256=<hex digest>`.
+func SignPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func notificationBackoff(attempts int) time.Duration {
+	backoff := notificationBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= notificationMaxBackoff {
+			return notificationMaxBackoff
+		}
+	}
+
+	return backoff
+}
+
+func newNotificationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate notification id: %s", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func writeSpooledNotification(path string, s *spooledNotification) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func readSpooledNotification(path string) (*spooledNotification, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s spooledNotification
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// redactURL strips credentials and query parameters, which commonly carry tokens.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid url>"
+	}
+
+	return fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, u.Path)
+}
+
+// redactURLError strips the url from errors returned by the http client and url parser, as they
+// embed it in full, query string included.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+
+	return fmt.Errorf("%s %q: %s", urlErr.Op, redactURL(urlErr.URL), urlErr.Err)
+}
+
+func validateWebhookURLs(value string) error {
+	for _, raw := range splitList(value) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("invalid url: %s", redactURLError(err))
+		}
+
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid url %q: expected an absolute http(s) url", redactURL(raw))
+		}
+	}
+
+	return nil
+}
+
+func validateWebhookEvents(value string) error {
+	known := NotificationEvents()
+	for _, event := range splitList(value) {
+		if !slices.Contains(known, event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+
+	return nil
+}
+
+func splitList(value string) []string {
+	var out []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			out = append(out, entry)
+		}
+	}
+
+	return out
+}
@@ -6,11 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
 	"github.com/fly-apps/postgres-flex/internal/flypg/state"
@@ -27,10 +30,21 @@ type PGConfig struct {
 
 	barmanConfigPath string
 
+	// applyDelay is the recovery_min_apply_delay of a delayed replica.
+	applyDelay time.Duration
+	// restoreFromArchive enables the restore_command. It is only set on members whose data directory
+	// was seeded from a base backup, see restoredFromBackupMarker.
+	restoreFromArchive bool
+
 	passwordFilePath string
 	repmgrUsername   string
 	repmgrDatabase   string
 
+	// authMethod is the password authentication method used by pg_hba.conf entries.
+	authMethod string
+	// requireClientTLS restricts the default access entries to TLS connections.
+	requireClientTLS bool
+
 	internalConfig ConfigMap
 	userConfig     ConfigMap
 }
@@ -112,7 +126,7 @@ func (c *PGConfig) Print(w io.Writer) error {
 	return e.Encode(cfg)
 }
 
-func (c *PGConfig) SetDefaults(store *state.Store) error {
+func (c *PGConfig) SetDefaults(store state.StateStore) error {
 	// The default wal_segment_size in mb
 	const walSegmentSize = 16
 
@@ -171,9 +185,22 @@ func (c *PGConfig) SetDefaults(store *state.Store) error {
 		"wal_level":                "replica",
 		"wal_log_hints":            true,
 		"hot_standby":              true,
+		"password_encryption":      "'scram-sha-256'",
 		"shared_preload_libraries": fmt.Sprintf("'%s'", strings.Join(sharedPreloadLibraries, ",")),
 	}
 
+	// TLS is enabled once the member has been issued a server certificate.
+	if TLSEnabled() {
+		c.internalConfig["ssl"] = "on"
+		c.internalConfig["ssl_cert_file"] = fmt.Sprintf("'%s'", serverCertPath())
+		c.internalConfig["ssl_key_file"] = fmt.Sprintf("'%s'", serverKeyPath())
+		c.internalConfig["ssl_ca_file"] = fmt.Sprintf("'%s'", CACertPath())
+	}
+
+	if c.applyDelay > 0 {
+		c.internalConfig["recovery_min_apply_delay"] = fmt.Sprintf("%dms", c.applyDelay.Milliseconds())
+	}
+
 	// Set WAL Archive specific settings
 	if err := c.setArchiveConfig(store); err != nil {
 		return fmt.Errorf("failed to set archive config: %s", err)
@@ -187,7 +214,7 @@ func (c *PGConfig) SetDefaults(store *state.Store) error {
 	return nil
 }
 
-func (c *PGConfig) setArchiveConfig(store *state.Store) error {
+func (c *PGConfig) setArchiveConfig(store state.StateStore) error {
 	if os.Getenv("S3_ARCHIVE_CONFIG") == "" {
 		c.internalConfig["archive_mode"] = "off"
 		return nil
@@ -206,6 +233,12 @@ func (c *PGConfig) setArchiveConfig(store *state.Store) error {
 	c.internalConfig["archive_command"] = fmt.Sprintf("'%s'", barman.walArchiveCommand())
 	c.internalConfig["archive_timeout"] = barman.Settings.ArchiveTimeout
 
+	// Standbys restored from a base backup replay the WAL archived since the backup was taken
+	// before they can stream from the primary. This is only consulted during recovery.
+	if c.restoreFromArchive {
+		c.internalConfig["restore_command"] = fmt.Sprintf("'%s'", barman.walRestoreCommand())
+	}
+
 	return nil
 }
 
@@ -246,7 +279,7 @@ func (c *PGConfig) setRecoveryTargetConfig() error {
 func (c *PGConfig) RuntimeApply(ctx context.Context, conn *pgx.Conn) error {
 	for key, value := range c.userConfig {
 		if err := admin.SetConfigurationSetting(ctx, conn, key, value); err != nil {
-			log.Printf("[WARN] Failed to set configuration setting %s -> %s: %s", key, value, err)
+			log.Printf("[WARN] Failed to set configuration setting %s -> %v: %s", key, RedactSetting(key, value), err)
 		}
 	}
 
@@ -269,8 +302,8 @@ func (c *PGConfig) isInitialized() bool {
 
 // initialize will ensure the required configuration files are stubbed and the parent
 // postgresql.conf file includes them.
-func (c *PGConfig) initialize(store *state.Store) error {
-	if err := c.setDefaultHBA(); err != nil {
+func (c *PGConfig) initialize(store state.StateStore) error {
+	if err := c.initializeHBA(store); err != nil {
 		return fmt.Errorf("failed updating pg_hba.conf: %s", err)
 	}
 
@@ -299,7 +332,7 @@ func (c *PGConfig) initialize(store *state.Store) error {
 	}
 
 	if err := SyncUserConfig(c, store); err != nil {
-		log.Printf("[WARN] Failed to sync user config from consul for postgres: %s\n", err.Error())
+		log.Printf("[WARN] Failed to sync user config from state store for postgres: %s\n", err.Error())
 		log.Println("[WARN] This may cause this node to behave unexpectedly")
 		if err := writeInternalConfigFile(c); err != nil {
 			return fmt.Errorf("failed to write pg config files: %s", err)
@@ -502,15 +535,22 @@ func resolveConfigValue(requested ConfigMap, current ConfigMap, key string, defa
 }
 
 type HBAEntry struct {
-	Type     string
-	Database string
-	User     string
-	Address  string
-	Method   string
+	Type     string `json:"type"`
+	Database string `json:"database"`
+	User     string `json:"user"`
+	Address  string `json:"address,omitempty"`
+	Method   string `json:"method"`
 }
 
-func (c *PGConfig) setDefaultHBA() error {
-	entries := []HBAEntry{
+func (e HBAEntry) String() string {
+	return fmt.Sprintf("%s %s %s %s %s", e.Type, e.Database, e.User, e.Address, e.Method)
+}
+
+// internalHBAEntries are required by the cluster itself and always take precedence.
+func (c *PGConfig) internalHBAEntries() []HBAEntry {
+	method := c.passwordAuthMethod()
+
+	return []HBAEntry{
 		{
 			Type:     "local",
 			Database: "all",
@@ -540,41 +580,63 @@ func (c *PGConfig) setDefaultHBA() error {
 			Database: "replication",
 			User:     c.repmgrUsername,
 			Address:  "fdaa::/16",
-			Method:   "md5",
+			Method:   method,
 		},
 		{
 			Type:     "host",
 			Database: fmt.Sprintf("replication,%s", c.repmgrDatabase),
 			User:     c.repmgrUsername,
 			Address:  "fdaa::/16",
-			Method:   "md5",
+			Method:   method,
 		},
+	}
+}
+
+// defaultAccessHBAEntries grant password access from anywhere. They are evaluated last, so
+// user-managed rules are able to narrow them down.
+func (c *PGConfig) defaultAccessHBAEntries() []HBAEntry {
+	method := c.passwordAuthMethod()
+
+	connType := "host"
+	if c.requireClientTLS {
+		connType = "hostssl"
+	}
+
+	return []HBAEntry{
 		{
-			Type:     "host",
+			Type:     connType,
 			Database: "all",
 			User:     "all",
 			Address:  "0.0.0.0/0",
-			Method:   "md5",
+			Method:   method,
 		},
 		{
-			Type:     "host",
+			Type:     connType,
 			Database: "all",
 			User:     "all",
 			Address:  "::0/0",
-			Method:   "md5",
+			Method:   method,
 		},
 	}
+}
 
-	path := fmt.Sprintf("%s/pg_hba.conf", c.DataDir)
-	file, err := os.OpenFile(path, os.O_RDWR|os.O_TRUNC|os.O_CREATE, 0o600)
+// setDefaultHBA writes pg_hba.conf, placing the user-managed rules between the internal entries
+// and the default access entries.
+func (c *PGConfig) setDefaultHBA(rules []HBARule) error {
+	entries := c.internalHBAEntries()
+	for _, rule := range rules {
+		entries = append(entries, rule.HBAEntry)
+	}
+	entries = append(entries, c.defaultAccessHBAEntries()...)
+
+	file, err := os.OpenFile(c.hbaFilePath(), os.O_RDWR|os.O_TRUNC|os.O_CREATE, 0o600)
 	if err != nil {
 		return fmt.Errorf("failed to create pg_hba.conf file: %s", err)
 	}
 	defer func() { _ = file.Close() }()
 
 	for _, entry := range entries {
-		str := fmt.Sprintf("%s %s %s %s %s\n", entry.Type, entry.Database, entry.User, entry.Address, entry.Method)
-		_, err := file.WriteString(str)
+		_, err := file.WriteString(entry.String() + "\n")
 		if err != nil {
 			return err
 		}
@@ -607,3 +669,33 @@ func diskSizeInBytes(dir string) (uint64, error) {
 
 	return stat.Blocks * uint64(stat.Bsize), nil
 }
+
+func diskAvailableInBytes(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
+
+func dirSizeInBytes(dir string) (uint64, error) {
+	var size uint64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += uint64(info.Size())
+		}
+
+		return nil
+	})
+
+	return size, err
+}
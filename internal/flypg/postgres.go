@@ -0,0 +1,66 @@
+package flypg
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/fly-apps/postgres-flex/internal/utils"
+)
+
+// RestartPostgres performs a fast restart of the local Postgres instance.
+// Similar to the restarts issued by repmgr, the restarted postmaster is detached
+// from the supervisor.
+func RestartPostgres(ctx context.Context, dataDir string) error {
+	logFile := filepath.Join(filepath.Dir(dataDir), "postgres_restart.log")
+
+	if _, err := utils.RunCmd(ctx, "postgres",
+		"pg_ctl", "restart",
+		"-D", dataDir,
+		"-m", "fast",
+		"-w",
+		"-l", logFile); err != nil {
+		return fmt.Errorf("failed to restart postgres: %s", err)
+	}
+
+	return nil
+}
+
+// StartPostgres starts the local Postgres instance. Much like RestartPostgres, the postmaster
+// is detached from the supervisor.
+func StartPostgres(ctx context.Context, dataDir string) error {
+	logFile := filepath.Join(filepath.Dir(dataDir), "postgres_restart.log")
+
+	if _, err := utils.RunCmd(ctx, "postgres",
+		"pg_ctl", "start",
+		"-D", dataDir,
+		"-w",
+		"-l", logFile); err != nil {
+		return fmt.Errorf("failed to start postgres: %s", err)
+	}
+
+	return nil
+}
+
+// StopPostgres performs a fast shutdown of the local Postgres instance.
+func StopPostgres(ctx context.Context, dataDir string) error {
+	if _, err := utils.RunCmd(ctx, "postgres",
+		"pg_ctl", "stop",
+		"-D", dataDir,
+		"-m", "fast",
+		"-w"); err != nil {
+		return fmt.Errorf("failed to stop postgres: %s", err)
+	}
+
+	return nil
+}
+
+// stopPostgresIfRunning stops the local Postgres instance, unless it's already down. A failed stop
+// is returned, so callers never remove the data of a postmaster that's still running.
+func stopPostgresIfRunning(ctx context.Context, dataDir string) error {
+	if !utils.FileExists(filepath.Join(dataDir, "postmaster.pid")) {
+		return nil
+	}
+
+	return StopPostgres(ctx, dataDir)
+}
@@ -0,0 +1,197 @@
+package flypg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultPrimaryRegionVoteWeight = 1
+	defaultRemoteRegionVoteWeight  = 0
+)
+
+// VoteWeights resolves how many votes each member holds when establishing quorum. Members
+// default to a weight based on whether they reside within the PRIMARY_REGION, which can
+// be overridden on a per-member basis.
+type VoteWeights struct {
+	PrimaryRegion string
+	InRegion      int
+	OutOfRegion   int
+	// Members overrides the weight of specific members, keyed by node name.
+	Members map[string]int
+}
+
+// DefaultVoteWeights only grants votes to members within the primary region.
+func DefaultVoteWeights(primaryRegion string) VoteWeights {
+	return VoteWeights{
+		PrimaryRegion: primaryRegion,
+		InRegion:      defaultPrimaryRegionVoteWeight,
+		OutOfRegion:   defaultRemoteRegionVoteWeight,
+	}
+}
+
+// Weight returns the number of votes held by the specified member.
+func (w VoteWeights) Weight(m Member) int {
+	if weight, ok := w.Members[m.Name]; ok {
+		return weight
+	}
+
+	if m.Region == w.PrimaryRegion {
+		return w.InRegion
+	}
+
+	return w.OutOfRegion
+}
+
+// selfWeight returns the weight of the member performing the evaluation, which always
+// holds at least a single vote.
+func (w VoteWeights) selfWeight(m Member) int {
+	if weight := w.Weight(m); weight > 0 {
+		return weight
+	}
+
+	return 1
+}
+
+// Quorum returns the number of votes required to form a majority.
+func Quorum(totalVotes int) int {
+	return totalVotes/2 + 1
+}
+
+// ParseMemberVoteWeights parses per-member weight overrides in the form of
+// `<node-name>:<weight>,<node-name>:<weight>`.
+func ParseMemberVoteWeights(s string) (map[string]int, error) {
+	weights := map[string]int{}
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(entry, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid member weight %q, expected <node-name>:<weight>", entry)
+		}
+
+		weight, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight for member %s: %q", name, value)
+		}
+
+		weights[strings.TrimSpace(name)] = weight
+	}
+
+	return weights, nil
+}
+
+// VoteWeights resolves the configured vote weights.
+func (c *FlyPGConfig) VoteWeights(primaryRegion string) VoteWeights {
+	w := VoteWeights{
+		PrimaryRegion: primaryRegion,
+		InRegion:      int(c.IntSetting("primaryRegionVoteWeight", defaultPrimaryRegionVoteWeight)),
+		OutOfRegion:   int(c.IntSetting("remoteRegionVoteWeight", defaultRemoteRegionVoteWeight)),
+	}
+
+	members, err := ParseMemberVoteWeights(c.StringSetting("memberVoteWeights", ""))
+	if err != nil {
+		// Validation prevents this, but don't let a bad override take down quorum evaluation.
+		return w
+	}
+	w.Members = members
+
+	return w
+}
+
+// VoteTally is the outcome of a weighted vote.
+type VoteTally struct {
+	Total   int `json:"total"`
+	Visible int `json:"visible"`
+	Quorum  int `json:"quorum"`
+}
+
+// Met returns true when the visible votes form a majority.
+func (t VoteTally) Met() bool {
+	return t.Visible > 0 && t.Visible >= t.Quorum
+}
+
+// TallyVotes weighs the visible members against every registered member. The member
+// performing the evaluation is always considered visible.
+func TallyVotes(weights VoteWeights, self Member, members []Member, visible map[int]bool) VoteTally {
+	tally := VoteTally{}
+
+	for _, m := range members {
+		if m.ID == self.ID {
+			continue
+		}
+
+		weight := weights.Weight(m)
+		tally.Total += weight
+		if visible[m.ID] {
+			tally.Visible += weight
+		}
+	}
+
+	selfWeight := weights.selfWeight(self)
+	tally.Total += selfWeight
+	tally.Visible += selfWeight
+	tally.Quorum = Quorum(tally.Total)
+
+	return tally
+}
+
+// FailoverVoteTally determines whether the local member can see enough of the cluster to
+// safely be promoted.
+func FailoverVoteTally(ctx context.Context, n *Node) (VoteTally, error) {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return VoteTally{}, fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	self, err := n.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return VoteTally{}, fmt.Errorf("failed to resolve local member: %s", err)
+	}
+
+	members, err := n.RepMgr.Members(ctx, conn)
+	if err != nil {
+		return VoteTally{}, fmt.Errorf("failed to query members: %s", err)
+	}
+
+	weights := n.VoteWeights()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		visible = map[int]bool{}
+	)
+
+	for _, m := range members {
+		// Only members that hold a vote need to be probed.
+		if m.ID == self.ID || weights.Weight(m) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func(m Member) {
+			defer wg.Done()
+
+			mConn, err := n.RepMgr.NewRemoteConnection(ctx, m.Hostname)
+			if err != nil {
+				return
+			}
+			_ = mConn.Close(ctx)
+
+			mu.Lock()
+			visible[m.ID] = true
+			mu.Unlock()
+		}(m)
+	}
+	wg.Wait()
+
+	return TallyVotes(weights, *self, members, visible), nil
+}
@@ -0,0 +1,157 @@
+package flypg
+
+import (
+	"testing"
+)
+
+func TestVoteWeights(t *testing.T) {
+	weights := VoteWeights{
+		PrimaryRegion: "iad",
+		InRegion:      2,
+		OutOfRegion:   1,
+		Members:       map[string]int{"148ed726c12358": 0},
+	}
+
+	tests := []struct {
+		name     string
+		member   Member
+		expected int
+	}{
+		{name: "InRegion", member: Member{Name: "3287e67bc12d18", Region: "iad"}, expected: 2},
+		{name: "OutOfRegion", member: Member{Name: "3287e67bc12d19", Region: "ord"}, expected: 1},
+		{name: "Override", member: Member{Name: "148ed726c12358", Region: "iad"}, expected: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := weights.Weight(tc.member); w != tc.expected {
+				t.Fatalf("expected weight %d, got %d", tc.expected, w)
+			}
+		})
+	}
+
+	t.Run("SelfAlwaysVotes", func(t *testing.T) {
+		if w := weights.selfWeight(Member{Name: "148ed726c12358", Region: "iad"}); w != 1 {
+			t.Fatalf("expected weight 1, got %d", w)
+		}
+	})
+}
+
+func TestParseMemberVoteWeights(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected map[string]int
+		valid    bool
+	}{
+		{name: "Empty", value: "", expected: map[string]int{}, valid: true},
+		{name: "Multiple", value: "148ed726c12358:2, 3287e67bc12d18:0", expected: map[string]int{"148ed726c12358": 2, "3287e67bc12d18": 0}, valid: true},
+		{name: "MissingWeight", value: "148ed726c12358", valid: false},
+		{name: "NegativeWeight", value: "148ed726c12358:-1", valid: false},
+		{name: "InvalidWeight", value: "148ed726c12358:two", valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			weights, err := ParseMemberVoteWeights(tc.value)
+			if !tc.valid {
+				if err == nil {
+					t.Fatalf("expected %q to be invalid", tc.value)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(weights) != len(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, weights)
+			}
+
+			for k, v := range tc.expected {
+				if weights[k] != v {
+					t.Fatalf("expected %v, got %v", tc.expected, weights)
+				}
+			}
+		})
+	}
+}
+
+func TestTallyVotes(t *testing.T) {
+	primary := Member{ID: 1, Name: "primary", Region: "iad", Role: PrimaryRoleName}
+	candidate := Member{ID: 2, Name: "candidate", Region: "iad", Role: StandbyRoleName}
+	standby := Member{ID: 3, Name: "standby", Region: "iad", Role: StandbyRoleName}
+	remote1 := Member{ID: 4, Name: "remote-1", Region: "ord", Role: StandbyRoleName}
+	remote2 := Member{ID: 5, Name: "remote-2", Region: "ord", Role: StandbyRoleName}
+
+	members := []Member{primary, candidate, standby, remote1, remote2}
+
+	tests := []struct {
+		name     string
+		weights  VoteWeights
+		self     Member
+		visible  map[int]bool
+		expected VoteTally
+		met      bool
+	}{
+		{
+			name:     "RemoteRegionPartitionWithDefaultWeights",
+			weights:  DefaultVoteWeights("iad"),
+			self:     candidate,
+			visible:  map[int]bool{standby.ID: true},
+			expected: VoteTally{Total: 3, Visible: 2, Quorum: 2},
+			met:      true,
+		},
+		{
+			name:     "RemoteRegionPartitionWithEqualWeights",
+			weights:  VoteWeights{PrimaryRegion: "iad", InRegion: 1, OutOfRegion: 1},
+			self:     candidate,
+			visible:  map[int]bool{standby.ID: true},
+			expected: VoteTally{Total: 5, Visible: 2, Quorum: 3},
+			met:      false,
+		},
+		{
+			name:     "RemoteRegionCandidate",
+			weights:  DefaultVoteWeights("iad"),
+			self:     remote1,
+			visible:  map[int]bool{remote2.ID: true},
+			expected: VoteTally{Total: 4, Visible: 1, Quorum: 3},
+			met:      false,
+		},
+		{
+			name:     "IsolatedCandidate",
+			weights:  DefaultVoteWeights("iad"),
+			self:     candidate,
+			visible:  map[int]bool{},
+			expected: VoteTally{Total: 3, Visible: 1, Quorum: 2},
+			met:      false,
+		},
+		{
+			name: "MemberOverride",
+			weights: VoteWeights{
+				PrimaryRegion: "iad",
+				InRegion:      1,
+				OutOfRegion:   0,
+				Members:       map[string]int{"standby": 3},
+			},
+			self:     candidate,
+			visible:  map[int]bool{standby.ID: true},
+			expected: VoteTally{Total: 5, Visible: 4, Quorum: 3},
+			met:      true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tally := TallyVotes(tc.weights, tc.self, members, tc.visible)
+			if tally != tc.expected {
+				t.Fatalf("expected %+v, got %+v", tc.expected, tally)
+			}
+
+			if tally.Met() != tc.met {
+				t.Fatalf("expected met to be %t", tc.met)
+			}
+		})
+	}
+}
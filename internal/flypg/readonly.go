@@ -68,22 +68,43 @@ func BroadcastReadonlyChange(ctx context.Context, n *Node, enabled bool) error {
 		target = BroadcastDisableEndpoint
 	}
 
+	state := "enabled"
+	if !enabled {
+		state = "disabled"
+	}
+	readonlyBroadcasts.Inc(state)
+
+	failures := 0
 	for _, member := range members {
 		if member.Role == PrimaryRoleName {
 			endpoint := fmt.Sprintf("http://%s:5500/%s", member.Hostname, target)
 			resp, err := http.Get(endpoint)
 			if err != nil {
 				log.Printf("[WARN] Failed to broadcast readonly state change to member %s: %s", member.Hostname, err)
+				readonlyBroadcastFailures.Inc()
+				failures++
 				continue
 			}
 			defer func() { _ = resp.Body.Close() }()
 
 			if resp.StatusCode > 299 {
 				log.Printf("[WARN] Failed to broadcast readonly state change to member %s: %d\n", member.Hostname, resp.StatusCode)
+				readonlyBroadcastFailures.Inc()
+				failures++
 			}
 		}
 	}
 
+	// Only report the state change once it has reached every primary. The notification is spooled
+	// so a slow webhook can't hold up the callers, which include fencing.
+	if failures == 0 {
+		if enabled {
+			n.SpoolNotification(NotificationReadonlyEnabled, "cluster has been made read-only", nil)
+		} else {
+			n.SpoolNotification(NotificationReadonlyDisabled, "cluster is accepting writes again", nil)
+		}
+	}
+
 	for _, member := range members {
 		endpoint := fmt.Sprintf("http://%s:5500/%s", member.Hostname, RestartHaproxyEndpoint)
 		resp, err := http.Get(endpoint)
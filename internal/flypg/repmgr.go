@@ -45,6 +45,10 @@ type RepMgr struct {
 	InternalConfigPath string
 	Port               int
 	Witness            bool
+	// Delayed members replay WAL behind the primary and are never promoted.
+	Delayed bool
+	// VerifyTLS requires TLS for connections between members, verified against the cluster CA.
+	VerifyTLS bool
 
 	internalConfig ConfigMap
 	userConfig     ConfigMap
@@ -98,12 +102,24 @@ func (r *RepMgr) CurrentConfig() (ConfigMap, error) {
 
 func (r *RepMgr) NewLocalConnection(ctx context.Context) (*pgx.Conn, error) {
 	host := net.JoinHostPort(r.PrivateIP, strconv.Itoa(r.Port))
-	return openConnection(ctx, host, r.DatabaseName, r.Credentials)
+	return openConnection(ctx, host, r.DatabaseName, r.Credentials, r.VerifyTLS)
 }
 
 func (r *RepMgr) NewRemoteConnection(ctx context.Context, hostname string) (*pgx.Conn, error) {
 	host := net.JoinHostPort(hostname, strconv.Itoa(r.Port))
-	return openConnection(ctx, host, r.DatabaseName, r.Credentials)
+	return openConnection(ctx, host, r.DatabaseName, r.Credentials, r.VerifyTLS)
+}
+
+// conninfo returns the libpq connection string used to reach the repmgr database on the
+// specified host. Repmgr derives primary_conninfo from it, so replication uses TLS as well.
+// nodeName is the name the member is registered with repmgr under.
+func (r *RepMgr) nodeName() string {
+	return r.MachineID
+}
+
+func (r *RepMgr) conninfo(hostname string) string {
+	return fmt.Sprintf("host=%s port=%d user=%s dbname=%s connect_timeout=5%s",
+		hostname, r.Port, r.Credentials.Username, r.DatabaseName, sslConninfo(r.VerifyTLS))
 }
 
 func (r *RepMgr) initialize() error {
@@ -121,14 +137,8 @@ func (r *RepMgr) initialize() error {
 		return fmt.Errorf("failed to set repmgr.conf ownership: %s", err)
 	}
 
-	// Create password file that repmgr will hook into for internal operations.
-	passStr := fmt.Sprintf("*:*:*:%s:%s", r.Credentials.Username, r.Credentials.Password)
-	if err := os.WriteFile(r.PasswordConfigPath, []byte(passStr), 0o600); err != nil {
-		return fmt.Errorf("failed to write file %s: %s", r.PasswordConfigPath, err)
-	}
-
-	if err := utils.SetFileOwnership(r.PasswordConfigPath, "postgres"); err != nil {
-		return fmt.Errorf("failed to set file ownership: %s", err)
+	if err := r.writePassfile(); err != nil {
+		return err
 	}
 
 	if err := r.setDefaults(); err != nil {
@@ -145,6 +155,20 @@ func (r *RepMgr) initialize() error {
 	return nil
 }
 
+// writePassfile creates the password file that repmgr will hook into for internal operations.
+func (r *RepMgr) writePassfile() error {
+	passStr := fmt.Sprintf("*:*:*:%s:%s", r.Credentials.Username, r.Credentials.Password)
+	if err := os.WriteFile(r.PasswordConfigPath, []byte(passStr), 0o600); err != nil {
+		return fmt.Errorf("failed to write file %s: %s", r.PasswordConfigPath, err)
+	}
+
+	if err := utils.SetFileOwnership(r.PasswordConfigPath, "postgres"); err != nil {
+		return fmt.Errorf("failed to set file ownership: %s", err)
+	}
+
+	return nil
+}
+
 func (r *RepMgr) enable(ctx context.Context, conn *pgx.Conn) error {
 	if err := admin.CreateDatabaseWithOwner(ctx, conn, r.DatabaseName, r.Credentials.Username); err != nil {
 		return fmt.Errorf("failed to create repmgr database: %s", err)
@@ -165,15 +189,15 @@ func (r *RepMgr) setDefaults() error {
 
 	conf := ConfigMap{
 		"node_id":                      nodeID,
-		"node_name":                    fmt.Sprintf("'%s'", r.MachineID),
-		"conninfo":                     fmt.Sprintf("'host=%s port=%d user=%s dbname=%s connect_timeout=5'", r.HostName, r.Port, r.Credentials.Username, r.DatabaseName),
+		"node_name":                    fmt.Sprintf("'%s'", r.nodeName()),
+		"conninfo":                     fmt.Sprintf("'%s'", r.conninfo(r.HostName)),
 		"data_directory":               fmt.Sprintf("'%s'", r.DataDir),
 		"failover":                     "'automatic'",
 		"use_replication_slots":        "yes",
 		"promote_command":              fmt.Sprintf("'repmgr standby promote -f %s --log-to-file'", r.ConfigPath),
 		"follow_command":               fmt.Sprintf("'repmgr standby follow -f %s --log-to-file --upstream-node-id=%%n'", r.ConfigPath),
 		"event_notification_command":   fmt.Sprintf("'/usr/local/bin/event_handler -node-id %%n -event %%e -success %%s -details \"%%d\"'"),
-		"event_notifications":          "'child_node_disconnect,child_node_reconnect,child_node_new_connect'",
+		"event_notifications":          fmt.Sprintf("'%s'", strings.Join(RepmgrEvents, ",")),
 		"location":                     fmt.Sprintf("'%s'", r.Region),
 		"primary_visibility_consensus": true,
 		"failover_validation_command":  fmt.Sprintf("'/usr/local/bin/failover_validation -visible-nodes %%v -total-nodes %%t'"),
@@ -184,7 +208,7 @@ func (r *RepMgr) setDefaults() error {
 		"passfile":                     fmt.Sprintf("'%s'", r.PasswordConfigPath),
 	}
 
-	if !r.eligiblePrimary() {
+	if !r.eligiblePrimary() || r.Delayed {
 		conf["priority"] = "0"
 	}
 
@@ -289,13 +313,7 @@ func (r *RepMgr) unregisterWitness(id int) error {
 }
 
 func (r *RepMgr) rejoinCluster(hostname string) error {
-	cmdStr := fmt.Sprintf("repmgr -f %s node rejoin -h %s -p %d -U %s -d %s --force-rewind --no-wait",
-		r.ConfigPath,
-		hostname,
-		r.Port,
-		r.Credentials.Username,
-		r.DatabaseName,
-	)
+	cmdStr := r.rejoinCommand(hostname)
 
 	log.Println(cmdStr)
 	_, err := utils.RunCommand(cmdStr, "postgres")
@@ -303,18 +321,59 @@ func (r *RepMgr) rejoinCluster(hostname string) error {
 	return err
 }
 
+// rejoinCommand connects through the same conninfo as the rest of repmgr, so it verifies the
+// upstream's certificate once internal TLS is enabled.
+func (r *RepMgr) rejoinCommand(hostname string) string {
+	return fmt.Sprintf("repmgr -f %s node rejoin -d '%s' --force-rewind --no-wait", r.ConfigPath, r.conninfo(hostname))
+}
+
+// StandbySwitchover promotes the local standby and demotes the current primary. Sibling standbys
+// are instructed to follow the new primary.
+func (r *RepMgr) StandbySwitchover(ctx context.Context) error {
+	if _, err := utils.RunCmd(ctx, "postgres",
+		"repmgr", "standby", "switchover",
+		"-f", r.ConfigPath,
+		"--siblings-follow",
+		"--force-rewind",
+		"--log-to-file"); err != nil {
+		return fmt.Errorf("failed to perform switchover: %s", err)
+	}
+
+	return nil
+}
+
+// SetDaemonsPaused pauses or unpauses repmgrd on every reachable member. A paused repmgrd
+// continues to monitor the cluster, but will not initiate a failover.
+func (r *RepMgr) SetDaemonsPaused(ctx context.Context, paused bool) error {
+	action := "unpause"
+	if paused {
+		action = "pause"
+	}
+
+	if _, err := utils.RunCmd(ctx, "postgres", "repmgr", "service", action, "-f", r.ConfigPath); err != nil {
+		return fmt.Errorf("failed to %s repmgrd: %s", action, err)
+	}
+
+	return nil
+}
+
+// DaemonPaused reports whether repmgrd has been paused on the connected member.
+func (*RepMgr) DaemonPaused(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	var paused bool
+	if err := conn.QueryRow(ctx, "SELECT repmgr.repmgrd_is_paused();").Scan(&paused); err != nil {
+		return false, err
+	}
+
+	return paused, nil
+}
+
 func (r *RepMgr) clonePrimary(hostname string) error {
 	cmdStr := fmt.Sprintf("mkdir -p %s", r.DataDir)
 	if _, err := utils.RunCommand(cmdStr, "postgres"); err != nil {
 		return fmt.Errorf("failed to create pg directory: %s", err)
 	}
 
-	cmdStr = fmt.Sprintf("repmgr -h %s -p %d -d %s -U %s -f %s standby clone -c -F",
-		hostname,
-		r.Port,
-		r.DatabaseName,
-		r.Credentials.Username,
-		r.ConfigPath)
+	cmdStr = fmt.Sprintf("repmgr -d '%s' -f %s standby clone -c -F", r.conninfo(hostname), r.ConfigPath)
 
 	log.Println(cmdStr)
 	if _, err := utils.RunCommand(cmdStr, "postgres"); err != nil {
@@ -325,20 +384,26 @@ func (r *RepMgr) clonePrimary(hostname string) error {
 }
 
 func (r *RepMgr) regenReplicationConf(ctx context.Context) error {
-	if _, err := utils.RunCmd(ctx, "postgres",
-		"repmgr", "--replication-conf-only",
-		"-h", r.HostName,
-		"-p", fmt.Sprint(r.Port),
-		"-d", r.DatabaseName,
-		"-U", r.Credentials.Username,
-		"-f", r.ConfigPath,
-		"standby", "clone", "-F"); err != nil {
+	if err := r.writeReplicationConf(ctx, r.HostName); err != nil {
 		return fmt.Errorf("failed to regenerate replication conf: %s", err)
 	}
 
 	return nil
 }
 
+// writeReplicationConf writes the configuration required to stream from the specified upstream
+// into an existing data directory, without cloning it. The standby's replication slot is created
+// on the upstream as well.
+func (r *RepMgr) writeReplicationConf(ctx context.Context, hostname string) error {
+	_, err := utils.RunCmd(ctx, "postgres",
+		"repmgr", "--replication-conf-only",
+		"-d", r.conninfo(hostname),
+		"-f", r.ConfigPath,
+		"standby", "clone", "-F")
+
+	return err
+}
+
 type Member struct {
 	ID       int
 	Name     string
@@ -346,10 +411,23 @@ type Member struct {
 	Active   bool
 	Region   string
 	Role     string
+	// UpstreamID is the node id of the member this member replicates from. Zero for the primary.
+	UpstreamID int
+}
+
+// ApplicationName returns the name the member's replication connection is reported under
+// within pg_stat_replication. The node name is used as the application name within the
+// standby's primary_conninfo.
+func (m Member) ApplicationName() string {
+	if m.Name == "" {
+		return m.Hostname
+	}
+
+	return m.Name
 }
 
 func (r *RepMgr) Members(ctx context.Context, pg *pgx.Conn) ([]Member, error) {
-	sql := "select node_id, node_name, location, active, type from repmgr.nodes;"
+	sql := "select node_id, node_name, location, active, type, coalesce(upstream_node_id, 0) from repmgr.nodes;"
 	rows, err := pg.Query(ctx, sql)
 	if err != nil {
 		return nil, err
@@ -359,7 +437,7 @@ func (r *RepMgr) Members(ctx context.Context, pg *pgx.Conn) ([]Member, error) {
 	var members []Member
 	for rows.Next() {
 		var member Member
-		if err := rows.Scan(&member.ID, &member.Name, &member.Region, &member.Active, &member.Role); err != nil {
+		if err := rows.Scan(&member.ID, &member.Name, &member.Region, &member.Active, &member.Role, &member.UpstreamID); err != nil {
 			return nil, err
 		}
 
@@ -418,6 +496,28 @@ func (r *RepMgr) PrimaryMember(ctx context.Context, pg *pgx.Conn) (*Member, erro
 	return &member, nil
 }
 
+// UpstreamMember returns the member the specified node is replicating from.
+func (r *RepMgr) UpstreamMember(ctx context.Context, pg *pgx.Conn, id int) (*Member, error) {
+	var member Member
+	sql := `select u.node_id, u.node_name, u.location, u.active, u.type from repmgr.nodes n
+		join repmgr.nodes u on u.node_id = n.upstream_node_id where n.node_id = $1;`
+	err := pg.QueryRow(ctx, sql, id).Scan(&member.ID, &member.Name, &member.Region, &member.Active, &member.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assume we are working with a machineID if the name is 14 characters long.
+	if len(member.Name) == 14 {
+		member.Hostname = r.machineIDToDNS(member.Name)
+	} else {
+		// Member name is the private IP.
+		member.Hostname = member.Name
+		member.Name = ""
+	}
+
+	return &member, nil
+}
+
 func (r *RepMgr) IsPrimary(ctx context.Context, pg *pgx.Conn) (bool, error) {
 	member, err := r.Member(ctx, pg)
 	if err != nil {
@@ -443,6 +543,24 @@ func (r *RepMgr) VotingMembers(ctx context.Context, conn *pgx.Conn) ([]Member, e
 	return voters, nil
 }
 
+// QuorumMembers returns every standby and witness, regardless of region. Their votes are
+// weighed when the sample is tallied, so members without a vote are still observed.
+func (r *RepMgr) QuorumMembers(ctx context.Context, conn *pgx.Conn) ([]Member, error) {
+	members, err := r.Members(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var voters []Member
+	for _, member := range members {
+		if member.Role == StandbyRoleName || member.Role == WitnessRoleName {
+			voters = append(voters, member)
+		}
+	}
+
+	return voters, nil
+}
+
 func (*RepMgr) MemberByID(ctx context.Context, pg *pgx.Conn, id int) (*Member, error) {
 	var member Member
 	sql := fmt.Sprintf("select node_id, node_name, location, active, type from repmgr.nodes where node_id = %d;", id)
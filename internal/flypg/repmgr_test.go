@@ -103,6 +103,13 @@ func TestRepmgrInitialization(t *testing.T) {
 		if config["node_id"] == "" {
 			t.Fatalf("expected node_id to not be empty, got %q", config["node_id"])
 		}
+
+		notifications := fmt.Sprint(config["event_notifications"])
+		for _, event := range []string{EventChildNodeDisconnect, EventRepmgrdFailoverPromote, EventStandbyFollow} {
+			if !strings.Contains(notifications, event) {
+				t.Fatalf("expected event_notifications to include %s, got %s", event, notifications)
+			}
+		}
 	})
 }
 
@@ -152,3 +159,29 @@ func TestRepmgrNodeIDGeneration(t *testing.T) {
 		t.Fatalf("expected node_id to be %s, got %q", nodeID, resolvedNodeID)
 	}
 }
+
+func TestRepmgrRejoinCommand(t *testing.T) {
+	conf := &RepMgr{
+		ConfigPath:   repmgrConfigFilePath,
+		Port:         5433,
+		DatabaseName: "repmgr",
+		Credentials:  admin.Credential{Username: "repmgr"},
+	}
+
+	t.Run("plaintext", func(t *testing.T) {
+		expected := fmt.Sprintf("repmgr -f %s node rejoin -d 'host=primary.internal port=5433 user=repmgr dbname=repmgr connect_timeout=5' --force-rewind --no-wait", repmgrConfigFilePath)
+
+		if cmd := conf.rejoinCommand("primary.internal"); cmd != expected {
+			t.Fatalf("expected %q, got %q", expected, cmd)
+		}
+	})
+
+	t.Run("verifyTLS", func(t *testing.T) {
+		conf.VerifyTLS = true
+		defer func() { conf.VerifyTLS = false }()
+
+		if cmd := conf.rejoinCommand("primary.internal"); !strings.Contains(cmd, "sslmode=verify-full sslrootcert="+CACertPath()) {
+			t.Fatalf("expected the rejoin to verify the upstream certificate, got %q", cmd)
+		}
+	})
+}
@@ -0,0 +1,478 @@
+package flypg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// ReseedMethodRewind rewinds the standby with pg_rewind and rejoins the primary.
+	ReseedMethodRewind = "rewind"
+	// ReseedMethodClone wipes the data directory and clones the primary from scratch.
+	ReseedMethodClone = "clone"
+
+	ReseedTriggerAutomatic = "automatic"
+	ReseedTriggerManual    = "manual"
+
+	defaultReseedDetectionThreshold = 5 * time.Minute
+
+	// walStatusLost indicates the primary has removed WAL the replication slot still required.
+	walStatusLost = "lost"
+)
+
+var (
+	reseedLogFile    = "/data/reseed.log"
+	reseedMarkerFile = "/data/.reseed_required"
+	reseedLockFile   = "/data/.reseed.lock"
+
+	// ErrReseedNotStandby - Only standbys can be reseeded.
+	ErrReseedNotStandby = errors.New("only standbys can be reseeded")
+	// ErrReseedInProgress - Another reseed is already running on this member.
+	ErrReseedInProgress = errors.New("a reseed is already in progress")
+)
+
+// Reseed records a single reseed of the local standby, or the planned reseed in the case of a dry run.
+type Reseed struct {
+	Timestamp time.Time `json:"timestamp"`
+	Hostname  string    `json:"hostname"`
+	Primary   string    `json:"primary"`
+	Trigger   string    `json:"trigger"`
+	Reason    string    `json:"reason,omitempty"`
+	DryRun    bool      `json:"dry_run,omitempty"`
+	Methods   []string  `json:"methods"`
+	Method    string    `json:"method,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// DiagnoseReseed reports why the local standby is unable to follow the primary. An empty reason
+// is returned when the member is healthy or isn't a standby.
+func DiagnoseReseed(ctx context.Context, n *Node) (string, error) {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to open local connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	member, err := n.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve member: %s", err)
+	}
+
+	if member.Role != StandbyRoleName {
+		return "", nil
+	}
+
+	receiver, err := admin.GetWalReceiverStatus(ctx, conn)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("failed to query wal receiver: %s", err)
+	}
+
+	// We are streaming, so any previously flagged failure has since been recovered from.
+	if receiver != nil && receiver.Status == "streaming" {
+		if err := clearReseedMarker(); err != nil {
+			log.Printf("[WARN] Failed to clear reseed marker: %s", err)
+		}
+		return "", nil
+	}
+
+	if reason, ok := reseedMarker(); ok {
+		return reason, nil
+	}
+
+	primary, err := n.RepMgr.PrimaryMember(ctx, conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve primary: %s", err)
+	}
+
+	pConn, err := n.RepMgr.NewRemoteConnection(ctx, primary.Hostname)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to primary %s: %s", primary.Hostname, err)
+	}
+	defer func() { _ = pConn.Close(ctx) }()
+
+	slot, err := admin.GetReplicationSlot(ctx, pConn, replicationSlotName(member.ID))
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("failed to query replication slot: %s", err)
+	}
+
+	if slot != nil && slot.WalStatus == walStatusLost {
+		return fmt.Sprintf("%s has removed WAL required by this standby", primary.Hostname), nil
+	}
+
+	localTimeline, err := admin.CurrentTimeline(ctx, conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve local timeline: %s", err)
+	}
+
+	primaryTimeline, err := admin.CurrentTimeline(ctx, pConn)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve primary timeline: %s", err)
+	}
+
+	if localTimeline != primaryTimeline {
+		return fmt.Sprintf("not streaming and on timeline %d while %s is on timeline %d",
+			localTimeline, primary.Hostname, primaryTimeline), nil
+	}
+
+	return "", nil
+}
+
+// ReseedStandby recovers a standby that is unable to follow the primary. A rewind and rejoin is
+// attempted first, falling back to wiping the data directory and cloning the primary. The rewind
+// is skipped when forceClone is set or the primary no longer holds the WAL we require.
+func ReseedStandby(ctx context.Context, n *Node, trigger, reason string, forceClone, dryRun bool) (*Reseed, error) {
+	res := &Reseed{
+		Timestamp: time.Now().UTC(),
+		Hostname:  n.Hostname(),
+		Trigger:   trigger,
+		Reason:    reason,
+		DryRun:    dryRun,
+	}
+
+	member, primary, walLost, err := reseedTarget(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Primary = primary.Hostname
+
+	if !forceClone && !walLost {
+		res.Methods = append(res.Methods, ReseedMethodRewind)
+	}
+	res.Methods = append(res.Methods, ReseedMethodClone)
+
+	if dryRun {
+		return res, nil
+	}
+
+	if err := acquireReseedLock(); err != nil {
+		return nil, err
+	}
+	defer releaseReseedLock()
+
+	err = reseed(ctx, n, member, primary, res)
+	if err != nil {
+		res.Error = err.Error()
+		reseeds.Inc("failed")
+		n.Notify(ctx, NotificationReseed, "standby reseed failed", map[string]any{
+			"primary": res.Primary,
+			"reason":  res.Reason,
+			"error":   res.Error,
+		})
+	} else {
+		reseeds.Inc(res.Method)
+		n.Notify(ctx, NotificationReseed, fmt.Sprintf("standby has been reseeded using %s", res.Method), map[string]any{
+			"primary": res.Primary,
+			"reason":  res.Reason,
+			"method":  res.Method,
+		})
+	}
+
+	recordReseed(*res)
+
+	return res, err
+}
+
+// reseedTarget confirms we are a standby and resolves the primary we'll be reseeding from.
+func reseedTarget(ctx context.Context, n *Node) (*Member, *Member, bool, error) {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to open local connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	member, err := n.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to resolve member: %s", err)
+	}
+
+	if member.Role != StandbyRoleName {
+		return nil, nil, false, fmt.Errorf("%w, we are a %s", ErrReseedNotStandby, member.Role)
+	}
+
+	primary, err := n.RepMgr.PrimaryMember(ctx, conn)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to resolve primary: %s", err)
+	}
+
+	pConn, err := n.RepMgr.NewRemoteConnection(ctx, primary.Hostname)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to connect to primary %s: %s", primary.Hostname, err)
+	}
+	defer func() { _ = pConn.Close(ctx) }()
+
+	// Confirm the primary still identifies itself as such before anything is discarded.
+	confirmed, err := n.RepMgr.PrimaryMember(ctx, pConn)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to confirm primary on %s: %s", primary.Hostname, err)
+	}
+
+	if confirmed.Hostname != primary.Hostname {
+		return nil, nil, false, fmt.Errorf("%s reports %s as the primary", primary.Hostname, confirmed.Hostname)
+	}
+
+	if primary.Hostname == n.Hostname() {
+		return nil, nil, false, fmt.Errorf("resolved primary %s is ourself", primary.Hostname)
+	}
+
+	slot, err := admin.GetReplicationSlot(ctx, pConn, replicationSlotName(member.ID))
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, false, fmt.Errorf("failed to query replication slot: %s", err)
+	}
+
+	return member, primary, slot != nil && slot.WalStatus == walStatusLost, nil
+}
+
+func reseed(ctx context.Context, n *Node, member, primary *Member, res *Reseed) error {
+	if res.Methods[0] == ReseedMethodRewind {
+		log.Printf("[INFO] Attempting to rewind and rejoin %s\n", primary.Hostname)
+
+		err := rewindStandby(ctx, n, member, primary)
+		if err == nil {
+			res.Method = ReseedMethodRewind
+			return clearReseedMarker()
+		}
+
+		log.Printf("[WARN] Failed to rewind standby, falling back to a clone: %s", err)
+	}
+
+	log.Printf("[WARN] Wiping %s and cloning %s\n", n.DataDir, primary.Hostname)
+
+	if err := cloneStandby(ctx, n, member, primary); err != nil {
+		return err
+	}
+
+	res.Method = ReseedMethodClone
+
+	return clearReseedMarker()
+}
+
+func rewindStandby(ctx context.Context, n *Node, member, primary *Member) error {
+	// The rejoin requires the local instance to be shut down. Repmgr will start it back up once
+	// the rewind completes, leaving it detached from the supervisor.
+	if err := stopPostgresIfRunning(ctx, n.DataDir); err != nil {
+		return err
+	}
+
+	if err := n.RepMgr.rejoinCluster(primary.Hostname); err != nil {
+		return fmt.Errorf("failed to rejoin cluster: %s", err)
+	}
+
+	return waitForStreaming(ctx, n, memberRecoveryTimeout)
+}
+
+func cloneStandby(ctx context.Context, n *Node, member, primary *Member) error {
+	if err := stopPostgresIfRunning(ctx, n.DataDir); err != nil {
+		return err
+	}
+
+	if err := dropLostSlot(ctx, n, member, primary); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(n.DataDir); err != nil {
+		return fmt.Errorf("failed to remove postgresql dir: %s", err)
+	}
+
+	store, err := n.StateStore()
+	if err != nil {
+		return fmt.Errorf("failed initialize cluster state store: %s", err)
+	}
+
+	if err := n.provisionStandby(ctx, store, primary.Hostname); err != nil {
+		// Clean-up the directory so the clone is retried on boot.
+		if rErr := os.RemoveAll(n.DataDir); rErr != nil {
+			log.Printf("[ERROR] failed to cleanup postgresql dir after clone error: %s\n", rErr)
+		}
+
+		return err
+	}
+
+	// The clone carries over the primary's configuration, so our own needs to be re-applied.
+	n.PGConfig.restoreFromArchive = restoredFromBackup()
+	if err := n.PGConfig.initialize(store); err != nil {
+		return fmt.Errorf("failed to initialize pg config: %s", err)
+	}
+
+	if err := setDirOwnership(ctx, n.DataDir); err != nil {
+		return err
+	}
+
+	if err := StartPostgres(ctx, n.DataDir); err != nil {
+		return err
+	}
+
+	if err := n.RepMgr.registerStandby(true); err != nil {
+		return fmt.Errorf("failed to register standby: %s", err)
+	}
+
+	// Standbys restored from a backup have to replay the archive before they can stream.
+	timeout := memberRecoveryTimeout
+	if restoredFromBackup() {
+		timeout = waitOnRecoveryTimeout
+	}
+
+	return waitForStreaming(ctx, n, timeout)
+}
+
+// dropLostSlot removes our replication slot from the primary when it can no longer be used, so
+// the clone is able to create a fresh one.
+func dropLostSlot(ctx context.Context, n *Node, member, primary *Member) error {
+	pConn, err := n.RepMgr.NewRemoteConnection(ctx, primary.Hostname)
+	if err != nil {
+		return fmt.Errorf("failed to connect to primary %s: %s", primary.Hostname, err)
+	}
+	defer func() { _ = pConn.Close(ctx) }()
+
+	name := replicationSlotName(member.ID)
+	slot, err := admin.GetReplicationSlot(ctx, pConn, name)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to query replication slot: %s", err)
+	case slot.Active || slot.WalStatus != walStatusLost:
+		return nil
+	}
+
+	log.Printf("[INFO] Dropping lost replication slot %s\n", name)
+	if err := admin.DropReplicationSlot(ctx, pConn, name); err != nil {
+		return fmt.Errorf("failed to drop replication slot %s: %s", name, err)
+	}
+
+	return nil
+}
+
+// waitForStreaming waits for the local WAL receiver to stream from its upstream. Cascaded standbys
+// stream from a regional relay rather than the primary, so the standby itself is checked.
+func waitForStreaming(ctx context.Context, n *Node, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(memberPollFrequency)
+	defer ticker.Stop()
+
+	for {
+		streaming, err := localWalReceiverStreaming(ctx, n)
+		if err != nil {
+			log.Printf("[WARN] Failed to resolve wal receiver status: %s", err)
+		}
+
+		if streaming {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("standby did not start streaming: %s", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func localWalReceiverStreaming(ctx context.Context, n *Node) (bool, error) {
+	conn, err := n.NewLocalConnection(ctx, "postgres", n.SUCredentials)
+	if err != nil {
+		return false, fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	status, err := admin.GetWalReceiverStatus(ctx, conn)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	return status.Status == "streaming", nil
+}
+
+// MarkReseedRequired flags the local standby as unable to follow its upstream.
+func MarkReseedRequired(reason string) error {
+	return os.WriteFile(reseedMarkerFile, []byte(reason), 0o600)
+}
+
+func reseedMarker() (string, bool) {
+	data, err := os.ReadFile(reseedMarkerFile)
+	if err != nil {
+		return "", false
+	}
+
+	return strings.TrimSpace(string(data)), true
+}
+
+func clearReseedMarker() error {
+	if err := os.Remove(reseedMarkerFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// acquireReseedLock guards against the monitor and an operator reseeding at the same time.
+// Locks left behind by a process that is no longer running are taken over.
+func acquireReseedLock() error {
+	if data, err := os.ReadFile(reseedLockFile); err == nil {
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil && processRunning(pid) {
+			return fmt.Errorf("%w (pid %d)", ErrReseedInProgress, pid)
+		}
+
+		if err := os.Remove(reseedLockFile); err != nil {
+			return fmt.Errorf("failed to remove stale reseed lock: %s", err)
+		}
+	}
+
+	file, err := os.OpenFile(reseedLockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		if os.IsExist(err) {
+			return ErrReseedInProgress
+		}
+		return fmt.Errorf("failed to create reseed lock: %s", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	if _, err := file.WriteString(strconv.Itoa(os.Getpid())); err != nil {
+		return fmt.Errorf("failed to write reseed lock: %s", err)
+	}
+
+	return nil
+}
+
+func releaseReseedLock() {
+	if err := os.Remove(reseedLockFile); err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] Failed to remove reseed lock: %s", err)
+	}
+}
+
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func recordReseed(r Reseed) {
+	if err := appendAuditEntry(reseedLogFile, r); err != nil {
+		log.Printf("[WARN] Failed to record reseed: %s", err)
+	}
+}
+
+// ReseedHistory returns the most recent reseeds, newest first. A limit of 0 returns every
+// retained reseed.
+func ReseedHistory(limit int) ([]Reseed, error) {
+	return auditHistory[Reseed](reseedLogFile, limit)
+}
@@ -0,0 +1,187 @@
+package flypg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/utils"
+)
+
+const (
+	reseedTestLogFile    = "./test_results/reseed.log"
+	reseedTestMarkerFile = "./test_results/.reseed_required"
+	reseedTestLockFile   = "./test_results/.reseed.lock"
+)
+
+func TestReseedMarker(t *testing.T) {
+	if err := setup(t); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	reseedMarkerFile = reseedTestMarkerFile
+	repmgrEventLogFile = repmgrEventTestLogFile
+
+	if _, ok := reseedMarker(); ok {
+		t.Fatal("expected no reseed marker")
+	}
+
+	t.Run("failedFollow", func(t *testing.T) {
+		ctx := context.Background()
+		event := RepmgrEvent{Name: EventStandbyFollow, NodeID: 2, Details: "unable to follow new primary"}
+
+		if err := ProcessRepmgrEvent(ctx, &Node{}, event); err == nil {
+			t.Fatal("expected failed events to return an error")
+		}
+
+		reason, ok := reseedMarker()
+		if !ok {
+			t.Fatal("expected a failed follow to flag the standby for reseed")
+		}
+
+		if reason == "" {
+			t.Fatal("expected the reseed reason to be recorded")
+		}
+
+		if err := clearReseedMarker(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("otherFailures", func(t *testing.T) {
+		ctx := context.Background()
+		event := RepmgrEvent{Name: EventRepmgrdPromoteError, NodeID: 2, Details: "promotion failed"}
+
+		if err := ProcessRepmgrEvent(ctx, &Node{}, event); err == nil {
+			t.Fatal("expected failed events to return an error")
+		}
+
+		if _, ok := reseedMarker(); ok {
+			t.Fatal("expected only follow failures to flag the standby for reseed")
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		if err := MarkReseedRequired("requested WAL segment has already been removed"); err != nil {
+			t.Fatal(err)
+		}
+
+		reason, ok := reseedMarker()
+		if !ok || reason != "requested WAL segment has already been removed" {
+			t.Fatalf("unexpected reseed marker %q", reason)
+		}
+
+		if err := clearReseedMarker(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Clearing a missing marker is a no-op.
+		if err := clearReseedMarker(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestReseedLock(t *testing.T) {
+	if err := setup(t); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	reseedLockFile = reseedTestLockFile
+
+	if err := acquireReseedLock(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("held", func(t *testing.T) {
+		if err := acquireReseedLock(); !errors.Is(err, ErrReseedInProgress) {
+			t.Fatalf("expected %s, got %v", ErrReseedInProgress, err)
+		}
+	})
+
+	t.Run("released", func(t *testing.T) {
+		releaseReseedLock()
+
+		if err := acquireReseedLock(); err != nil {
+			t.Fatal(err)
+		}
+		releaseReseedLock()
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		// A pid that can't be running.
+		if err := os.WriteFile(reseedLockFile, []byte(strconv.Itoa(-1)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := acquireReseedLock(); err != nil {
+			t.Fatalf("expected stale lock to be taken over, got %s", err)
+		}
+		releaseReseedLock()
+	})
+}
+
+func TestReseedHistory(t *testing.T) {
+	if err := setup(t); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	reseedLogFile = reseedTestLogFile
+
+	recordReseed(Reseed{Timestamp: time.Now(), Trigger: ReseedTriggerAutomatic, Methods: []string{ReseedMethodRewind, ReseedMethodClone}, Method: ReseedMethodRewind})
+	recordReseed(Reseed{Timestamp: time.Now(), Trigger: ReseedTriggerManual, Methods: []string{ReseedMethodClone}, Error: "failed to clone primary"})
+
+	reseeds, err := ReseedHistory(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reseeds) != 2 {
+		t.Fatalf("expected 2 reseeds, got %d", len(reseeds))
+	}
+
+	if reseeds[0].Trigger != ReseedTriggerManual || reseeds[0].Error == "" {
+		t.Fatalf("expected most recent reseed to be the failed manual reseed, got %+v", reseeds[0])
+	}
+
+	limited, err := ReseedHistory(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(limited) != 1 {
+		t.Fatalf("expected 1 reseed, got %d", len(limited))
+	}
+}
+
+func TestReseedStopFailure(t *testing.T) {
+	stubOwnership(t)
+
+	dataDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dataDir, "postmaster.pid"), []byte("12345\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	n := &Node{DataDir: dataDir}
+	ctx := context.Background()
+
+	// Tests don't run as the postgres user, so stopping the postmaster always fails.
+	if err := cloneStandby(ctx, n, &Member{}, &Member{}); err == nil {
+		t.Fatal("expected the clone to fail when postgres can't be stopped")
+	}
+
+	if err := rewindStandby(ctx, n, &Member{}, &Member{}); err == nil {
+		t.Fatal("expected the rewind to fail when postgres can't be stopped")
+	}
+
+	if !utils.FileExists(filepath.Join(dataDir, "postmaster.pid")) {
+		t.Fatal("expected the data directory to be left in place")
+	}
+}
@@ -0,0 +1,202 @@
+package flypg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	PasswordAuthMD5   = "md5"
+	PasswordAuthSCRAM = "scram-sha-256"
+
+	scramEnforcedKey = "scram_enforced"
+)
+
+var (
+	// ErrScramMigrationNotPrimary - Password hashes can only be rewritten on the primary.
+	ErrScramMigrationNotPrimary = errors.New("scram migration must be run against the primary")
+	// ErrMD5RolesRemaining - Enforcing scram-sha-256 would lock out roles with md5 hashes.
+	ErrMD5RolesRemaining = errors.New("roles with md5 password hashes remain")
+)
+
+// ScramStatus reports how far the cluster has progressed in its move to scram-sha-256.
+type ScramStatus struct {
+	// Enforced reports whether pg_hba.conf entries require scram-sha-256.
+	Enforced           bool     `json:"enforced"`
+	PasswordEncryption string   `json:"password_encryption"`
+	MD5Roles           []string `json:"md5_roles"`
+}
+
+// ScramMigration is the outcome of a scram-sha-256 migration.
+type ScramMigration struct {
+	Rehashed []string `json:"rehashed"`
+	ScramStatus
+	Members []HBAReloadResult `json:"members,omitempty"`
+}
+
+// passwordAuthMethod returns the method used by password-based pg_hba.conf entries. md5 entries
+// still negotiate scram-sha-256 for roles with scram hashes, so it is the safe fallback.
+func (c *PGConfig) passwordAuthMethod() string {
+	if c.authMethod == "" {
+		return PasswordAuthMD5
+	}
+
+	return c.authMethod
+}
+
+// resolvePasswordAuthMethod returns scram-sha-256 once it has been enforced.
+func resolvePasswordAuthMethod(store state.StateStore) (string, error) {
+	enforced, err := ScramEnforced(store)
+	if err != nil {
+		return "", err
+	}
+
+	if enforced {
+		return PasswordAuthSCRAM, nil
+	}
+
+	return PasswordAuthMD5, nil
+}
+
+// enforceScramOnInit enforces scram-sha-256 while registering a new cluster, unless roles
+// carried over by a restore still have md5 hashes.
+func (n *Node) enforceScramOnInit(ctx context.Context, conn *pgx.Conn, store state.StateStore) error {
+	roles, err := admin.MD5PasswordRoles(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to list md5 roles: %s", err)
+	}
+
+	if len(roles) > 0 {
+		log.Printf("[WARN] Roles %v have md5 password hashes, scram-sha-256 will not be enforced", roles)
+		return nil
+	}
+
+	return setScramEnforced(store)
+}
+
+// ScramEnforced reports whether pg_hba.conf entries require scram-sha-256.
+func ScramEnforced(store state.StateStore) (bool, error) {
+	data, err := store.PullUserConfig(scramEnforcedKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to pull scram state: %s", err)
+	}
+
+	return string(data) == "true", nil
+}
+
+func setScramEnforced(store state.StateStore) error {
+	if err := store.PushUserConfig(scramEnforcedKey, []byte("true")); err != nil {
+		return fmt.Errorf("failed to record scram state: %s", err)
+	}
+
+	return nil
+}
+
+// GetScramStatus reports whether scram-sha-256 is enforced and which roles still have md5 hashes.
+func GetScramStatus(ctx context.Context, n *Node) (*ScramStatus, error) {
+	store, err := n.StateStore()
+	if err != nil {
+		return nil, err
+	}
+
+	enforced, err := ScramEnforced(store)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := n.NewLocalConnection(ctx, "postgres", n.SUCredentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	encryption, err := admin.GetSetting(ctx, conn, "password_encryption")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve password_encryption: %s", err)
+	}
+
+	roles, err := admin.MD5PasswordRoles(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list md5 roles: %s", err)
+	}
+
+	return &ScramStatus{
+		Enforced:           enforced,
+		PasswordEncryption: encryption.Setting,
+		MD5Roles:           roles,
+	}, nil
+}
+
+// MigrateToScram re-hashes the internal credentials with scram-sha-256. When enforce is set and
+// no md5 hashes remain, pg_hba.conf entries are switched over to scram-sha-256 across the cluster.
+// This must be run on the primary.
+func MigrateToScram(ctx context.Context, n *Node, enforce bool) (*ScramMigration, error) {
+	repConn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local connection: %s", err)
+	}
+	defer func() { _ = repConn.Close(ctx) }()
+
+	primary, err := n.RepMgr.IsPrimary(ctx, repConn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve primary status: %s", err)
+	}
+
+	if !primary {
+		return nil, ErrScramMigrationNotPrimary
+	}
+
+	conn, err := n.NewLocalConnection(ctx, "postgres", n.SUCredentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	if err := n.setupCredentials(ctx, conn); err != nil {
+		return nil, fmt.Errorf("failed to re-hash internal credentials: %s", err)
+	}
+
+	migration := &ScramMigration{
+		Rehashed: []string{n.OperatorCredentials.Username, n.ReplCredentials.Username, n.SUCredentials.Username},
+	}
+
+	status, err := GetScramStatus(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	migration.ScramStatus = *status
+
+	if !enforce || status.Enforced {
+		return migration, nil
+	}
+
+	if len(status.MD5Roles) > 0 {
+		return nil, fmt.Errorf("%w: %v, reset their passwords before enforcing scram-sha-256", ErrMD5RolesRemaining, status.MD5Roles)
+	}
+
+	store, err := n.StateStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setScramEnforced(store); err != nil {
+		return nil, err
+	}
+	migration.Enforced = true
+
+	log.Println("[INFO] Enforcing scram-sha-256 authentication across the cluster")
+
+	members, err := BroadcastHBAReload(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	migration.Members = members
+
+	return migration, nil
+}
@@ -0,0 +1,46 @@
+package flypg
+
+import (
+	"testing"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+func TestResolvePasswordAuthMethod(t *testing.T) {
+	store := state.NewMemoryStore()
+
+	t.Run("default", func(t *testing.T) {
+		method, err := resolvePasswordAuthMethod(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if method != PasswordAuthMD5 {
+			t.Fatalf("expected %s, got %s", PasswordAuthMD5, method)
+		}
+	})
+
+	t.Run("enforced", func(t *testing.T) {
+		if err := setScramEnforced(store); err != nil {
+			t.Fatal(err)
+		}
+
+		enforced, err := ScramEnforced(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !enforced {
+			t.Fatal("expected scram to be enforced")
+		}
+
+		method, err := resolvePasswordAuthMethod(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if method != PasswordAuthSCRAM {
+			t.Fatalf("expected %s, got %s", PasswordAuthSCRAM, method)
+		}
+	})
+}
@@ -12,6 +12,9 @@ const (
 	sshConfigFile  = "/data/.ssh/config"
 )
 
+// writeSSHKey is swapped out within tests, as the keys are copied over to the root user.
+var writeSSHKey = WriteSSHKey
+
 func WriteSSHKey() error {
 	err := os.Mkdir("/data/.ssh", 0o700)
 	if err != nil && !os.IsExist(err) {
@@ -0,0 +1,115 @@
+package flypg
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+const (
+	// StandbyCloneSourcePrimary clones new standbys directly from the primary.
+	StandbyCloneSourcePrimary = "primary"
+	// StandbyCloneSourceBackup restores new standbys from the latest base backup in object storage,
+	// catching up from the WAL archive before streaming from the primary.
+	StandbyCloneSourceBackup = "backup"
+)
+
+// restoredFromBackupMarker is written once a standby's data directory has been seeded from a base
+// backup, so the restore_command is only configured on members that need it. It lives outside of
+// the data directory, so it isn't carried over when the member is cloned.
+var restoredFromBackupMarker = "/data/.restored_from_backup"
+
+// provisionStandby seeds the data directory of a new standby. When configured, the latest base
+// backup is restored from object storage, falling back to cloning the primary.
+func (n *Node) provisionStandby(ctx context.Context, store state.StateStore, primary string) error {
+	// The data directory is about to be replaced, so a previous restore no longer applies.
+	if err := os.Remove(restoredFromBackupMarker); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove backup restore marker: %s", err)
+	}
+
+	if n.cloneFromBackup() {
+		err := n.restoreStandbyFromBackup(ctx, store, primary)
+		if err == nil {
+			standbyClones.Inc(StandbyCloneSourceBackup)
+
+			if err := os.WriteFile(restoredFromBackupMarker, nil, 0o600); err != nil {
+				return fmt.Errorf("failed to write backup restore marker: %s", err)
+			}
+
+			return nil
+		}
+
+		log.Printf("[WARN] Failed to restore standby from backup, falling back to cloning %s: %s", primary, err)
+
+		// Clean-up whatever was restored so the primary can be cloned into an empty directory.
+		if rErr := os.RemoveAll(n.DataDir); rErr != nil {
+			return fmt.Errorf("failed to cleanup postgresql dir after restore error: %s", rErr)
+		}
+	}
+
+	if err := n.RepMgr.clonePrimary(primary); err != nil {
+		// Clean-up the directory so it can be retried.
+		if rErr := os.Remove(n.DataDir); rErr != nil {
+			log.Printf("[ERROR] failed to cleanup postgresql dir after clone error: %s\n", rErr)
+		}
+
+		return fmt.Errorf("failed to clone primary: %s", err)
+	}
+
+	standbyClones.Inc(StandbyCloneSourcePrimary)
+
+	return nil
+}
+
+// restoredFromBackup reports whether the local data directory was seeded from a base backup.
+func restoredFromBackup() bool {
+	_, err := os.Stat(restoredFromBackupMarker)
+	return err == nil
+}
+
+func (n *Node) cloneFromBackup() bool {
+	if os.Getenv("S3_ARCHIVE_CONFIG") == "" {
+		return false
+	}
+
+	return n.FlyConfig.StringSetting("standbyCloneSource", StandbyCloneSourcePrimary) == StandbyCloneSourceBackup
+}
+
+// restoreStandbyFromBackup restores the latest completed base backup and configures the standby to
+// stream from the primary. The WAL generated since the backup is replayed from the archive via
+// the restore_command before streaming takes over.
+func (n *Node) restoreStandbyFromBackup(ctx context.Context, store state.StateStore, primary string) error {
+	barman, err := NewBarman(store, os.Getenv("S3_ARCHIVE_CONFIG"), DefaultAuthProfile)
+	if err != nil {
+		return fmt.Errorf("failed to initialize barman: %s", err)
+	}
+
+	backups, err := barman.ListCompletedBackups(ctx)
+	if err != nil {
+		return err
+	}
+
+	backup, err := latestCompletedBackup(backups)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Restoring standby from base backup %s completed at %s\n", backup.ID, backup.EndTime)
+
+	start := time.Now()
+	if _, err := barman.RestoreBackup(ctx, backup.ID); err != nil {
+		return fmt.Errorf("failed to restore backup %s: %s", backup.ID, err)
+	}
+
+	log.Printf("[INFO] Base backup %s restored in %s\n", backup.ID, time.Since(start).Round(time.Second))
+
+	if err := n.RepMgr.writeReplicationConf(ctx, primary); err != nil {
+		return fmt.Errorf("failed to configure replication from %s: %s", primary, err)
+	}
+
+	return nil
+}
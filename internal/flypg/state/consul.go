@@ -0,0 +1,144 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ConsulStore persists cluster state within Consul's KV store.
+type ConsulStore struct {
+	Client *api.Client
+	prefix string
+}
+
+// type assertion
+var _ StateStore = &ConsulStore{}
+
+func NewConsulStore() (*ConsulStore, error) {
+	conf, err := clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := api.NewClient(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := pathPrefix()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConsulStore{
+		Client: client,
+		prefix: prefix,
+	}, nil
+}
+
+func (c *ConsulStore) SetInitializationFlag() error {
+	kv := &api.KVPair{Key: c.targetKey(initializationFlagKey), Value: []byte("true")}
+	_, err := c.Client.KV().Put(kv, nil)
+	return err
+}
+
+func (c *ConsulStore) IsInitializationFlagSet() (bool, error) {
+	result, _, err := c.Client.KV().Get(c.targetKey(initializationFlagKey), nil)
+	if err != nil {
+		return false, err
+	}
+
+	if result == nil {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (c *ConsulStore) PushUserConfig(key string, config []byte) error {
+	kv := &api.KVPair{Key: c.targetKey(key), Value: config}
+	_, err := c.Client.KV().Put(kv, nil)
+	return err
+}
+
+func (c *ConsulStore) PullUserConfig(key string) ([]byte, error) {
+	pair, _, err := c.Client.KV().Get(c.targetKey(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if pair == nil {
+		return nil, nil
+	}
+
+	return pair.Value, nil
+}
+
+func (c *ConsulStore) CompareAndSwap(key string, expected, value []byte) (bool, error) {
+	pair, _, err := c.Client.KV().Get(c.targetKey(key), nil)
+	if err != nil {
+		return false, err
+	}
+
+	// A ModifyIndex of 0 instructs Consul to only write the key if it doesn't exist.
+	var modifyIndex uint64
+	switch {
+	case pair == nil && expected != nil:
+		return false, nil
+	case pair != nil:
+		if expected == nil || !bytes.Equal(pair.Value, expected) {
+			return false, nil
+		}
+		modifyIndex = pair.ModifyIndex
+	}
+
+	kv := &api.KVPair{Key: c.targetKey(key), Value: value, ModifyIndex: modifyIndex}
+	swapped, _, err := c.Client.KV().CAS(kv, nil)
+	return swapped, err
+}
+
+func (c *ConsulStore) targetKey(key string) string {
+	return c.prefix + key
+}
+
+func clientConfig() (*api.Config, error) {
+	u, err := url.Parse(resolveEndpoint())
+	if err != nil {
+		panic(err)
+	}
+
+	token, set := u.User.Password()
+	if !set {
+		return nil, fmt.Errorf("consul token not set")
+	}
+
+	u.User = nil
+
+	return &api.Config{
+		Token:   token,
+		Scheme:  u.Scheme,
+		Address: u.Host,
+	}, nil
+}
+
+func pathPrefix() (string, error) {
+	u, err := url.Parse(resolveEndpoint())
+	if err != nil {
+		return "", err
+	}
+
+	return u.Path[1:], nil
+}
+
+func resolveEndpoint() string {
+	consulURL := os.Getenv("CONSUL_URL")
+	if consulURL == "" {
+		consulURL = os.Getenv("FLY_CONSUL_URL")
+	}
+
+	return consulURL
+}
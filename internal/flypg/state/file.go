@@ -0,0 +1,139 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const fileStoreLockName = ".lock"
+
+// FileStore persists cluster state as individual files within a local directory.
+// The directory is only visible to members that share it, so this backend is intended
+// for single member clusters or environments where Consul is not available.
+type FileStore struct {
+	dir string
+}
+
+// type assertion
+var _ StateStore = &FileStore{}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory %s: %s", dir, err)
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+func (f *FileStore) SetInitializationFlag() error {
+	return f.PushUserConfig(initializationFlagKey, []byte("true"))
+}
+
+func (f *FileStore) IsInitializationFlagSet() (bool, error) {
+	val, err := f.PullUserConfig(initializationFlagKey)
+	if err != nil {
+		return false, err
+	}
+
+	return val != nil, nil
+}
+
+func (f *FileStore) PushUserConfig(key string, config []byte) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return f.write(key, config)
+}
+
+func (f *FileStore) PullUserConfig(key string) ([]byte, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return val, nil
+}
+
+func (f *FileStore) CompareAndSwap(key string, expected, value []byte) (bool, error) {
+	unlock, err := f.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	current, err := f.PullUserConfig(key)
+	if err != nil {
+		return false, err
+	}
+
+	if (current == nil) != (expected == nil) || !bytes.Equal(current, expected) {
+		return false, nil
+	}
+
+	if err := f.write(key, value); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// write atomically replaces the file backing the specified key.
+func (f *FileStore) write(key string, value []byte) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory for key %s: %s", key, err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, value, 0o600); err != nil {
+		return fmt.Errorf("failed to write key %s: %s", key, err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// lock acquires an exclusive lock that is shared across every process using the store.
+func (f *FileStore) lock() (func(), error) {
+	file, err := os.OpenFile(filepath.Join(f.dir, fileStoreLockName), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state lock: %s", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to acquire state lock: %s", err)
+	}
+
+	return func() {
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		_ = file.Close()
+	}, nil
+}
+
+func (f *FileStore) path(key string) (string, error) {
+	path := filepath.Join(f.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(f.dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+
+	return path, nil
+}
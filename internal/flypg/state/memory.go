@@ -0,0 +1,67 @@
+package state
+
+import (
+	"bytes"
+	"sync"
+)
+
+// MemoryStore keeps cluster state in memory. It is primarily intended for testing.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+// type assertion
+var _ StateStore = &MemoryStore{}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: map[string][]byte{}}
+}
+
+func (m *MemoryStore) SetInitializationFlag() error {
+	return m.PushUserConfig(initializationFlagKey, []byte("true"))
+}
+
+func (m *MemoryStore) IsInitializationFlagSet() (bool, error) {
+	val, err := m.PullUserConfig(initializationFlagKey)
+	if err != nil {
+		return false, err
+	}
+
+	return val != nil, nil
+}
+
+func (m *MemoryStore) PushUserConfig(key string, config []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.data[key] = bytes.Clone(config)
+
+	return nil
+}
+
+func (m *MemoryStore) PullUserConfig(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	val, ok := m.data[key]
+	if !ok {
+		return nil, nil
+	}
+
+	return bytes.Clone(val), nil
+}
+
+func (m *MemoryStore) CompareAndSwap(key string, expected, value []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.data[key]
+	if ok != (expected != nil) || !bytes.Equal(current, expected) {
+		return false, nil
+	}
+
+	m.data[key] = bytes.Clone(value)
+
+	return true, nil
+}
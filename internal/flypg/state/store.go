@@ -3,115 +3,50 @@ package state
 
 import (
 	"fmt"
-	"net/url"
 	"os"
-
-	"github.com/hashicorp/consul/api"
 )
 
-type Store struct {
-	Client *api.Client
-	prefix string
-}
-
-func NewStore() (*Store, error) {
-	conf, err := clientConfig()
-	if err != nil {
-		return nil, err
-	}
-
-	client, err := api.NewClient(conf)
-	if err != nil {
-		return nil, err
-	}
-
-	prefix, err := pathPrefix()
-	if err != nil {
-		return nil, err
-	}
-
-	return &Store{
-		Client: client,
-		prefix: prefix,
-	}, nil
-}
-
-func (c *Store) SetInitializationFlag() error {
-	kv := &api.KVPair{Key: c.targetKey("INITIALIZED"), Value: []byte("true")}
-	_, err := c.Client.KV().Put(kv, nil)
-	return err
-}
-
-func (c *Store) IsInitializationFlagSet() (bool, error) {
-	result, _, err := c.Client.KV().Get(c.targetKey("INITIALIZED"), nil)
-	if err != nil {
-		return false, err
-	}
-
-	if result == nil {
-		return false, nil
-	}
+const (
+	// BackendConsul stores cluster state within Consul's KV store.
+	BackendConsul = "consul"
+	// BackendFile stores cluster state on the local filesystem.
+	BackendFile = "file"
 
-	return true, nil
-}
+	initializationFlagKey = "INITIALIZED"
 
-func (c *Store) PushUserConfig(key string, config []byte) error {
-	kv := &api.KVPair{Key: c.targetKey(key), Value: config}
-	_, err := c.Client.KV().Put(kv, nil)
-	return err
-}
-
-func (c *Store) PullUserConfig(key string) ([]byte, error) {
-	pair, _, err := c.Client.KV().Get(c.targetKey(key), nil)
-	if err != nil {
-		return nil, err
-	}
-
-	if pair == nil {
-		return nil, nil
-	}
-
-	return pair.Value, nil
-}
-
-func (c *Store) targetKey(key string) string {
-	return c.prefix + key
-}
-
-func clientConfig() (*api.Config, error) {
-	u, err := url.Parse(resolveEndpoint())
-	if err != nil {
-		panic(err)
-	}
-
-	token, set := u.User.Password()
-	if !set {
-		return nil, fmt.Errorf("consul token not set")
-	}
-
-	u.User = nil
-
-	return &api.Config{
-		Token:   token,
-		Scheme:  u.Scheme,
-		Address: u.Host,
-	}, nil
-}
-
-func pathPrefix() (string, error) {
-	u, err := url.Parse(resolveEndpoint())
-	if err != nil {
-		return "", err
-	}
-
-	return u.Path[1:], nil
-}
+	defaultFileStoreDir = "/data/state"
+)
 
-func resolveEndpoint() string {
-	consulURL := os.Getenv("CONSUL_URL")
-	if consulURL == "" {
-		consulURL = os.Getenv("FLY_CONSUL_URL")
+// StateStore is the persistent store used to share cluster state across members.
+type StateStore interface {
+	// SetInitializationFlag records that the cluster primary has been registered.
+	SetInitializationFlag() error
+	// IsInitializationFlagSet reports whether the cluster has already been initialized.
+	IsInitializationFlagSet() (bool, error)
+	// PushUserConfig stores the user config under the specified key.
+	PushUserConfig(key string, config []byte) error
+	// PullUserConfig returns the user config stored under the specified key. A nil
+	// slice is returned if the key does not exist.
+	PullUserConfig(key string) ([]byte, error)
+	// CompareAndSwap sets key to value only if the current value matches expected.
+	// A nil expected value requires that the key does not exist yet.
+	CompareAndSwap(key string, expected, value []byte) (bool, error)
+}
+
+// NewStore returns the state store backend selected through the `STATE_STORE_BACKEND`
+// environment variable. Consul is used when no backend has been specified.
+func NewStore() (StateStore, error) {
+	switch backend := os.Getenv("STATE_STORE_BACKEND"); backend {
+	case "", BackendConsul:
+		return NewConsulStore()
+	case BackendFile:
+		dir := os.Getenv("STATE_STORE_PATH")
+		if dir == "" {
+			dir = defaultFileStoreDir
+		}
+
+		return NewFileStore(dir)
+	default:
+		return nil, fmt.Errorf("unsupported state store backend %q", backend)
 	}
-
-	return consulURL
 }
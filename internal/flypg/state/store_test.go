@@ -0,0 +1,146 @@
+package state
+
+import (
+	"testing"
+)
+
+func TestStateStoreBackends(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backends := map[string]StateStore{
+		"memory": NewMemoryStore(),
+		"file":   fileStore,
+	}
+
+	for name, store := range backends {
+		t.Run(name, func(t *testing.T) {
+			t.Run("InitializationFlag", func(t *testing.T) {
+				set, err := store.IsInitializationFlagSet()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if set {
+					t.Fatal("expected initialization flag to be unset")
+				}
+
+				if err := store.SetInitializationFlag(); err != nil {
+					t.Fatal(err)
+				}
+
+				set, err = store.IsInitializationFlagSet()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !set {
+					t.Fatal("expected initialization flag to be set")
+				}
+			})
+
+			t.Run("UserConfig", func(t *testing.T) {
+				val, err := store.PullUserConfig("PGConfig")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if val != nil {
+					t.Fatalf("expected missing key to return nil, got %q", val)
+				}
+
+				if err := store.PushUserConfig("PGConfig", []byte(`{"max_connections":"100"}`)); err != nil {
+					t.Fatal(err)
+				}
+
+				val, err = store.PullUserConfig("PGConfig")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(val) != `{"max_connections":"100"}` {
+					t.Fatalf("unexpected value %q", val)
+				}
+			})
+
+			t.Run("CompareAndSwap", func(t *testing.T) {
+				swapped, err := store.CompareAndSwap("lock", nil, []byte("a"))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !swapped {
+					t.Fatal("expected swap to succeed when key does not exist")
+				}
+
+				swapped, err = store.CompareAndSwap("lock", nil, []byte("b"))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if swapped {
+					t.Fatal("expected swap to fail when key already exists")
+				}
+
+				swapped, err = store.CompareAndSwap("lock", []byte("z"), []byte("b"))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if swapped {
+					t.Fatal("expected swap to fail on value mismatch")
+				}
+
+				swapped, err = store.CompareAndSwap("lock", []byte("a"), []byte("b"))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !swapped {
+					t.Fatal("expected swap to succeed on value match")
+				}
+
+				val, err := store.PullUserConfig("lock")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(val) != "b" {
+					t.Fatalf("expected b, got %q", val)
+				}
+			})
+		})
+	}
+}
+
+func TestFileStoreInvalidKey(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.PushUserConfig("../escape", []byte("x")); err == nil {
+		t.Fatal("expected keys outside of the state directory to be rejected")
+	}
+}
+
+func TestNewStoreBackend(t *testing.T) {
+	t.Setenv("STATE_STORE_BACKEND", BackendFile)
+	t.Setenv("STATE_STORE_PATH", t.TempDir())
+
+	store, err := NewStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := store.(*FileStore); !ok {
+		t.Fatalf("expected file store, got %T", store)
+	}
+
+	t.Setenv("STATE_STORE_BACKEND", "etcd")
+	if _, err := NewStore(); err == nil {
+		t.Fatal("expected unsupported backend to error")
+	}
+}
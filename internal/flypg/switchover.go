@@ -0,0 +1,202 @@
+package flypg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	SwitchoverEndpoint = "commands/admin/switchover"
+
+	// DefaultSwitchoverMaxLag is the maximum replay lag a candidate may have before
+	// a switchover will be refused.
+	DefaultSwitchoverMaxLag int64 = 16 * 1024 * 1024
+)
+
+// ErrInvalidSwitchoverCandidate - The requested member is not eligible for promotion.
+var ErrInvalidSwitchoverCandidate = errors.New("invalid switchover candidate")
+
+// SwitchoverResult describes the outcome of a planned switchover.
+type SwitchoverResult struct {
+	OldPrimary string `json:"old_primary"`
+	NewPrimary string `json:"new_primary"`
+	LagBytes   int64  `json:"lag_bytes"`
+	Duration   string `json:"duration"`
+}
+
+// PlannedSwitchover promotes the specified standby and demotes the local primary. The candidate
+// must reside within the primary region and be within maxLag bytes of the primary.
+func PlannedSwitchover(ctx context.Context, n *Node, candidateName string, maxLag int64) (*SwitchoverResult, error) {
+	start := time.Now()
+
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	primary, err := n.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve member: %s", err)
+	}
+
+	if primary.Role != PrimaryRoleName {
+		return nil, fmt.Errorf("switchover must be coordinated by the primary")
+	}
+
+	members, err := n.RepMgr.Members(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members: %s", err)
+	}
+
+	candidate, err := resolveSwitchoverCandidate(n, members, candidateName)
+	if err != nil {
+		return nil, err
+	}
+
+	lag, err := admin.StandbyReplayLag(ctx, conn, candidate.Name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: member %s is not streaming from the primary", ErrInvalidSwitchoverCandidate, candidateName)
+		}
+		return nil, fmt.Errorf("failed to resolve replication lag: %s", err)
+	}
+
+	if lag > maxLag {
+		return nil, fmt.Errorf("%w: member %s is %d bytes behind the primary (max %d)", ErrInvalidSwitchoverCandidate, candidateName, lag, maxLag)
+	}
+
+	if err := conn.Close(ctx); err != nil {
+		return nil, fmt.Errorf("failed to close connection: %s", err)
+	}
+
+	log.Printf("Performing switchover to %s (lag: %d bytes)", candidate.Hostname, lag)
+
+	if err := switchover(ctx, n, *candidate, members); err != nil {
+		return nil, err
+	}
+
+	// Ensure the old primary is streaming from the new primary before reporting success.
+	if err := waitForStandbyCatchUp(ctx, n, candidate.Hostname, *primary); err != nil {
+		return nil, fmt.Errorf("old primary failed to follow %s: %s", candidate.Hostname, err)
+	}
+
+	return &SwitchoverResult{
+		OldPrimary: primary.Hostname,
+		NewPrimary: candidate.Hostname,
+		LagBytes:   lag,
+		Duration:   time.Since(start).Round(time.Second).String(),
+	}, nil
+}
+
+// findSwitchoverCandidate resolves the candidate from the registered members. Members are
+// registered using their machine id.
+func findSwitchoverCandidate(members []Member, name string) (*Member, error) {
+	for _, member := range members {
+		if member.Name == name {
+			return &member, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: member %q is not registered", ErrInvalidSwitchoverCandidate, name)
+}
+
+// resolveSwitchoverCandidate resolves the candidate from the registered members and verifies
+// it is eligible for promotion. Delayed replicas are resolved through the state store, as
+// their apply delay isn't visible from the primary.
+func resolveSwitchoverCandidate(n *Node, members []Member, name string) (*Member, error) {
+	candidate, err := findSwitchoverCandidate(members, name)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := n.StateStore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize state store: %s", err)
+	}
+
+	delayed, err := DelayedMembers(store)
+	if err != nil {
+		return nil, err
+	}
+
+	_, isDelayed := delayed[candidate.Name]
+
+	if err := validateSwitchoverCandidate(n, candidate, isDelayed); err != nil {
+		return nil, err
+	}
+
+	return candidate, nil
+}
+
+// PromoteSwitchoverCandidate promotes the local standby as part of a switchover coordinated by
+// the primary. The standby is held to the same rules as within PlannedSwitchover.
+func PromoteSwitchoverCandidate(ctx context.Context, n *Node) error {
+	if n.RepMgr.Delayed {
+		return fmt.Errorf("%w: member %s is a delayed replica", ErrInvalidSwitchoverCandidate, n.RepMgr.nodeName())
+	}
+
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	member, err := n.RepMgr.Member(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to resolve member: %s", err)
+	}
+
+	if err := validateSwitchoverCandidate(n, member, n.RepMgr.Delayed); err != nil {
+		return err
+	}
+
+	if err := conn.Close(ctx); err != nil {
+		return fmt.Errorf("failed to close connection: %s", err)
+	}
+
+	return n.RepMgr.StandbySwitchover(ctx)
+}
+
+func validateSwitchoverCandidate(n *Node, candidate *Member, delayed bool) error {
+	switch {
+	case candidate.Role != StandbyRoleName:
+		return fmt.Errorf("%w: member %s is not a standby", ErrInvalidSwitchoverCandidate, candidate.Name)
+	case !candidate.Active:
+		return fmt.Errorf("%w: member %s is not active", ErrInvalidSwitchoverCandidate, candidate.Name)
+	case candidate.Region != n.PrimaryRegion:
+		return fmt.Errorf("%w: member %s resides in %s, outside of the primary region %s",
+			ErrInvalidSwitchoverCandidate, candidate.Name, candidate.Region, n.PrimaryRegion)
+	case delayed:
+		return fmt.Errorf("%w: member %s is a delayed replica", ErrInvalidSwitchoverCandidate, candidate.Name)
+	}
+
+	return nil
+}
+
+// switchover instructs the candidate to promote itself, waits for the local primary to be
+// demoted and then restarts haproxy across the cluster so traffic is routed to the new primary.
+func switchover(ctx context.Context, n *Node, candidate Member, members []Member) error {
+	if err := requestMember(ctx, http.MethodPost, candidate.Hostname, StandbySwitchoverEndpoint); err != nil {
+		return fmt.Errorf("failed to promote %s: %s", candidate.Hostname, err)
+	}
+
+	if err := waitForLocalRole(ctx, n, StandbyRoleName); err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		if err := requestMember(ctx, http.MethodGet, member.Hostname, RestartHaproxyEndpoint); err != nil {
+			log.Printf("[WARN] Failed to restart haproxy on member %s: %s", member.Hostname, err)
+		}
+	}
+
+	return nil
+}
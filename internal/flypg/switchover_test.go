@@ -0,0 +1,128 @@
+package flypg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+func TestValidateSwitchoverCandidate(t *testing.T) {
+	node := &Node{PrimaryRegion: "ord"}
+
+	t.Run("EligibleStandby", func(t *testing.T) {
+		candidate := &Member{Name: "6e8226ec711087", Role: StandbyRoleName, Active: true, Region: "ord"}
+
+		if err := validateSwitchoverCandidate(node, candidate, false); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Primary", func(t *testing.T) {
+		candidate := &Member{Name: "6e8226ec711087", Role: PrimaryRoleName, Active: true, Region: "ord"}
+
+		if err := validateSwitchoverCandidate(node, candidate, false); !errors.Is(err, ErrInvalidSwitchoverCandidate) {
+			t.Fatalf("expected ErrInvalidSwitchoverCandidate, got %v", err)
+		}
+	})
+
+	t.Run("Witness", func(t *testing.T) {
+		candidate := &Member{Name: "6e8226ec711087", Role: WitnessRoleName, Active: true, Region: "ord"}
+
+		if err := validateSwitchoverCandidate(node, candidate, false); !errors.Is(err, ErrInvalidSwitchoverCandidate) {
+			t.Fatalf("expected ErrInvalidSwitchoverCandidate, got %v", err)
+		}
+	})
+
+	t.Run("InactiveStandby", func(t *testing.T) {
+		candidate := &Member{Name: "6e8226ec711087", Role: StandbyRoleName, Active: false, Region: "ord"}
+
+		if err := validateSwitchoverCandidate(node, candidate, false); !errors.Is(err, ErrInvalidSwitchoverCandidate) {
+			t.Fatalf("expected ErrInvalidSwitchoverCandidate, got %v", err)
+		}
+	})
+
+	t.Run("OutOfRegionStandby", func(t *testing.T) {
+		candidate := &Member{Name: "6e8226ec711087", Role: StandbyRoleName, Active: true, Region: "lax"}
+
+		if err := validateSwitchoverCandidate(node, candidate, false); !errors.Is(err, ErrInvalidSwitchoverCandidate) {
+			t.Fatalf("expected ErrInvalidSwitchoverCandidate, got %v", err)
+		}
+	})
+
+	t.Run("DelayedStandby", func(t *testing.T) {
+		candidate := &Member{Name: "6e8226ec711087", Role: StandbyRoleName, Active: true, Region: "ord"}
+
+		if err := validateSwitchoverCandidate(node, candidate, true); !errors.Is(err, ErrInvalidSwitchoverCandidate) {
+			t.Fatalf("expected ErrInvalidSwitchoverCandidate, got %v", err)
+		}
+	})
+}
+
+func TestResolveSwitchoverCandidate(t *testing.T) {
+	store := state.NewMemoryStore()
+	if err := setDelayedMember(store, "3d8d9925f15e89", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	node := &Node{PrimaryRegion: "ord"}
+	node.SetStateStore(store)
+
+	members := []Member{
+		{Name: "6e8226ec711087", Role: PrimaryRoleName, Active: true, Region: "ord"},
+		{Name: "3d8d9925f15e89", Role: StandbyRoleName, Active: true, Region: "ord"},
+		{Name: "148ed193b95d89", Role: StandbyRoleName, Active: true, Region: "ord"},
+	}
+
+	t.Run("EligibleStandby", func(t *testing.T) {
+		candidate, err := resolveSwitchoverCandidate(node, members, "148ed193b95d89")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if candidate.Name != "148ed193b95d89" {
+			t.Fatalf("expected 148ed193b95d89 to be resolved, got %s", candidate.Name)
+		}
+	})
+
+	t.Run("DelayedStandby", func(t *testing.T) {
+		if _, err := resolveSwitchoverCandidate(node, members, "3d8d9925f15e89"); !errors.Is(err, ErrInvalidSwitchoverCandidate) {
+			t.Fatalf("expected ErrInvalidSwitchoverCandidate, got %v", err)
+		}
+	})
+}
+
+func TestPromoteSwitchoverCandidateDelayed(t *testing.T) {
+	node := newTestNode(t)
+	node.RepMgr.Delayed = true
+
+	if err := PromoteSwitchoverCandidate(context.Background(), node); !errors.Is(err, ErrInvalidSwitchoverCandidate) {
+		t.Fatalf("expected ErrInvalidSwitchoverCandidate, got %v", err)
+	}
+}
+
+func TestFindSwitchoverCandidate(t *testing.T) {
+	members := []Member{
+		{Name: "6e8226ec711087", Role: PrimaryRoleName},
+		{Name: "3d8d9925f15e89", Role: StandbyRoleName},
+	}
+
+	t.Run("Registered", func(t *testing.T) {
+		candidate, err := findSwitchoverCandidate(members, "3d8d9925f15e89")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if candidate.Role != StandbyRoleName {
+			t.Fatalf("expected the standby to be resolved, got %+v", candidate)
+		}
+	})
+
+	t.Run("Unregistered", func(t *testing.T) {
+		if _, err := findSwitchoverCandidate(members, "148ed193b95d89"); !errors.Is(err, ErrInvalidSwitchoverCandidate) {
+			t.Fatalf("expected ErrInvalidSwitchoverCandidate, got %v", err)
+		}
+	})
+}
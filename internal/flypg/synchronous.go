@@ -0,0 +1,148 @@
+package flypg
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+)
+
+const synchronousStandbyNamesSetting = "synchronous_standby_names"
+
+// SynchronousStandbyNames builds the synchronous_standby_names value requiring confirmation
+// from the requested number of standbys. When fewer healthy standbys are available, the quorum
+// is lowered to match so writes don't hang. An empty value disables synchronous replication.
+func SynchronousStandbyNames(requested int, standbys []string) string {
+	quorum := requested
+	if len(standbys) < quorum {
+		quorum = len(standbys)
+	}
+
+	if quorum <= 0 {
+		return ""
+	}
+
+	names := make([]string, len(standbys))
+	copy(names, standbys)
+	sort.Strings(names)
+
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%q", name)
+	}
+
+	return fmt.Sprintf("ANY %d (%s)", quorum, strings.Join(names, ", "))
+}
+
+// ManageSynchronousReplication keeps synchronous_standby_names in line with the healthy in-region
+// standbys. This is expected to run on the primary.
+func ManageSynchronousReplication(ctx context.Context, n *Node) error {
+	requested := int(n.FlyConfig.IntSetting("synchronousReplicas", 0))
+
+	conn, err := n.NewLocalConnection(ctx, "postgres", n.SUCredentials)
+	if err != nil {
+		return fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	// Leave synchronous_standby_names alone when the mode is disabled, unless we were the ones
+	// that set it.
+	if requested == 0 {
+		managed, err := admin.AlterSystemSettingExists(ctx, conn, synchronousStandbyNamesSetting)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s source: %s", synchronousStandbyNamesSetting, err)
+		}
+
+		if !managed {
+			return nil
+		}
+
+		log.Println("[INFO] Synchronous replication disabled, resetting synchronous_standby_names")
+
+		if err := admin.AlterSystemReset(ctx, conn, synchronousStandbyNamesSetting); err != nil {
+			return fmt.Errorf("failed to reset %s: %s", synchronousStandbyNamesSetting, err)
+		}
+
+		return admin.ReloadPostgresConfig(ctx, conn)
+	}
+
+	standbys, err := healthySynchronousCandidates(ctx, n)
+	if err != nil {
+		return err
+	}
+
+	desired := SynchronousStandbyNames(requested, standbys)
+
+	current, err := admin.GetSetting(ctx, conn, synchronousStandbyNamesSetting)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %s", synchronousStandbyNamesSetting, err)
+	}
+
+	if current.Setting == desired {
+		return nil
+	}
+
+	switch {
+	case desired == "":
+		log.Printf("[WARN] No healthy in-region standbys available, degrading to asynchronous replication")
+	case len(standbys) < requested:
+		log.Printf("[WARN] Only %d of %d requested synchronous standbys are healthy", len(standbys), requested)
+	}
+
+	log.Printf("[INFO] Updating synchronous_standby_names from '%s' to '%s'", current.Setting, desired)
+
+	if err := admin.AlterSystemSetting(ctx, conn, synchronousStandbyNamesSetting, desired); err != nil {
+		return fmt.Errorf("failed to set %s: %s", synchronousStandbyNamesSetting, err)
+	}
+
+	return admin.ReloadPostgresConfig(ctx, conn)
+}
+
+// healthySynchronousCandidates returns the application names of the active in-region standbys
+// that are currently streaming from the primary.
+func healthySynchronousCandidates(ctx context.Context, n *Node) ([]string, error) {
+	conn, err := n.RepMgr.NewLocalConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish repmgr connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	members, err := n.RepMgr.Members(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members: %s", err)
+	}
+
+	stats, err := admin.ListReplicationStats(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query replication stats: %s", err)
+	}
+
+	streaming := map[string]bool{}
+	for _, stat := range stats {
+		if stat.State == "streaming" {
+			streaming[stat.ApplicationName] = true
+		}
+	}
+
+	delayed := n.delayedMemberNames()
+
+	var candidates []string
+	for _, member := range members {
+		if member.Role != StandbyRoleName || !member.Active || member.Region != n.PrimaryRegion {
+			continue
+		}
+
+		// Delayed replicas would hold up commits when remote_apply is in use.
+		if delayed[member.Name] {
+			continue
+		}
+
+		if appName := member.ApplicationName(); streaming[appName] {
+			candidates = append(candidates, appName)
+		}
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,31 @@
+package flypg
+
+import "testing"
+
+func TestSynchronousStandbyNames(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		if val := SynchronousStandbyNames(0, []string{"a", "b"}); val != "" {
+			t.Fatalf("expected empty value, got %s", val)
+		}
+	})
+
+	t.Run("noStandbys", func(t *testing.T) {
+		if val := SynchronousStandbyNames(2, nil); val != "" {
+			t.Fatalf("expected empty value, got %s", val)
+		}
+	})
+
+	t.Run("quorum", func(t *testing.T) {
+		expected := `ANY 1 ("148ed726c12358", "3287e67bc12d18")`
+		if val := SynchronousStandbyNames(1, []string{"3287e67bc12d18", "148ed726c12358"}); val != expected {
+			t.Fatalf("expected %s, got %s", expected, val)
+		}
+	})
+
+	t.Run("degraded", func(t *testing.T) {
+		expected := `ANY 1 ("148ed726c12358")`
+		if val := SynchronousStandbyNames(2, []string{"148ed726c12358"}); val != expected {
+			t.Fatalf("expected %s, got %s", expected, val)
+		}
+	})
+}
@@ -0,0 +1,403 @@
+package flypg
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/admin"
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+	"github.com/fly-apps/postgres-flex/internal/utils"
+)
+
+const clusterCAKey = "tls_ca"
+
+var (
+	tlsDir = "/data/tls"
+
+	caCertValidity        = time.Hour * 24 * 365 * 10
+	serverCertValidity    = time.Hour * 24 * 90
+	serverCertRenewBefore = time.Hour * 24 * 30
+)
+
+// CACertPath returns the path of the cluster CA certificate clients verify members against.
+func CACertPath() string { return filepath.Join(tlsDir, "ca.crt") }
+
+func serverCertPath() string { return filepath.Join(tlsDir, "server.crt") }
+func serverKeyPath() string  { return filepath.Join(tlsDir, "server.key") }
+
+// TLSEnabled reports whether the local server certificate has been provisioned.
+func TLSEnabled() bool {
+	return utils.FileExists(CACertPath()) && utils.FileExists(serverCertPath()) && utils.FileExists(serverKeyPath())
+}
+
+// clusterCA is the certificate authority every member's server certificate is issued by. It is
+// stored within the state store so members are able to verify each other.
+type clusterCA struct {
+	CertPEM string `json:"cert"`
+	KeyPEM  string `json:"key"`
+
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newClusterCA(appName string, now time.Time) (*clusterCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ca key: %s", err)
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("%s cluster ca", appName)},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caCertValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ca certificate: %s", err)
+	}
+
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseClusterCA(string(encodeCert(der)), string(keyPEM))
+}
+
+func parseClusterCA(certPEM, keyPEM string) (*clusterCA, error) {
+	cert, err := decodeCert([]byte(certPEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ca certificate: %s", err)
+	}
+
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode ca key")
+	}
+
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ca key: %s", err)
+	}
+
+	return &clusterCA{CertPEM: certPEM, KeyPEM: keyPEM, cert: cert, key: key}, nil
+}
+
+// loadOrCreateClusterCA returns the cluster CA, generating it when it doesn't exist yet. This
+// happens on the first boot of the primary, or the first member to boot after upgrading.
+func loadOrCreateClusterCA(store state.StateStore, appName string) (*clusterCA, error) {
+	data, err := store.PullUserConfig(clusterCAKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull cluster ca: %s", err)
+	}
+
+	if len(data) == 0 {
+		ca, err := newClusterCA(appName, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		encoded, err := json.Marshal(ca)
+		if err != nil {
+			return nil, err
+		}
+
+		swapped, err := store.CompareAndSwap(clusterCAKey, nil, encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store cluster ca: %s", err)
+		}
+
+		if swapped {
+			log.Println("[INFO] Generated cluster certificate authority")
+			return ca, nil
+		}
+
+		// Another member beat us to it.
+		if data, err = store.PullUserConfig(clusterCAKey); err != nil {
+			return nil, fmt.Errorf("failed to pull cluster ca: %s", err)
+		}
+	}
+
+	var stored clusterCA
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cluster ca: %s", err)
+	}
+
+	return parseClusterCA(stored.CertPEM, stored.KeyPEM)
+}
+
+// issueServerCert issues a server certificate for the specified names and addresses.
+func (ca *clusterCA) issueServerCert(dnsNames []string, ips []net.IP, now time.Time) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate server key: %s", err)
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notAfter := now.Add(serverCertValidity)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create server certificate: %s", err)
+	}
+
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encodeCert(der), keyPEM, nil
+}
+
+// serverCertRenewalReason returns why the server certificate needs to be (re)issued, or an empty
+// string when it remains valid.
+func serverCertRenewalReason(certPEM []byte, ca *clusterCA, dnsNames []string, ips []net.IP, now time.Time) string {
+	if len(certPEM) == 0 {
+		return "missing"
+	}
+
+	cert, err := decodeCert(certPEM)
+	if err != nil {
+		return fmt.Sprintf("unreadable: %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: pool, CurrentTime: now}); err != nil {
+		return fmt.Sprintf("not valid for the cluster ca: %s", err)
+	}
+
+	if remaining := cert.NotAfter.Sub(now); remaining < serverCertRenewBefore {
+		return fmt.Sprintf("expires in %s", remaining.Round(time.Hour))
+	}
+
+	for _, name := range dnsNames {
+		if !slices.Contains(cert.DNSNames, name) {
+			return fmt.Sprintf("missing name %s", name)
+		}
+	}
+
+	for _, ip := range ips {
+		if !slices.ContainsFunc(cert.IPAddresses, ip.Equal) {
+			return fmt.Sprintf("missing address %s", ip)
+		}
+	}
+
+	return ""
+}
+
+// serverCertSANs returns the names and addresses the local server certificate must be valid for.
+func (n *Node) serverCertSANs() ([]string, []net.IP) {
+	dnsNames := []string{
+		n.Hostname(),
+		fmt.Sprintf("%s.internal", n.AppName),
+		fmt.Sprintf("%s.flycast", n.AppName),
+		"localhost",
+	}
+
+	ips := []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback}
+	if ip := net.ParseIP(n.PrivateIP); ip != nil {
+		ips = append([]net.IP{ip}, ips...)
+	}
+
+	return dnsNames, ips
+}
+
+// EnsureCertificates provisions the cluster CA certificate and the local server certificate,
+// re-issuing the server certificate when it is missing, close to expiry or no longer matches
+// the member. True is returned when a new server certificate was written.
+func (n *Node) EnsureCertificates(store state.StateStore) (bool, error) {
+	ca, err := loadOrCreateClusterCA(store, n.AppName)
+	if err != nil {
+		return false, err
+	}
+
+	if err := os.MkdirAll(tlsDir, 0o700); err != nil {
+		return false, fmt.Errorf("failed to create %s: %s", tlsDir, err)
+	}
+
+	if err := writeTLSFile(CACertPath(), []byte(ca.CertPEM), 0o644); err != nil {
+		return false, err
+	}
+
+	dnsNames, ips := n.serverCertSANs()
+	now := time.Now()
+
+	current, err := os.ReadFile(serverCertPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("failed to read server certificate: %s", err)
+	}
+
+	reason := serverCertRenewalReason(current, ca, dnsNames, ips, now)
+	if reason == "" {
+		recordCertExpiry(current)
+		return false, nil
+	}
+
+	log.Printf("[INFO] Issuing server certificate, previous certificate %s", reason)
+
+	certPEM, keyPEM, err := ca.issueServerCert(dnsNames, ips, now)
+	if err != nil {
+		return false, err
+	}
+
+	// The key is written first, so the certificate never refers to a key that doesn't exist.
+	if err := writeTLSFile(serverKeyPath(), keyPEM, 0o600); err != nil {
+		return false, err
+	}
+
+	if err := writeTLSFile(serverCertPath(), certPEM, 0o644); err != nil {
+		return false, err
+	}
+
+	recordCertExpiry(certPEM)
+	certificateRenewals.Inc()
+
+	return true, nil
+}
+
+// RotateCertificates re-issues the local server certificate when required and reloads postgres
+// so new connections pick it up.
+func RotateCertificates(ctx context.Context, n *Node) error {
+	store, err := n.StateStore()
+	if err != nil {
+		return err
+	}
+
+	renewed, err := n.EnsureCertificates(store)
+	if err != nil {
+		return err
+	}
+
+	if !renewed {
+		return nil
+	}
+
+	conn, err := n.NewLocalConnection(ctx, "postgres", n.SUCredentials)
+	if err != nil {
+		return fmt.Errorf("failed to establish connection: %s", err)
+	}
+	defer func() { _ = conn.Close(ctx) }()
+
+	return admin.ReloadPostgresConfig(ctx, conn)
+}
+
+// internalTLS reports whether internal connections should require TLS and verify the server
+// against the cluster CA.
+func (n *Node) internalTLS() bool {
+	if !utils.FileExists(n.FlyConfig.InternalConfigFile()) || !TLSEnabled() {
+		return false
+	}
+
+	return n.FlyConfig.BoolSetting("internalTLS", false)
+}
+
+// requireClientTLS reports whether the default access entries should only accept TLS connections.
+func (n *Node) requireClientTLS() bool {
+	if !utils.FileExists(n.FlyConfig.InternalConfigFile()) || !TLSEnabled() {
+		return false
+	}
+
+	return n.FlyConfig.BoolSetting("requireClientTLS", false)
+}
+
+// sslConninfo returns the libpq parameters used for internal connections.
+func sslConninfo(verify bool) string {
+	if !verify {
+		return ""
+	}
+
+	return fmt.Sprintf(" sslmode=verify-full sslrootcert=%s", CACertPath())
+}
+
+func recordCertExpiry(certPEM []byte) {
+	cert, err := decodeCert(certPEM)
+	if err != nil {
+		return
+	}
+
+	certificateExpiry.Set(float64(cert.NotAfter.Unix()))
+}
+
+func writeTLSFile(path string, data []byte, perm os.FileMode) error {
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %s", path, err)
+	}
+
+	return utils.SetFileOwnership(path, "postgres")
+}
+
+func newSerialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %s", err)
+	}
+
+	return serial, nil
+}
+
+func encodeCert(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func decodeCert(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem data found")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key: %s", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
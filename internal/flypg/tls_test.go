@@ -0,0 +1,156 @@
+package flypg
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fly-apps/postgres-flex/internal/flypg/state"
+)
+
+func TestLoadOrCreateClusterCA(t *testing.T) {
+	store := state.NewMemoryStore()
+
+	ca, err := loadOrCreateClusterCA(store, "my-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ca.cert.IsCA {
+		t.Fatal("expected a ca certificate")
+	}
+
+	t.Run("reuse", func(t *testing.T) {
+		existing, err := loadOrCreateClusterCA(store, "my-app")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if existing.CertPEM != ca.CertPEM {
+			t.Fatal("expected the stored ca to be reused")
+		}
+	})
+}
+
+func TestServerCertRenewalReason(t *testing.T) {
+	now := time.Now()
+
+	ca, err := newClusterCA("my-app", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dnsNames := []string{"abc123.vm.my-app.internal", "localhost"}
+	ips := []net.IP{net.ParseIP("fdaa:0:2e26:a7b:8c31:bf37:488c:2"), net.IPv6loopback}
+
+	certPEM, _, err := ca.issueServerCert(dnsNames, ips, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		if reason := serverCertRenewalReason(certPEM, ca, dnsNames, ips, now); reason != "" {
+			t.Fatalf("expected no renewal, got %q", reason)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		if reason := serverCertRenewalReason(nil, ca, dnsNames, ips, now); reason == "" {
+			t.Fatal("expected a missing certificate to be issued")
+		}
+	})
+
+	t.Run("expiring", func(t *testing.T) {
+		later := now.Add(serverCertValidity - serverCertRenewBefore + time.Hour)
+		if reason := serverCertRenewalReason(certPEM, ca, dnsNames, ips, later); reason == "" {
+			t.Fatal("expected a certificate close to expiry to be renewed")
+		}
+	})
+
+	t.Run("address-changed", func(t *testing.T) {
+		moved := []net.IP{net.ParseIP("fdaa:0:2e26:a7b:8c31:bf37:488c:3"), net.IPv6loopback}
+		if reason := serverCertRenewalReason(certPEM, ca, dnsNames, moved, now); reason == "" {
+			t.Fatal("expected a certificate missing the private ip to be renewed")
+		}
+	})
+
+	t.Run("different-ca", func(t *testing.T) {
+		other, err := newClusterCA("my-app", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if reason := serverCertRenewalReason(certPEM, other, dnsNames, ips, now); reason == "" {
+			t.Fatal("expected a certificate issued by another ca to be renewed")
+		}
+	})
+}
+
+func TestEnsureCertificates(t *testing.T) {
+	stubOwnership(t)
+
+	previous := tlsDir
+	tlsDir = t.TempDir()
+	defer func() { tlsDir = previous }()
+
+	store := state.NewMemoryStore()
+	node := &Node{
+		AppName:   "my-app",
+		MachineID: "abc123",
+		PrivateIP: "fdaa:0:2e26:a7b:8c31:bf37:488c:2",
+	}
+
+	renewed, err := node.EnsureCertificates(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !renewed {
+		t.Fatal("expected a server certificate to be issued")
+	}
+
+	if !TLSEnabled() {
+		t.Fatal("expected tls to be enabled")
+	}
+
+	info, err := os.Stat(serverKeyPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected the server key to be 0600, got %s", info.Mode().Perm())
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		renewed, err := node.EnsureCertificates(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if renewed {
+			t.Fatal("expected the existing server certificate to be kept")
+		}
+	})
+
+	t.Run("hostname", func(t *testing.T) {
+		data, err := os.ReadFile(serverCertPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cert, err := decodeCert(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := cert.VerifyHostname(node.Hostname()); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := cert.VerifyHostname(node.PrivateIP); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
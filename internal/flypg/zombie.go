@@ -55,8 +55,10 @@ func ReadZombieLock() (string, error) {
 	return string(body), nil
 }
 
-func PerformScreening(ctx context.Context, conn *pgx.Conn, n *Node) (string, error) {
-	members, err := n.RepMgr.VotingMembers(ctx, conn)
+func PerformScreening(ctx context.Context, conn *pgx.Conn, n *Node) (primary string, err error) {
+	defer func() { zombieScreenings.Inc(screeningOutcome(err)) }()
+
+	members, err := n.RepMgr.QuorumMembers(ctx, conn)
 	if err != nil {
 		if !errors.Is(err, pgx.ErrNoRows) {
 			return "", fmt.Errorf("failed to query standbys")
@@ -65,39 +67,59 @@ func PerformScreening(ctx context.Context, conn *pgx.Conn, n *Node) (string, err
 
 	sample, err := TakeDNASample(ctx, n, members)
 	if err != nil {
+		recordFencingEvent(newFencingEvent(FencingEventScreening, nil, "", err))
 		return "", fmt.Errorf("failed to evaluate cluster data: %s", err)
 	}
 
 	log.Println(DNASampleString(sample))
 
-	return ZombieDiagnosis(sample)
+	primary, err = ZombieDiagnosis(sample)
+	recordFencingEvent(newFencingEvent(FencingEventScreening, sample, primary, err))
+
+	return primary, err
 }
 
+// DNASample captures the state of the cluster from the perspective of the primary. Totals are
+// expressed in votes, which equal the number of members when every member holds a single vote.
 type DNASample struct {
 	hostname       string
+	selfWeight     int
 	totalMembers   int
 	totalActive    int
 	totalInactive  int
 	totalConflicts int
 	conflictMap    map[string]int
+	observations   []MemberObservation
+}
+
+// MemberObservation records what a single voting member reported while taking a DNA sample.
+type MemberObservation struct {
+	Hostname        string `json:"hostname"`
+	Weight          int    `json:"weight"`
+	Reachable       bool   `json:"reachable"`
+	ReportedPrimary string `json:"reported_primary,omitempty"`
+	Conflict        bool   `json:"conflict"`
+	Error           string `json:"error,omitempty"`
 }
 
 func TakeDNASample(ctx context.Context, node *Node, standbys []Member) (*DNASample, error) {
-	sample := &DNASample{
-		hostname:       node.Hostname(),
-		totalMembers:   len(standbys) + 1,
-		totalActive:    1,
-		totalInactive:  0,
-		totalConflicts: 0,
-		conflictMap:    map[string]int{},
-	}
+	weights := node.VoteWeights()
+	self := Member{Name: node.MachineID, Region: node.RepMgr.Region}
+
+	sample := newDNASample(node.Hostname(), weights.selfWeight(self))
 
 	for _, standby := range standbys {
+		observation := MemberObservation{
+			Hostname: standby.Hostname,
+			Weight:   weights.Weight(standby),
+		}
+
 		// Check for connectivity
 		mConn, err := node.RepMgr.NewRemoteConnection(ctx, standby.Hostname)
 		if err != nil {
 			log.Printf("[WARN] Failed to connect to %s\n", standby.Hostname)
-			sample.totalInactive++
+			observation.Error = fmt.Sprintf("failed to connect: %s", err)
+			sample.addObservation(observation)
 			continue
 		}
 		defer func() { _ = mConn.Close(ctx) }()
@@ -106,7 +128,8 @@ func TakeDNASample(ctx context.Context, node *Node, standbys []Member) (*DNASamp
 		primary, err := node.RepMgr.PrimaryMember(ctx, mConn)
 		if err != nil {
 			log.Printf("[WARN] Failed to resolve primary from standby %s\n", standby.Hostname)
-			sample.totalInactive++
+			observation.Error = fmt.Sprintf("failed to resolve primary: %s", err)
+			sample.addObservation(observation)
 			continue
 		}
 
@@ -114,25 +137,55 @@ func TakeDNASample(ctx context.Context, node *Node, standbys []Member) (*DNASamp
 			return nil, fmt.Errorf("failed to close connection: %s", err)
 		}
 
-		sample.totalActive++
+		observation.Reachable = true
+		observation.ReportedPrimary = primary.Hostname
 
 		// Record conflict when primary name does not match our machine ID
 		if primary.Hostname != node.Hostname() && primary.Hostname != node.PrivateIP {
-			sample.totalConflicts++
-			sample.conflictMap[primary.Hostname]++
+			observation.Conflict = true
 		}
+
+		sample.addObservation(observation)
 	}
 
 	return sample, nil
 }
 
+func newDNASample(hostname string, selfWeight int) *DNASample {
+	return &DNASample{
+		hostname:     hostname,
+		selfWeight:   selfWeight,
+		totalMembers: selfWeight,
+		totalActive:  selfWeight,
+		conflictMap:  map[string]int{},
+	}
+}
+
+// addObservation tallies the observation using the votes held by the observed member.
+func (s *DNASample) addObservation(o MemberObservation) {
+	s.totalMembers += o.Weight
+
+	switch {
+	case !o.Reachable:
+		s.totalInactive += o.Weight
+	case o.Conflict:
+		s.totalActive += o.Weight
+		s.totalConflicts += o.Weight
+		s.conflictMap[o.ReportedPrimary] += o.Weight
+	default:
+		s.totalActive += o.Weight
+	}
+
+	s.observations = append(s.observations, o)
+}
+
 func ZombieDiagnosis(s *DNASample) (string, error) {
-	// We can short-circuit a single node cluster.
-	if s.totalMembers == 1 {
+	// We can short-circuit a cluster where no other member holds a vote.
+	if s.totalMembers == s.selfWeight {
 		return s.hostname, nil
 	}
 
-	quorum := s.totalMembers/2 + 1
+	quorum := s.quorum()
 
 	if s.totalActive < quorum {
 		return "", ErrZombieDiagnosisUndecided
@@ -168,11 +221,25 @@ func ZombieDiagnosis(s *DNASample) (string, error) {
 	return "", ErrZombieDiagnosisUndecided
 }
 
+func (s *DNASample) quorum() int {
+	return Quorum(s.totalMembers)
+}
+
 func Quarantine(ctx context.Context, n *Node, primary string) error {
+	quarantines.Inc()
+	recordFencingEvent(newFencingEvent(FencingEventQuarantine, nil, primary, nil))
+
 	if err := writeZombieLock(primary); err != nil {
 		return fmt.Errorf("failed to set zombie lock: %s", err)
 	}
 
+	message := "primary has been quarantined as the real primary could not be confirmed"
+	if primary != "" {
+		message = fmt.Sprintf("primary has been quarantined, %s was identified as the real primary", primary)
+	}
+	// Delivery is left to the monitor, so a slow webhook can't hold up the read-only broadcast.
+	n.SpoolNotification(NotificationQuarantine, message, map[string]any{"resolved_primary": primary})
+
 	if err := BroadcastReadonlyChange(ctx, n, true); err != nil {
 		return fmt.Errorf("failed to set read-only: %s", err)
 	}
@@ -246,12 +313,15 @@ func handleZombieLock(ctx context.Context, n *Node) error {
 		log.Println("[WARN] Zombie lock file does not contain a hostname.")
 		log.Println("[WARN] This likely means that we were unable to determine who the real primary is.")
 		log.Println("[WARN] If a new primary has been established, consider adding a new replica with `fly machines clone <primary-machine-id>` and then remove this member.")
+		log.Println("[WARN] Alternatively, use `flexctl fencing resolve --rejoin <primary>` to rejoin the real primary or `flexctl fencing resolve --promote` to declare this member the primary.")
 	}
 
 	return nil
 }
 
 func EvaluateClusterState(ctx context.Context, conn *pgx.Conn, node *Node) error {
+	defer func() { zombieLockGauge.Set(boolToFloat(ZombieLockExists())) }()
+
 	primary, err := PerformScreening(ctx, conn, node)
 	if errors.Is(err, ErrZombieDiagnosisUndecided) || errors.Is(err, ErrZombieDiscovered) {
 		if err := Quarantine(ctx, node, primary); err != nil {
@@ -308,3 +308,112 @@ func TestZombieDiagnosis(t *testing.T) {
 		}
 	})
 }
+
+func TestWeightedZombieDiagnosis(t *testing.T) {
+	t.Run("SingleMemberWithHeavierWeight", func(t *testing.T) {
+		sample := newDNASample("host-1", 2)
+
+		primary, err := ZombieDiagnosis(sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if primary != sample.hostname {
+			t.Fatalf("expected %s, got %q", sample.hostname, primary)
+		}
+	})
+
+	t.Run("OnlyNonVotingMembers", func(t *testing.T) {
+		sample := newDNASample("host-1", 1)
+		sample.addObservation(MemberObservation{Hostname: "remote-1", Weight: 0})
+		sample.addObservation(MemberObservation{Hostname: "remote-2", Weight: 0, Reachable: true, ReportedPrimary: "remote-1", Conflict: true})
+
+		primary, err := ZombieDiagnosis(sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if primary != sample.hostname {
+			t.Fatalf("expected %s, got %q", sample.hostname, primary)
+		}
+	})
+
+	t.Run("RemoteRegionPartitionWithHeavierPrimaryRegion", func(t *testing.T) {
+		sample := newDNASample("host-1", 2)
+		sample.addObservation(MemberObservation{Hostname: "host-2", Weight: 2, Reachable: true, ReportedPrimary: "host-1"})
+		sample.addObservation(MemberObservation{Hostname: "remote-1", Weight: 1})
+		sample.addObservation(MemberObservation{Hostname: "remote-2", Weight: 1})
+
+		primary, err := ZombieDiagnosis(sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if primary != sample.hostname {
+			t.Fatalf("expected %s, got %q", sample.hostname, primary)
+		}
+	})
+
+	t.Run("RemoteRegionPartitionWithEqualWeights", func(t *testing.T) {
+		sample := newDNASample("host-1", 1)
+		sample.addObservation(MemberObservation{Hostname: "host-2", Weight: 1, Reachable: true, ReportedPrimary: "host-1"})
+		sample.addObservation(MemberObservation{Hostname: "remote-1", Weight: 1})
+		sample.addObservation(MemberObservation{Hostname: "remote-2", Weight: 1})
+
+		primary, err := ZombieDiagnosis(sample)
+		if !errors.Is(err, ErrZombieDiagnosisUndecided) {
+			t.Fatal(err)
+		}
+
+		if primary != "" {
+			t.Fatalf("expected %s, got %q", "", primary)
+		}
+	})
+
+	t.Run("WeightedMajorityReportingDiffPrimary", func(t *testing.T) {
+		sample := newDNASample("host-1", 2)
+		sample.addObservation(MemberObservation{Hostname: "host-2", Weight: 2, Reachable: true, ReportedPrimary: "host-99", Conflict: true})
+		sample.addObservation(MemberObservation{Hostname: "remote-1", Weight: 1, Reachable: true, ReportedPrimary: "host-99", Conflict: true})
+		sample.addObservation(MemberObservation{Hostname: "remote-2", Weight: 1, Reachable: true, ReportedPrimary: "host-99", Conflict: true})
+
+		primary, err := ZombieDiagnosis(sample)
+		if !errors.Is(err, ErrZombieDiscovered) {
+			t.Fatal(err)
+		}
+
+		if primary != "host-99" {
+			t.Fatalf("expected %s, got %q", "host-99", primary)
+		}
+	})
+
+	t.Run("HeavyMemberInactive", func(t *testing.T) {
+		sample := newDNASample("host-1", 1)
+		sample.addObservation(MemberObservation{Hostname: "host-2", Weight: 3})
+		sample.addObservation(MemberObservation{Hostname: "host-3", Weight: 1, Reachable: true, ReportedPrimary: "host-1"})
+
+		primary, err := ZombieDiagnosis(sample)
+		if !errors.Is(err, ErrZombieDiagnosisUndecided) {
+			t.Fatal(err)
+		}
+
+		if primary != "" {
+			t.Fatalf("expected %s, got %q", "", primary)
+		}
+	})
+
+	t.Run("LightMembersInactive", func(t *testing.T) {
+		sample := newDNASample("host-1", 1)
+		sample.addObservation(MemberObservation{Hostname: "host-2", Weight: 3, Reachable: true, ReportedPrimary: "host-1"})
+		sample.addObservation(MemberObservation{Hostname: "host-3", Weight: 1})
+		sample.addObservation(MemberObservation{Hostname: "host-4", Weight: 1})
+
+		primary, err := ZombieDiagnosis(sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if primary != sample.hostname {
+			t.Fatalf("expected %s, got %q", sample.hostname, primary)
+		}
+	})
+}
@@ -0,0 +1,377 @@
+// Package metrics records control plane metrics and renders them using the Prometheus
+// text exposition format.
+//
+// Metrics are emitted from several short and long-lived processes (monitor, start, flexctl, etc),
+// so samples are persisted to a shared file rather than held in memory. Updates are serialized
+// across processes with an exclusive file lock.
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	defaultStorePath = "/data/flypg_metrics.json"
+
+	counterType   = "counter"
+	gaugeType     = "gauge"
+	histogramType = "histogram"
+)
+
+var defaultStore = NewStore(defaultStorePath)
+
+type family struct {
+	Help    string             `json:"help"`
+	Type    string             `json:"type"`
+	Buckets []float64          `json:"buckets,omitempty"`
+	Series  map[string]*series `json:"series"`
+}
+
+type series struct {
+	Value  float64  `json:"value"`
+	Counts []uint64 `json:"counts,omitempty"`
+	Count  uint64   `json:"count,omitempty"`
+}
+
+// Store persists metric samples to the specified file.
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// WriteText renders every recorded metric using the default store.
+func WriteText(w io.Writer) error {
+	return defaultStore.WriteText(w)
+}
+
+// WriteText renders every recorded metric using the Prometheus text exposition format.
+func (s *Store) WriteText(w io.Writer) error {
+	var families map[string]*family
+	err := s.withLock(syscall.LOCK_SH, func() error {
+		var err error
+		families, err = s.read()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := families[name]
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(f.Help), name, f.Type); err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(f.Series))
+		for key := range f.Series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if err := writeSeries(w, name, f, key, f.Series[key]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeSeries(w io.Writer, name string, f *family, key string, s *series) error {
+	if f.Type != histogramType {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", name, braces(key), formatFloat(s.Value))
+		return err
+	}
+
+	var cumulative uint64
+	for i, upper := range f.Buckets {
+		if i < len(s.Counts) {
+			cumulative += s.Counts[i]
+		}
+		le := fmt.Sprintf("le=%q", formatFloat(upper))
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", name, braces(joinLabels(key, le)), cumulative); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", name, braces(joinLabels(key, `le="+Inf"`)), s.Count); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%s_sum%s %s\n", name, braces(key), formatFloat(s.Value)); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "%s_count%s %d\n", name, braces(key), s.Count)
+	return err
+}
+
+// update applies the change to the specified series. Updates are silently dropped when the
+// store's directory doesn't exist, e.g. when running outside of a fly machine.
+func (s *Store) update(name string, def family, key string, fn func(*family, *series)) error {
+	if _, err := os.Stat(filepath.Dir(s.path)); os.IsNotExist(err) {
+		return nil
+	}
+
+	return s.withLock(syscall.LOCK_EX, func() error {
+		families, err := s.read()
+		if err != nil {
+			return err
+		}
+
+		f, ok := families[name]
+		if !ok || f.Type != def.Type || !slices.Equal(f.Buckets, def.Buckets) {
+			f = &family{Help: def.Help, Type: def.Type, Buckets: def.Buckets, Series: map[string]*series{}}
+			families[name] = f
+		}
+		f.Help = def.Help
+
+		sr, ok := f.Series[key]
+		if !ok {
+			sr = &series{}
+			f.Series[key] = sr
+		}
+
+		fn(f, sr)
+
+		return s.write(families)
+	})
+}
+
+func (s *Store) withLock(how int, fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fn()
+		}
+		return fmt.Errorf("failed to open lock file: %s", err)
+	}
+	defer func() { _ = lock.Close() }()
+
+	if err := syscall.Flock(int(lock.Fd()), how); err != nil {
+		return fmt.Errorf("failed to acquire lock: %s", err)
+	}
+	defer func() { _ = syscall.Flock(int(lock.Fd()), syscall.LOCK_UN) }()
+
+	return fn()
+}
+
+func (s *Store) read() (map[string]*family, error) {
+	families := map[string]*family{}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return families, nil
+		}
+		return nil, fmt.Errorf("failed to read metrics: %s", err)
+	}
+
+	if err := json.Unmarshal(data, &families); err != nil {
+		// A corrupt file shouldn't prevent new samples from being recorded.
+		log.Printf("[WARN] Discarding unreadable metrics file: %s", err)
+		return map[string]*family{}, nil
+	}
+
+	return families, nil
+}
+
+func (s *Store) write(families map[string]*family) error {
+	data, err := json.Marshal(families)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write metrics: %s", err)
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+// Counter is a cumulative metric that only increases.
+type Counter struct {
+	store  *Store
+	name   string
+	help   string
+	labels []string
+}
+
+// NewCounter defines a counter within the default store.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return defaultStore.NewCounter(name, help, labels...)
+}
+
+func (s *Store) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{store: s, name: name, help: help, labels: labels}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	key, err := labelKey(c.labels, labelValues)
+	if err == nil {
+		err = c.store.update(c.name, family{Help: c.help, Type: counterType}, key, func(_ *family, s *series) {
+			s.Value += v
+		})
+	}
+
+	if err != nil {
+		log.Printf("[WARN] Failed to record metric %s: %s", c.name, err)
+	}
+}
+
+// Gauge is a metric that represents a single value that can go up and down.
+type Gauge struct {
+	store  *Store
+	name   string
+	help   string
+	labels []string
+}
+
+// NewGauge defines a gauge within the default store.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return defaultStore.NewGauge(name, help, labels...)
+}
+
+func (s *Store) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{store: s, name: name, help: help, labels: labels}
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	key, err := labelKey(g.labels, labelValues)
+	if err == nil {
+		err = g.store.update(g.name, family{Help: g.help, Type: gaugeType}, key, func(_ *family, s *series) {
+			s.Value = v
+		})
+	}
+
+	if err != nil {
+		log.Printf("[WARN] Failed to record metric %s: %s", g.name, err)
+	}
+}
+
+// Histogram samples observations into the configured buckets.
+type Histogram struct {
+	store   *Store
+	name    string
+	help    string
+	buckets []float64
+	labels  []string
+}
+
+// NewHistogram defines a histogram within the default store.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return defaultStore.NewHistogram(name, help, buckets, labels...)
+}
+
+func (s *Store) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	return &Histogram{store: s, name: name, help: help, buckets: sorted, labels: labels}
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key, err := labelKey(h.labels, labelValues)
+	if err == nil {
+		def := family{Help: h.help, Type: histogramType, Buckets: h.buckets}
+		err = h.store.update(h.name, def, key, func(f *family, s *series) {
+			if len(s.Counts) != len(f.Buckets) {
+				s.Counts = make([]uint64, len(f.Buckets))
+			}
+
+			for i, upper := range f.Buckets {
+				if v <= upper {
+					s.Counts[i]++
+					break
+				}
+			}
+
+			s.Value += v
+			s.Count++
+		})
+	}
+
+	if err != nil {
+		log.Printf("[WARN] Failed to record metric %s: %s", h.name, err)
+	}
+}
+
+func labelKey(names, values []string) (string, error) {
+	if len(names) != len(values) {
+		return "", fmt.Errorf("expected %d label values, got %d", len(names), len(values))
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", name, escapeLabel(values[i]))
+	}
+
+	return strings.Join(pairs, ","), nil
+}
+
+func joinLabels(key, extra string) string {
+	if key == "" {
+		return extra
+	}
+
+	return key + "," + extra
+}
+
+func braces(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	return "{" + key + "}"
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func escapeHelp(v string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStoreWriteText(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "metrics.json"))
+
+	counter := store.NewCounter("flypg_test_total", "Test counter.", "outcome")
+	counter.Inc("healthy")
+	counter.Inc("healthy")
+	counter.Inc("zombie")
+
+	gauge := store.NewGauge("flypg_test_gauge", "Test gauge.")
+	gauge.Set(3)
+	gauge.Set(1)
+
+	histogram := store.NewHistogram("flypg_test_seconds", "Test histogram.", []float64{10, 1})
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+	histogram.Observe(50)
+
+	var buf bytes.Buffer
+	if err := store.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	expected := []string{
+		"# TYPE flypg_test_total counter",
+		`flypg_test_total{outcome="healthy"} 2`,
+		`flypg_test_total{outcome="zombie"} 1`,
+		"# TYPE flypg_test_gauge gauge",
+		"flypg_test_gauge 1",
+		"# TYPE flypg_test_seconds histogram",
+		`flypg_test_seconds_bucket{le="1"} 1`,
+		`flypg_test_seconds_bucket{le="10"} 2`,
+		`flypg_test_seconds_bucket{le="+Inf"} 3`,
+		"flypg_test_seconds_sum 55.5",
+		"flypg_test_seconds_count 3",
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Fatalf("expected output to contain %q, got:\n%s", line, out)
+		}
+	}
+}
+
+func TestStoreLabelMismatch(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "metrics.json"))
+
+	counter := store.NewCounter("flypg_test_total", "Test counter.", "outcome")
+	counter.Inc()
+
+	var buf bytes.Buffer
+	if err := store.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() != 0 {
+		t.Fatalf("expected mismatched labels to be dropped, got:\n%s", buf.String())
+	}
+}
+
+func TestStoreMissingDirectory(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "missing", "metrics.json"))
+	store.NewCounter("flypg_test_total", "Test counter.").Inc()
+
+	var buf bytes.Buffer
+	if err := store.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output, got:\n%s", buf.String())
+	}
+}
@@ -75,11 +75,9 @@ func RunCommand(cmdStr, usr string) ([]byte, error) {
 	return cmd.Output()
 }
 
-func SetFileOwnership(pathToFile, owner string) error {
-	if os.Getenv("UNIT_TESTING") != "" {
-		return nil
-	}
-
+// SetFileOwnership hands the file over to the specified system user. Tests run outside of the image,
+// where the system users don't exist, so they swap it out.
+var SetFileOwnership = func(pathToFile, owner string) error {
 	uid, gid, err := SystemUserIDs(owner)
 	if err != nil {
 		return fmt.Errorf("failed to resolve system user ids: %s", err)